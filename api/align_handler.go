@@ -0,0 +1,197 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+)
+
+type AlignHandler struct {
+	loader *dataset.Loader
+}
+
+func NewAlignHandler(loader *dataset.Loader) *AlignHandler {
+	return &AlignHandler{loader: loader}
+}
+
+type AlignResponse struct {
+	Columns       []AlignedColumn `json:"columns"`
+	Rows          []AlignedRow    `json:"rows"`
+	RowCount      int             `json:"row_count"`
+	Truncated     bool            `json:"truncated,omitempty"`
+	Interval      string          `json:"interval,omitempty"`
+	Interpolation string          `json:"interpolation"`
+	Tolerance     string          `json:"tolerance,omitempty"`
+}
+
+type AlignedColumn struct {
+	DataSourceId int64  `json:"data_source_id"`
+	Name         string `json:"name"`
+	Unit         string `json:"unit,omitempty"`
+}
+
+type AlignedRow struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Values holds one value per column, null where the datasource has no value
+	Values []*float64 `json:"values" swaggertype:"array,number"`
+}
+
+// alignQuery holds the parsed parameters of AlignDataSources
+type alignQuery struct {
+	ids            []int64
+	opts           timeseries.AlignOptions
+	unit           string
+	dropIncomplete bool
+	limit          int
+}
+
+// AlignDataSources godoc
+// @Summary Align datasources
+// @Description Join several datasources into one table on common timestamps: the union of their timestamps, or a regular grid when interval is set. Each datasource is sampled with the chosen interpolation; linear draws a line between neighbouring points, previous carries the last value forward, nearest takes the closest point and none only uses points at the timestamp. tolerance is how far from a timestamp the points used for it may be (with none, how far a match may be). Values without a close enough point are null.
+// @Tags datasources
+// @Produce json
+// @Param ids query string true "Comma-separated datasource IDs, e.g. 1,2,3"
+// @Param interval query string false "Grid step, e.g. 30s, 5m, 1h or 1d; omit to use the union of timestamps"
+// @Param interpolation query string false "linear (default), previous, nearest or none"
+// @Param tolerance query string false "Maximum distance to the points used for a value, e.g. 10m"
+// @Param range query string false "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1"
+// @Param start_time query string false "Start time (RFC3339 or expression such as now-3d); overrides the start of range"
+// @Param end_time query string false "End time (RFC3339 or expression such as now); overrides the end of range"
+// @Param tz query string false "IANA timezone used to resolve expressions (default UTC)"
+// @Param unit query string false "Convert every datasource to this unit, e.g. degF; see GET /api/units"
+// @Param drop_incomplete query bool false "Leave out rows where any datasource has no value"
+// @Param limit query int false "Maximum number of rows"
+// @Success 200 {object} AlignResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/datasources/align [get]
+func (h *AlignHandler) AlignDataSources(w http.ResponseWriter, r *http.Request) {
+	q, err := parseAlignQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	table, err := h.loader.Align(r.Context(), q.ids, q.opts, q.unit)
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	response := AlignResponse{
+		Columns:       make([]AlignedColumn, len(table.DataSources)),
+		Rows:          make([]AlignedRow, 0, len(table.Times)),
+		Interpolation: string(q.opts.Interpolation),
+	}
+	if q.opts.Interval > 0 {
+		response.Interval = q.opts.Interval.String()
+	}
+	if q.opts.Tolerance > 0 {
+		response.Tolerance = q.opts.Tolerance.String()
+	}
+	for i, ds := range table.DataSources {
+		response.Columns[i] = AlignedColumn{DataSourceId: ds.DataSourceId, Name: ds.Name, Unit: table.Units[i]}
+	}
+
+	for i, t := range table.Times {
+		row := AlignedRow{Timestamp: t, Values: make([]*float64, len(table.Columns))}
+		complete := true
+		for j, column := range table.Columns {
+			if v := column[i]; !math.IsNaN(v) {
+				row.Values[j] = &v
+			} else {
+				complete = false
+			}
+		}
+		if q.dropIncomplete && !complete {
+			continue
+		}
+		if q.limit > 0 && len(response.Rows) == q.limit {
+			response.Truncated = true
+			break
+		}
+		response.Rows = append(response.Rows, row)
+	}
+	response.RowCount = len(response.Rows)
+
+	respondJSON(w, response, http.StatusOK)
+}
+
+func parseAlignQuery(query url.Values, now time.Time) (alignQuery, error) {
+	var q alignQuery
+
+	seen := map[int64]bool{}
+	for _, field := range strings.Split(query.Get("ids"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || id < 1 {
+			return q, fmt.Errorf("Invalid datasource ID %q", field)
+		}
+		if !seen[id] {
+			seen[id] = true
+			q.ids = append(q.ids, id)
+		}
+	}
+	switch {
+	case len(q.ids) == 0:
+		return q, errors.New("ids is required, e.g. ids=1,2")
+	case len(q.ids) > dataset.MaxAlignedDataSources:
+		return q, fmt.Errorf("At most %d datasources can be aligned", dataset.MaxAlignedDataSources)
+	}
+
+	var err error
+	if q.opts.Interpolation, err = timeseries.ParseInterpolation(query.Get("interpolation")); err != nil {
+		return q, fmt.Errorf("Invalid interpolation: %v", err)
+	}
+	if param := query.Get("interval"); param != "" {
+		if q.opts.Interval, err = timerange.ParseDuration(param); err != nil {
+			return q, fmt.Errorf("Invalid interval: %v", err)
+		}
+		if q.opts.Interval <= 0 {
+			return q, errors.New("Invalid interval, want a positive duration such as 5m")
+		}
+	}
+	if param := query.Get("tolerance"); param != "" {
+		if q.opts.Tolerance, err = timerange.ParseDuration(param); err != nil {
+			return q, fmt.Errorf("Invalid tolerance: %v", err)
+		}
+		if q.opts.Tolerance < 0 {
+			return q, errors.New("Invalid tolerance, want a duration such as 10m")
+		}
+	}
+
+	loc, err := timerange.LoadLocation(query.Get("tz"))
+	if err != nil {
+		return q, fmt.Errorf("Invalid tz: %v", err)
+	}
+	q.opts.Start, q.opts.End, err = timerange.Resolve(query.Get("range"), query.Get("start_time"), query.Get("end_time"), now, loc)
+	if err != nil {
+		return q, fmt.Errorf("Invalid time range: %v", err)
+	}
+
+	q.unit = query.Get("unit")
+	if param := query.Get("drop_incomplete"); param != "" {
+		if q.dropIncomplete, err = strconv.ParseBool(param); err != nil {
+			return q, errors.New("Invalid drop_incomplete, want true or false")
+		}
+	}
+	if param := query.Get("limit"); param != "" {
+		if q.limit, err = strconv.Atoi(param); err != nil || q.limit < 1 {
+			return q, errors.New("Invalid limit")
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,448 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+)
+
+type AnalysisHandler struct {
+	loader *dataset.Loader
+}
+
+func NewAnalysisHandler(loader *dataset.Loader) *AnalysisHandler {
+	return &AnalysisHandler{loader: loader}
+}
+
+type AnalysisSeries struct {
+	DataSourceId int64  `json:"data_source_id"`
+	Name         string `json:"name"`
+	Unit         string `json:"unit,omitempty"`
+}
+
+type CorrelationResponse struct {
+	X         AnalysisSeries           `json:"x"`
+	Y         AnalysisSeries           `json:"y"`
+	StartTime time.Time                `json:"start_time"`
+	EndTime   time.Time                `json:"end_time"`
+	Interval  string                   `json:"interval"`
+	Points    int                      `json:"points"`
+	Summary   string                   `json:"summary"`
+	Pearson   analysis.CorrelationInfo `json:"pearson"`
+	Spearman  analysis.CorrelationInfo `json:"spearman"`
+	// BestLag is the lag with the strongest correlation; a positive lag means x leads y
+	BestLag          *analysis.LagCorrelationInfo  `json:"best_lag,omitempty"`
+	CrossCorrelation []analysis.LagCorrelationInfo `json:"cross_correlation"`
+	Rolling          []DataPoint                   `json:"rolling,omitempty"`
+	Granger          []analysis.GrangerInfo        `json:"granger,omitempty"`
+	GrangerError     string                        `json:"granger_error,omitempty"`
+}
+
+type SpectrumResponse struct {
+	Series    AnalysisSeries `json:"series"`
+	StartTime time.Time      `json:"start_time"`
+	EndTime   time.Time      `json:"end_time"`
+	Interval  string         `json:"interval"`
+	Points    int            `json:"points"`
+	Method    string         `json:"method"`
+	// Segment is the time covered by each transform; periods over half of it are not reported
+	Segment  string              `json:"segment"`
+	Segments int                 `json:"segments"`
+	Peaks    []SpectralPeakInfo  `json:"peaks"`
+	Spectrum []SpectrumPointInfo `json:"spectrum,omitempty"`
+}
+
+type SpectralPeakInfo struct {
+	Period        string  `json:"period"`
+	PeriodSeconds float64 `json:"period_seconds"`
+	FrequencyHz   float64 `json:"frequency_hz"`
+	Power         float64 `json:"power"`
+	// Share is the peak's share of the total power of the series without its mean
+	Share float64 `json:"share"`
+}
+
+type SpectrumPointInfo struct {
+	FrequencyHz float64 `json:"frequency_hz"`
+	Power       float64 `json:"power"`
+}
+
+type AutocorrelationResponse struct {
+	Series    AnalysisSeries           `json:"series"`
+	StartTime time.Time                `json:"start_time"`
+	EndTime   time.Time                `json:"end_time"`
+	Interval  string                   `json:"interval"`
+	Points    int                      `json:"points"`
+	Lags      []AutocorrelationLagInfo `json:"lags"`
+	// PACFBand is the half-width of the 95% band of every lag's partial autocorrelation
+	PACFBand float64               `json:"pacf_band"`
+	LjungBox analysis.LjungBoxInfo `json:"ljung_box"`
+	// Seasonality lists the periods the series repeats with, strongest first
+	Seasonality      []SeasonalPeriodInfo `json:"seasonality"`
+	SeasonalityError string               `json:"seasonality_error,omitempty"`
+}
+
+type AutocorrelationLagInfo struct {
+	LagSteps   int      `json:"lag_steps"`
+	Lag        string   `json:"lag"`
+	LagSeconds float64  `json:"lag_seconds"`
+	ACF        *float64 `json:"acf"`
+	// ACFBand is the half-width of the 95% band of the autocorrelation at this lag
+	ACFBand float64  `json:"acf_band"`
+	PACF    *float64 `json:"pacf"`
+	// Significant is set when the autocorrelation is outside its band
+	Significant bool `json:"significant"`
+}
+
+type SeasonalPeriodInfo struct {
+	Period        string  `json:"period"`
+	PeriodSeconds float64 `json:"period_seconds"`
+	LagSteps      int     `json:"lag_steps,omitempty"`
+	// Strength is the autocorrelation of values one period apart
+	Strength float64 `json:"strength"`
+}
+
+const (
+	defaultSpectralPeaks = 5
+	maxSpectralPeaks     = 50
+)
+
+// maxSeasonalPeriods limits the seasonal periods the autocorrelation reports
+const maxSeasonalPeriods = 5
+
+// Correlate godoc
+// @Summary Correlate two datasources
+// @Description Relate two datasources over a time window: Pearson and Spearman correlation, cross-correlation over a range of lags with the best lag, an optional rolling correlation, and Granger causality tests in both directions. Both are resampled onto a common grid with linear interpolation, limited to the time they overlap. A positive lag means x leads y.
+// @Tags analysis
+// @Produce json
+// @Param x query int true "First datasource ID"
+// @Param y query int true "Second datasource ID"
+// @Param range query string false "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1"
+// @Param start_time query string false "Start time (RFC3339 or expression such as now-3d); overrides the start of range"
+// @Param end_time query string false "End time (RFC3339 or expression such as now); overrides the end of range"
+// @Param tz query string false "IANA timezone used to resolve expressions (default UTC)"
+// @Param interval query string false "Grid step, e.g. 1m or 1h (default the coarser sampling interval of the two)"
+// @Param max_lag query string false "Largest lag of the cross-correlation, e.g. 2h (default a quarter of the window, at most 200 steps)"
+// @Param rolling_window query string false "Window of a rolling correlation, e.g. 1d"
+// @Param granger_lags query int false "Lags of the Granger tests, 1 to 24 (default the best lag)"
+// @Success 200 {object} CorrelationResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/analysis/correlation [get]
+func (h *AnalysisHandler) Correlate(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var ids [2]int64
+	for i, name := range []string{"x", "y"} {
+		id, err := strconv.ParseInt(query.Get(name), 10, 64)
+		if err != nil || id < 1 {
+			respondError(w, fmt.Sprintf("Invalid %s, want a datasource ID", name), http.StatusBadRequest)
+			return
+		}
+		ids[i] = id
+	}
+	if ids[0] == ids[1] {
+		respondError(w, "x and y must be different datasources", http.StatusBadRequest)
+		return
+	}
+
+	startTime, endTime, interval, err := parseAnalysisWindow(query, time.Now())
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxLag, err := parseOptionalDuration(query, "max_lag")
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rollingWindow, err := parseOptionalDuration(query, "rolling_window")
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var grangerLags int
+	if param := query.Get("granger_lags"); param != "" {
+		if grangerLags, err = strconv.Atoi(param); err != nil || grangerLags < 1 || grangerLags > analysis.MaxGrangerLags {
+			respondError(w, fmt.Sprintf("Invalid granger_lags, want 1 to %d", analysis.MaxGrangerLags), http.StatusBadRequest)
+			return
+		}
+	}
+
+	table, err := h.loader.Resample(r.Context(), ids[:], startTime, endTime, interval, "")
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	report, err := analysis.Correlate(table.Columns[0], table.Columns[1], analysis.CorrelationOptions{
+		MaxLag:        analysis.Steps(maxLag, table.Interval),
+		RollingWindow: analysis.Steps(rollingWindow, table.Interval),
+		GrangerLags:   grangerLags,
+	})
+	if err != nil {
+		respondError(w, fmt.Sprintf("Cannot correlate: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	x, y := table.DataSources[0], table.DataSources[1]
+	response := CorrelationResponse{
+		X:                AnalysisSeries{DataSourceId: x.DataSourceId, Name: x.Name, Unit: table.Units[0]},
+		Y:                AnalysisSeries{DataSourceId: y.DataSourceId, Name: y.Name, Unit: table.Units[1]},
+		StartTime:        table.Times[0],
+		EndTime:          table.Times[len(table.Times)-1],
+		Interval:         analysis.FormatDuration(table.Interval),
+		Points:           report.N,
+		Summary:          analysis.DescribeLead(x.Name, y.Name, report.BestLag, table.Interval),
+		Pearson:          analysis.NewCorrelationInfo(report.Pearson),
+		Spearman:         analysis.NewCorrelationInfo(report.Spearman),
+		CrossCorrelation: make([]analysis.LagCorrelationInfo, 0, len(report.CrossCorrelation)),
+	}
+	for _, l := range report.CrossCorrelation {
+		response.CrossCorrelation = append(response.CrossCorrelation, analysis.NewLagCorrelationInfo(l, table.Interval))
+	}
+	if report.BestLag != nil {
+		best := analysis.NewLagCorrelationInfo(*report.BestLag, table.Interval)
+		response.BestLag = &best
+	}
+	for i, v := range report.Rolling {
+		if !math.IsNaN(v) {
+			response.Rolling = append(response.Rolling, DataPoint{Timestamp: table.Times[i], Value: v})
+		}
+	}
+	if report.GrangerError != nil {
+		response.GrangerError = report.GrangerError.Error()
+	} else {
+		response.Granger = []analysis.GrangerInfo{
+			analysis.NewGrangerInfo(x.DataSourceId, y.DataSourceId, report.GrangerXY, table.Interval),
+			analysis.NewGrangerInfo(y.DataSourceId, x.DataSourceId, report.GrangerYX, table.Interval),
+		}
+	}
+
+	respondJSON(w, response, http.StatusOK)
+}
+
+// Spectrum godoc
+// @Summary Find cycles in a datasource
+// @Description Estimate the power spectrum of a datasource resampled onto a regular grid, and report its dominant periods in human units such as 24h, 7d or 12 months. The periodogram transforms the whole window for the finest resolution; Welch averages overlapping segments for a less noisy estimate. Power is in squared value units per hertz.
+// @Tags analysis
+// @Produce json
+// @Param id query int true "Datasource ID"
+// @Param range query string false "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1"
+// @Param start_time query string false "Start time (RFC3339 or expression such as now-3d); overrides the start of range"
+// @Param end_time query string false "End time (RFC3339 or expression such as now); overrides the end of range"
+// @Param tz query string false "IANA timezone used to resolve expressions (default UTC)"
+// @Param interval query string false "Grid step, e.g. 1s or 1h (default the median sampling interval)"
+// @Param method query string false "periodogram (default) or welch"
+// @Param segment query string false "Length of the Welch segments, e.g. 7d (default a quarter of the window)"
+// @Param peaks query int false "Number of dominant periods to report (default 5, at most 50)"
+// @Param include_spectrum query bool false "Also return the spectrum"
+// @Success 200 {object} SpectrumResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/analysis/spectrum [get]
+func (h *AnalysisHandler) Spectrum(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	id, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil || id < 1 {
+		respondError(w, "Invalid id, want a datasource ID", http.StatusBadRequest)
+		return
+	}
+	startTime, endTime, interval, err := parseAnalysisWindow(query, time.Now())
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	segment, err := parseOptionalDuration(query, "segment")
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	peaks := defaultSpectralPeaks
+	if param := query.Get("peaks"); param != "" {
+		if peaks, err = strconv.Atoi(param); err != nil || peaks < 1 || peaks > maxSpectralPeaks {
+			respondError(w, fmt.Sprintf("Invalid peaks, want 1 to %d", maxSpectralPeaks), http.StatusBadRequest)
+			return
+		}
+	}
+	includeSpectrum := false
+	if param := query.Get("include_spectrum"); param != "" {
+		if includeSpectrum, err = strconv.ParseBool(param); err != nil {
+			respondError(w, "Invalid include_spectrum, want true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	table, err := h.loader.Resample(r.Context(), []int64{id}, startTime, endTime, interval, "")
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	spectrum, err := analysis.ComputeSpectrum(table.Columns[0], table.Interval, query.Get("method"), segment)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Cannot compute spectrum: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ds := table.DataSources[0]
+	response := SpectrumResponse{
+		Series:    AnalysisSeries{DataSourceId: ds.DataSourceId, Name: ds.Name, Unit: table.Units[0]},
+		StartTime: table.Times[0],
+		EndTime:   table.Times[len(table.Times)-1],
+		Interval:  analysis.FormatDuration(table.Interval),
+		Points:    len(table.Times),
+		Method:    spectrum.Method,
+		Segment:   analysis.FormatDuration(spectrum.Span),
+		Segments:  spectrum.Segments,
+		Peaks:     make([]SpectralPeakInfo, 0, peaks),
+	}
+	for _, p := range spectrum.Peaks(peaks) {
+		response.Peaks = append(response.Peaks, SpectralPeakInfo{
+			Period:        analysis.FormatPeriod(p.Period),
+			PeriodSeconds: p.Period.Seconds(),
+			FrequencyHz:   p.Frequency,
+			Power:         p.Power,
+			Share:         p.Share,
+		})
+	}
+	if includeSpectrum {
+		response.Spectrum = make([]SpectrumPointInfo, len(spectrum.Frequencies))
+		for k, f := range spectrum.Frequencies {
+			response.Spectrum[k] = SpectrumPointInfo{FrequencyHz: f, Power: spectrum.Power[k]}
+		}
+	}
+
+	respondJSON(w, response, http.StatusOK)
+}
+
+// Autocorrelation godoc
+// @Summary Autocorrelation and seasonality of a datasource
+// @Description Describe how a datasource resampled onto a regular grid relates to its own past: the autocorrelation (ACF) and partial autocorrelation (PACF) at each lag with their 95% confidence bands, a Ljung-Box test of whether the series is white noise, and the seasonal periods it repeats with. Seasonal periods are peaks of the periodogram confirmed by the autocorrelation at their lag, snapped to an hour, half a day, a day, a week or a year when close to one.
+// @Tags analysis
+// @Produce json
+// @Param id query int true "Datasource ID"
+// @Param range query string false "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1"
+// @Param start_time query string false "Start time (RFC3339 or expression such as now-3d); overrides the start of range"
+// @Param end_time query string false "End time (RFC3339 or expression such as now); overrides the end of range"
+// @Param tz query string false "IANA timezone used to resolve expressions (default UTC)"
+// @Param interval query string false "Grid step, e.g. 1s or 1h (default the median sampling interval)"
+// @Param max_lag query string false "Largest lag of the ACF and PACF, e.g. 2d (default a quarter of the window, at most 200 steps)"
+// @Param ljung_box_lags query int false "Lags of the Ljung-Box test, 1 to 1000 (default 10)"
+// @Success 200 {object} AutocorrelationResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/analysis/autocorrelation [get]
+func (h *AnalysisHandler) Autocorrelation(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	id, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil || id < 1 {
+		respondError(w, "Invalid id, want a datasource ID", http.StatusBadRequest)
+		return
+	}
+	startTime, endTime, interval, err := parseAnalysisWindow(query, time.Now())
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxLag, err := parseOptionalDuration(query, "max_lag")
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var ljungBoxLags int
+	if param := query.Get("ljung_box_lags"); param != "" {
+		if ljungBoxLags, err = strconv.Atoi(param); err != nil || ljungBoxLags < 1 || ljungBoxLags > analysis.MaxAutocorrelationLag {
+			respondError(w, fmt.Sprintf("Invalid ljung_box_lags, want 1 to %d", analysis.MaxAutocorrelationLag), http.StatusBadRequest)
+			return
+		}
+	}
+
+	table, err := h.loader.Resample(r.Context(), []int64{id}, startTime, endTime, interval, "")
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	report, err := analysis.Autocorrelate(table.Columns[0], table.Interval, analysis.AutocorrelationOptions{
+		MaxLag:       analysis.Steps(maxLag, table.Interval),
+		LjungBoxLags: ljungBoxLags,
+		Seasonality:  maxSeasonalPeriods,
+	})
+	if err != nil {
+		respondError(w, fmt.Sprintf("Cannot compute autocorrelation: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ds := table.DataSources[0]
+	response := AutocorrelationResponse{
+		Series:      AnalysisSeries{DataSourceId: ds.DataSourceId, Name: ds.Name, Unit: table.Units[0]},
+		StartTime:   table.Times[0],
+		EndTime:     table.Times[len(table.Times)-1],
+		Interval:    analysis.FormatDuration(table.Interval),
+		Points:      report.N,
+		Lags:        make([]AutocorrelationLagInfo, 0, len(report.ACF)),
+		PACFBand:    report.PACFBand,
+		LjungBox:    analysis.NewLjungBoxInfo(report.LjungBox, table.Interval),
+		Seasonality: make([]SeasonalPeriodInfo, 0, len(report.Seasonality)),
+	}
+	for k := 1; k < len(report.ACF); k++ {
+		lag := time.Duration(k) * table.Interval
+		response.Lags = append(response.Lags, AutocorrelationLagInfo{
+			LagSteps:    k,
+			Lag:         analysis.FormatDuration(lag),
+			LagSeconds:  lag.Seconds(),
+			ACF:         analysis.FiniteOrNil(report.ACF[k]),
+			ACFBand:     report.ACFBands[k],
+			PACF:        analysis.FiniteOrNil(report.PACF[k]),
+			Significant: math.Abs(report.ACF[k]) > report.ACFBands[k],
+		})
+	}
+	for _, p := range report.Seasonality {
+		response.Seasonality = append(response.Seasonality, SeasonalPeriodInfo{
+			Period:        analysis.FormatPeriod(p.Period),
+			PeriodSeconds: p.Period.Seconds(),
+			LagSteps:      p.Lag,
+			Strength:      p.Strength,
+		})
+	}
+	if report.SeasonalityError != nil {
+		response.SeasonalityError = report.SeasonalityError.Error()
+	}
+
+	respondJSON(w, response, http.StatusOK)
+}
+
+// parseAnalysisWindow parses the time range and grid interval shared by the analysis
+// endpoints. A zero interval lets the analysis infer it.
+func parseAnalysisWindow(query url.Values, now time.Time) (*time.Time, *time.Time, time.Duration, error) {
+	loc, err := timerange.LoadLocation(query.Get("tz"))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("Invalid tz: %v", err)
+	}
+	startTime, endTime, err := timerange.Resolve(query.Get("range"), query.Get("start_time"), query.Get("end_time"), now, loc)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("Invalid time range: %v", err)
+	}
+	interval, err := parseOptionalDuration(query, "interval")
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return startTime, endTime, interval, nil
+}
+
+// parseOptionalDuration parses the positive duration parameter name, zero if it is unset
+func parseOptionalDuration(query url.Values, name string) (time.Duration, error) {
+	return parseDurationValue(name, query.Get(name))
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/auth"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+)
+
+type AuthHandler struct {
+	auth *auth.Authenticator
+}
+
+func NewAuthHandler(authenticator *auth.Authenticator) *AuthHandler {
+	return &AuthHandler{
+		auth: authenticator,
+	}
+}
+
+type PrincipalResponse struct {
+	UserId   int64    `json:"user_id"`
+	Username string   `json:"username"`
+	KeyId    int64    `json:"key_id"`
+	Scopes   []string `json:"scopes"`
+}
+
+type UserRequest struct {
+	Username string `json:"username"`
+}
+
+type UserResponse struct {
+	UserId      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	IsActive    bool      `json:"is_active"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
+	WhenCreated time.Time `json:"when_created"`
+}
+
+type UserListResponse struct {
+	Users []UserResponse `json:"users"`
+}
+
+type ApiKeyRequest struct {
+	// UserId defaults to the caller; issuing keys for other users requires the admin scope
+	UserId    int64      `json:"user_id,omitempty"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type ApiKeyResponse struct {
+	ApiKeyId     int64      `json:"api_key_id"`
+	UserId       int64      `json:"user_id"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	CreatedBy    *int64     `json:"created_by,omitempty"`
+	WhenCreated  time.Time  `json:"when_created"`
+	WhenExpires  *time.Time `json:"when_expires,omitempty"`
+	WhenLastUsed *time.Time `json:"when_last_used,omitempty"`
+	WhenRevoked  *time.Time `json:"when_revoked,omitempty"`
+}
+
+// ApiKeyCreatedResponse includes the key itself, which is only ever returned once
+type ApiKeyCreatedResponse struct {
+	ApiKeyResponse
+	Key string `json:"key"`
+}
+
+type ApiKeyListResponse struct {
+	Keys []ApiKeyResponse `json:"keys"`
+}
+
+// Authenticate rejects requests without a valid API key and stores the caller's
+// principal in the request context. Keys are sent as "Authorization: Bearer <key>"
+// or in the X-API-Key header.
+func (h *AuthHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="iot-data-sandbox"`)
+			respondError(w, "Missing API key", http.StatusUnauthorized)
+			return
+		}
+
+		principal, err := h.auth.Authenticate(token)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidKey) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="iot-data-sandbox", error="invalid_token"`)
+				respondError(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			respondError(w, fmt.Sprintf("Failed to authenticate: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		ctx := logging.Annotate(r.Context(), "user_id", principal.UserId, "username", principal.Username)
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(ctx, principal)))
+	})
+}
+
+// RequireScope rejects requests whose API key was not granted scope. It must run
+// after Authenticate.
+func (h *AuthHandler) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.FromContext(r.Context())
+			if principal == nil || !principal.HasScope(scope) {
+				respondError(w, fmt.Sprintf("API key lacks the %s scope", scope), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WhoAmI godoc
+// @Summary Get the caller
+// @Description Get the user and scopes of the API key used for the request
+// @Tags auth
+// @Produce json
+// @Success 200 {object} PrincipalResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/auth/me [get]
+func (h *AuthHandler) WhoAmI(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+	respondJSON(w, PrincipalResponse{
+		UserId:   principal.UserId,
+		Username: principal.Username,
+		KeyId:    principal.KeyId,
+		Scopes:   principal.Scopes,
+	}, http.StatusOK)
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description Get all users. Requires the admin scope.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} UserListResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users [get]
+func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.auth.Users()
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load users: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := UserListResponse{Users: make([]UserResponse, 0, len(users))}
+	for _, user := range users {
+		response.Users = append(response.Users, newUserResponse(user))
+	}
+	respondJSON(w, response, http.StatusOK)
+}
+
+// CreateUser godoc
+// @Summary Create a user
+// @Description Add a user. Issue keys for the user with POST /api/keys. Requires the admin scope.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body UserRequest true "Username"
+// @Success 201 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/users [post]
+func (h *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Username) == "" {
+		respondError(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.auth.CreateUser(req.Username, auth.ActingUser(r.Context()))
+	if err != nil {
+		if errors.Is(err, auth.ErrUserExists) {
+			respondError(w, "User already exists", http.StatusConflict)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to create user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, newUserResponse(user), http.StatusCreated)
+}
+
+// ListApiKeys godoc
+// @Summary List API keys
+// @Description Get the caller's API keys, newest first. Admins may list another user's keys with user_id, or every key by omitting it. Keys themselves are never returned.
+// @Tags auth
+// @Produce json
+// @Param user_id query int false "User ID (admin only)"
+// @Success 200 {object} ApiKeyListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/keys [get]
+func (h *AuthHandler) ListApiKeys(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+
+	userId := principal.UserId
+	if principal.HasScope(auth.ScopeAdmin) {
+		userId = 0
+	}
+	if param := r.URL.Query().Get("user_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			respondError(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		if id != principal.UserId && !principal.HasScope(auth.ScopeAdmin) {
+			respondError(w, "Listing another user's keys requires the admin scope", http.StatusForbidden)
+			return
+		}
+		userId = id
+	}
+
+	keys, err := h.auth.Keys(userId)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load API keys: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := ApiKeyListResponse{Keys: make([]ApiKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.Keys = append(response.Keys, newApiKeyResponse(key))
+	}
+	respondJSON(w, response, http.StatusOK)
+}
+
+// CreateApiKey godoc
+// @Summary Create an API key
+// @Description Issue an API key with a set of scopes (read, write, admin). The key is only returned in this response. A key can only grant scopes its creator holds; keys for other users require the admin scope.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ApiKeyRequest true "Key name, scopes and optional expiry"
+// @Success 201 {object} ApiKeyCreatedResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/keys [post]
+func (h *AuthHandler) CreateApiKey(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+
+	var req ApiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserId == 0 {
+		req.UserId = principal.UserId
+	}
+	if req.UserId != principal.UserId && !principal.HasScope(auth.ScopeAdmin) {
+		respondError(w, "Creating keys for another user requires the admin scope", http.StatusForbidden)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		respondError(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	scopes, err := auth.ParseScopes(req.Scopes)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, scope := range scopes {
+		if !principal.HasScope(scope) {
+			respondError(w, fmt.Sprintf("Cannot grant the %s scope without holding it", scope), http.StatusForbidden)
+			return
+		}
+	}
+
+	key, token, err := h.auth.CreateKey(req.UserId, req.Name, scopes, req.ExpiresAt, auth.ActingUser(r.Context()))
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			respondError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to create API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, ApiKeyCreatedResponse{ApiKeyResponse: newApiKeyResponse(key), Key: token}, http.StatusCreated)
+}
+
+// RevokeApiKey godoc
+// @Summary Revoke an API key
+// @Description Revoke one of the caller's API keys. Admins may revoke any key.
+// @Tags auth
+// @Param id path int true "API key ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/keys/{id} [delete]
+func (h *AuthHandler) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	// Other users' keys are reported as missing rather than forbidden so key IDs
+	// cannot be probed
+	key, err := h.auth.Key(id)
+	if err != nil || key.WhenRevoked != nil || (key.UserId != principal.UserId && !principal.HasScope(auth.ScopeAdmin)) {
+		if err != nil && !errors.Is(err, auth.ErrKeyNotFound) {
+			respondError(w, fmt.Sprintf("Failed to load API key: %v", err), http.StatusInternalServerError)
+			return
+		}
+		respondError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.auth.RevokeKey(id); err != nil {
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			respondError(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to revoke API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestToken extracts the API key from the Authorization or X-API-Key header
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+func newUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		UserId:      user.UserId,
+		Username:    user.Username,
+		IsActive:    user.IsActive,
+		CreatedBy:   user.CreatedBy,
+		WhenCreated: user.WhenCreated,
+	}
+}
+
+func newApiKeyResponse(key *models.ApiKey) ApiKeyResponse {
+	return ApiKeyResponse{
+		ApiKeyId:     key.ApiKeyId,
+		UserId:       key.UserId,
+		Name:         key.Name,
+		Prefix:       key.Prefix,
+		Scopes:       key.Scopes,
+		CreatedBy:    key.CreatedBy,
+		WhenCreated:  key.WhenCreated,
+		WhenExpires:  key.WhenExpires,
+		WhenLastUsed: key.WhenLastUsed,
+		WhenRevoked:  key.WhenRevoked,
+	}
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/agent"
+)
+
+type ChatHandler struct {
+	sessions *agent.Sessions
+}
+
+func NewChatHandler(sessions *agent.Sessions) *ChatHandler {
+	return &ChatHandler{
+		sessions: sessions,
+	}
+}
+
+type ChatRequest struct {
+	Message string `json:"message"`
+	// SessionId continues an existing session; omit it to start a new one
+	SessionId int64 `json:"session_id,omitempty"`
+}
+
+type ChatResponse struct {
+	SessionId int64                  `json:"session_id"`
+	Answer    string                 `json:"answer"`
+	ToolCalls []agent.ToolCallRecord `json:"tool_calls"`
+	Artifacts []agent.Artifact       `json:"artifacts"`
+	Usage     agent.Usage            `json:"usage"`
+}
+
+// Chat godoc
+// @Summary Ask the agent
+// @Description Send a natural-language prompt to the agent. The agent calls the enabled tools as needed and returns its answer together with the tool calls it made and the artifacts those calls produced. Pass session_id to continue an earlier session with its prior context; otherwise a new session is created.
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Param request body ChatRequest true "Prompt"
+// @Success 200 {object} ChatResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/chat [post]
+func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		respondError(w, "message is required", http.StatusBadRequest)
+		return
+	}
+
+	h.chat(w, r, req.SessionId, req.Message)
+}
+
+// ResumeSession godoc
+// @Summary Continue a chat session
+// @Description Send a prompt to an existing session. The agent sees the session's earlier prompts, responses and tool results as context.
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Param id path int true "Session ID"
+// @Param request body ChatRequest true "Prompt (session_id is taken from the path)"
+// @Success 200 {object} ChatResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sessions/{id}/chat [post]
+func (h *ChatHandler) ResumeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		respondError(w, "message is required", http.StatusBadRequest)
+		return
+	}
+
+	h.chat(w, r, id, req.Message)
+}
+
+func (h *ChatHandler) chat(w http.ResponseWriter, r *http.Request, sessionId int64, message string) {
+	session, result, err := h.sessions.Chat(r.Context(), sessionId, message)
+	if err != nil {
+		if errors.Is(err, agent.ErrSessionNotFound) {
+			respondError(w, "Chat session not found", http.StatusNotFound)
+			return
+		}
+		respondError(w, fmt.Sprintf("Agent failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, ChatResponse{
+		SessionId: session.SessionId,
+		Answer:    result.Answer,
+		ToolCalls: result.ToolCalls,
+		Artifacts: result.Artifacts,
+		Usage:     result.Usage,
+	}, http.StatusOK)
+}
+
+// ChatStream godoc
+// @Summary Ask the agent and stream its progress
+// @Description Same as POST /api/chat, but the response is a text/event-stream of typed events: session (session_id), token (a chunk of the model's reply), tool_start (id, name, arguments), tool_finish (the tool call record with its result or error), artifact (an intermediate result), error and finally done (the full chat response). GET is supported for EventSource clients, with the prompt in the message and session_id query parameters. Closing the connection cancels the agent.
+// @Tags chat
+// @Accept json
+// @Produce text/event-stream
+// @Param request body ChatRequest false "Prompt (POST)"
+// @Param message query string false "Prompt (GET)"
+// @Param session_id query int false "Session to continue (GET)"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/chat/stream [post]
+// @Router /api/chat/stream [get]
+func (h *ChatHandler) ChatStream(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if r.Method == http.MethodGet {
+		req.Message = r.URL.Query().Get("message")
+		if idStr := r.URL.Query().Get("session_id"); idStr != "" {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				respondError(w, "Invalid session ID", http.StatusBadRequest)
+				return
+			}
+			req.SessionId = id
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		respondError(w, "message is required", http.StatusBadRequest)
+		return
+	}
+
+	// The stream is opened on the first event, so errors raised before the agent
+	// starts (such as an unknown session) still get a regular error response
+	var (
+		stream    *sseWriter
+		streamErr error
+		stopPing  = make(chan struct{})
+	)
+	defer close(stopPing)
+
+	open := func() *sseWriter {
+		if stream == nil && streamErr == nil {
+			stream, streamErr = newSSEWriter(w)
+			if streamErr == nil {
+				go keepAlive(stream, stopPing)
+			}
+		}
+		return stream
+	}
+
+	emit := func(event agent.Event) {
+		if s := open(); s != nil {
+			s.Send(string(event.Type), event.Data)
+		}
+	}
+
+	session, result, err := h.sessions.ChatStream(r.Context(), req.SessionId, req.Message, emit)
+	if stream == nil && streamErr == nil {
+		if errors.Is(err, agent.ErrSessionNotFound) {
+			respondError(w, "Chat session not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respondError(w, fmt.Sprintf("Agent failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if open() == nil {
+		respondError(w, streamErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err != nil {
+		stream.Send(string(agent.EventError), agent.ErrorEvent{Error: fmt.Sprintf("Agent failed: %v", err)})
+		return
+	}
+	stream.Send(string(agent.EventDone), ChatResponse{
+		SessionId: session.SessionId,
+		Answer:    result.Answer,
+		ToolCalls: result.ToolCalls,
+		Artifacts: result.Artifacts,
+		Usage:     result.Usage,
+	})
+}
+
+func keepAlive(stream *sseWriter, stop <-chan struct{}) {
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := stream.KeepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
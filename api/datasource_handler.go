@@ -2,29 +2,67 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/jobs"
+	"github.com/nathanaday/iot-data-sandbox/internal/metrics"
 	"github.com/nathanaday/iot-data-sandbox/internal/models"
 	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
 	"github.com/nathanaday/iot-data-sandbox/internal/storage"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
 	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
 )
 
+// maxUploadMemory is how much of a multipart upload is buffered in memory; the rest
+// is spooled to temporary files
+const maxUploadMemory = 32 << 20
+
+// Page sizes of ListDataSources
+const (
+	defaultDataSourcePageSize = 100
+	maxDataSourcePageSize     = 1000
+)
+
+// Limits on editable datasource metadata
+const (
+	maxDataSourceNameLength = 255
+	maxDescriptionLength    = 4096
+	maxMetadataFieldLength  = 255
+	maxDataSourceTags       = 50
+	maxTagKeyLength         = 64
+	maxTagValueLength       = 255
+)
+
+// tagKeyPattern keeps tag keys usable in the tag=key:value list filter
+var tagKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-/]+$`)
+
 type DataSourceHandler struct {
-	store     *persistence.Store
-	fileStore *storage.FileStore
+	store         *persistence.Store
+	fileStore     *storage.FileStore
+	loader        *dataset.Loader
+	jobs          *jobs.Manager
+	maxUploadSize int64
 }
 
-func NewDataSourceHandler(store *persistence.Store, fileStore *storage.FileStore) *DataSourceHandler {
+func NewDataSourceHandler(store *persistence.Store, fileStore *storage.FileStore, loader *dataset.Loader, jobManager *jobs.Manager, maxUploadSize int64) *DataSourceHandler {
 	return &DataSourceHandler{
-		store:     store,
-		fileStore: fileStore,
+		store:         store,
+		fileStore:     fileStore,
+		loader:        loader,
+		jobs:          jobManager,
+		maxUploadSize: maxUploadSize,
 	}
 }
 
@@ -36,28 +74,63 @@ type UploadResponse struct {
 	EndTime      *time.Time `json:"end_time,omitempty"`
 	TimeLabel    string     `json:"time_label"`
 	ValueLabel   string     `json:"value_label"`
-	WhenCreated  time.Time  `json:"when_created"`
+	Unit         string     `json:"unit,omitempty"`
+	// Seasonality is detected while ingesting, strongest first
+	Seasonality []SeasonalPeriodInfo `json:"seasonality,omitempty"`
+	CreatedBy   *int64               `json:"created_by,omitempty"`
+	WhenCreated time.Time            `json:"when_created"`
 }
 
 type DataSourceListResponse struct {
 	DataSources []DataSourceMetadata `json:"data_sources"`
+	Total       int                  `json:"total"`
+	Limit       int                  `json:"limit"`
+	Offset      int                  `json:"offset"`
 }
 
 type DataSourceMetadata struct {
-	DataSourceId int64      `json:"data_source_id"`
-	Name         string     `json:"name"`
-	Type         string     `json:"type"`
-	RowCount     int        `json:"row_count"`
-	StartTime    *time.Time `json:"start_time,omitempty"`
-	EndTime      *time.Time `json:"end_time,omitempty"`
-	TimeLabel    string     `json:"time_label"`
-	ValueLabel   string     `json:"value_label"`
-	WhenCreated  time.Time  `json:"when_created"`
+	DataSourceId int64             `json:"data_source_id"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Expression   string            `json:"expression,omitempty"`
+	RowCount     int               `json:"row_count"`
+	StartTime    *time.Time        `json:"start_time,omitempty"`
+	EndTime      *time.Time        `json:"end_time,omitempty"`
+	TimeLabel    string            `json:"time_label"`
+	ValueLabel   string            `json:"value_label"`
+	Description  string            `json:"description,omitempty"`
+	Unit         string            `json:"unit,omitempty"`
+	SensorType   string            `json:"sensor_type,omitempty"`
+	Location     string            `json:"location,omitempty"`
+	Tags         map[string]string `json:"tags"`
+	// Seasonality is detected when the datasource is created, strongest first
+	Seasonality []SeasonalPeriodInfo `json:"seasonality,omitempty"`
+	CreatedBy   *int64               `json:"created_by,omitempty"`
+	WhenCreated time.Time            `json:"when_created"`
+}
+
+type CreateVirtualDataSourceRequest struct {
+	Name        string `json:"name"`
+	Expression  string `json:"expression"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// UpdateDataSourceRequest changes the fields that are present. Tags are merged
+// into the existing ones; a null value removes a tag.
+type UpdateDataSourceRequest struct {
+	Name        *string            `json:"name,omitempty"`
+	Description *string            `json:"description,omitempty"`
+	Unit        *string            `json:"unit,omitempty"`
+	SensorType  *string            `json:"sensor_type,omitempty"`
+	Location    *string            `json:"location,omitempty"`
+	Tags        map[string]*string `json:"tags,omitempty"`
 }
 
 type DataQueryResponse struct {
 	Data      []DataPoint `json:"data"`
 	RowCount  int         `json:"row_count"`
+	Unit      string      `json:"unit,omitempty"`
 	StartTime time.Time   `json:"start_time"`
 	EndTime   time.Time   `json:"end_time"`
 }
@@ -79,12 +152,23 @@ type ErrorResponse struct {
 // @Produce json
 // @Param file formData file true "CSV file to upload"
 // @Param name formData string false "Name for the datasource (defaults to filename)"
+// @Param unit formData string false "Unit of the values, from GET /api/units (e.g., degC, kW, psi)"
+// @Param async query bool false "Ingest in a background job and return 202 with the job"
 // @Success 201 {object} UploadResponse
+// @Success 202 {object} JobResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/datasources [post]
 func (h *DataSourceHandler) UploadCSV(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(storage.MaxFileSize); err != nil {
+	// Allow some room for the multipart headers and the name field on top of the file
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize+1<<20)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(w, fmt.Sprintf("Upload exceeds the %d byte limit", h.maxUploadSize), http.StatusRequestEntityTooLarge)
+			return
+		}
 		respondError(w, "Failed to parse multipart form", http.StatusBadRequest)
 		return
 	}
@@ -106,43 +190,46 @@ func (h *DataSourceHandler) UploadCSV(w http.ResponseWriter, r *http.Request) {
 		name = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
 	}
 
-	savedFilename, err := h.fileStore.SaveFile(header.Filename, file, storage.MaxFileSize)
-	if err != nil {
-		respondError(w, fmt.Sprintf("Failed to save file: %v", err), http.StatusInternalServerError)
-		return
+	unit := r.FormValue("unit")
+	if unit != "" {
+		if unit, err = units.Canonical(unit); err != nil {
+			respondError(w, fmt.Sprintf("Invalid unit: %v", err), http.StatusBadRequest)
+			return
+		}
 	}
 
-	filePath := h.fileStore.GetFilePath(savedFilename)
-
-	tsData, err := timeseries.LoadAndValidateCSV(filePath)
+	savedFilename, err := h.fileStore.SaveFile(header.Filename, file, h.maxUploadSize)
 	if err != nil {
-		h.fileStore.DeleteFile(savedFilename)
-		respondError(w, fmt.Sprintf("Invalid CSV: %v", err), http.StatusBadRequest)
+		if errors.Is(err, storage.ErrFileTooLarge) {
+			respondError(w, fmt.Sprintf("Upload exceeds the %d byte limit", h.maxUploadSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to save file: %v", err), http.StatusInternalServerError)
 		return
 	}
+	metrics.UploadBytes.Add(float64(header.Size))
 
-	dataSource := &models.DataSource{
-		Name:           name,
-		DataSourceType: 0,
-		DataSourcePath: savedFilename,
-		RowCount:       tsData.RowCount,
-		TimeLabel:      tsData.TimeLabel,
-		ValueLabel:     tsData.ValueLabel,
-		WhenCreated:    time.Now(),
-	}
-
-	if tsData.RowCount > 0 {
-		dataSource.StartTime = &tsData.StartTime
-		dataSource.EndTime = &tsData.EndTime
+	if isAsync(r) {
+		job, err := h.jobs.Submit(r.Context(), jobs.KindIngestCSV, jobs.IngestCSVParams{Name: name, Unit: unit, File: savedFilename})
+		if err != nil {
+			h.fileStore.DeleteFile(savedFilename)
+			respondError(w, fmt.Sprintf("Failed to queue ingestion: %v", err), http.StatusInternalServerError)
+			return
+		}
+		respondAccepted(w, job)
+		return
 	}
 
-	schema := dataSource.ToSchema()
-	if err := h.store.SaveDataSource(schema); err != nil {
-		h.fileStore.DeleteFile(savedFilename)
-		respondError(w, fmt.Sprintf("Failed to save datasource: %v", err), http.StatusInternalServerError)
+	dataSource, err := h.loader.Ingest(r.Context(), name, unit, savedFilename)
+	if err != nil {
+		var validationErr *dataset.ValidationError
+		if errors.As(err, &validationErr) {
+			respondError(w, fmt.Sprintf("Invalid CSV: %v", validationErr.Err), http.StatusBadRequest)
+			return
+		}
+		respondError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	dataSource.DataSourceId = schema.DataSourceId
 
 	response := UploadResponse{
 		DataSourceId: dataSource.DataSourceId,
@@ -152,22 +239,94 @@ func (h *DataSourceHandler) UploadCSV(w http.ResponseWriter, r *http.Request) {
 		EndTime:      dataSource.EndTime,
 		TimeLabel:    dataSource.TimeLabel,
 		ValueLabel:   dataSource.ValueLabel,
+		Unit:         dataSource.Unit,
+		Seasonality:  newSeasonalPeriodInfos(dataSource.Seasonality),
+		CreatedBy:    dataSource.CreatedBy,
 		WhenCreated:  dataSource.WhenCreated,
 	}
 
 	respondJSON(w, response, http.StatusCreated)
 }
 
+// CreateVirtualDataSource godoc
+// @Summary Create a virtual datasource
+// @Description Save an expression over other datasources, such as ds(3) * 1.8 + 32 or ds(4) - ds(5), as a datasource that is recomputed whenever it is read. Inputs are aligned on the union of their timestamps with linear interpolation. The unit defaults to the unit of the expression's result.
+// @Tags datasources
+// @Accept json
+// @Produce json
+// @Param request body CreateVirtualDataSourceRequest true "Virtual datasource"
+// @Success 201 {object} DataSourceMetadata
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/datasources/virtual [post]
+func (h *DataSourceHandler) CreateVirtualDataSource(w http.ResponseWriter, r *http.Request) {
+	var req CreateVirtualDataSourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	switch {
+	case req.Name == "":
+		respondError(w, "name is required", http.StatusBadRequest)
+		return
+	case len(req.Name) > maxDataSourceNameLength:
+		respondError(w, fmt.Sprintf("name is longer than %d characters", maxDataSourceNameLength), http.StatusBadRequest)
+		return
+	case strings.TrimSpace(req.Expression) == "":
+		respondError(w, "expression is required", http.StatusBadRequest)
+		return
+	case len(req.Description) > maxDescriptionLength:
+		respondError(w, fmt.Sprintf("description is longer than %d characters", maxDescriptionLength), http.StatusBadRequest)
+		return
+	}
+
+	ds, err := h.loader.CreateVirtual(r.Context(), req.Name, req.Expression, req.Unit)
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	if req.Description != "" {
+		ds.Description = req.Description
+		schema := ds.ToSchema()
+		if err := h.store.SaveDataSource(schema); err != nil {
+			respondError(w, fmt.Sprintf("Failed to save datasource: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	respondJSON(w, newDataSourceMetadata(ds), http.StatusCreated)
+}
+
 // ListDataSources godoc
-// @Summary List all datasources
-// @Description Get a list of all registered datasources with their metadata
+// @Summary List datasources
+// @Description List registered datasources with their metadata. Datasources can be filtered by tags, name and time coverage, and are returned in pages. Time bounds accept the same expressions as the data query.
 // @Tags datasources
 // @Produce json
+// @Param tag query []string false "Tag the datasource must have, as key:value or just key for any value; repeat to require several" collectionFormat(multi)
+// @Param q query string false "Case-insensitive substring of the name"
+// @Param range query string false "Only datasources whose data overlaps this time range expression (e.g., last 7 days, 2024-Q1)"
+// @Param start_time query string false "Only datasources with data at or after this time"
+// @Param end_time query string false "Only datasources with data at or before this time"
+// @Param tz query string false "IANA timezone used to resolve expressions (default UTC)"
+// @Param sort query string false "Sort by name, when_created, start_time, end_time or row_count; prefix with - for descending (default -when_created)"
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param offset query int false "Number of datasources to skip"
 // @Success 200 {object} DataSourceListResponse
+// @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/datasources [get]
 func (h *DataSourceHandler) ListDataSources(w http.ResponseWriter, r *http.Request) {
-	schemas, err := h.store.LoadAllDataSources()
+	filter, err := parseDataSourceFilter(r.URL.Query(), time.Now())
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	schemas, total, err := h.store.ListDataSources(filter)
 	if err != nil {
 		respondError(w, fmt.Sprintf("Failed to load datasources: %v", err), http.StatusInternalServerError)
 		return
@@ -177,20 +336,15 @@ func (h *DataSourceHandler) ListDataSources(w http.ResponseWriter, r *http.Reque
 	for _, schema := range schemas {
 		ds := &models.DataSource{}
 		ds.FromSchema(schema)
-		metadata = append(metadata, DataSourceMetadata{
-			DataSourceId: ds.DataSourceId,
-			Name:         ds.Name,
-			Type:         models.DataSourceTypes[ds.DataSourceType],
-			RowCount:     ds.RowCount,
-			StartTime:    ds.StartTime,
-			EndTime:      ds.EndTime,
-			TimeLabel:    ds.TimeLabel,
-			ValueLabel:   ds.ValueLabel,
-			WhenCreated:  ds.WhenCreated,
-		})
+		metadata = append(metadata, newDataSourceMetadata(ds))
 	}
 
-	respondJSON(w, DataSourceListResponse{DataSources: metadata}, http.StatusOK)
+	respondJSON(w, DataSourceListResponse{
+		DataSources: metadata,
+		Total:       total,
+		Limit:       filter.Limit,
+		Offset:      filter.Offset,
+	}, http.StatusOK)
 }
 
 // GetDataSource godoc
@@ -219,41 +373,35 @@ func (h *DataSourceHandler) GetDataSource(w http.ResponseWriter, r *http.Request
 	ds := &models.DataSource{}
 	ds.FromSchema(schema)
 
-	metadata := DataSourceMetadata{
-		DataSourceId: ds.DataSourceId,
-		Name:         ds.Name,
-		Type:         models.DataSourceTypes[ds.DataSourceType],
-		RowCount:     ds.RowCount,
-		StartTime:    ds.StartTime,
-		EndTime:      ds.EndTime,
-		TimeLabel:    ds.TimeLabel,
-		ValueLabel:   ds.ValueLabel,
-		WhenCreated:  ds.WhenCreated,
-	}
-
-	respondJSON(w, metadata, http.StatusOK)
+	respondJSON(w, newDataSourceMetadata(ds), http.StatusOK)
 }
 
-// QueryData godoc
-// @Summary Query time series data
-// @Description Query time series data from a datasource with optional time range filtering
+// UpdateDataSource godoc
+// @Summary Update datasource metadata
+// @Description Change the name, description, unit, sensor type, location or tags of a datasource. The unit must be one of GET /api/units. Only the fields present in the body change. Tags are merged into the existing ones, and a null tag value removes the tag. Tag keys may contain letters, digits and _ . - /.
 // @Tags datasources
+// @Accept json
 // @Produce json
 // @Param id path int true "Datasource ID"
-// @Param start_time query string false "Start time in RFC3339 format (e.g., 2024-01-01T00:00:00Z)"
-// @Param end_time query string false "End time in RFC3339 format (e.g., 2024-01-01T23:59:59Z)"
-// @Success 200 {object} DataQueryResponse
+// @Param request body UpdateDataSourceRequest true "Fields to change"
+// @Success 200 {object} DataSourceMetadata
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
-// @Router /api/datasources/{id}/data [get]
-func (h *DataSourceHandler) QueryData(w http.ResponseWriter, r *http.Request) {
+// @Router /api/datasources/{id} [patch]
+func (h *DataSourceHandler) UpdateDataSource(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		respondError(w, "Invalid datasource ID", http.StatusBadRequest)
 		return
 	}
 
+	var req UpdateDataSourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
 	schema, err := h.store.LoadDataSource(id)
 	if err != nil {
 		respondError(w, "Datasource not found", http.StatusNotFound)
@@ -262,41 +410,75 @@ func (h *DataSourceHandler) QueryData(w http.ResponseWriter, r *http.Request) {
 
 	ds := &models.DataSource{}
 	ds.FromSchema(schema)
+	if err := applyDataSourceUpdate(ds, &req); err != nil {
+		respondError(w, fmt.Sprintf("Invalid update: %v", err), http.StatusBadRequest)
+		return
+	}
 
-	filePath := h.fileStore.GetFilePath(ds.DataSourcePath)
-	if !h.fileStore.FileExists(ds.DataSourcePath) {
-		respondError(w, "Data file not found", http.StatusNotFound)
+	if err := h.store.SaveDataSource(ds.ToSchema()); err != nil {
+		respondError(w, fmt.Sprintf("Failed to save datasource: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, newDataSourceMetadata(ds), http.StatusOK)
+}
+
+// QueryData godoc
+// @Summary Query time series data
+// @Description Query time series data from a datasource with optional time range filtering. Time bounds accept RFC3339 timestamps or relative expressions resolved against the current time in tz; start_time and end_time override the corresponding end of range.
+// @Tags datasources
+// @Produce json
+// @Param id path int true "Datasource ID"
+// @Param range query string false "Time range expression (e.g., last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1)"
+// @Param start_time query string false "Start time in RFC3339 or as an expression (e.g., 2024-01-01T00:00:00Z, now-3d, yesterday)"
+// @Param end_time query string false "End time in RFC3339 or as an expression (e.g., 2024-01-01T23:59:59Z, now, today)"
+// @Param tz query string false "IANA timezone used to resolve expressions (default UTC)"
+// @Param unit query string false "Convert values to this unit (e.g., degF); the datasource must have a unit of the same dimension"
+// @Success 200 {object} DataQueryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/datasources/{id}/data [get]
+func (h *DataSourceHandler) QueryData(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid datasource ID", http.StatusBadRequest)
 		return
 	}
 
-	tsData, err := timeseries.LoadAndValidateCSV(filePath)
+	query := r.URL.Query()
+	loc, err := timerange.LoadLocation(query.Get("tz"))
 	if err != nil {
-		respondError(w, fmt.Sprintf("Failed to load data: %v", err), http.StatusInternalServerError)
+		respondError(w, fmt.Sprintf("Invalid tz: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	var startTime, endTime *time.Time
-	if startStr := r.URL.Query().Get("start_time"); startStr != "" {
-		t, err := time.Parse(time.RFC3339, startStr)
-		if err != nil {
-			respondError(w, "Invalid start_time format, use RFC3339", http.StatusBadRequest)
-			return
-		}
-		startTime = &t
+	startTime, endTime, err := timerange.Resolve(query.Get("range"), query.Get("start_time"), query.Get("end_time"), time.Now(), loc)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid time range: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	if endStr := r.URL.Query().Get("end_time"); endStr != "" {
-		t, err := time.Parse(time.RFC3339, endStr)
-		if err != nil {
-			respondError(w, "Invalid end_time format, use RFC3339", http.StatusBadRequest)
-			return
+	ds, filteredData, err := h.loader.Load(r.Context(), id, startTime, endTime)
+	if err != nil {
+		switch {
+		// Checked first since a virtual datasource reports missing inputs as an
+		// ExpressionError wrapping ErrNotFound
+		case errors.As(err, new(*dataset.ExpressionError)):
+			respondError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, dataset.ErrNotFound):
+			respondError(w, "Datasource not found", http.StatusNotFound)
+		case errors.Is(err, dataset.ErrFileNotFound):
+			respondError(w, "Data file not found", http.StatusNotFound)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
 		}
-		endTime = &t
+		return
 	}
 
-	filteredData, err := timeseries.FilterByTimeRange(tsData, startTime, endTime)
+	convert, unit, err := dataset.UnitConverter(ds, query.Get("unit"))
 	if err != nil {
-		respondError(w, fmt.Sprintf("Failed to filter data: %v", err), http.StatusInternalServerError)
+		respondError(w, fmt.Sprintf("Invalid unit: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -305,7 +487,7 @@ func (h *DataSourceHandler) QueryData(w http.ResponseWriter, r *http.Request) {
 	timestampRecords := filteredData.DataFrame.Col("timestamp").Records()
 	valueRecords := filteredData.DataFrame.Col("value").Records()
 
-	for i := 1; i < len(timestampRecords); i++ {
+	for i := range timestampRecords {
 		ts, err := time.Parse(time.RFC3339, timestampRecords[i])
 		if err != nil {
 			continue
@@ -318,13 +500,14 @@ func (h *DataSourceHandler) QueryData(w http.ResponseWriter, r *http.Request) {
 
 		dataPoints = append(dataPoints, DataPoint{
 			Timestamp: ts,
-			Value:     val,
+			Value:     convert(val),
 		})
 	}
 
 	response := DataQueryResponse{
 		Data:     dataPoints,
 		RowCount: len(dataPoints),
+		Unit:     unit,
 	}
 
 	if len(dataPoints) > 0 {
@@ -337,7 +520,7 @@ func (h *DataSourceHandler) QueryData(w http.ResponseWriter, r *http.Request) {
 
 // DeleteDataSource godoc
 // @Summary Delete a datasource
-// @Description Delete a datasource and its associated CSV file
+// @Description Delete a datasource and its associated CSV file. Virtual datasources built on it fail to read afterwards.
 // @Tags datasources
 // @Param id path int true "Datasource ID"
 // @Success 204 "No Content"
@@ -361,9 +544,12 @@ func (h *DataSourceHandler) DeleteDataSource(w http.ResponseWriter, r *http.Requ
 	ds := &models.DataSource{}
 	ds.FromSchema(schema)
 
-	if err := h.fileStore.DeleteFile(ds.DataSourcePath); err != nil {
-		respondError(w, fmt.Sprintf("Failed to delete file: %v", err), http.StatusInternalServerError)
-		return
+	// Virtual datasources have no file
+	if !ds.IsVirtual() {
+		if err := h.fileStore.DeleteFile(ds.DataSourcePath); err != nil {
+			respondError(w, fmt.Sprintf("Failed to delete file: %v", err), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	if err := h.store.DeleteDataSource(id); err != nil {
@@ -374,6 +560,194 @@ func (h *DataSourceHandler) DeleteDataSource(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func newDataSourceMetadata(ds *models.DataSource) DataSourceMetadata {
+	tags := ds.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return DataSourceMetadata{
+		DataSourceId: ds.DataSourceId,
+		Name:         ds.Name,
+		Type:         models.DataSourceTypes[ds.DataSourceType],
+		Expression:   ds.Expression,
+		RowCount:     ds.RowCount,
+		StartTime:    ds.StartTime,
+		EndTime:      ds.EndTime,
+		TimeLabel:    ds.TimeLabel,
+		ValueLabel:   ds.ValueLabel,
+		Description:  ds.Description,
+		Unit:         ds.Unit,
+		SensorType:   ds.SensorType,
+		Location:     ds.Location,
+		Tags:         tags,
+		Seasonality:  newSeasonalPeriodInfos(ds.Seasonality),
+		CreatedBy:    ds.CreatedBy,
+		WhenCreated:  ds.WhenCreated,
+	}
+}
+
+func newSeasonalPeriodInfos(periods []models.SeasonalPeriod) []SeasonalPeriodInfo {
+	var infos []SeasonalPeriodInfo
+	for _, p := range periods {
+		infos = append(infos, SeasonalPeriodInfo{
+			Period:        analysis.FormatPeriod(p.Period),
+			PeriodSeconds: p.Period.Seconds(),
+			Strength:      p.Strength,
+		})
+	}
+	return infos
+}
+
+// applyDataSourceUpdate validates req and applies it to ds
+func applyDataSourceUpdate(ds *models.DataSource, req *UpdateDataSourceRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return errors.New("name must not be empty")
+		}
+		if len(name) > maxDataSourceNameLength {
+			return fmt.Errorf("name is longer than %d characters", maxDataSourceNameLength)
+		}
+		ds.Name = name
+	}
+	if req.Description != nil {
+		if len(*req.Description) > maxDescriptionLength {
+			return fmt.Errorf("description is longer than %d characters", maxDescriptionLength)
+		}
+		ds.Description = *req.Description
+	}
+
+	fields := []struct {
+		name  string
+		value *string
+		dest  *string
+	}{
+		{"unit", req.Unit, &ds.Unit},
+		{"sensor_type", req.SensorType, &ds.SensorType},
+		{"location", req.Location, &ds.Location},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		if len(*f.value) > maxMetadataFieldLength {
+			return fmt.Errorf("%s is longer than %d characters", f.name, maxMetadataFieldLength)
+		}
+		*f.dest = strings.TrimSpace(*f.value)
+	}
+	// Units must be in the registry so that values can be converted
+	if ds.Unit != "" && req.Unit != nil {
+		unit, err := units.Canonical(ds.Unit)
+		if err != nil {
+			return fmt.Errorf("%w, see GET /api/units", err)
+		}
+		ds.Unit = unit
+	}
+
+	if len(req.Tags) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(ds.Tags)+len(req.Tags))
+	for key, value := range ds.Tags {
+		tags[key] = value
+	}
+	for key, value := range req.Tags {
+		if value == nil {
+			delete(tags, key)
+			continue
+		}
+		if len(key) > maxTagKeyLength || !tagKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag key %q: use up to %d letters, digits and _ . - /", key, maxTagKeyLength)
+		}
+		if len(*value) > maxTagValueLength {
+			return fmt.Errorf("value of tag %q is longer than %d characters", key, maxTagValueLength)
+		}
+		tags[key] = *value
+	}
+	if len(tags) > maxDataSourceTags {
+		return fmt.Errorf("a datasource can have at most %d tags", maxDataSourceTags)
+	}
+	ds.Tags = tags
+	return nil
+}
+
+// parseDataSourceFilter reads the filter, sort and page parameters of ListDataSources
+func parseDataSourceFilter(query url.Values, now time.Time) (persistence.DataSourceFilter, error) {
+	filter := persistence.DataSourceFilter{
+		Search: strings.TrimSpace(query.Get("q")),
+		Limit:  defaultDataSourcePageSize,
+	}
+
+	for _, tag := range query["tag"] {
+		key, value, _ := strings.Cut(tag, ":")
+		if key == "" {
+			return filter, fmt.Errorf("Invalid tag filter %q, want key:value or key", tag)
+		}
+		if filter.Tags == nil {
+			filter.Tags = map[string]string{}
+		}
+		filter.Tags[key] = value
+	}
+
+	loc, err := timerange.LoadLocation(query.Get("tz"))
+	if err != nil {
+		return filter, fmt.Errorf("Invalid tz: %v", err)
+	}
+	filter.From, filter.To, err = timerange.Resolve(query.Get("range"), query.Get("start_time"), query.Get("end_time"), now, loc)
+	if err != nil {
+		return filter, fmt.Errorf("Invalid time range: %v", err)
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		filter.SortBy, filter.Descending = strings.CutPrefix(sort, "-")
+		if _, ok := persistence.DataSourceSortColumns[filter.SortBy]; !ok {
+			return filter, fmt.Errorf("Invalid sort %q (valid: name, when_created, start_time, end_time, row_count)", sort)
+		}
+	} else {
+		filter.SortBy, filter.Descending = "when_created", true
+	}
+
+	if param := query.Get("limit"); param != "" {
+		limit, err := strconv.Atoi(param)
+		if err != nil || limit < 1 || limit > maxDataSourcePageSize {
+			return filter, fmt.Errorf("Invalid limit, want 1 to %d", maxDataSourcePageSize)
+		}
+		filter.Limit = limit
+	}
+	if param := query.Get("offset"); param != "" {
+		offset, err := strconv.Atoi(param)
+		if err != nil || offset < 0 {
+			return filter, errors.New("Invalid offset")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
+
+// respondDataError reports an error from reading, combining or evaluating
+// datasources
+func respondDataError(w http.ResponseWriter, err error) {
+	switch {
+	// Checked first since a virtual datasource reports missing inputs as an
+	// ExpressionError wrapping ErrNotFound
+	case errors.As(err, new(*dataset.ExpressionError)), errors.As(err, new(*dataset.ImputeError)):
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, dataset.ErrNotFound):
+		respondError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, dataset.ErrFileNotFound):
+		respondError(w, "Data file not found", http.StatusNotFound)
+	case errors.Is(err, dataset.ErrNoUnit), errors.Is(err, units.ErrUnknownUnit), errors.Is(err, units.ErrIncompatible):
+		respondError(w, fmt.Sprintf("Invalid unit: %v", err), http.StatusBadRequest)
+	case errors.Is(err, timeseries.ErrTooManyTimes):
+		respondError(w, fmt.Sprintf("Invalid interval: %v", err), http.StatusBadRequest)
+	case errors.Is(err, dataset.ErrTooFewPoints):
+		respondError(w, err.Error(), http.StatusBadRequest)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func respondJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
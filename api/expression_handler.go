@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+)
+
+type ExpressionHandler struct {
+	loader *dataset.Loader
+}
+
+func NewExpressionHandler(loader *dataset.Loader) *ExpressionHandler {
+	return &ExpressionHandler{loader: loader}
+}
+
+type EvaluateExpressionRequest struct {
+	Expression string `json:"expression"`
+	Range      string `json:"range,omitempty"`
+	StartTime  string `json:"start_time,omitempty"`
+	EndTime    string `json:"end_time,omitempty"`
+	Tz         string `json:"tz,omitempty"`
+	Unit       string `json:"unit,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+type EvaluateExpressionResponse struct {
+	Expression string      `json:"expression"`
+	Data       []DataPoint `json:"data"`
+	RowCount   int         `json:"row_count"`
+	Truncated  bool        `json:"truncated,omitempty"`
+	Unit       string      `json:"unit,omitempty"`
+	StartTime  time.Time   `json:"start_time"`
+	EndTime    time.Time   `json:"end_time"`
+}
+
+// EvaluateExpression godoc
+// @Summary Evaluate an expression
+// @Description Compute a series from other datasources without saving it, e.g. ds(3) * 1.8 + 32, ds(4) - ds(5) or max(ds(1), ds(2, "degC")). Inputs are aligned on the union of their timestamps with linear interpolation, and times where any input has no data are left out. Adding, subtracting or comparing values of different dimensions is rejected. Functions: abs, round, floor, ceil, sqrt, exp, log, log10, pow, min, max, clamp, rate.
+// @Tags expressions
+// @Accept json
+// @Produce json
+// @Param request body EvaluateExpressionRequest true "Expression and time range"
+// @Success 200 {object} EvaluateExpressionResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/expressions/evaluate [post]
+func (h *ExpressionHandler) EvaluateExpression(w http.ResponseWriter, r *http.Request) {
+	var req EvaluateExpressionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Expression == "" {
+		respondError(w, "expression is required", http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 {
+		respondError(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	loc, err := timerange.LoadLocation(req.Tz)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid tz: %v", err), http.StatusBadRequest)
+		return
+	}
+	startTime, endTime, err := timerange.Resolve(req.Range, req.StartTime, req.EndTime, time.Now(), loc)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid time range: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	points, unit, err := h.loader.Evaluate(r.Context(), req.Expression, startTime, endTime, req.Unit)
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	response := EvaluateExpressionResponse{
+		Expression: req.Expression,
+		Data:       make([]DataPoint, 0, len(points)),
+		Unit:       unit,
+	}
+	if req.Limit > 0 && len(points) > req.Limit {
+		points = points[:req.Limit]
+		response.Truncated = true
+	}
+	for _, p := range points {
+		response.Data = append(response.Data, DataPoint{Timestamp: p.Timestamp, Value: p.Value})
+	}
+	response.RowCount = len(response.Data)
+	if len(points) > 0 {
+		response.StartTime = points[0].Timestamp
+		response.EndTime = points[len(points)-1].Timestamp
+	}
+
+	respondJSON(w, response, http.StatusOK)
+}
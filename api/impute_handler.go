@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+)
+
+type ImputeHandler struct {
+	loader *dataset.Loader
+}
+
+func NewImputeHandler(loader *dataset.Loader) *ImputeHandler {
+	return &ImputeHandler{loader: loader}
+}
+
+type ImputeRequest struct {
+	// Method is linear (default), spline, locf, seasonal or rolling_mean
+	Method   string `json:"method,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	MaxGap   string `json:"max_gap,omitempty"`
+	Season   string `json:"season,omitempty"`
+	Window   string `json:"window,omitempty"`
+	Range    string `json:"range,omitempty"`
+	// StartTime and EndTime override the start and end of Range
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+	Timezone  string `json:"tz,omitempty"`
+	Unit      string `json:"unit,omitempty"`
+	// SaveAs saves the filled series as a new datasource with this name
+	SaveAs string `json:"save_as,omitempty"`
+}
+
+type ImputeResponse struct {
+	Series   AnalysisSeries `json:"series"`
+	Method   string         `json:"method"`
+	Interval string         `json:"interval"`
+	MaxGap   string         `json:"max_gap,omitempty"`
+	Season   string         `json:"season,omitempty"`
+	RowCount int            `json:"row_count"`
+	// Imputed counts the filled values, and Missing the gaps left empty
+	Imputed int                 `json:"imputed"`
+	Missing int                 `json:"missing"`
+	Data    []ImputedDataPoint  `json:"data"`
+	Saved   *DataSourceMetadata `json:"saved,omitempty"`
+}
+
+type ImputedDataPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Value is null for a gap left empty
+	Value   *float64 `json:"value"`
+	Imputed bool     `json:"imputed,omitempty"`
+}
+
+// ImputeDataSource godoc
+// @Summary Fill the gaps of a datasource
+// @Description Put a datasource on a regular grid and fill its gaps: linear draws a line across the gap, spline follows a natural cubic spline through the known values, locf carries the last observation forward, seasonal repeats the value one season earlier and rolling_mean takes the mean of the known values in a window around each missing time. Gaps longer than max_gap, measured between the values around them, are left empty, as are the times before the first and after the last value. Every filled value is flagged. With save_as the result is saved as a new datasource.
+// @Tags datasources
+// @Accept json
+// @Produce json
+// @Param id path int true "Datasource ID"
+// @Param request body ImputeRequest true "Imputation"
+// @Success 200 {object} ImputeResponse
+// @Success 201 {object} ImputeResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/datasources/{id}/impute [post]
+func (h *ImputeHandler) ImputeDataSource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid datasource ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ImputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.SaveAs = strings.TrimSpace(req.SaveAs)
+	if len(req.SaveAs) > maxDataSourceNameLength {
+		respondError(w, fmt.Sprintf("save_as is longer than %d characters", maxDataSourceNameLength), http.StatusBadRequest)
+		return
+	}
+
+	method, err := timeseries.ParseImputeMethod(req.Method)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid method: %v", err), http.StatusBadRequest)
+		return
+	}
+	opts := timeseries.ImputeOptions{Method: method}
+	for _, d := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"interval", req.Interval, &opts.Interval},
+		{"max_gap", req.MaxGap, &opts.MaxGap},
+		{"season", req.Season, &opts.Season},
+		{"window", req.Window, &opts.Window},
+	} {
+		if *d.dest, err = parseDurationValue(d.name, d.value); err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	loc, err := timerange.LoadLocation(req.Timezone)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid tz: %v", err), http.StatusBadRequest)
+		return
+	}
+	startTime, endTime, err := timerange.Resolve(req.Range, req.StartTime, req.EndTime, time.Now(), loc)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid time range: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.loader.Impute(r.Context(), id, startTime, endTime, opts, req.Unit)
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	response := ImputeResponse{
+		Series:   AnalysisSeries{DataSourceId: result.DataSource.DataSourceId, Name: result.DataSource.Name, Unit: result.Unit},
+		Method:   string(result.Options.Method),
+		Interval: analysis.FormatDuration(result.Options.Interval),
+		RowCount: len(result.Points),
+		Imputed:  result.Imputed,
+		Missing:  result.Missing,
+		Data:     make([]ImputedDataPoint, len(result.Points)),
+	}
+	if result.Options.MaxGap > 0 {
+		response.MaxGap = analysis.FormatDuration(result.Options.MaxGap)
+	}
+	if method == timeseries.ImputeSeasonal {
+		response.Season = analysis.FormatPeriod(result.Options.Season)
+	}
+	for i, p := range result.Points {
+		response.Data[i] = ImputedDataPoint{Timestamp: p.Timestamp, Imputed: p.Imputed}
+		if !math.IsNaN(p.Value) {
+			v := p.Value
+			response.Data[i].Value = &v
+		}
+	}
+
+	if req.SaveAs == "" {
+		respondJSON(w, response, http.StatusOK)
+		return
+	}
+	ds, err := h.loader.SaveImputed(r.Context(), req.SaveAs, result)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to save datasource: %v", err), http.StatusInternalServerError)
+		return
+	}
+	saved := newDataSourceMetadata(ds)
+	response.Saved = &saved
+	respondJSON(w, response, http.StatusCreated)
+}
+
+// parseDurationValue parses the optional positive duration name, zero if it is empty
+func parseDurationValue(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := timerange.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: %v", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("Invalid %s, want a positive duration such as 5m", name)
+	}
+	return d, nil
+}
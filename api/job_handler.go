@@ -0,0 +1,305 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/jobs"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+)
+
+type JobHandler struct {
+	jobs *jobs.Manager
+}
+
+func NewJobHandler(jobManager *jobs.Manager) *JobHandler {
+	return &JobHandler{
+		jobs: jobManager,
+	}
+}
+
+type JobRequest struct {
+	// Kind is tool_invoke (params: fx_name, arguments)
+	Kind   string          `json:"kind"`
+	Params json.RawMessage `json:"params" swaggertype:"object"`
+}
+
+type JobResponse struct {
+	JobId        int64           `json:"job_id"`
+	Kind         string          `json:"kind"`
+	Status       string          `json:"status"`
+	Progress     float64         `json:"progress"`
+	Message      string          `json:"message,omitempty"`
+	Params       json.RawMessage `json:"params" swaggertype:"object"`
+	Result       json.RawMessage `json:"result,omitempty" swaggertype:"object"`
+	Error        *string         `json:"error,omitempty"`
+	Attempts     int             `json:"attempts"`
+	CreatedBy    *int64          `json:"created_by,omitempty"`
+	WhenCreated  time.Time       `json:"when_created"`
+	WhenStarted  *time.Time      `json:"when_started,omitempty"`
+	WhenFinished *time.Time      `json:"when_finished,omitempty"`
+}
+
+type JobListResponse struct {
+	Jobs []JobResponse `json:"jobs"`
+}
+
+// SubmitJob godoc
+// @Summary Submit a background job
+// @Description Queue a long-running job. Kinds: tool_invoke runs a tool (params: fx_name, arguments). Uploads are ingested in the background with POST /api/datasources?async=true instead. Poll GET /api/jobs/{id} for progress and the result.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param request body JobRequest true "Job kind and params"
+// @Success 202 {object} JobResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/jobs [post]
+func (h *JobHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Params) == 0 {
+		req.Params = json.RawMessage(`{}`)
+	}
+	if h.jobs.IsInternal(req.Kind) {
+		respondError(w, fmt.Sprintf("Invalid job kind: %s jobs are submitted by the server; upload with ?async=true instead", req.Kind), http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobs.Submit(r.Context(), req.Kind, req.Params)
+	if err != nil {
+		var paramsErr *jobs.ParamsError
+		if errors.Is(err, jobs.ErrUnknownKind) || errors.As(err, &paramsErr) {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to submit job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondAccepted(w, job)
+}
+
+// ListJobs godoc
+// @Summary List jobs
+// @Description Get background jobs, newest first
+// @Tags jobs
+// @Produce json
+// @Param status query string false "Only jobs with this status (queued, running, succeeded, failed, cancelled)"
+// @Success 200 {object} JobListResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/jobs [get]
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	list, err := h.jobs.List(r.URL.Query().Get("status"))
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := JobListResponse{Jobs: make([]JobResponse, 0, len(list))}
+	for _, job := range list {
+		response.Jobs = append(response.Jobs, newJobResponse(job))
+	}
+	respondJSON(w, response, http.StatusOK)
+}
+
+// GetJob godoc
+// @Summary Get a job
+// @Description Get the status, progress and, once finished, the result or error of a background job
+// @Tags jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} JobResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/jobs/{id} [get]
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobs.Get(id)
+	if err != nil {
+		if errors.Is(err, jobs.ErrNotFound) {
+			respondError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to load job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, newJobResponse(job), http.StatusOK)
+}
+
+// CancelJob godoc
+// @Summary Cancel a job
+// @Description Cancel a queued or running job. Running jobs are stopped at their next cancellation point and then reported as cancelled.
+// @Tags jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} JobResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/jobs/{id}/cancel [post]
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobs.Cancel(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			respondError(w, "Job not found", http.StatusNotFound)
+		case errors.Is(err, jobs.ErrFinished):
+			respondError(w, fmt.Sprintf("Job already %s", job.Status), http.StatusConflict)
+		default:
+			respondError(w, fmt.Sprintf("Failed to cancel job: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, newJobResponse(job), http.StatusOK)
+}
+
+// JobProgressEvent is the data of a job progress event
+type JobProgressEvent struct {
+	JobId    int64   `json:"job_id"`
+	Progress float64 `json:"progress"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// jobEventPoll is how often a job stream reloads the job in case a change
+// notification was missed, e.g. for a job finished by another process
+const jobEventPoll = 5 * time.Second
+
+// StreamJobEvents godoc
+// @Summary Stream job progress
+// @Description Stream a job's progress as text/event-stream. Events: status (the job, sent first and on every status change), progress (job_id, progress, message) and done (the finished job, including its result or error). The stream ends after done.
+// @Tags jobs
+// @Produce text/event-stream
+// @Param id path int true "Job ID"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/jobs/{id}/events [get]
+func (h *JobHandler) StreamJobEvents(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	// Watch before the first load so no change between the two is missed
+	changed, stop := h.jobs.Watch(id)
+	defer stop()
+
+	job, err := h.jobs.Get(id)
+	if err != nil {
+		if errors.Is(err, jobs.ErrNotFound) {
+			respondError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to load job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	stream, err := newSSEWriter(w)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	poll := time.NewTicker(jobEventPoll)
+	defer poll.Stop()
+	ping := time.NewTicker(sseKeepAlive)
+	defer ping.Stop()
+
+	var last *models.Job
+	for {
+		if last == nil || job.Status != last.Status {
+			if job.IsFinished() {
+				stream.Send("done", newJobResponse(job))
+				return
+			}
+			if err := stream.Send("status", newJobResponse(job)); err != nil {
+				return
+			}
+		} else if job.Progress != last.Progress || job.Message != last.Message {
+			if err := stream.Send("progress", JobProgressEvent{JobId: job.JobId, Progress: job.Progress, Message: job.Message}); err != nil {
+				return
+			}
+		}
+		last = job
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-serverDraining(r.Context()):
+			// The job keeps running; clients reconnect once the server is back
+			stream.Send("error", ErrorResponse{Error: "Server is shutting down"})
+			return
+		case <-ping.C:
+			if err := stream.KeepAlive(); err != nil {
+				return
+			}
+			continue
+		case <-changed:
+		case <-poll.C:
+		}
+
+		job, err = h.jobs.Get(id)
+		if err != nil {
+			stream.Send("error", ErrorResponse{Error: fmt.Sprintf("Failed to load job: %v", err)})
+			return
+		}
+	}
+}
+
+// respondAccepted answers an ?async=true request with the queued job
+func respondAccepted(w http.ResponseWriter, job *models.Job) {
+	w.Header().Set("Location", fmt.Sprintf("/api/jobs/%d", job.JobId))
+	respondJSON(w, newJobResponse(job), http.StatusAccepted)
+}
+
+func isAsync(r *http.Request) bool {
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+func newJobResponse(job *models.Job) JobResponse {
+	response := JobResponse{
+		JobId:        job.JobId,
+		Kind:         job.Kind,
+		Status:       job.Status,
+		Progress:     job.Progress,
+		Message:      job.Message,
+		Params:       json.RawMessage(job.Params),
+		Error:        job.Error,
+		Attempts:     job.Attempts,
+		CreatedBy:    job.CreatedBy,
+		WhenCreated:  job.WhenCreated,
+		WhenStarted:  job.WhenStarted,
+		WhenFinished: job.WhenFinished,
+	}
+	if job.Result != nil {
+		response.Result = json.RawMessage(*job.Result)
+	}
+	return response
+}
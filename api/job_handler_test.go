@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/jobs"
+)
+
+func TestSubmitJobRejectsInternalKinds(t *testing.T) {
+	m := jobs.NewManager(nil, 1)
+	jobs.RegisterBuiltinKinds(m, nil, nil)
+	h := NewJobHandler(m)
+
+	body := `{"kind": "ingest_csv", "params": {"name": "copy", "file": "meter.csv"}}`
+	rec := httptest.NewRecorder()
+	h.SubmitJob(rec, httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
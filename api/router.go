@@ -1,43 +1,123 @@
 package api
 
 import (
-	"log"
 	"net/http"
+	"slices"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nathanaday/iot-data-sandbox/internal/agent"
+	"github.com/nathanaday/iot-data-sandbox/internal/auth"
+	"github.com/nathanaday/iot-data-sandbox/internal/config"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/jobs"
+	"github.com/nathanaday/iot-data-sandbox/internal/mcpserver"
+	"github.com/nathanaday/iot-data-sandbox/internal/metrics"
 	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
 	"github.com/nathanaday/iot-data-sandbox/internal/storage"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+	"github.com/nathanaday/iot-data-sandbox/internal/vault"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func ListenAndServe(addr string, r *chi.Mux) error {
-
-	if addr == "" {
-		addr = ":8080" // Default
-	}
-	log.Printf("Server starting on %s", addr)
-	if err := http.ListenAndServe(addr, r); err != nil {
-		return err
-	}
-	return nil
-}
-
-func SetupRouter(store *persistence.Store, fileStore *storage.FileStore) *chi.Mux {
+func SetupRouter(cfg *config.Config, store *persistence.Store, fileStore *storage.FileStore, loader *dataset.Loader, executor *tools.Executor, secrets *vault.Vault, authenticator *auth.Authenticator, jobManager *jobs.Manager, sessions *agent.Sessions, mcpServer *mcpserver.Server) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-	r.Use(corsMiddleware)
-
-	dataSourceHandler := NewDataSourceHandler(store, fileStore)
-	r.Route("/api/datasources", func(r chi.Router) {
-		r.Post("/", dataSourceHandler.UploadCSV)
-		r.Get("/", dataSourceHandler.ListDataSources)
-		r.Get("/{id}", dataSourceHandler.GetDataSource)
-		r.Get("/{id}/data", dataSourceHandler.QueryData)
-		r.Delete("/{id}", dataSourceHandler.DeleteDataSource)
+	r.Use(requestLogger)
+	r.Use(tracingMiddleware)
+	r.Use(metricsMiddleware)
+	r.Use(corsMiddleware(cfg.CORS.AllowedOrigins))
+
+	authHandler := NewAuthHandler(authenticator)
+	read := authHandler.RequireScope(auth.ScopeRead)
+	write := authHandler.RequireScope(auth.ScopeWrite)
+	admin := authHandler.RequireScope(auth.ScopeAdmin)
+
+	// Every /api route and the MCP endpoint require an API key
+	r.Group(func(r chi.Router) {
+		r.Use(authHandler.Authenticate)
+
+		r.Get("/api/auth/me", authHandler.WhoAmI)
+		r.Route("/api/users", func(r chi.Router) {
+			r.Use(admin)
+			r.Get("/", authHandler.ListUsers)
+			r.Post("/", authHandler.CreateUser)
+		})
+		r.Route("/api/keys", func(r chi.Router) {
+			r.Get("/", authHandler.ListApiKeys)
+			r.Post("/", authHandler.CreateApiKey)
+			r.Delete("/{id}", authHandler.RevokeApiKey)
+		})
+
+		dataSourceHandler := NewDataSourceHandler(store, fileStore, loader, jobManager, int64(cfg.Storage.MaxUploadSize))
+		alignHandler := NewAlignHandler(loader)
+		imputeHandler := NewImputeHandler(loader)
+		transformHandler := NewTransformHandler(loader)
+		r.Route("/api/datasources", func(r chi.Router) {
+			r.With(write).Post("/", dataSourceHandler.UploadCSV)
+			r.With(write).Post("/virtual", dataSourceHandler.CreateVirtualDataSource)
+			r.With(read).Get("/", dataSourceHandler.ListDataSources)
+			r.With(read).Get("/align", alignHandler.AlignDataSources)
+			r.With(read).Get("/{id}", dataSourceHandler.GetDataSource)
+			r.With(write).Patch("/{id}", dataSourceHandler.UpdateDataSource)
+			r.With(read).Get("/{id}/data", dataSourceHandler.QueryData)
+			r.With(write).Post("/{id}/impute", imputeHandler.ImputeDataSource)
+			r.With(write).Post("/{id}/transform", transformHandler.TransformDataSource)
+			r.With(write).Delete("/{id}", dataSourceHandler.DeleteDataSource)
+		})
+
+		expressionHandler := NewExpressionHandler(loader)
+		r.With(read).Post("/api/expressions/evaluate", expressionHandler.EvaluateExpression)
+
+		analysisHandler := NewAnalysisHandler(loader)
+		r.Route("/api/analysis", func(r chi.Router) {
+			r.Use(read)
+			r.Get("/correlation", analysisHandler.Correlate)
+			r.Get("/spectrum", analysisHandler.Spectrum)
+			r.Get("/autocorrelation", analysisHandler.Autocorrelation)
+		})
+
+		unitHandler := NewUnitHandler()
+		r.With(read).Get("/api/units", unitHandler.ListUnits)
+
+		toolHandler := NewToolHandler(store, executor, secrets, jobManager)
+		r.Route("/api/tools", func(r chi.Router) {
+			r.With(read).Get("/", toolHandler.ListTools)
+			r.With(read).Get("/catalog", toolHandler.GetToolCatalog)
+			r.With(write).Post("/{fxName}/invoke", toolHandler.InvokeTool)
+			r.With(admin).Get("/{fxName}/secrets", toolHandler.ListToolSecrets)
+			r.With(admin).Put("/{fxName}/secrets/{name}", toolHandler.PutToolSecret)
+			r.With(admin).Delete("/{fxName}/secrets/{name}", toolHandler.DeleteToolSecret)
+		})
+		r.With(admin).Post("/api/vault/rotate", toolHandler.RotateVaultKey)
+
+		jobHandler := NewJobHandler(jobManager)
+		r.Route("/api/jobs", func(r chi.Router) {
+			r.With(write).Post("/", jobHandler.SubmitJob)
+			r.With(read).Get("/", jobHandler.ListJobs)
+			r.With(read).Get("/{id}", jobHandler.GetJob)
+			r.With(write).Post("/{id}/cancel", jobHandler.CancelJob)
+			r.With(read).Get("/{id}/events", jobHandler.StreamJobEvents)
+		})
+
+		// Chatting creates sessions and invokes tools, so it needs write even over GET
+		chatHandler := NewChatHandler(sessions)
+		r.Route("/api/chat", func(r chi.Router) {
+			r.Use(write)
+			r.Post("/", chatHandler.Chat)
+			r.Get("/stream", chatHandler.ChatStream)
+			r.Post("/stream", chatHandler.ChatStream)
+		})
+
+		sessionHandler := NewSessionHandler(store)
+		r.Route("/api/sessions", func(r chi.Router) {
+			r.With(read).Get("/", sessionHandler.ListSessions)
+			r.With(read).Get("/{id}", sessionHandler.GetSessionTranscript)
+			r.With(write).Post("/{id}/chat", chatHandler.ResumeSession)
+		})
+
+		r.With(write).Handle(mcpserver.HTTPPath, mcpServer.HTTPHandler())
 	})
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -45,24 +125,41 @@ func SetupRouter(store *persistence.Store, fileStore *storage.FileStore) *chi.Mu
 		w.Write([]byte("OK"))
 	})
 
+	// Left open like /health so Prometheus can scrape without an API key
+	r.Handle("/metrics", metrics.Handler())
+
 	r.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
+		httpSwagger.URL(cfg.Server.SwaggerURL),
 	))
 
 	return r
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// corsMiddleware allows browsers on the given origins to call the API. "*" allows
+// any origin; since API keys are sent in a header rather than a cookie, this does
+// not expose them to other sites.
+func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowAll := slices.Contains(allowedOrigins, "*")
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			switch {
+			case allowAll:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && slices.Contains(allowedOrigins, origin):
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 
-		next.ServeHTTP(w, r)
-	})
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
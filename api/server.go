@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/config"
+)
+
+// Server is the HTTP server with its timeouts and graceful shutdown
+type Server struct {
+	cfg      config.ServerConfig
+	http     *http.Server
+	draining chan struct{}
+}
+
+type drainingKey struct{}
+
+func NewServer(cfg config.ServerConfig, handler http.Handler) *Server {
+	s := &Server{
+		cfg:      cfg,
+		draining: make(chan struct{}),
+	}
+	s.http = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), drainingKey{}, s.draining)
+		},
+	}
+	return s
+}
+
+// ListenAndServe serves until Shutdown is called, in which case it returns nil
+func (s *Server) ListenAndServe() error {
+	var err error
+	if s.cfg.TLSEnabled() {
+		slog.Info("Server starting", "addr", s.cfg.Addr, "tls", true)
+		err = s.http.ListenAndServeTLS(s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+	} else {
+		slog.Info("Server starting", "addr", s.cfg.Addr, "tls", false)
+		err = s.http.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections, ends open event streams and waits for
+// in-flight requests to finish. If ctx expires first the remaining connections are
+// closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.draining)
+
+	if err := s.http.Shutdown(ctx); err != nil {
+		s.http.Close()
+		return err
+	}
+	return nil
+}
+
+// serverDraining returns a channel that is closed once the server starts shutting
+// down. Long-lived responses such as event streams should end when it closes, since
+// they would otherwise hold up the shutdown until it times out.
+func serverDraining(ctx context.Context) <-chan struct{} {
+	draining, _ := ctx.Value(drainingKey{}).(chan struct{})
+	return draining
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/agent"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+)
+
+type SessionHandler struct {
+	store *persistence.Store
+}
+
+func NewSessionHandler(store *persistence.Store) *SessionHandler {
+	return &SessionHandler{
+		store: store,
+	}
+}
+
+type SessionListResponse struct {
+	Sessions []SessionMetadata `json:"sessions"`
+}
+
+type SessionMetadata struct {
+	SessionId   int64     `json:"session_id"`
+	Title       string    `json:"title"`
+	CreatedBy   *int64    `json:"created_by,omitempty"`
+	WhenCreated time.Time `json:"when_created"`
+	WhenUpdated time.Time `json:"when_updated"`
+}
+
+type SessionTranscriptResponse struct {
+	Session         SessionMetadata      `json:"session"`
+	Messages        []TranscriptMessage  `json:"messages"`
+	ToolInvocations []ToolInvocationInfo `json:"tool_invocations"`
+}
+
+type TranscriptMessage struct {
+	MessageId        int64           `json:"message_id"`
+	Seq              int             `json:"seq"`
+	Role             string          `json:"role"`
+	Content          string          `json:"content"`
+	ToolCalls        json.RawMessage `json:"tool_calls,omitempty" swaggertype:"object"`
+	ToolCallId       *string         `json:"tool_call_id,omitempty"`
+	Name             *string         `json:"name,omitempty"`
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	WhenCreated      time.Time       `json:"when_created"`
+}
+
+type ToolInvocationInfo struct {
+	InvocationId  int64           `json:"invocation_id"`
+	MessageId     *int64          `json:"message_id,omitempty"`
+	ToolCallId    string          `json:"tool_call_id"`
+	ToolName      string          `json:"tool_name"`
+	Arguments     json.RawMessage `json:"arguments" swaggertype:"object"`
+	ResultSummary *string         `json:"result_summary,omitempty"`
+	DurationMs    int64           `json:"duration_ms"`
+	Error         *string         `json:"error,omitempty"`
+	WhenCreated   time.Time       `json:"when_created"`
+}
+
+// ListSessions godoc
+// @Summary List chat sessions
+// @Description Get all agent chat sessions, most recently active first
+// @Tags chat
+// @Produce json
+// @Success 200 {object} SessionListResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sessions [get]
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	schemas, err := h.store.LoadAllChatSessions()
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sessions := make([]SessionMetadata, 0, len(schemas))
+	for _, schema := range schemas {
+		session := &models.ChatSession{}
+		session.FromSchema(schema)
+		sessions = append(sessions, newSessionMetadata(session))
+	}
+
+	respondJSON(w, SessionListResponse{Sessions: sessions}, http.StatusOK)
+}
+
+// GetSessionTranscript godoc
+// @Summary Get a chat session transcript
+// @Description Get every prompt, model response and tool invocation (arguments, result summary, duration and error) recorded in a session
+// @Tags chat
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} SessionTranscriptResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/sessions/{id} [get]
+func (h *SessionHandler) GetSessionTranscript(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	schema, err := h.store.LoadChatSession(id)
+	if err != nil {
+		respondError(w, "Chat session not found", http.StatusNotFound)
+		return
+	}
+	session := &models.ChatSession{}
+	session.FromSchema(schema)
+
+	messageSchemas, err := h.store.LoadChatMessages(id)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load messages: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	invocationSchemas, err := h.store.LoadToolInvocations(id)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load tool invocations: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := SessionTranscriptResponse{
+		Session:         newSessionMetadata(session),
+		Messages:        make([]TranscriptMessage, 0, len(messageSchemas)),
+		ToolInvocations: make([]ToolInvocationInfo, 0, len(invocationSchemas)),
+	}
+
+	for _, ms := range messageSchemas {
+		msg := &models.ChatMessage{}
+		msg.FromSchema(ms)
+
+		tm := TranscriptMessage{
+			MessageId:        msg.MessageId,
+			Seq:              msg.Seq,
+			Role:             msg.Role,
+			Content:          msg.Content,
+			ToolCallId:       msg.ToolCallId,
+			Name:             msg.Name,
+			PromptTokens:     msg.PromptTokens,
+			CompletionTokens: msg.CompletionTokens,
+			WhenCreated:      msg.WhenCreated,
+		}
+		if msg.ToolCalls != nil {
+			tm.ToolCalls = json.RawMessage(*msg.ToolCalls)
+		}
+		response.Messages = append(response.Messages, tm)
+	}
+
+	for _, is := range invocationSchemas {
+		inv := &models.ToolInvocation{}
+		inv.FromSchema(is)
+		response.ToolInvocations = append(response.ToolInvocations, ToolInvocationInfo{
+			InvocationId:  inv.InvocationId,
+			MessageId:     inv.MessageId,
+			ToolCallId:    inv.ToolCallId,
+			ToolName:      inv.ToolName,
+			Arguments:     agent.ToolArguments([]byte(inv.Arguments)),
+			ResultSummary: inv.ResultSummary,
+			DurationMs:    inv.DurationMs,
+			Error:         inv.Error,
+			WhenCreated:   inv.WhenCreated,
+		})
+	}
+
+	respondJSON(w, response, http.StatusOK)
+}
+
+func newSessionMetadata(session *models.ChatSession) SessionMetadata {
+	return SessionMetadata{
+		SessionId:   session.SessionId,
+		Title:       session.Title,
+		CreatedBy:   session.CreatedBy,
+		WhenCreated: session.WhenCreated,
+		WhenUpdated: session.WhenUpdated,
+	}
+}
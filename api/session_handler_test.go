@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+func TestGetSessionTranscriptWrapsInvalidArguments(t *testing.T) {
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	session := &schemas.ChatSessionSchema{Title: "Boiler", WhenCreated: now, WhenUpdated: now}
+	if err := store.SaveChatSession(session); err != nil {
+		t.Fatalf("SaveChatSession: %v", err)
+	}
+	// Arguments stored before they were normalized can be truncated JSON
+	invocations := []*schemas.ToolInvocationSchema{
+		{ToolCallId: "call_1", ToolName: "query_data", Arguments: `{"datasource_id": 1`, WhenCreated: now},
+	}
+	if err := store.AppendChatTurn(session.SessionId, nil, invocations); err != nil {
+		t.Fatalf("AppendChatTurn: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/api/sessions/{id}", NewSessionHandler(store).GetSessionTranscript)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var response SessionTranscriptResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("transcript is not valid JSON: %v: %s", err, rec.Body.String())
+	}
+	var arguments string
+	if len(response.ToolInvocations) != 1 || json.Unmarshal(response.ToolInvocations[0].Arguments, &arguments) != nil {
+		t.Fatalf("tool invocations = %+v, want the arguments as a JSON string", response.ToolInvocations)
+	}
+	if arguments != `{"datasource_id": 1` {
+		t.Errorf("arguments = %q", arguments)
+	}
+}
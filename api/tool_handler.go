@@ -0,0 +1,397 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/jobs"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+	"github.com/nathanaday/iot-data-sandbox/internal/vault"
+)
+
+// maxToolArgsSize limits the size of a tool invocation request body
+const maxToolArgsSize = 1 << 20
+
+type ToolHandler struct {
+	store    *persistence.Store
+	executor *tools.Executor
+	secrets  *vault.Vault
+	jobs     *jobs.Manager
+}
+
+func NewToolHandler(store *persistence.Store, executor *tools.Executor, secrets *vault.Vault, jobManager *jobs.Manager) *ToolHandler {
+	return &ToolHandler{
+		store:    store,
+		executor: executor,
+		secrets:  secrets,
+		jobs:     jobManager,
+	}
+}
+
+type ToolListResponse struct {
+	Tools []ToolMetadata `json:"tools"`
+}
+
+type ToolMetadata struct {
+	ToolId       int64      `json:"tool_id"`
+	Name         string     `json:"name"`
+	FxName       string     `json:"fx_name"`
+	Description  string     `json:"description"`
+	Category     string     `json:"category"`
+	Version      string     `json:"version"`
+	TimeoutS     int        `json:"timeout_s"`
+	IsEnabled    bool       `json:"is_enabled"`
+	WhenLastCall *time.Time `json:"when_last_call,omitempty"`
+	NumCalls     int        `json:"num_calls"`
+	MaxCalls     *int       `json:"max_calls,omitempty"`
+	NumCallReset *int       `json:"num_call_reset,omitempty"`
+}
+
+type ToolCatalogResponse struct {
+	Tools []ToolSpec `json:"tools"`
+}
+
+type ToolSpec struct {
+	FxName       string          `json:"fx_name"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	Category     string          `json:"category"`
+	Version      string          `json:"version"`
+	InputSchema  json.RawMessage `json:"input_schema" swaggertype:"object"`
+	OutputSchema json.RawMessage `json:"output_schema,omitempty" swaggertype:"object"`
+}
+
+type ToolInvokeResponse struct {
+	FxName     string `json:"fx_name"`
+	Result     any    `json:"result"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ListTools godoc
+// @Summary List tools
+// @Description Get all tools with an implementation registered in this server, along with their call policy and usage counters
+// @Tags tools
+// @Produce json
+// @Success 200 {object} ToolListResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/tools [get]
+func (h *ToolHandler) ListTools(w http.ResponseWriter, r *http.Request) {
+	schemas, err := h.store.LoadAllTools()
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load tools: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	metadata := make([]ToolMetadata, 0, len(schemas))
+	for _, schema := range schemas {
+		if _, ok := h.executor.Registry().Lookup(schema.FxName); !ok {
+			continue
+		}
+
+		tool := &models.Tool{}
+		tool.FromSchema(schema)
+		metadata = append(metadata, ToolMetadata{
+			ToolId:       tool.ToolId,
+			Name:         tool.Name,
+			FxName:       tool.FxName,
+			Description:  tool.Description,
+			Category:     tool.Category,
+			Version:      tool.Version,
+			TimeoutS:     tool.TimeoutS,
+			IsEnabled:    tool.IsEnabled,
+			WhenLastCall: tool.WhenLastCall,
+			NumCalls:     tool.NumCalls,
+			MaxCalls:     tool.MaxCalls,
+			NumCallReset: tool.NumCallReset,
+		})
+	}
+
+	respondJSON(w, ToolListResponse{Tools: metadata}, http.StatusOK)
+}
+
+// GetToolCatalog godoc
+// @Summary Get the tool catalog
+// @Description Get the enabled tools with their JSON Schemas. Use format=openai or format=anthropic to get a tools array that can be passed directly into an LLM function-calling request.
+// @Tags tools
+// @Produce json
+// @Param format query string false "Output format: native (default), openai or anthropic"
+// @Success 200 {object} ToolCatalogResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/tools/catalog [get]
+func (h *ToolHandler) GetToolCatalog(w http.ResponseWriter, r *http.Request) {
+	defs, err := h.executor.EnabledDefinitions()
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load tools: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "native":
+		specs := make([]ToolSpec, 0, len(defs))
+		for _, def := range defs {
+			specs = append(specs, ToolSpec{
+				FxName:       def.FxName,
+				Name:         def.Name,
+				Description:  def.Description,
+				Category:     def.Category,
+				Version:      def.Version,
+				InputSchema:  def.InputSchema,
+				OutputSchema: def.OutputSchema,
+			})
+		}
+		respondJSON(w, ToolCatalogResponse{Tools: specs}, http.StatusOK)
+	case "openai":
+		respondJSON(w, tools.OpenAIFunctions(defs), http.StatusOK)
+	case "anthropic":
+		respondJSON(w, tools.AnthropicTools(defs), http.StatusOK)
+	default:
+		respondError(w, fmt.Sprintf("Unknown catalog format %q, use native, openai or anthropic", format), http.StatusBadRequest)
+	}
+}
+
+// InvokeTool godoc
+// @Summary Invoke a tool
+// @Description Run a tool with the JSON arguments in the request body. Arguments are validated against the tool's input schema. Calls are refused if the tool is disabled or has reached its call limit.
+// @Tags tools
+// @Accept json
+// @Produce json
+// @Param fxName path string true "Tool function name"
+// @Param args body object false "Tool arguments"
+// @Param async query bool false "Run in a background job and return 202 with the job"
+// @Success 200 {object} ToolInvokeResponse
+// @Success 202 {object} JobResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/tools/{fxName}/invoke [post]
+func (h *ToolHandler) InvokeTool(w http.ResponseWriter, r *http.Request) {
+	fxName := chi.URLParam(r, "fxName")
+
+	args, err := io.ReadAll(io.LimitReader(r.Body, maxToolArgsSize))
+	if err != nil {
+		respondError(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(args) > 0 && !json.Valid(args) {
+		respondError(w, "Request body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	if isAsync(r) {
+		if err := h.executor.Validate(fxName, args); err != nil {
+			respondError(w, err.Error(), toolErrorStatus(err))
+			return
+		}
+		job, err := h.jobs.Submit(r.Context(), jobs.KindToolInvoke, jobs.ToolInvokeParams{FxName: fxName, Arguments: args})
+		if err != nil {
+			respondError(w, fmt.Sprintf("Failed to queue tool invocation: %v", err), http.StatusInternalServerError)
+			return
+		}
+		respondAccepted(w, job)
+		return
+	}
+
+	started := time.Now()
+	result, err := h.executor.Execute(r.Context(), fxName, args)
+	if err != nil {
+		respondError(w, err.Error(), toolErrorStatus(err))
+		return
+	}
+
+	respondJSON(w, ToolInvokeResponse{
+		FxName:     fxName,
+		Result:     result,
+		DurationMs: time.Since(started).Milliseconds(),
+	}, http.StatusOK)
+}
+
+type ToolSecretListResponse struct {
+	FxName  string           `json:"fx_name"`
+	Secrets []ToolSecretInfo `json:"secrets"`
+}
+
+// ToolSecretInfo describes a stored credential. Secret values are never returned.
+type ToolSecretInfo struct {
+	Name        string    `json:"name"`
+	KeyId       string    `json:"key_id"`
+	WhenCreated time.Time `json:"when_created"`
+	WhenUpdated time.Time `json:"when_updated"`
+}
+
+type ToolSecretRequest struct {
+	Value string `json:"value"`
+}
+
+type VaultRotateResponse struct {
+	KeyId     string `json:"key_id"`
+	Rewrapped int    `json:"rewrapped"`
+}
+
+// ListToolSecrets godoc
+// @Summary List tool secrets
+// @Description List the names of the credentials stored for a tool and the master key each is wrapped with. Values are never returned.
+// @Tags tools
+// @Produce json
+// @Param fxName path string true "Tool function name"
+// @Success 200 {object} ToolSecretListResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/tools/{fxName}/secrets [get]
+func (h *ToolHandler) ListToolSecrets(w http.ResponseWriter, r *http.Request) {
+	tool, ok := h.loadTool(w, chi.URLParam(r, "fxName"))
+	if !ok {
+		return
+	}
+
+	infos, err := h.secrets.List(tool.ToolId)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to load secrets: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := ToolSecretListResponse{FxName: tool.FxName, Secrets: make([]ToolSecretInfo, 0, len(infos))}
+	for _, info := range infos {
+		response.Secrets = append(response.Secrets, ToolSecretInfo{
+			Name:        info.Name,
+			KeyId:       info.KeyId,
+			WhenCreated: info.WhenCreated,
+			WhenUpdated: info.WhenUpdated,
+		})
+	}
+	respondJSON(w, response, http.StatusOK)
+}
+
+// PutToolSecret godoc
+// @Summary Store a tool secret
+// @Description Encrypt and store a credential the tool uses to authenticate to a downstream service (for example api_key, username or password). The value is only decrypted by the tool executor when the tool runs.
+// @Tags tools
+// @Accept json
+// @Param fxName path string true "Tool function name"
+// @Param name path string true "Secret name"
+// @Param request body ToolSecretRequest true "Secret value"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/tools/{fxName}/secrets/{name} [put]
+func (h *ToolHandler) PutToolSecret(w http.ResponseWriter, r *http.Request) {
+	tool, ok := h.loadTool(w, chi.URLParam(r, "fxName"))
+	if !ok {
+		return
+	}
+
+	var req ToolSecretRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxToolArgsSize)).Decode(&req); err != nil {
+		respondError(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+	if req.Value == "" {
+		respondError(w, "value is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.secrets.Put(tool.ToolId, chi.URLParam(r, "name"), req.Value); err != nil {
+		if errors.Is(err, vault.ErrNoMasterKey) {
+			respondError(w, "No vault master key is configured", http.StatusServiceUnavailable)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to store secret: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteToolSecret godoc
+// @Summary Delete a tool secret
+// @Description Remove a stored credential from a tool
+// @Tags tools
+// @Param fxName path string true "Tool function name"
+// @Param name path string true "Secret name"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/tools/{fxName}/secrets/{name} [delete]
+func (h *ToolHandler) DeleteToolSecret(w http.ResponseWriter, r *http.Request) {
+	tool, ok := h.loadTool(w, chi.URLParam(r, "fxName"))
+	if !ok {
+		return
+	}
+
+	if err := h.secrets.Delete(tool.ToolId, chi.URLParam(r, "name")); err != nil {
+		if errors.Is(err, vault.ErrSecretNotFound) {
+			respondError(w, "Secret not found", http.StatusNotFound)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to delete secret: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateVaultKey godoc
+// @Summary Rotate the vault master key
+// @Description Rewrap every secret's data key with the current primary master key. Configure the new key as VAULT_MASTER_KEY with the old one in VAULT_PREVIOUS_KEYS, restart, call this endpoint, then drop the old key.
+// @Tags tools
+// @Produce json
+// @Success 200 {object} VaultRotateResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/vault/rotate [post]
+func (h *ToolHandler) RotateVaultKey(w http.ResponseWriter, r *http.Request) {
+	rewrapped, err := h.secrets.Rotate()
+	if err != nil {
+		if errors.Is(err, vault.ErrNoMasterKey) {
+			respondError(w, "No vault master key is configured", http.StatusServiceUnavailable)
+			return
+		}
+		respondError(w, fmt.Sprintf("Failed to rotate vault key (%d secrets rewrapped): %v", rewrapped, err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, VaultRotateResponse{KeyId: h.secrets.KeyId(), Rewrapped: rewrapped}, http.StatusOK)
+}
+
+func (h *ToolHandler) loadTool(w http.ResponseWriter, fxName string) (*models.Tool, bool) {
+	schema, err := h.store.LoadToolByFxName(fxName)
+	if err != nil {
+		respondError(w, "Tool not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	tool := &models.Tool{}
+	tool.FromSchema(schema)
+	return tool, true
+}
+
+func toolErrorStatus(err error) int {
+	var argErr *tools.ArgumentError
+	switch {
+	case errors.As(err, &argErr):
+		return http.StatusBadRequest
+	case errors.Is(err, tools.ErrUnknownTool):
+		return http.StatusNotFound
+	case errors.Is(err, persistence.ErrToolDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, persistence.ErrToolCallLimit):
+		return http.StatusTooManyRequests
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, tools.ErrMissingSecret), errors.Is(err, vault.ErrNoMasterKey):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
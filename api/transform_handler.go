@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+)
+
+type TransformHandler struct {
+	loader *dataset.Loader
+}
+
+func NewTransformHandler(loader *dataset.Loader) *TransformHandler {
+	return &TransformHandler{loader: loader}
+}
+
+type TransformRequest struct {
+	// Transform is rate, difference, integral, counter_delta, running_total or
+	// period_total
+	Transform string `json:"transform"`
+	// Per is the time unit of rates (1s by default) and integrals (1h by default)
+	Per string `json:"per,omitempty"`
+	// Rollover is the value at which a counter wraps to zero, e.g. 65536
+	Rollover float64 `json:"rollover,omitempty"`
+	// Period of period totals: hour, day, week, month, quarter or year
+	Period string `json:"period,omitempty"`
+	Range  string `json:"range,omitempty"`
+	// StartTime and EndTime override the start and end of Range
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+	// Timezone is also the one period totals restart in
+	Timezone string `json:"tz,omitempty"`
+	Unit     string `json:"unit,omitempty"`
+	// SaveAs saves the transformed series as a new datasource with this name
+	SaveAs string `json:"save_as,omitempty"`
+}
+
+type TransformResponse struct {
+	Series    AnalysisSeries `json:"series"`
+	Transform string         `json:"transform"`
+	// Unit of the transformed values, empty if the registry has none for them
+	Unit     string `json:"unit,omitempty"`
+	Per      string `json:"per,omitempty"`
+	Period   string `json:"period,omitempty"`
+	RowCount int    `json:"row_count"`
+	// Total is the change or consumption over the range, absent for rates
+	Total *float64 `json:"total,omitempty"`
+	// Resets, Rollovers and Glitches count the decreases of a counter
+	Resets    int                 `json:"resets,omitempty"`
+	Rollovers int                 `json:"rollovers,omitempty"`
+	Glitches  int                 `json:"glitches,omitempty"`
+	Data      []DataPoint         `json:"data"`
+	Saved     *DataSourceMetadata `json:"saved,omitempty"`
+}
+
+// TransformDataSource godoc
+// @Summary Transform a datasource into rates, integrals or totals
+// @Description Derive a series from a datasource: rate is the change per unit of time since the previous reading, difference the change itself, and integral the area under the series by the trapezoidal rule, so power in kW integrated per hour gives energy in kWh. counter_delta turns the readings of a cumulative counter, such as an energy or flow meter register, into the consumption between readings: a drop from the top tenth of rollover to its bottom tenth is taken as the counter wrapping around, a drop to less than half the previous reading as a reset to zero, and smaller drops as glitches that are ignored. running_total sums the values so far and period_total sums them since the start of each calendar period in tz. With save_as the result is saved as a new datasource.
+// @Tags datasources
+// @Accept json
+// @Produce json
+// @Param id path int true "Datasource ID"
+// @Param request body TransformRequest true "Transformation"
+// @Success 200 {object} TransformResponse
+// @Success 201 {object} TransformResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/datasources/{id}/transform [post]
+func (h *TransformHandler) TransformDataSource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, "Invalid datasource ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TransformRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.SaveAs = strings.TrimSpace(req.SaveAs)
+	if len(req.SaveAs) > maxDataSourceNameLength {
+		respondError(w, fmt.Sprintf("save_as is longer than %d characters", maxDataSourceNameLength), http.StatusBadRequest)
+		return
+	}
+
+	transform, err := timeseries.ParseTransform(req.Transform)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid transform: %v", err), http.StatusBadRequest)
+		return
+	}
+	opts := dataset.TransformOptions{Transform: transform, Rollover: req.Rollover}
+	if opts.Per, err = parseDurationValue("per", req.Per); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Rollover < 0 {
+		respondError(w, "Invalid rollover, want a positive counter value", http.StatusBadRequest)
+		return
+	}
+
+	loc, err := timerange.LoadLocation(req.Timezone)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid tz: %v", err), http.StatusBadRequest)
+		return
+	}
+	if transform == timeseries.TransformPeriodTotal {
+		if req.Period == "" {
+			respondError(w, "Invalid period, period_total needs one of hour, day, week, month, quarter or year", http.StatusBadRequest)
+			return
+		}
+		if opts.PeriodStart, err = timerange.PeriodStart(req.Period, loc); err != nil {
+			respondError(w, fmt.Sprintf("Invalid period: %v", err), http.StatusBadRequest)
+			return
+		}
+		opts.Period = strings.ToLower(strings.TrimSpace(req.Period))
+	}
+	startTime, endTime, err := timerange.Resolve(req.Range, req.StartTime, req.EndTime, time.Now(), loc)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Invalid time range: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.loader.Transform(r.Context(), id, startTime, endTime, opts, req.Unit)
+	if err != nil {
+		respondDataError(w, err)
+		return
+	}
+
+	response := TransformResponse{
+		Series:    AnalysisSeries{DataSourceId: result.DataSource.DataSourceId, Name: result.DataSource.Name, Unit: result.SourceUnit},
+		Transform: string(transform),
+		Unit:      result.Unit,
+		Period:    result.Options.Period,
+		RowCount:  len(result.Points),
+		Total:     result.Total,
+		Resets:    result.Counter.Resets,
+		Rollovers: result.Counter.Rollovers,
+		Glitches:  result.Counter.Glitches,
+		Data:      make([]DataPoint, len(result.Points)),
+	}
+	if result.Options.Per > 0 {
+		response.Per = analysis.FormatDuration(result.Options.Per)
+	}
+	for i, p := range result.Points {
+		response.Data[i] = DataPoint{Timestamp: p.Timestamp, Value: p.Value}
+	}
+
+	if req.SaveAs == "" {
+		respondJSON(w, response, http.StatusOK)
+		return
+	}
+	ds, err := h.loader.SaveTransformed(r.Context(), req.SaveAs, result)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to save datasource: %v", err), http.StatusInternalServerError)
+		return
+	}
+	saved := newDataSourceMetadata(ds)
+	response.Saved = &saved
+	respondJSON(w, response, http.StatusCreated)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+type UnitHandler struct{}
+
+func NewUnitHandler() *UnitHandler {
+	return &UnitHandler{}
+}
+
+type UnitListResponse struct {
+	Units []UnitInfo `json:"units"`
+}
+
+type UnitInfo struct {
+	Symbol    string   `json:"symbol"`
+	Name      string   `json:"name"`
+	Dimension string   `json:"dimension"`
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+// ListUnits godoc
+// @Summary List units
+// @Description List the units a datasource can be recorded in and converted to. Values convert between units of the same dimension (temperature, pressure, energy, power, flow, speed, ratio).
+// @Tags units
+// @Produce json
+// @Param dimension query string false "Only units of this dimension"
+// @Success 200 {object} UnitListResponse
+// @Router /api/units [get]
+func (h *UnitHandler) ListUnits(w http.ResponseWriter, r *http.Request) {
+	dimension := r.URL.Query().Get("dimension")
+
+	infos := make([]UnitInfo, 0)
+	for _, u := range units.All() {
+		if dimension != "" && string(u.Dimension) != dimension {
+			continue
+		}
+		infos = append(infos, UnitInfo{
+			Symbol:    u.Symbol,
+			Name:      u.Name,
+			Dimension: string(u.Dimension),
+			Aliases:   u.Aliases,
+		})
+	}
+
+	respondJSON(w, UnitListResponse{Units: infos}, http.StatusOK)
+}
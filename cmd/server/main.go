@@ -1,11 +1,30 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/nathanaday/iot-data-sandbox/api"
+	"github.com/nathanaday/iot-data-sandbox/internal/agent"
+	"github.com/nathanaday/iot-data-sandbox/internal/auth"
+	"github.com/nathanaday/iot-data-sandbox/internal/config"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/jobs"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/mcpserver"
+	"github.com/nathanaday/iot-data-sandbox/internal/metrics"
 	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
 	"github.com/nathanaday/iot-data-sandbox/internal/storage"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"github.com/nathanaday/iot-data-sandbox/internal/vault"
 
 	_ "github.com/nathanaday/iot-data-sandbox/docs"
 )
@@ -21,25 +40,217 @@ import (
 // @termsOfService http://swagger.io/terms/
 // @contact.name API Support
 // @license.name MIT
-// @host localhost:8080
 // @BasePath /
+// @security ApiKeyAuth
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API key sent as "Bearer <key>". Create the first key with the -create-admin-key flag.
 
 func main() {
-	store, err := persistence.NewStore("./sandbox.db")
+	mcpStdio := flag.Bool("mcp-stdio", false, "Serve the Model Context Protocol over stdin/stdout instead of starting the HTTP server")
+	generateVaultKey := flag.Bool("generate-vault-key", false, "Print a new random vault master key and exit")
+	createAdminKey := flag.String("create-admin-key", "", "Create an admin API key for the given username (creating the user if needed), print it and exit")
+	migrateStatus := flag.Bool("migrate-status", false, "Print the database schema migrations and whether each is applied, then exit")
+	migrateTo := flag.Int("migrate-to", -1, "Migrate the database schema up to the given version, then exit")
+	configFlags := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	if *generateVaultKey {
+		key, err := vault.GenerateKey()
+		if err != nil {
+			log.Fatalf("Failed to generate vault key: %v", err)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	cfg, err := config.Load(configFlags)
 	if err != nil {
-		log.Fatalf("Failed to initialize store: %v", err)
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if _, err := logging.Setup(os.Stderr, cfg.Log); err != nil {
+		log.Fatalf("Invalid log settings: %v", err)
+	}
+	slog.Info("Configuration loaded", "config", cfg.String())
+
+	if *migrateStatus || *migrateTo >= 0 {
+		if err := runMigrationCommand(cfg.Database.Path, *migrateStatus, *migrateTo); err != nil {
+			fatal("Migration failed", err)
+		}
+		return
+	}
+
+	store, err := persistence.NewStore(cfg.Database.Path)
+	if err != nil {
+		fatal("Failed to initialize store", err)
+	}
+
+	authenticator := auth.NewAuthenticator(store)
+	if *createAdminKey != "" {
+		token, err := authenticator.BootstrapAdmin(*createAdminKey)
+		if err != nil {
+			fatal("Failed to create admin key", err)
+		}
+		fmt.Println(token)
+		store.Close()
+		return
+	}
+	if hasUsers, err := authenticator.HasUsers(); err != nil {
+		fatal("Failed to load users", err)
+	} else if !hasUsers {
+		slog.Warn("No users exist yet; create an admin API key with -create-admin-key <username>")
+	}
+
+	fileStore, err := storage.NewFileStore(cfg.Storage.Dir)
+	if err != nil {
+		fatal("Failed to initialize file store", err)
+	}
+	slog.Info("File storage initialized", "dir", fileStore.GetBaseDir())
+	metrics.Registry.MustRegister(metrics.NewStateCollector(store, fileStore))
+
+	loader := dataset.NewLoader(store, fileStore)
+
+	registry := tools.NewRegistry()
+	if err := tools.RegisterBuiltins(registry, store, loader); err != nil {
+		fatal("Failed to register built-in tools", err)
+	}
+	if err := registry.Sync(store); err != nil {
+		fatal("Failed to sync tools", err)
+	}
+	keyring, err := vault.LoadKeyring()
+	if err != nil {
+		fatal("Failed to load vault master key", err)
+	}
+	secrets := vault.NewVault(store, keyring)
+	if secrets.Configured() {
+		slog.Info("Vault master key loaded", "key_id", keyring.PrimaryId())
+	} else {
+		slog.Warn(fmt.Sprintf("No vault master key configured (%s or %s); tool secrets are unavailable", vault.EnvMasterKey, vault.EnvMasterKeyFile))
+	}
+
+	executor := tools.NewExecutor(store, registry, secrets)
+
+	mcpServer := mcpserver.NewServer(store, loader, executor, "1.0")
+	if *mcpStdio {
+		if err := mcpServer.ServeStdio(); err != nil {
+			fatal("MCP stdio server failed", err)
+		}
+		store.Close()
+		return
 	}
-	defer store.Close()
 
-	fileStore, err := storage.NewFileStore()
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatalf("Failed to initialize file store: %v", err)
+		fatal("Failed to initialize tracing", err)
 	}
-	log.Printf("File storage initialized at: %s", fileStore.GetBaseDir())
+	if cfg.Tracing.Exporter != config.TracingExporterNone {
+		slog.Info("Exporting traces", "exporter", cfg.Tracing.Exporter, "endpoint", cfg.Tracing.Endpoint)
+	}
+
+	provider, err := agent.NewProvider(cfg.LLM.Provider, agent.ProviderConfig{
+		Backend: cfg.LLM.Backend,
+		Model:   cfg.LLM.Model,
+		APIKey:  cfg.LLM.APIKey,
+		BaseURL: cfg.LLM.BaseURL,
+	})
+	if err != nil {
+		fatal("Failed to initialize LLM provider", err)
+	}
+	slog.Info("Agent LLM provider initialized", "llm_provider", provider.Name())
+	chatAgent := agent.NewAgent(provider, executor, agent.Config{})
+	sessions := agent.NewSessions(store, chatAgent)
+
+	jobManager := jobs.NewManager(store, cfg.Jobs.Workers)
+	jobs.RegisterBuiltinKinds(jobManager, executor, loader)
+	if err := jobManager.Start(); err != nil {
+		fatal("Failed to start job manager", err)
+	}
+
+	router := api.SetupRouter(cfg, store, fileStore, loader, executor, secrets, authenticator, jobManager, sessions, mcpServer)
+	server := api.NewServer(cfg.Server, router)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		fatal("Failed to start server", err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process without waiting for the drain
+	stop()
+
+	timeout := time.Duration(cfg.Server.ShutdownTimeout)
+	slog.Info("Shutting down, draining requests and jobs", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop taking requests first, since they may queue jobs, then drain the job
+	// workers, which write to the file store and the database
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP server did not drain in time", "error", err)
+	}
+	if err := jobManager.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Job workers did not drain in time; interrupted jobs resume on the next start", "error", err)
+	}
+	if err := fileStore.Close(); err != nil {
+		slog.Error("Failed to close file store", "error", err)
+	}
+	if err := store.Close(); err != nil {
+		slog.Error("Failed to close store", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Server stopped")
+}
 
-	router := api.SetupRouter(store, fileStore)
-	err = api.ListenAndServe(":8080", router)
+// runMigrationCommand migrates the database to target, if target is not negative,
+// and prints the migration status if requested
+func runMigrationCommand(dbPath string, status bool, target int) error {
+	store, err := persistence.OpenStore(dbPath)
 	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		return err
 	}
+	defer store.Close()
+
+	if target >= 0 {
+		applied, err := store.Migrate(target)
+		for _, version := range applied {
+			slog.Info("Applied migration", "version", version)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			slog.Info("Database is already at the target schema version", "version", target)
+		}
+	}
+
+	if status {
+		statuses, err := store.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, m := range statuses {
+			applied := "pending"
+			if m.WhenApplied != nil {
+				applied = "applied " + m.WhenApplied.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-30s %s\n", m.Version, m.Name, applied)
+		}
+	}
+	return nil
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
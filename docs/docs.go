@@ -21,21 +21,84 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/datasources": {
+        "/api/analysis/autocorrelation": {
             "get": {
-                "description": "Get a list of all registered datasources with their metadata",
+                "description": "Describe how a datasource resampled onto a regular grid relates to its own past: the autocorrelation (ACF) and partial autocorrelation (PACF) at each lag with their 95% confidence bands, a Ljung-Box test of whether the series is white noise, and the seasonal periods it repeats with. Seasonal periods are peaks of the periodogram confirmed by the autocorrelation at their lag, snapped to an hour, half a day, a day, a week or a year when close to one.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "datasources"
+                    "analysis"
+                ],
+                "summary": "Autocorrelation and seasonality of a datasource",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Datasource ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1",
+                        "name": "range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start time (RFC3339 or expression such as now-3d); overrides the start of range",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End time (RFC3339 or expression such as now); overrides the end of range",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone used to resolve expressions (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Grid step, e.g. 1s or 1h (default the median sampling interval)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Largest lag of the ACF and PACF, e.g. 2d (default a quarter of the window, at most 200 steps)",
+                        "name": "max_lag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Lags of the Ljung-Box test, 1 to 1000 (default 10)",
+                        "name": "ljung_box_lags",
+                        "in": "query"
+                    }
                 ],
-                "summary": "List all datasources",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.DataSourceListResponse"
+                            "$ref": "#/definitions/api.AutocorrelationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
                     "500": {
@@ -45,39 +108,87 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Upload a CSV file containing time series data. The CSV must have 'timestamp' and 'value' columns. Supports various timestamp formats (ISO8601, Unix, Julian Day).",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+            }
+        },
+        "/api/analysis/correlation": {
+            "get": {
+                "description": "Relate two datasources over a time window: Pearson and Spearman correlation, cross-correlation over a range of lags with the best lag, an optional rolling correlation, and Granger causality tests in both directions. Both are resampled onto a common grid with linear interpolation, limited to the time they overlap. A positive lag means x leads y.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "datasources"
+                    "analysis"
                 ],
-                "summary": "Upload a CSV datasource",
+                "summary": "Correlate two datasources",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "CSV file to upload",
-                        "name": "file",
-                        "in": "formData",
+                        "type": "integer",
+                        "description": "First datasource ID",
+                        "name": "x",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Second datasource ID",
+                        "name": "y",
+                        "in": "query",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Name for the datasource (defaults to filename)",
-                        "name": "name",
-                        "in": "formData"
+                        "description": "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1",
+                        "name": "range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start time (RFC3339 or expression such as now-3d); overrides the start of range",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End time (RFC3339 or expression such as now); overrides the end of range",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone used to resolve expressions (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Grid step, e.g. 1m or 1h (default the coarser sampling interval of the two)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Largest lag of the cross-correlation, e.g. 2h (default a quarter of the window, at most 200 steps)",
+                        "name": "max_lag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Window of a rolling correlation, e.g. 1d",
+                        "name": "rolling_window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Lags of the Granger tests, 1 to 24 (default the best lag)",
+                        "name": "granger_lags",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.UploadResponse"
+                            "$ref": "#/definitions/api.CorrelationResponse"
                         }
                     },
                     "400": {
@@ -86,6 +197,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -95,30 +212,84 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/datasources/{id}": {
+        "/api/analysis/spectrum": {
             "get": {
-                "description": "Get metadata for a specific datasource by ID",
+                "description": "Estimate the power spectrum of a datasource resampled onto a regular grid, and report its dominant periods in human units such as 24h, 7d or 12 months. The periodogram transforms the whole window for the finest resolution; Welch averages overlapping segments for a less noisy estimate. Power is in squared value units per hertz.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "datasources"
+                    "analysis"
                 ],
-                "summary": "Get datasource metadata",
+                "summary": "Find cycles in a datasource",
                 "parameters": [
                     {
                         "type": "integer",
                         "description": "Datasource ID",
                         "name": "id",
-                        "in": "path",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1",
+                        "name": "range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start time (RFC3339 or expression such as now-3d); overrides the start of range",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End time (RFC3339 or expression such as now); overrides the end of range",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone used to resolve expressions (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Grid step, e.g. 1s or 1h (default the median sampling interval)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "periodogram (default) or welch",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Length of the Welch segments, e.g. 7d (default a quarter of the window)",
+                        "name": "segment",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of dominant periods to report (default 5, at most 50)",
+                        "name": "peaks",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also return the spectrum",
+                        "name": "include_spectrum",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.DataSourceMetadata"
+                            "$ref": "#/definitions/api.SpectrumResponse"
                         }
                     },
                     "400": {
@@ -132,27 +303,72 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a datasource and its associated CSV file",
+            }
+        },
+        "/api/auth/me": {
+            "get": {
+                "description": "Get the user and scopes of the API key used for the request",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "datasources"
+                    "auth"
                 ],
-                "summary": "Delete a datasource",
+                "summary": "Get the caller",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PrincipalResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chat": {
+            "post": {
+                "description": "Send a natural-language prompt to the agent. The agent calls the enabled tools as needed and returns its answer together with the tool calls it made and the artifacts those calls produced. Pass session_id to continue an earlier session with its prior context; otherwise a new session is created.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Ask the agent",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Datasource ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Prompt",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ChatRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ChatResponse"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -175,42 +391,46 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/datasources/{id}/data": {
+        "/api/chat/stream": {
             "get": {
-                "description": "Query time series data from a datasource with optional time range filtering",
-                "produces": [
+                "description": "Same as POST /api/chat, but the response is a text/event-stream of typed events: session (session_id), token (a chunk of the model's reply), tool_start (id, name, arguments), tool_finish (the tool call record with its result or error), artifact (an intermediate result), error and finally done (the full chat response). GET is supported for EventSource clients, with the prompt in the message and session_id query parameters. Closing the connection cancels the agent.",
+                "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "text/event-stream"
+                ],
                 "tags": [
-                    "datasources"
+                    "chat"
                 ],
-                "summary": "Query time series data",
+                "summary": "Ask the agent and stream its progress",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Datasource ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Prompt (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.ChatRequest"
+                        }
                     },
                     {
                         "type": "string",
-                        "description": "Start time in RFC3339 format (e.g., 2024-01-01T00:00:00Z)",
-                        "name": "start_time",
+                        "description": "Prompt (GET)",
+                        "name": "message",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "End time in RFC3339 format (e.g., 2024-01-01T23:59:59Z)",
-                        "name": "end_time",
+                        "type": "integer",
+                        "description": "Session to continue (GET)",
+                        "name": "session_id",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Event stream",
                         "schema": {
-                            "$ref": "#/definitions/api.DataQueryResponse"
+                            "type": "string"
                         }
                     },
                     "400": {
@@ -232,82 +452,3105 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "api.DataPoint": {
-            "type": "object",
-            "properties": {
-                "timestamp": {
-                    "type": "string"
-                },
-                "value": {
-                    "type": "number"
-                }
-            }
-        },
-        "api.DataQueryResponse": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/api.DataPoint"
-                    }
-                },
+            },
+            "post": {
+                "description": "Same as POST /api/chat, but the response is a text/event-stream of typed events: session (session_id), token (a chunk of the model's reply), tool_start (id, name, arguments), tool_finish (the tool call record with its result or error), artifact (an intermediate result), error and finally done (the full chat response). GET is supported for EventSource clients, with the prompt in the message and session_id query parameters. Closing the connection cancels the agent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Ask the agent and stream its progress",
+                "parameters": [
+                    {
+                        "description": "Prompt (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.ChatRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Prompt (GET)",
+                        "name": "message",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Session to continue (GET)",
+                        "name": "session_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/datasources": {
+            "get": {
+                "description": "List registered datasources with their metadata. Datasources can be filtered by tags, name and time coverage, and are returned in pages. Time bounds accept the same expressions as the data query.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "List datasources",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Tag the datasource must have, as key:value or just key for any value; repeat to require several",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive substring of the name",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only datasources whose data overlaps this time range expression (e.g., last 7 days, 2024-Q1)",
+                        "name": "range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only datasources with data at or after this time",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only datasources with data at or before this time",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone used to resolve expressions (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by name, when_created, start_time, end_time or row_count; prefix with - for descending (default -when_created)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of datasources to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.DataSourceListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Upload a CSV file containing time series data. The CSV must have 'timestamp' and 'value' columns. Supports various timestamp formats (ISO8601, Unix, Julian Day).",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Upload a CSV datasource",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV file to upload",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name for the datasource (defaults to filename)",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unit of the values, from GET /api/units (e.g., degC, kW, psi)",
+                        "name": "unit",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Ingest in a background job and return 202 with the job",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/datasources/align": {
+            "get": {
+                "description": "Join several datasources into one table on common timestamps: the union of their timestamps, or a regular grid when interval is set. Each datasource is sampled with the chosen interpolation; linear draws a line between neighbouring points, previous carries the last value forward, nearest takes the closest point and none only uses points at the timestamp. tolerance is how far from a timestamp the points used for it may be (with none, how far a match may be). Values without a close enough point are null.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Align datasources",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated datasource IDs, e.g. 1,2,3",
+                        "name": "ids",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Grid step, e.g. 30s, 5m, 1h or 1d; omit to use the union of timestamps",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "linear (default), previous, nearest or none",
+                        "name": "interpolation",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum distance to the points used for a value, e.g. 10m",
+                        "name": "tolerance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1",
+                        "name": "range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start time (RFC3339 or expression such as now-3d); overrides the start of range",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End time (RFC3339 or expression such as now); overrides the end of range",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone used to resolve expressions (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Convert every datasource to this unit, e.g. degF; see GET /api/units",
+                        "name": "unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out rows where any datasource has no value",
+                        "name": "drop_incomplete",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of rows",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.AlignResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/datasources/virtual": {
+            "post": {
+                "description": "Save an expression over other datasources, such as ds(3) * 1.8 + 32 or ds(4) - ds(5), as a datasource that is recomputed whenever it is read. Inputs are aligned on the union of their timestamps with linear interpolation. The unit defaults to the unit of the expression's result.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Create a virtual datasource",
+                "parameters": [
+                    {
+                        "description": "Virtual datasource",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CreateVirtualDataSourceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.DataSourceMetadata"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/datasources/{id}": {
+            "get": {
+                "description": "Get metadata for a specific datasource by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Get datasource metadata",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Datasource ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.DataSourceMetadata"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a datasource and its associated CSV file. Virtual datasources built on it fail to read afterwards.",
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Delete a datasource",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Datasource ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change the name, description, unit, sensor type, location or tags of a datasource. The unit must be one of GET /api/units. Only the fields present in the body change. Tags are merged into the existing ones, and a null tag value removes the tag. Tag keys may contain letters, digits and _ . - /.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Update datasource metadata",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Datasource ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.UpdateDataSourceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.DataSourceMetadata"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/datasources/{id}/data": {
+            "get": {
+                "description": "Query time series data from a datasource with optional time range filtering. Time bounds accept RFC3339 timestamps or relative expressions resolved against the current time in tz; start_time and end_time override the corresponding end of range.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Query time series data",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Datasource ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time range expression (e.g., last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1)",
+                        "name": "range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start time in RFC3339 or as an expression (e.g., 2024-01-01T00:00:00Z, now-3d, yesterday)",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End time in RFC3339 or as an expression (e.g., 2024-01-01T23:59:59Z, now, today)",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA timezone used to resolve expressions (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Convert values to this unit (e.g., degF); the datasource must have a unit of the same dimension",
+                        "name": "unit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.DataQueryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/datasources/{id}/impute": {
+            "post": {
+                "description": "Put a datasource on a regular grid and fill its gaps: linear draws a line across the gap, spline follows a natural cubic spline through the known values, locf carries the last observation forward, seasonal repeats the value one season earlier and rolling_mean takes the mean of the known values in a window around each missing time. Gaps longer than max_gap, measured between the values around them, are left empty, as are the times before the first and after the last value. Every filled value is flagged. With save_as the result is saved as a new datasource.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Fill the gaps of a datasource",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Datasource ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Imputation",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ImputeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ImputeResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.ImputeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/datasources/{id}/transform": {
+            "post": {
+                "description": "Derive a series from a datasource: rate is the change per unit of time since the previous reading, difference the change itself, and integral the area under the series by the trapezoidal rule, so power in kW integrated per hour gives energy in kWh. counter_delta turns the readings of a cumulative counter, such as an energy or flow meter register, into the consumption between readings: a drop from the top tenth of rollover to its bottom tenth is taken as the counter wrapping around, a drop to less than half the previous reading as a reset to zero, and smaller drops as glitches that are ignored. running_total sums the values so far and period_total sums them since the start of each calendar period in tz. With save_as the result is saved as a new datasource.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "datasources"
+                ],
+                "summary": "Transform a datasource into rates, integrals or totals",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Datasource ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Transformation",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TransformRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TransformResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.TransformResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/expressions/evaluate": {
+            "post": {
+                "description": "Compute a series from other datasources without saving it, e.g. ds(3) * 1.8 + 32, ds(4) - ds(5) or max(ds(1), ds(2, \"degC\")). Inputs are aligned on the union of their timestamps with linear interpolation, and times where any input has no data are left out. Adding, subtracting or comparing values of different dimensions is rejected. Functions: abs, round, floor, ceil, sqrt, exp, log, log10, pow, min, max, clamp, rate.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expressions"
+                ],
+                "summary": "Evaluate an expression",
+                "parameters": [
+                    {
+                        "description": "Expression and time range",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.EvaluateExpressionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.EvaluateExpressionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs": {
+            "get": {
+                "description": "Get background jobs, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "List jobs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only jobs with this status (queued, running, succeeded, failed, cancelled)",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.JobListResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Queue a long-running job. Kinds: tool_invoke runs a tool (params: fx_name, arguments). Uploads are ingested in the background with POST /api/datasources?async=true instead. Poll GET /api/jobs/{id} for progress and the result.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Submit a background job",
+                "parameters": [
+                    {
+                        "description": "Job kind and params",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.JobRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}": {
+            "get": {
+                "description": "Get the status, progress and, once finished, the result or error of a background job",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get a job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/cancel": {
+            "post": {
+                "description": "Cancel a queued or running job. Running jobs are stopped at their next cancellation point and then reported as cancelled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Cancel a job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/events": {
+            "get": {
+                "description": "Stream a job's progress as text/event-stream. Events: status (the job, sent first and on every status change), progress (job_id, progress, message) and done (the finished job, including its result or error). The stream ends after done.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Stream job progress",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/keys": {
+            "get": {
+                "description": "Get the caller's API keys, newest first. Admins may list another user's keys with user_id, or every key by omitting it. Keys themselves are never returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "List API keys",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID (admin only)",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ApiKeyListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Issue an API key with a set of scopes (read, write, admin). The key is only returned in this response. A key can only grant scopes its creator holds; keys for other users require the admin scope.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "Key name, scopes and optional expiry",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ApiKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.ApiKeyCreatedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/keys/{id}": {
+            "delete": {
+                "description": "Revoke one of the caller's API keys. Admins may revoke any key.",
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sessions": {
+            "get": {
+                "description": "Get all agent chat sessions, most recently active first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "List chat sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SessionListResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sessions/{id}": {
+            "get": {
+                "description": "Get every prompt, model response and tool invocation (arguments, result summary, duration and error) recorded in a session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Get a chat session transcript",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SessionTranscriptResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sessions/{id}/chat": {
+            "post": {
+                "description": "Send a prompt to an existing session. The agent sees the session's earlier prompts, responses and tool results as context.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "Continue a chat session",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Prompt (session_id is taken from the path)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ChatRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ChatResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools": {
+            "get": {
+                "description": "Get all tools with an implementation registered in this server, along with their call policy and usage counters",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tools"
+                ],
+                "summary": "List tools",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ToolListResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools/catalog": {
+            "get": {
+                "description": "Get the enabled tools with their JSON Schemas. Use format=openai or format=anthropic to get a tools array that can be passed directly into an LLM function-calling request.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tools"
+                ],
+                "summary": "Get the tool catalog",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Output format: native (default), openai or anthropic",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ToolCatalogResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools/{fxName}/invoke": {
+            "post": {
+                "description": "Run a tool with the JSON arguments in the request body. Arguments are validated against the tool's input schema. Calls are refused if the tool is disabled or has reached its call limit.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tools"
+                ],
+                "summary": "Invoke a tool",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tool function name",
+                        "name": "fxName",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tool arguments",
+                        "name": "args",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run in a background job and return 202 with the job",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ToolInvokeResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools/{fxName}/secrets": {
+            "get": {
+                "description": "List the names of the credentials stored for a tool and the master key each is wrapped with. Values are never returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tools"
+                ],
+                "summary": "List tool secrets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tool function name",
+                        "name": "fxName",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ToolSecretListResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools/{fxName}/secrets/{name}": {
+            "put": {
+                "description": "Encrypt and store a credential the tool uses to authenticate to a downstream service (for example api_key, username or password). The value is only decrypted by the tool executor when the tool runs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tools"
+                ],
+                "summary": "Store a tool secret",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tool function name",
+                        "name": "fxName",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Secret name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Secret value",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ToolSecretRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a stored credential from a tool",
+                "tags": [
+                    "tools"
+                ],
+                "summary": "Delete a tool secret",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tool function name",
+                        "name": "fxName",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Secret name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/units": {
+            "get": {
+                "description": "List the units a datasource can be recorded in and converted to. Values convert between units of the same dimension (temperature, pressure, energy, power, flow, speed, ratio).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "units"
+                ],
+                "summary": "List units",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only units of this dimension",
+                        "name": "dimension",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.UnitListResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users": {
+            "get": {
+                "description": "Get all users. Requires the admin scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "List users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.UserListResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a user. Issue keys for the user with POST /api/keys. Requires the admin scope.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Create a user",
+                "parameters": [
+                    {
+                        "description": "Username",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.UserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/vault/rotate": {
+            "post": {
+                "description": "Rewrap every secret's data key with the current primary master key. Configure the new key as VAULT_MASTER_KEY with the old one in VAULT_PREVIOUS_KEYS, restart, call this endpoint, then drop the old key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tools"
+                ],
+                "summary": "Rotate the vault master key",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.VaultRotateResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "agent.Artifact": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "tool": {
+                    "type": "string"
+                },
+                "tool_call_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "agent.ToolCallRecord": {
+            "type": "object",
+            "properties": {
+                "arguments": {
+                    "type": "object"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "result": {}
+            }
+        },
+        "agent.Usage": {
+            "type": "object",
+            "properties": {
+                "completion_tokens": {
+                    "type": "integer"
+                },
+                "prompt_tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "analysis.CorrelationInfo": {
+            "type": "object",
+            "properties": {
+                "n": {
+                    "type": "integer"
+                },
+                "p_value": {
+                    "type": "number"
+                },
+                "r": {
+                    "description": "R is null when the correlation is undefined, e.g. for a constant series",
+                    "type": "number"
+                }
+            }
+        },
+        "analysis.GrangerInfo": {
+            "type": "object",
+            "properties": {
+                "cause": {
+                    "description": "Cause is tested for helping predict Effect",
+                    "type": "integer"
+                },
+                "df_den": {
+                    "type": "integer"
+                },
+                "df_num": {
+                    "type": "integer"
+                },
+                "effect": {
+                    "type": "integer"
+                },
+                "f": {
+                    "type": "number"
+                },
+                "lag": {
+                    "type": "string"
+                },
+                "lags": {
+                    "type": "integer"
+                },
+                "p_value": {
+                    "type": "number"
+                },
+                "significant": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "analysis.LagCorrelationInfo": {
+            "type": "object",
+            "properties": {
+                "lag": {
+                    "type": "string"
+                },
+                "lag_seconds": {
+                    "type": "number"
+                },
+                "lag_steps": {
+                    "type": "integer"
+                },
+                "n": {
+                    "type": "integer"
+                },
+                "r": {
+                    "type": "number"
+                }
+            }
+        },
+        "analysis.LjungBoxInfo": {
+            "type": "object",
+            "properties": {
+                "autocorrelated": {
+                    "description": "Autocorrelated is set when the p-value rejects white noise at 5%",
+                    "type": "boolean"
+                },
+                "lag": {
+                    "type": "string"
+                },
+                "lags": {
+                    "type": "integer"
+                },
+                "p_value": {
+                    "type": "number"
+                },
+                "q": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.AlignResponse": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.AlignedColumn"
+                    }
+                },
+                "interpolation": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "row_count": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.AlignedRow"
+                    }
+                },
+                "tolerance": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.AlignedColumn": {
+            "type": "object",
+            "properties": {
+                "data_source_id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.AlignedRow": {
+            "type": "object",
+            "properties": {
+                "timestamp": {
+                    "type": "string"
+                },
+                "values": {
+                    "description": "Values holds one value per column, null where the datasource has no value",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                }
+            }
+        },
+        "api.AnalysisSeries": {
+            "type": "object",
+            "properties": {
+                "data_source_id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ApiKeyCreatedResponse": {
+            "type": "object",
+            "properties": {
+                "api_key_id": {
+                    "type": "integer"
+                },
+                "created_by": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "when_created": {
+                    "type": "string"
+                },
+                "when_expires": {
+                    "type": "string"
+                },
+                "when_last_used": {
+                    "type": "string"
+                },
+                "when_revoked": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ApiKeyListResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ApiKeyResponse"
+                    }
+                }
+            }
+        },
+        "api.ApiKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "description": "UserId defaults to the caller; issuing keys for other users requires the admin scope",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ApiKeyResponse": {
+            "type": "object",
+            "properties": {
+                "api_key_id": {
+                    "type": "integer"
+                },
+                "created_by": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "when_created": {
+                    "type": "string"
+                },
+                "when_expires": {
+                    "type": "string"
+                },
+                "when_last_used": {
+                    "type": "string"
+                },
+                "when_revoked": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.AutocorrelationLagInfo": {
+            "type": "object",
+            "properties": {
+                "acf": {
+                    "type": "number"
+                },
+                "acf_band": {
+                    "description": "ACFBand is the half-width of the 95% band of the autocorrelation at this lag",
+                    "type": "number"
+                },
+                "lag": {
+                    "type": "string"
+                },
+                "lag_seconds": {
+                    "type": "number"
+                },
+                "lag_steps": {
+                    "type": "integer"
+                },
+                "pacf": {
+                    "type": "number"
+                },
+                "significant": {
+                    "description": "Significant is set when the autocorrelation is outside its band",
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.AutocorrelationResponse": {
+            "type": "object",
+            "properties": {
+                "end_time": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "lags": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.AutocorrelationLagInfo"
+                    }
+                },
+                "ljung_box": {
+                    "$ref": "#/definitions/analysis.LjungBoxInfo"
+                },
+                "pacf_band": {
+                    "description": "PACFBand is the half-width of the 95% band of every lag's partial autocorrelation",
+                    "type": "number"
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "seasonality": {
+                    "description": "Seasonality lists the periods the series repeats with, strongest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SeasonalPeriodInfo"
+                    }
+                },
+                "seasonality_error": {
+                    "type": "string"
+                },
+                "series": {
+                    "$ref": "#/definitions/api.AnalysisSeries"
+                },
+                "start_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ChatRequest": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "session_id": {
+                    "description": "SessionId continues an existing session; omit it to start a new one",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ChatResponse": {
+            "type": "object",
+            "properties": {
+                "answer": {
+                    "type": "string"
+                },
+                "artifacts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/agent.Artifact"
+                    }
+                },
+                "session_id": {
+                    "type": "integer"
+                },
+                "tool_calls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/agent.ToolCallRecord"
+                    }
+                },
+                "usage": {
+                    "$ref": "#/definitions/agent.Usage"
+                }
+            }
+        },
+        "api.CorrelationResponse": {
+            "type": "object",
+            "properties": {
+                "best_lag": {
+                    "description": "BestLag is the lag with the strongest correlation; a positive lag means x leads y",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/analysis.LagCorrelationInfo"
+                        }
+                    ]
+                },
+                "cross_correlation": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/analysis.LagCorrelationInfo"
+                    }
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "granger": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/analysis.GrangerInfo"
+                    }
+                },
+                "granger_error": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "pearson": {
+                    "$ref": "#/definitions/analysis.CorrelationInfo"
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "rolling": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DataPoint"
+                    }
+                },
+                "spearman": {
+                    "$ref": "#/definitions/analysis.CorrelationInfo"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "x": {
+                    "$ref": "#/definitions/api.AnalysisSeries"
+                },
+                "y": {
+                    "$ref": "#/definitions/api.AnalysisSeries"
+                }
+            }
+        },
+        "api.CreateVirtualDataSourceRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "expression": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.DataPoint": {
+            "type": "object",
+            "properties": {
+                "timestamp": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.DataQueryResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DataPoint"
+                    }
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "row_count": {
+                    "type": "integer"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.DataSourceListResponse": {
+            "type": "object",
+            "properties": {
+                "data_sources": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DataSourceMetadata"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.DataSourceMetadata": {
+            "type": "object",
+            "properties": {
+                "created_by": {
+                    "type": "integer"
+                },
+                "data_source_id": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "expression": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "row_count": {
+                    "type": "integer"
+                },
+                "seasonality": {
+                    "description": "Seasonality is detected when the datasource is created, strongest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SeasonalPeriodInfo"
+                    }
+                },
+                "sensor_type": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "time_label": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                },
+                "value_label": {
+                    "type": "string"
+                },
+                "when_created": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.EvaluateExpressionRequest": {
+            "type": "object",
+            "properties": {
+                "end_time": {
+                    "type": "string"
+                },
+                "expression": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "range": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "tz": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.EvaluateExpressionResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DataPoint"
+                    }
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "expression": {
+                    "type": "string"
+                },
+                "row_count": {
+                    "type": "integer"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "type": "boolean"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ImputeRequest": {
+            "type": "object",
+            "properties": {
+                "end_time": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "max_gap": {
+                    "type": "string"
+                },
+                "method": {
+                    "description": "Method is linear (default), spline, locf, seasonal or rolling_mean",
+                    "type": "string"
+                },
+                "range": {
+                    "type": "string"
+                },
+                "save_as": {
+                    "description": "SaveAs saves the filled series as a new datasource with this name",
+                    "type": "string"
+                },
+                "season": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "description": "StartTime and EndTime override the start and end of Range",
+                    "type": "string"
+                },
+                "tz": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                },
+                "window": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ImputeResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ImputedDataPoint"
+                    }
+                },
+                "imputed": {
+                    "description": "Imputed counts the filled values, and Missing the gaps left empty",
+                    "type": "integer"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "max_gap": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "missing": {
+                    "type": "integer"
+                },
+                "row_count": {
+                    "type": "integer"
+                },
+                "saved": {
+                    "$ref": "#/definitions/api.DataSourceMetadata"
+                },
+                "season": {
+                    "type": "string"
+                },
+                "series": {
+                    "$ref": "#/definitions/api.AnalysisSeries"
+                }
+            }
+        },
+        "api.ImputedDataPoint": {
+            "type": "object",
+            "properties": {
+                "imputed": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Value is null for a gap left empty",
+                    "type": "number"
+                }
+            }
+        },
+        "api.JobListResponse": {
+            "type": "object",
+            "properties": {
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.JobResponse"
+                    }
+                }
+            }
+        },
+        "api.JobRequest": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "description": "Kind is tool_invoke (params: fx_name, arguments)",
+                    "type": "string"
+                },
+                "params": {
+                    "type": "object"
+                }
+            }
+        },
+        "api.JobResponse": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_by": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "params": {
+                    "type": "object"
+                },
+                "progress": {
+                    "type": "number"
+                },
+                "result": {
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "when_created": {
+                    "type": "string"
+                },
+                "when_finished": {
+                    "type": "string"
+                },
+                "when_started": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.PrincipalResponse": {
+            "type": "object",
+            "properties": {
+                "key_id": {
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SeasonalPeriodInfo": {
+            "type": "object",
+            "properties": {
+                "lag_steps": {
+                    "type": "integer"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "period_seconds": {
+                    "type": "number"
+                },
+                "strength": {
+                    "description": "Strength is the autocorrelation of values one period apart",
+                    "type": "number"
+                }
+            }
+        },
+        "api.SessionListResponse": {
+            "type": "object",
+            "properties": {
+                "sessions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SessionMetadata"
+                    }
+                }
+            }
+        },
+        "api.SessionMetadata": {
+            "type": "object",
+            "properties": {
+                "created_by": {
+                    "type": "integer"
+                },
+                "session_id": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "when_created": {
+                    "type": "string"
+                },
+                "when_updated": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SessionTranscriptResponse": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.TranscriptMessage"
+                    }
+                },
+                "session": {
+                    "$ref": "#/definitions/api.SessionMetadata"
+                },
+                "tool_invocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ToolInvocationInfo"
+                    }
+                }
+            }
+        },
+        "api.SpectralPeakInfo": {
+            "type": "object",
+            "properties": {
+                "frequency_hz": {
+                    "type": "number"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "period_seconds": {
+                    "type": "number"
+                },
+                "power": {
+                    "type": "number"
+                },
+                "share": {
+                    "description": "Share is the peak's share of the total power of the series without its mean",
+                    "type": "number"
+                }
+            }
+        },
+        "api.SpectrumPointInfo": {
+            "type": "object",
+            "properties": {
+                "frequency_hz": {
+                    "type": "number"
+                },
+                "power": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.SpectrumResponse": {
+            "type": "object",
+            "properties": {
                 "end_time": {
                     "type": "string"
                 },
-                "row_count": {
+                "interval": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "peaks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SpectralPeakInfo"
+                    }
+                },
+                "points": {
+                    "type": "integer"
+                },
+                "segment": {
+                    "description": "Segment is the time covered by each transform; periods over half of it are not reported",
+                    "type": "string"
+                },
+                "segments": {
+                    "type": "integer"
+                },
+                "series": {
+                    "$ref": "#/definitions/api.AnalysisSeries"
+                },
+                "spectrum": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SpectrumPointInfo"
+                    }
+                },
+                "start_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ToolCatalogResponse": {
+            "type": "object",
+            "properties": {
+                "tools": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ToolSpec"
+                    }
+                }
+            }
+        },
+        "api.ToolInvocationInfo": {
+            "type": "object",
+            "properties": {
+                "arguments": {
+                    "type": "object"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "invocation_id": {
                     "type": "integer"
                 },
-                "start_time": {
+                "message_id": {
+                    "type": "integer"
+                },
+                "result_summary": {
+                    "type": "string"
+                },
+                "tool_call_id": {
+                    "type": "string"
+                },
+                "tool_name": {
+                    "type": "string"
+                },
+                "when_created": {
                     "type": "string"
                 }
             }
         },
-        "api.DataSourceListResponse": {
+        "api.ToolInvokeResponse": {
             "type": "object",
             "properties": {
-                "data_sources": {
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "fx_name": {
+                    "type": "string"
+                },
+                "result": {}
+            }
+        },
+        "api.ToolListResponse": {
+            "type": "object",
+            "properties": {
+                "tools": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/api.DataSourceMetadata"
+                        "$ref": "#/definitions/api.ToolMetadata"
                     }
                 }
             }
         },
-        "api.DataSourceMetadata": {
+        "api.ToolMetadata": {
             "type": "object",
             "properties": {
-                "data_source_id": {
+                "category": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "fx_name": {
+                    "type": "string"
+                },
+                "is_enabled": {
+                    "type": "boolean"
+                },
+                "max_calls": {
                     "type": "integer"
                 },
-                "end_time": {
+                "name": {
+                    "type": "string"
+                },
+                "num_call_reset": {
+                    "type": "integer"
+                },
+                "num_calls": {
+                    "type": "integer"
+                },
+                "timeout_s": {
+                    "type": "integer"
+                },
+                "tool_id": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                },
+                "when_last_call": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ToolSecretInfo": {
+            "type": "object",
+            "properties": {
+                "key_id": {
                     "type": "string"
                 },
                 "name": {
                     "type": "string"
                 },
-                "row_count": {
+                "when_created": {
+                    "type": "string"
+                },
+                "when_updated": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ToolSecretListResponse": {
+            "type": "object",
+            "properties": {
+                "fx_name": {
+                    "type": "string"
+                },
+                "secrets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ToolSecretInfo"
+                    }
+                }
+            }
+        },
+        "api.ToolSecretRequest": {
+            "type": "object",
+            "properties": {
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ToolSpec": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "fx_name": {
+                    "type": "string"
+                },
+                "input_schema": {
+                    "type": "object"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "output_schema": {
+                    "type": "object"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.TranscriptMessage": {
+            "type": "object",
+            "properties": {
+                "completion_tokens": {
                     "type": "integer"
                 },
-                "start_time": {
+                "content": {
                     "type": "string"
                 },
-                "type": {
+                "message_id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prompt_tokens": {
+                    "type": "integer"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "seq": {
+                    "type": "integer"
+                },
+                "tool_call_id": {
                     "type": "string"
                 },
+                "tool_calls": {
+                    "type": "object"
+                },
                 "when_created": {
                     "type": "string"
                 }
             }
         },
-        "api.ErrorResponse": {
+        "api.TransformRequest": {
             "type": "object",
             "properties": {
-                "error": {
+                "end_time": {
+                    "type": "string"
+                },
+                "per": {
+                    "description": "Per is the time unit of rates (1s by default) and integrals (1h by default)",
+                    "type": "string"
+                },
+                "period": {
+                    "description": "Period of period totals: hour, day, week, month, quarter or year",
+                    "type": "string"
+                },
+                "range": {
+                    "type": "string"
+                },
+                "rollover": {
+                    "description": "Rollover is the value at which a counter wraps to zero, e.g. 65536",
+                    "type": "number"
+                },
+                "save_as": {
+                    "description": "SaveAs saves the transformed series as a new datasource with this name",
+                    "type": "string"
+                },
+                "start_time": {
+                    "description": "StartTime and EndTime override the start and end of Range",
+                    "type": "string"
+                },
+                "transform": {
+                    "description": "Transform is rate, difference, integral, counter_delta, running_total or\nperiod_total",
+                    "type": "string"
+                },
+                "tz": {
+                    "description": "Timezone is also the one period totals restart in",
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.TransformResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DataPoint"
+                    }
+                },
+                "glitches": {
+                    "type": "integer"
+                },
+                "per": {
+                    "type": "string"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "resets": {
+                    "description": "Resets, Rollovers and Glitches count the decreases of a counter",
+                    "type": "integer"
+                },
+                "rollovers": {
+                    "type": "integer"
+                },
+                "row_count": {
+                    "type": "integer"
+                },
+                "saved": {
+                    "$ref": "#/definitions/api.DataSourceMetadata"
+                },
+                "series": {
+                    "$ref": "#/definitions/api.AnalysisSeries"
+                },
+                "total": {
+                    "description": "Total is the change or consumption over the range, absent for rates",
+                    "type": "number"
+                },
+                "transform": {
+                    "type": "string"
+                },
+                "unit": {
+                    "description": "Unit of the transformed values, empty if the registry has none for them",
+                    "type": "string"
+                }
+            }
+        },
+        "api.UnitInfo": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "dimension": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "symbol": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.UnitListResponse": {
+            "type": "object",
+            "properties": {
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.UnitInfo"
+                    }
+                }
+            }
+        },
+        "api.UpdateDataSourceRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sensor_type": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "unit": {
                     "type": "string"
                 }
             }
@@ -315,6 +3558,9 @@ const docTemplate = `{
         "api.UploadResponse": {
             "type": "object",
             "properties": {
+                "created_by": {
+                    "type": "integer"
+                },
                 "data_source_id": {
                     "type": "integer"
                 },
@@ -327,21 +3573,100 @@ const docTemplate = `{
                 "row_count": {
                     "type": "integer"
                 },
+                "seasonality": {
+                    "description": "Seasonality is detected while ingesting, strongest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SeasonalPeriodInfo"
+                    }
+                },
                 "start_time": {
                     "type": "string"
                 },
+                "time_label": {
+                    "type": "string"
+                },
+                "unit": {
+                    "type": "string"
+                },
+                "value_label": {
+                    "type": "string"
+                },
+                "when_created": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.UserListResponse": {
+            "type": "object",
+            "properties": {
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.UserResponse"
+                    }
+                }
+            }
+        },
+        "api.UserRequest": {
+            "type": "object",
+            "properties": {
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.UserResponse": {
+            "type": "object",
+            "properties": {
+                "created_by": {
+                    "type": "integer"
+                },
+                "is_active": {
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                },
                 "when_created": {
                     "type": "string"
                 }
             }
+        },
+        "api.VaultRotateResponse": {
+            "type": "object",
+            "properties": {
+                "key_id": {
+                    "type": "string"
+                },
+                "rewrapped": {
+                    "type": "integer"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key sent as \"Bearer \u003ckey\u003e\". Create the first key with the -create-admin-key flag.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
+    },
+    "security": [
+        {
+            "ApiKeyAuth": []
         }
-    }
+    ]
 }`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
-	Host:             "localhost:8080",
+	Host:             "",
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "IoT Data Sandbox API",
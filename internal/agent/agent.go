@@ -0,0 +1,301 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/metrics"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DefaultMaxSteps = 8
+	// DefaultMaxToolResultSize caps the JSON tool result sent back to the model.
+	// The full result is still available as an artifact.
+	DefaultMaxToolResultSize = 16 * 1024
+)
+
+const defaultSystemPrompt = `You are an analysis assistant for an IoT time series sandbox.
+Answer the user's questions about their sensor datasources by calling the available tools.
+Always look up datasources with tools instead of guessing ids, names or time ranges.
+Datasources may record values in different units: check each datasource's unit and only
+compare series after converting them to a common unit with the unit argument.
+Use evaluate_expression to derive series such as differences or ratios of datasources.
+Use correlate_datasources to check whether one datasource leads or drives another.
+Use find_cycles to look for recurring daily, weekly or machine cycles.
+Use detect_seasonality to check whether a datasource repeats itself; datasources list the
+seasonal periods detected when they were created.
+Use fill_gaps to fill missing values before analyses that need a regular series.
+Use transform_series with counter_delta for consumption from cumulative meter readings, never
+plain differences, and with integral to turn power into energy.
+When a tool returns an error, explain it or try a corrected call. Keep final answers short
+and reference the datasources and time ranges you used.`
+
+var (
+	// ErrMaxSteps is returned when the model keeps calling tools past Config.MaxSteps
+	ErrMaxSteps = errors.New("agent reached the maximum number of steps without an answer")
+	// ErrSessionNotFound is returned when resuming a chat session that does not exist
+	ErrSessionNotFound = errors.New("chat session not found")
+)
+
+type Config struct {
+	SystemPrompt      string
+	MaxSteps          int
+	MaxToolResultSize int
+}
+
+// Agent runs the tool-calling loop: it sends the conversation to the LLM provider,
+// executes any tool calls through the tool executor and feeds the results back until
+// the model replies without calling a tool.
+type Agent struct {
+	provider Provider
+	executor *tools.Executor
+	config   Config
+}
+
+func NewAgent(provider Provider, executor *tools.Executor, config Config) *Agent {
+	if config.SystemPrompt == "" {
+		config.SystemPrompt = defaultSystemPrompt
+	}
+	if config.MaxSteps <= 0 {
+		config.MaxSteps = DefaultMaxSteps
+	}
+	if config.MaxToolResultSize <= 0 {
+		config.MaxToolResultSize = DefaultMaxToolResultSize
+	}
+
+	return &Agent{
+		provider: provider,
+		executor: executor,
+		config:   config,
+	}
+}
+
+// ToolCallRecord describes one tool call made while answering a prompt
+type ToolCallRecord struct {
+	Id         string          `json:"id"`
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments" swaggertype:"object"`
+	Result     any             `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// Artifact is a tool output worth showing to the user as-is (a series, a table...)
+type Artifact struct {
+	Id         string `json:"id"`
+	Kind       string `json:"kind"`
+	Tool       string `json:"tool"`
+	ToolCallId string `json:"tool_call_id"`
+	Data       any    `json:"data"`
+}
+
+// Result is the outcome of one prompt
+type Result struct {
+	Answer    string           `json:"answer"`
+	ToolCalls []ToolCallRecord `json:"tool_calls"`
+	Artifacts []Artifact       `json:"artifacts"`
+	Usage     Usage            `json:"usage"`
+	// Messages holds the new messages produced for this prompt, starting with the
+	// user message, so callers can persist or replay the conversation
+	Messages []Message `json:"-"`
+}
+
+// Run answers prompt. History holds earlier user, assistant and tool messages of the
+// same conversation (without the system prompt).
+func (a *Agent) Run(ctx context.Context, history []Message, prompt string) (*Result, error) {
+	return a.RunStream(ctx, history, prompt, nil)
+}
+
+// RunStream is Run that reports reply tokens, tool calls and artifacts to emit as
+// they happen. It does not emit EventDone or EventError; the caller reports the
+// returned result or error.
+func (a *Agent) RunStream(ctx context.Context, history []Message, prompt string, emit EventFunc) (result *Result, err error) {
+	ctx, span := tracing.Start(ctx, "agent.Run", attribute.String("llm.provider", a.provider.Name()))
+	started := time.Now()
+	defer func() {
+		logger := logging.FromContext(ctx).With("llm_provider", a.provider.Name(), "duration_ms", time.Since(started).Milliseconds())
+		if result != nil {
+			logger = logger.With("tool_calls", len(result.ToolCalls),
+				"prompt_tokens", result.Usage.PromptTokens, "completion_tokens", result.Usage.CompletionTokens)
+		}
+		if err != nil {
+			logger.Warn("Agent run failed", "error", err)
+		} else {
+			logger.Info("Agent run finished")
+		}
+		if result != nil {
+			span.SetAttributes(
+				attribute.Int("agent.tool_calls", len(result.ToolCalls)),
+				attribute.Int("llm.usage.prompt_tokens", result.Usage.PromptTokens),
+				attribute.Int("llm.usage.completion_tokens", result.Usage.CompletionTokens),
+			)
+		}
+		tracing.End(span, err)
+	}()
+
+	defs, err := a.executor.EnabledDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tools: %w", err)
+	}
+
+	userMsg := Message{Role: RoleUser, Content: prompt}
+
+	messages := make([]Message, 0, len(history)+2)
+	messages = append(messages, Message{Role: RoleSystem, Content: a.config.SystemPrompt})
+	messages = append(messages, history...)
+	messages = append(messages, userMsg)
+
+	result = &Result{
+		ToolCalls: []ToolCallRecord{},
+		Artifacts: []Artifact{},
+		Messages:  []Message{userMsg},
+	}
+
+	for step := 0; step < a.config.MaxSteps; step++ {
+		req := &Request{Messages: messages, Tools: defs}
+		if emit != nil {
+			req.OnToken = func(token string) {
+				emit.emit(EventToken, TokenEvent{Content: token})
+			}
+		}
+
+		resp, err := a.generate(ctx, req, step)
+		if err != nil {
+			return result, fmt.Errorf("LLM provider %s failed: %w", a.provider.Name(), err)
+		}
+		result.Usage.Add(resp.Usage)
+
+		reply := resp.Message
+		reply.Role = RoleAssistant
+		for i := range reply.ToolCalls {
+			reply.ToolCalls[i].Arguments = ToolArguments(reply.ToolCalls[i].Arguments)
+		}
+		usage := resp.Usage
+		reply.Usage = &usage
+		messages = append(messages, reply)
+		result.Messages = append(result.Messages, reply)
+
+		if len(reply.ToolCalls) == 0 {
+			result.Answer = reply.Content
+			return result, nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			emit.emit(EventToolStart, ToolStartEvent{Id: call.Id, Name: call.Name, Arguments: call.Arguments})
+			record, toolMsg := a.runTool(ctx, call)
+			emit.emit(EventToolFinish, record)
+			result.ToolCalls = append(result.ToolCalls, record)
+			messages = append(messages, toolMsg)
+			result.Messages = append(result.Messages, toolMsg)
+
+			if record.Error != "" {
+				continue
+			}
+			if def, ok := a.executor.Registry().Lookup(call.Name); ok && def.ArtifactKind != "" {
+				artifact := Artifact{
+					Id:         fmt.Sprintf("artifact_%d", len(result.Artifacts)+1),
+					Kind:       def.ArtifactKind,
+					Tool:       call.Name,
+					ToolCallId: call.Id,
+					Data:       record.Result,
+				}
+				result.Artifacts = append(result.Artifacts, artifact)
+				emit.emit(EventArtifact, artifact)
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, ErrMaxSteps
+}
+
+// generate sends one completion request to the provider, recording its latency
+// and token usage
+func (a *Agent) generate(ctx context.Context, req *Request, step int) (resp *Response, err error) {
+	provider := a.provider.Name()
+	ctx, span := tracing.Start(ctx, "llm.Generate",
+		attribute.String("llm.provider", provider),
+		attribute.Int("agent.step", step),
+		attribute.Int("llm.messages", len(req.Messages)),
+	)
+	started := time.Now()
+	defer func() {
+		metrics.ObserveLLM(provider, time.Since(started), err)
+		tracing.End(span, err)
+	}()
+
+	resp, err = a.provider.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("LLM response", "llm_provider", provider, "step", step,
+		"duration_ms", time.Since(started).Milliseconds(), "tool_calls", len(resp.Message.ToolCalls),
+		"prompt_tokens", resp.Usage.PromptTokens, "completion_tokens", resp.Usage.CompletionTokens)
+	metrics.AddLLMTokens(provider, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	span.SetAttributes(
+		attribute.Int("llm.usage.prompt_tokens", resp.Usage.PromptTokens),
+		attribute.Int("llm.usage.completion_tokens", resp.Usage.CompletionTokens),
+		attribute.Int("llm.tool_calls", len(resp.Message.ToolCalls)),
+	)
+	return resp, nil
+}
+
+// runTool executes one tool call. Tool failures are reported back to the model as the
+// tool message instead of aborting the loop, so it can correct itself.
+func (a *Agent) runTool(ctx context.Context, call ToolCall) (ToolCallRecord, Message) {
+	record := ToolCallRecord{
+		Id:        call.Id,
+		Name:      call.Name,
+		Arguments: call.Arguments,
+	}
+
+	started := time.Now()
+	output, err := a.executor.Execute(ctx, call.Name, call.Arguments)
+	record.DurationMs = time.Since(started).Milliseconds()
+
+	msg := Message{
+		Role:       RoleTool,
+		ToolCallId: call.Id,
+		Name:       call.Name,
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+		msg.Content = errorContent(record.Error)
+		return record, msg
+	}
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		record.Error = fmt.Sprintf("failed to encode tool result: %v", err)
+		msg.Content = errorContent(record.Error)
+		return record, msg
+	}
+	record.Result = output
+
+	if len(encoded) > a.config.MaxToolResultSize {
+		preview, _ := json.Marshal(map[string]any{
+			"truncated": true,
+			"size":      len(encoded),
+			"preview":   string(encoded[:a.config.MaxToolResultSize]),
+		})
+		msg.Content = string(preview)
+	} else {
+		msg.Content = string(encoded)
+	}
+	return record, msg
+}
+
+func errorContent(message string) string {
+	encoded, _ := json.Marshal(map[string]string{"error": message})
+	return string(encoded)
+}
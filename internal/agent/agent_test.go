@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+)
+
+// newTestAgent builds an agent on a fresh database with three tools: echo, which
+// returns its text argument as a series artifact, fail, which always errors, and
+// infinite, whose result cannot be encoded as JSON
+func newTestAgent(t *testing.T, provider Provider, config Config) (*Agent, *persistence.Store) {
+	t.Helper()
+
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	registry := tools.NewRegistry()
+	for _, def := range []*tools.Definition{
+		{
+			FxName:       "echo",
+			InputSchema:  json.RawMessage(`{"type": "object", "properties": {"text": {"type": "string"}}, "required": ["text"]}`),
+			ArtifactKind: tools.ArtifactSeries,
+			Fn: func(ctx context.Context, args json.RawMessage) (any, error) {
+				var a struct {
+					Text string `json:"text"`
+				}
+				if err := json.Unmarshal(args, &a); err != nil {
+					return nil, err
+				}
+				return map[string]string{"text": a.Text}, nil
+			},
+		},
+		{
+			FxName: "fail",
+			Fn: func(ctx context.Context, args json.RawMessage) (any, error) {
+				return nil, &tools.ArgumentError{Message: "no such datasource"}
+			},
+		},
+		{
+			FxName:       "infinite",
+			ArtifactKind: tools.ArtifactTable,
+			Fn: func(ctx context.Context, args json.RawMessage) (any, error) {
+				return map[string]float64{"mean": math.Inf(1)}, nil
+			},
+		},
+	} {
+		if err := registry.Register(def); err != nil {
+			t.Fatalf("Register(%s): %v", def.FxName, err)
+		}
+	}
+	if err := registry.Sync(store); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	return NewAgent(provider, tools.NewExecutor(store, registry, nil), config), store
+}
+
+func toolCall(name, args string) ToolCall {
+	return ToolCall{Name: name, Arguments: json.RawMessage(args)}
+}
+
+func TestRunCallsTool(t *testing.T) {
+	provider := NewScriptedProvider(
+		ScriptStep{ToolCalls: []ToolCall{toolCall("echo", `{"text": "boiler"}`)}, Usage: Usage{PromptTokens: 10, CompletionTokens: 2}},
+		ScriptStep{Content: "The boiler is fine.", Usage: Usage{PromptTokens: 20, CompletionTokens: 5}},
+	)
+	a, _ := newTestAgent(t, provider, Config{})
+
+	result, err := a.Run(context.Background(), nil, "How is the boiler?")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Answer != "The boiler is fine." {
+		t.Errorf("Answer = %q", result.Answer)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].Name != "echo" || result.ToolCalls[0].Error != "" {
+		t.Fatalf("ToolCalls = %+v, want one successful echo call", result.ToolCalls)
+	}
+	if len(result.Artifacts) != 1 || result.Artifacts[0].Kind != tools.ArtifactSeries || result.Artifacts[0].ToolCallId != result.ToolCalls[0].Id {
+		t.Errorf("Artifacts = %+v, want the echo result as a series", result.Artifacts)
+	}
+	if result.Usage != (Usage{PromptTokens: 30, CompletionTokens: 7}) {
+		t.Errorf("Usage = %+v", result.Usage)
+	}
+
+	// The second request carries the tool result back to the model
+	if len(provider.Requests) != 2 {
+		t.Fatalf("provider got %d requests, want 2", len(provider.Requests))
+	}
+	messages := provider.Requests[1].Messages
+	last := messages[len(messages)-1]
+	if last.Role != RoleTool || last.ToolCallId != result.ToolCalls[0].Id || last.Content != `{"text":"boiler"}` {
+		t.Errorf("last message = %+v, want the echo result", last)
+	}
+}
+
+func TestRunFeedsToolErrorBack(t *testing.T) {
+	provider := NewScriptedProvider(
+		ScriptStep{ToolCalls: []ToolCall{toolCall("fail", `{}`)}},
+		ScriptStep{Content: "That datasource does not exist."},
+	)
+	a, _ := newTestAgent(t, provider, Config{})
+
+	result, err := a.Run(context.Background(), nil, "Plot datasource 99")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.ToolCalls) != 1 || !strings.Contains(result.ToolCalls[0].Error, "no such datasource") {
+		t.Fatalf("ToolCalls = %+v, want the failed call recorded", result.ToolCalls)
+	}
+	if len(result.Artifacts) != 0 {
+		t.Errorf("Artifacts = %+v, want none for a failed call", result.Artifacts)
+	}
+
+	messages := provider.Requests[1].Messages
+	last := messages[len(messages)-1]
+	var content map[string]string
+	if err := json.Unmarshal([]byte(last.Content), &content); err != nil || last.Role != RoleTool {
+		t.Fatalf("last message = %+v, want a tool message with a JSON error", last)
+	}
+	if !strings.Contains(content["error"], "no such datasource") {
+		t.Errorf("tool message error = %q", content["error"])
+	}
+	if result.Answer != "That datasource does not exist." {
+		t.Errorf("Answer = %q", result.Answer)
+	}
+}
+
+func TestRunStopsAtMaxSteps(t *testing.T) {
+	provider := NewScriptedProvider()
+	provider.SetFallback(ScriptStep{ToolCalls: []ToolCall{toolCall("echo", `{"text": "again"}`)}})
+	a, _ := newTestAgent(t, provider, Config{MaxSteps: 3})
+
+	result, err := a.Run(context.Background(), nil, "Loop forever")
+	if !errors.Is(err, ErrMaxSteps) {
+		t.Fatalf("Run error = %v, want ErrMaxSteps", err)
+	}
+	if len(provider.Requests) != 3 {
+		t.Errorf("provider got %d requests, want 3", len(provider.Requests))
+	}
+	if result == nil || len(result.ToolCalls) != 3 {
+		t.Errorf("result = %+v, want the 3 tool calls made", result)
+	}
+}
+
+func TestRunRecordsMalformedArgumentsAsJSON(t *testing.T) {
+	provider := NewScriptedProvider(
+		ScriptStep{ToolCalls: []ToolCall{
+			{Id: "call_1", Name: "echo", Arguments: json.RawMessage(`{"text": "boi`)},
+			{Id: "call_2", Name: "echo", Arguments: json.RawMessage(``)},
+			toolCall("infinite", `{}`),
+		}},
+		ScriptStep{Content: "Sorry, let me try again."},
+	)
+	a, _ := newTestAgent(t, provider, Config{})
+
+	result, err := a.Run(context.Background(), nil, "How is the boiler?")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.ToolCalls) != 3 {
+		t.Fatalf("ToolCalls = %+v, want 3", result.ToolCalls)
+	}
+	if got := string(result.ToolCalls[0].Arguments); got != `"{\"text\": \"boi"` {
+		t.Errorf("truncated arguments recorded as %s, want a JSON string", got)
+	}
+	if got := string(result.ToolCalls[1].Arguments); got != `{}` {
+		t.Errorf("empty arguments recorded as %s, want {}", got)
+	}
+	for _, call := range result.ToolCalls {
+		if call.Error == "" {
+			t.Errorf("call %s succeeded, want an error", call.Id)
+		}
+	}
+	if result.ToolCalls[2].Result != nil || len(result.Artifacts) != 0 {
+		t.Errorf("unencodable result kept: %+v, artifacts %+v", result.ToolCalls[2].Result, result.Artifacts)
+	}
+	if _, err := json.Marshal(result); err != nil {
+		t.Errorf("result cannot be encoded: %v", err)
+	}
+}
+
+func TestSessionsPersistTurns(t *testing.T) {
+	provider := NewScriptedProvider(
+		ScriptStep{ToolCalls: []ToolCall{toolCall("echo", `{"text": "first"}`)}},
+		ScriptStep{Content: "First answer."},
+		ScriptStep{Content: "Second answer."},
+	)
+	a, store := newTestAgent(t, provider, Config{})
+	sessions := NewSessions(store, a)
+	ctx := context.Background()
+
+	session, _, err := sessions.Chat(ctx, 0, "First question")
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if _, _, err := sessions.Chat(ctx, session.SessionId, "Second question"); err != nil {
+		t.Fatalf("Chat in session %d: %v", session.SessionId, err)
+	}
+
+	// user, assistant with tool call, tool, assistant; then user, assistant
+	messages, err := store.LoadChatMessages(session.SessionId)
+	if err != nil {
+		t.Fatalf("LoadChatMessages: %v", err)
+	}
+	var roles []string
+	for _, m := range messages {
+		roles = append(roles, m.Role)
+	}
+	want := "user,assistant,tool,assistant,user,assistant"
+	if got := strings.Join(roles, ","); got != want {
+		t.Errorf("message roles = %s, want %s", got, want)
+	}
+
+	invocations, err := store.LoadToolInvocations(session.SessionId)
+	if err != nil {
+		t.Fatalf("LoadToolInvocations: %v", err)
+	}
+	if len(invocations) != 1 || invocations[0].ToolName != "echo" || invocations[0].Error != nil {
+		t.Errorf("invocations = %+v, want one successful echo call", invocations)
+	}
+
+	// The second prompt is answered with the first turn as history
+	history := provider.Requests[2].Messages
+	if len(history) != 6 || history[0].Role != RoleSystem || history[1].Content != "First question" {
+		t.Errorf("second turn sent %d messages, want the system prompt, the first turn and the new prompt", len(history))
+	}
+}
+
+func TestSessionsSaveMalformedArguments(t *testing.T) {
+	provider := NewScriptedProvider(
+		ScriptStep{ToolCalls: []ToolCall{{Id: "call_1", Name: "echo", Arguments: json.RawMessage(`{"text": `)}}},
+		ScriptStep{Content: "Sorry."},
+	)
+	a, store := newTestAgent(t, provider, Config{})
+
+	session, _, err := NewSessions(store, a).Chat(context.Background(), 0, "How is the boiler?")
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	invocations, err := store.LoadToolInvocations(session.SessionId)
+	if err != nil {
+		t.Fatalf("LoadToolInvocations: %v", err)
+	}
+	if len(invocations) != 1 || invocations[0].Arguments != `"{\"text\": "` {
+		t.Errorf("invocations = %+v, want the arguments stored as a JSON string", invocations)
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import "encoding/json"
+
+// EventType identifies the kind of progress event emitted while answering a prompt
+type EventType string
+
+const (
+	// EventSession carries the ID of the chat session the prompt runs in
+	EventSession EventType = "session"
+	// EventToken carries a chunk of the model's reply text as it is generated
+	EventToken EventType = "token"
+	// EventToolStart is emitted before a tool call runs, with its arguments
+	EventToolStart EventType = "tool_start"
+	// EventToolFinish is emitted after a tool call, with its result or error
+	EventToolFinish EventType = "tool_finish"
+	// EventArtifact carries an intermediate result produced by a tool call
+	EventArtifact EventType = "artifact"
+	// EventError reports a failure that ends the run
+	EventError EventType = "error"
+	// EventDone carries the final result
+	EventDone EventType = "done"
+)
+
+// Event is one progress update. Data is JSON-encodable.
+type Event struct {
+	Type EventType
+	Data any
+}
+
+// EventFunc receives progress events. It is called from the goroutine running the
+// prompt and must not block for long.
+type EventFunc func(Event)
+
+// SessionEvent is the data of an EventSession event
+type SessionEvent struct {
+	SessionId int64 `json:"session_id"`
+}
+
+// TokenEvent is the data of an EventToken event
+type TokenEvent struct {
+	Content string `json:"content"`
+}
+
+// ToolStartEvent is the data of an EventToolStart event
+type ToolStartEvent struct {
+	Id        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments" swaggertype:"object"`
+}
+
+// ErrorEvent is the data of an EventError event
+type ErrorEvent struct {
+	Error string `json:"error"`
+}
+
+func (f EventFunc) emit(eventType EventType, data any) {
+	if f != nil {
+		f(Event{Type: eventType, Data: data})
+	}
+}
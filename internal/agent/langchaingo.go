@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+func init() {
+	RegisterProvider("langchaingo", NewLangChainProvider)
+}
+
+// LangChainProvider adapts a langchaingo model to the Provider interface
+type LangChainProvider struct {
+	model llms.Model
+	name  string
+}
+
+// NewLangChainProvider builds a langchaingo model for cfg.Backend (openai, anthropic or ollama)
+func NewLangChainProvider(cfg ProviderConfig) (Provider, error) {
+	var (
+		model llms.Model
+		err   error
+	)
+
+	switch cfg.Backend {
+	case "", "openai":
+		opts := []openai.Option{}
+		if cfg.Model != "" {
+			opts = append(opts, openai.WithModel(cfg.Model))
+		}
+		if cfg.APIKey != "" {
+			opts = append(opts, openai.WithToken(cfg.APIKey))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, openai.WithBaseURL(cfg.BaseURL))
+		}
+		model, err = openai.New(opts...)
+	case "anthropic":
+		opts := []anthropic.Option{}
+		if cfg.Model != "" {
+			opts = append(opts, anthropic.WithModel(cfg.Model))
+		}
+		if cfg.APIKey != "" {
+			opts = append(opts, anthropic.WithToken(cfg.APIKey))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, anthropic.WithBaseURL(cfg.BaseURL))
+		}
+		model, err = anthropic.New(opts...)
+	case "ollama":
+		opts := []ollama.Option{}
+		if cfg.Model != "" {
+			opts = append(opts, ollama.WithModel(cfg.Model))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, ollama.WithServerURL(cfg.BaseURL))
+		}
+		model, err = ollama.New(opts...)
+	default:
+		return nil, fmt.Errorf("unsupported langchaingo backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s model: %w", cfg.Backend, err)
+	}
+
+	return &LangChainProvider{model: model, name: "langchaingo"}, nil
+}
+
+func (p *LangChainProvider) Name() string {
+	return p.name
+}
+
+func (p *LangChainProvider) Generate(ctx context.Context, req *Request) (*Response, error) {
+	options := []llms.CallOption{llms.WithTools(toLangChainTools(req))}
+	if req.OnToken != nil {
+		options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			req.OnToken(string(chunk))
+			return nil
+		}))
+	}
+
+	resp, err := p.model.GenerateContent(ctx, toLangChainMessages(req.Messages), options...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("model returned no choices")
+	}
+
+	choice := resp.Choices[0]
+	msg := Message{
+		Role:    RoleAssistant,
+		Content: choice.Content,
+	}
+	for _, call := range choice.ToolCalls {
+		if call.FunctionCall == nil {
+			continue
+		}
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			Id:        call.ID,
+			Name:      call.FunctionCall.Name,
+			Arguments: ToolArguments([]byte(call.FunctionCall.Arguments)),
+		})
+	}
+
+	return &Response{Message: msg, Usage: usageFromGenerationInfo(choice.GenerationInfo)}, nil
+}
+
+func toLangChainTools(req *Request) []llms.Tool {
+	lcTools := make([]llms.Tool, 0, len(req.Tools))
+	for _, def := range req.Tools {
+		lcTools = append(lcTools, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        def.FxName,
+				Description: def.Description,
+				Parameters:  def.InputSchema,
+			},
+		})
+	}
+	return lcTools
+}
+
+func toLangChainMessages(messages []Message) []llms.MessageContent {
+	out := make([]llms.MessageContent, 0, len(messages))
+	for _, m := range messages {
+		switch m.Role {
+		case RoleSystem:
+			out = append(out, llms.TextParts(llms.ChatMessageTypeSystem, m.Content))
+		case RoleUser:
+			out = append(out, llms.TextParts(llms.ChatMessageTypeHuman, m.Content))
+		case RoleAssistant:
+			mc := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+			if m.Content != "" {
+				mc.Parts = append(mc.Parts, llms.TextContent{Text: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				mc.Parts = append(mc.Parts, llms.ToolCall{
+					ID:   call.Id,
+					Type: "function",
+					FunctionCall: &llms.FunctionCall{
+						Name:      call.Name,
+						Arguments: string(call.Arguments),
+					},
+				})
+			}
+			out = append(out, mc)
+		case RoleTool:
+			out = append(out, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{
+					ToolCallID: m.ToolCallId,
+					Name:       m.Name,
+					Content:    m.Content,
+				}},
+			})
+		}
+	}
+	return out
+}
+
+// usageFromGenerationInfo reads token counts from the provider-specific generation info
+func usageFromGenerationInfo(info map[string]any) Usage {
+	var usage Usage
+	for _, key := range []string{"PromptTokens", "InputTokens"} {
+		if v, ok := info[key].(int); ok {
+			usage.PromptTokens = v
+		}
+	}
+	for _, key := range []string{"CompletionTokens", "OutputTokens"} {
+		if v, ok := info[key].(int); ok {
+			usage.CompletionTokens = v
+		}
+	}
+	return usage
+}
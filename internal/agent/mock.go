@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"unicode"
+)
+
+// ErrScriptExhausted is returned by ScriptedProvider when it runs out of steps
+var ErrScriptExhausted = errors.New("scripted provider has no more steps")
+
+// ScriptStep is one canned assistant reply
+type ScriptStep struct {
+	Content   string
+	ToolCalls []ToolCall
+	Usage     Usage
+}
+
+// ScriptedProvider is a deterministic Provider for offline use and tests. Each call
+// to Generate returns the next step of the script, regardless of the request.
+type ScriptedProvider struct {
+	mu       sync.Mutex
+	steps    []ScriptStep
+	next     int
+	fallback *ScriptStep
+
+	// Requests records every request received, for assertions in tests
+	Requests []*Request
+}
+
+func NewScriptedProvider(steps ...ScriptStep) *ScriptedProvider {
+	return &ScriptedProvider{steps: steps}
+}
+
+// NewOfflineProvider returns the provider used when no LLM is configured. It never
+// calls tools and answers every prompt with a fixed notice.
+func NewOfflineProvider() *ScriptedProvider {
+	p := NewScriptedProvider()
+	p.SetFallback(ScriptStep{
+		Content: "No LLM provider is configured for this server, so the prompt was not analyzed. " +
+			"Set LLM_PROVIDER=langchaingo to enable the agent.",
+	})
+	return p
+}
+
+// SetFallback sets the reply returned once the script is exhausted instead of an error
+func (p *ScriptedProvider) SetFallback(step ScriptStep) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fallback = &step
+}
+
+func (p *ScriptedProvider) Name() string {
+	return "mock"
+}
+
+func (p *ScriptedProvider) Generate(ctx context.Context, req *Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Requests = append(p.Requests, req)
+
+	var step ScriptStep
+	switch {
+	case p.next < len(p.steps):
+		step = p.steps[p.next]
+		p.next++
+	case p.fallback != nil:
+		step = *p.fallback
+	default:
+		return nil, ErrScriptExhausted
+	}
+
+	if req.OnToken != nil {
+		for _, token := range tokenize(step.Content) {
+			req.OnToken(token)
+		}
+	}
+
+	msg := Message{
+		Role:    RoleAssistant,
+		Content: step.Content,
+	}
+	for i, call := range step.ToolCalls {
+		if call.Id == "" {
+			call.Id = fmt.Sprintf("call_%d_%d", p.next, i)
+		}
+		msg.ToolCalls = append(msg.ToolCalls, call)
+	}
+
+	return &Response{Message: msg, Usage: step.Usage}, nil
+}
+
+// tokenize splits text into word-sized chunks, keeping the separating whitespace,
+// to imitate a streaming model
+func tokenize(text string) []string {
+	var tokens []string
+	start := 0
+	for i, r := range text {
+		if unicode.IsSpace(r) && i > start {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
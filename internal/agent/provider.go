@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+)
+
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// Message is one entry in a conversation with the LLM. Assistant messages may carry
+// tool calls; tool messages carry the result of one call, linked by ToolCallId.
+type Message struct {
+	Role       Role       `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallId string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	// Usage is set on assistant messages to the tokens spent producing them
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// ToolCall is a request from the LLM to run a tool
+type ToolCall struct {
+	Id        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments" swaggertype:"object"`
+}
+
+// ToolArguments makes the arguments a model sent with a tool call valid JSON, so
+// they can be recorded and encoded in responses. Models sometimes send nothing or
+// truncated JSON: no arguments become an empty object and text that is not JSON
+// becomes a JSON string, which the tool's input schema then rejects.
+func ToolArguments(raw []byte) json.RawMessage {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return json.RawMessage(`{}`)
+	}
+	if json.Valid(raw) {
+		return json.RawMessage(raw)
+	}
+	encoded, _ := json.Marshal(string(raw))
+	return encoded
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+}
+
+// Request is a single completion request: the conversation so far and the tools
+// the model may call
+type Request struct {
+	Messages []Message
+	Tools    []*tools.Definition
+	// OnToken, when set, receives the reply text as the provider streams it.
+	// Providers that cannot stream ignore it; the full reply is still returned.
+	OnToken func(token string)
+}
+
+// Response is the model's next assistant message
+type Response struct {
+	Message Message
+	Usage   Usage
+}
+
+// Provider is an LLM backend that supports tool calling
+type Provider interface {
+	Name() string
+	Generate(ctx context.Context, req *Request) (*Response, error)
+}
+
+// ProviderConfig holds the settings passed to a provider factory
+type ProviderConfig struct {
+	// Backend selects the model vendor for multi-vendor providers (openai, anthropic, ollama)
+	Backend string
+	Model   string
+	APIKey  string
+	BaseURL string
+}
+
+// ProviderFactory builds a Provider from its configuration
+type ProviderFactory func(cfg ProviderConfig) (Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]ProviderFactory{
+		"mock": func(cfg ProviderConfig) (Provider, error) {
+			return NewOfflineProvider(), nil
+		},
+	}
+)
+
+// RegisterProvider makes a provider available to NewProvider under name. Providers
+// other than the offline mock, such as langchaingo, register themselves from init.
+func RegisterProvider(name string, factory ProviderFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// NewProvider builds the provider registered under name. An empty name selects the
+// offline mock provider.
+func NewProvider(name string, cfg ProviderConfig) (Provider, error) {
+	if name == "" {
+		name = "mock"
+	}
+
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q (available: %v)", name, ProviderNames())
+	}
+	return factory(cfg)
+}
+
+// ProviderNames lists the registered provider names
+func ProviderNames() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
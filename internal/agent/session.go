@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/auth"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+const (
+	// maxSessionTitleLen bounds the title derived from a session's first prompt
+	maxSessionTitleLen = 80
+	// maxResultSummaryLen bounds the tool result stored with each invocation record
+	maxResultSummaryLen = 2048
+)
+
+// Sessions runs the agent inside persistent chat sessions. Every prompt is answered
+// with the session's earlier messages as context, and the full transcript (prompts,
+// model responses and tool invocations) is written to the store.
+type Sessions struct {
+	store *persistence.Store
+	agent *Agent
+}
+
+func NewSessions(store *persistence.Store, agent *Agent) *Sessions {
+	return &Sessions{
+		store: store,
+		agent: agent,
+	}
+}
+
+// Chat answers prompt in the session with ID sessionId, creating a new session when
+// sessionId is 0. The transcript is saved even if the agent fails part-way.
+func (s *Sessions) Chat(ctx context.Context, sessionId int64, prompt string) (*models.ChatSession, *Result, error) {
+	return s.ChatStream(ctx, sessionId, prompt, nil)
+}
+
+// ChatStream is Chat that reports progress to emit, starting with an EventSession
+// event once the session is known. See Agent.RunStream.
+func (s *Sessions) ChatStream(ctx context.Context, sessionId int64, prompt string, emit EventFunc) (*models.ChatSession, *Result, error) {
+	session, history, err := s.open(ctx, sessionId, prompt)
+	if err != nil {
+		return nil, nil, err
+	}
+	emit.emit(EventSession, SessionEvent{SessionId: session.SessionId})
+	// New sessions get their ID only now; annotate the request too so its access
+	// log line carries it
+	ctx = logging.Annotate(ctx, "session_id", session.SessionId)
+
+	result, runErr := s.agent.RunStream(ctx, history, prompt, emit)
+	if result == nil {
+		return session, nil, runErr
+	}
+
+	if err := s.saveTurn(session.SessionId, result); err != nil {
+		return session, result, fmt.Errorf("failed to save chat transcript: %w", err)
+	}
+	return session, result, runErr
+}
+
+// History loads the messages of a session as agent context
+func (s *Sessions) History(sessionId int64) ([]Message, error) {
+	schemas, err := s.store.LoadChatMessages(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(schemas))
+	for _, schema := range schemas {
+		cm := &models.ChatMessage{}
+		cm.FromSchema(schema)
+
+		msg := Message{
+			Role:    Role(cm.Role),
+			Content: cm.Content,
+		}
+		if cm.ToolCalls != nil {
+			if err := json.Unmarshal([]byte(*cm.ToolCalls), &msg.ToolCalls); err != nil {
+				return nil, fmt.Errorf("corrupt tool calls in message %d: %w", cm.MessageId, err)
+			}
+		}
+		if cm.ToolCallId != nil {
+			msg.ToolCallId = *cm.ToolCallId
+		}
+		if cm.Name != nil {
+			msg.Name = *cm.Name
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (s *Sessions) open(ctx context.Context, sessionId int64, prompt string) (*models.ChatSession, []Message, error) {
+	session := &models.ChatSession{}
+
+	if sessionId == 0 {
+		now := time.Now()
+		session.Title = sessionTitle(prompt)
+		session.CreatedBy = auth.ActingUser(ctx)
+		session.WhenCreated = now
+		session.WhenUpdated = now
+
+		schema := session.ToSchema()
+		if err := s.store.SaveChatSession(schema); err != nil {
+			return nil, nil, fmt.Errorf("failed to create chat session: %w", err)
+		}
+		session.SessionId = schema.SessionId
+		return session, nil, nil
+	}
+
+	schema, err := s.store.LoadChatSession(sessionId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %d", ErrSessionNotFound, sessionId)
+	}
+	session.FromSchema(schema)
+
+	history, err := s.History(sessionId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load chat history: %w", err)
+	}
+	return session, history, nil
+}
+
+func (s *Sessions) saveTurn(sessionId int64, result *Result) error {
+	now := time.Now()
+
+	messages := make([]*schemas.ChatMessageSchema, 0, len(result.Messages))
+	for _, msg := range result.Messages {
+		cm := &models.ChatMessage{
+			SessionId:   sessionId,
+			Role:        string(msg.Role),
+			Content:     msg.Content,
+			WhenCreated: now,
+		}
+		if len(msg.ToolCalls) > 0 {
+			encoded, err := json.Marshal(msg.ToolCalls)
+			if err != nil {
+				return err
+			}
+			toolCalls := string(encoded)
+			cm.ToolCalls = &toolCalls
+		}
+		if msg.ToolCallId != "" {
+			toolCallId := msg.ToolCallId
+			cm.ToolCallId = &toolCallId
+		}
+		if msg.Name != "" {
+			name := msg.Name
+			cm.Name = &name
+		}
+		if msg.Usage != nil {
+			cm.PromptTokens = msg.Usage.PromptTokens
+			cm.CompletionTokens = msg.Usage.CompletionTokens
+		}
+		messages = append(messages, cm.ToSchema())
+	}
+
+	invocations := make([]*schemas.ToolInvocationSchema, 0, len(result.ToolCalls))
+	for _, call := range result.ToolCalls {
+		inv := &models.ToolInvocation{
+			SessionId:   sessionId,
+			ToolCallId:  call.Id,
+			ToolName:    call.Name,
+			Arguments:   string(ToolArguments(call.Arguments)),
+			DurationMs:  call.DurationMs,
+			WhenCreated: now,
+		}
+		if call.Error != "" {
+			callErr := call.Error
+			inv.Error = &callErr
+		} else {
+			summary := summarizeResult(call.Result)
+			inv.ResultSummary = &summary
+		}
+		invocations = append(invocations, inv.ToSchema())
+	}
+
+	return s.store.AppendChatTurn(sessionId, messages, invocations)
+}
+
+func sessionTitle(prompt string) string {
+	title := strings.Join(strings.Fields(prompt), " ")
+	if utf8.RuneCountInString(title) > maxSessionTitleLen {
+		title = string([]rune(title)[:maxSessionTitleLen-3]) + "..."
+	}
+	return title
+}
+
+func summarizeResult(result any) string {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("unencodable result: %v", err)
+	}
+	if len(encoded) > maxResultSummaryLen {
+		return string(encoded[:maxResultSummaryLen]) + "..."
+	}
+	return string(encoded)
+}
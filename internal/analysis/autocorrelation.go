@@ -0,0 +1,297 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"time"
+)
+
+// confidenceZ is the normal quantile of the 95% confidence bands
+const confidenceZ = 1.959963984540054
+
+// ACF is the sample autocorrelation of evenly spaced values at lags 0 to maxLag,
+// computed through the FFT. It is NaN at every lag for a constant series.
+func ACF(values []float64, maxLag int) []float64 {
+	n := len(values)
+	maxLag = min(maxLag, n-1)
+	if maxLag < 0 {
+		return nil
+	}
+
+	m := mean(values)
+	size := 1
+	for size < 2*n {
+		size <<= 1
+	}
+	buf := make([]complex128, size)
+	for i, v := range values {
+		buf[i] = complex(v-m, 0)
+	}
+
+	// The autocovariance is the inverse transform of the power, and the inverse
+	// transform is the conjugate of the transform of the conjugate
+	FFT(buf)
+	for i, c := range buf {
+		buf[i] = complex(real(c)*real(c)+imag(c)*imag(c), 0)
+	}
+	FFT(buf)
+
+	acf := make([]float64, maxLag+1)
+	c0 := real(cmplx.Conj(buf[0]))
+	for k := range acf {
+		if c0 <= 0 {
+			acf[k] = math.NaN()
+			continue
+		}
+		acf[k] = clampUnit(real(cmplx.Conj(buf[k])) / c0)
+	}
+	return acf
+}
+
+// PACF is the partial autocorrelation at lags 0 to len(acf)-1, derived from the
+// autocorrelation with the Durbin-Levinson recursion
+func PACF(acf []float64) []float64 {
+	pacf := make([]float64, len(acf))
+	if len(acf) == 0 {
+		return pacf
+	}
+	pacf[0] = 1
+
+	phi := make([]float64, len(acf))
+	prev := make([]float64, len(acf))
+	for k := 1; k < len(acf); k++ {
+		num, den := acf[k], 1.0
+		for j := 1; j < k; j++ {
+			num -= prev[j] * acf[k-j]
+			den -= prev[j] * acf[j]
+		}
+		if den == 0 || math.IsNaN(den) {
+			for ; k < len(acf); k++ {
+				pacf[k] = math.NaN()
+			}
+			break
+		}
+		phi[k] = num / den
+		for j := 1; j < k; j++ {
+			phi[j] = prev[j] - phi[k]*prev[k-j]
+		}
+		pacf[k] = clampUnit(phi[k])
+		copy(prev, phi)
+	}
+	return pacf
+}
+
+// ACFConfidence is the half-width of the 95% band around zero of the autocorrelation
+// at each lag of a series of n values, with Bartlett's formula: lag k may differ
+// from zero by chance more when the lags below it are correlated
+func ACFConfidence(acf []float64, n int) []float64 {
+	bands := make([]float64, len(acf))
+	sum := 0.0
+	for k := 1; k < len(acf); k++ {
+		bands[k] = confidenceZ * math.Sqrt((1+2*sum)/float64(n))
+		if !math.IsNaN(acf[k]) {
+			sum += acf[k] * acf[k]
+		}
+	}
+	return bands
+}
+
+// PACFConfidence is the half-width of the 95% band around zero of the partial
+// autocorrelation of a series of n values
+func PACFConfidence(n int) float64 {
+	return confidenceZ / math.Sqrt(float64(n))
+}
+
+// LjungBoxResult tests whether a series is white noise up to a number of lags
+type LjungBoxResult struct {
+	Lags int
+	Q    float64
+	// PValue of the null hypothesis that the series has no autocorrelation; a small
+	// value means its past predicts its future
+	PValue float64
+}
+
+// LjungBox runs the Ljung-Box test on evenly spaced values over lags 1 to lags
+func LjungBox(values []float64, lags int) (*LjungBoxResult, error) {
+	n := len(values)
+	if lags < 1 {
+		return nil, fmt.Errorf("lags must be at least 1")
+	}
+	if lags >= n {
+		return nil, fmt.Errorf("%w: %d lags need more than %d points", ErrTooFewPoints, lags, n)
+	}
+
+	acf := ACF(values, lags)
+	q := 0.0
+	for k := 1; k <= lags; k++ {
+		q += acf[k] * acf[k] / float64(n-k)
+	}
+	q *= float64(n) * float64(n+2)
+	return &LjungBoxResult{Lags: lags, Q: q, PValue: ChiSquarePValue(q, float64(lags))}, nil
+}
+
+// MinSeasonalStrength is the autocorrelation at its lag a period needs to count as
+// seasonal
+const MinSeasonalStrength = 0.3
+
+// calendarPeriods are the periods that detected ones close to them are snapped to
+var calendarPeriods = []time.Duration{
+	time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	time.Duration(365.2425 * 24 * float64(time.Hour)),
+}
+
+// SeasonalPeriod is a period a series repeats itself with
+type SeasonalPeriod struct {
+	Period time.Duration
+	// Lag is the period in steps of the series
+	Lag int
+	// Strength is the autocorrelation of the detrended series at Lag, from
+	// MinSeasonalStrength to 1
+	Strength float64
+}
+
+// DetectSeasonality finds up to n periods evenly spaced values repeat with, strongest
+// first. Candidates are the peaks of the periodogram, confirmed by the
+// autocorrelation at their lag, and periods within 5% of an hour, half a day, a
+// day, a week or a year are reported as exactly that.
+func DetectSeasonality(values []float64, interval time.Duration, n int) ([]SeasonalPeriod, error) {
+	if len(values) < 16 {
+		return nil, fmt.Errorf("%w: seasonality detection needs at least 16 points, have %d", ErrTooFewPoints, len(values))
+	}
+
+	detrended := detrend(values)
+	spectrum, err := Periodogram(detrended, interval)
+	if err != nil {
+		return nil, err
+	}
+	acf := ACF(detrended, len(values)/2)
+
+	byPeriod := map[time.Duration]SeasonalPeriod{}
+	for _, peak := range spectrum.Peaks(10) {
+		lag := int(math.Round(float64(peak.Period) / float64(interval)))
+		if lag < 2 || lag >= len(acf) {
+			continue
+		}
+
+		// The spectrum's resolution is coarse at long periods, so look for the
+		// strongest autocorrelation near the peak's lag
+		spread := max(lag/10, 1)
+		best := lag
+		for k := max(lag-spread, 2); k <= min(lag+spread, len(acf)-1); k++ {
+			if acf[k] > acf[best] {
+				best = k
+			}
+		}
+		if math.IsNaN(acf[best]) || acf[best] < MinSeasonalStrength {
+			continue
+		}
+
+		period := time.Duration(best) * interval
+		for _, calendar := range calendarPeriods {
+			if math.Abs(float64(period-calendar)) <= 0.05*float64(calendar) {
+				period = calendar
+				break
+			}
+		}
+		// Neighbouring peaks can settle on the same period
+		found := SeasonalPeriod{Period: period, Lag: best, Strength: acf[best]}
+		if other, ok := byPeriod[period]; !ok || found.Strength > other.Strength {
+			byPeriod[period] = found
+		}
+	}
+
+	periods := make([]SeasonalPeriod, 0, len(byPeriod))
+	for _, p := range byPeriod {
+		periods = append(periods, p)
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		if periods[i].Strength != periods[j].Strength {
+			return periods[i].Strength > periods[j].Strength
+		}
+		return periods[i].Lag < periods[j].Lag
+	})
+	if len(periods) > n {
+		periods = periods[:n]
+	}
+	return periods, nil
+}
+
+// MaxAutocorrelationLag limits the lags of Autocorrelate, in steps
+const MaxAutocorrelationLag = 1000
+
+// AutocorrelationOptions select the diagnostics of Autocorrelate, in steps of the
+// evenly spaced series
+type AutocorrelationOptions struct {
+	// MaxLag bounds the ACF and PACF; zero uses a quarter of the series, at most
+	// 200 steps
+	MaxLag int
+	// LjungBoxLags is the number of lags tested; zero uses 10, or a fifth of the
+	// series when that is fewer
+	LjungBoxLags int
+	// Seasonality is the number of seasonal periods to look for
+	Seasonality int
+}
+
+// AutocorrelationReport describes how an evenly spaced series relates to its past
+type AutocorrelationReport struct {
+	N    int
+	ACF  []float64
+	PACF []float64
+	// ACFBands holds the half-width of the 95% band of each lag of ACF; PACFBand is
+	// the same for every lag of PACF
+	ACFBands []float64
+	PACFBand float64
+	LjungBox *LjungBoxResult
+	// Seasonality is nil, and SeasonalityError says why, when the series is too
+	// short to look for periods
+	Seasonality      []SeasonalPeriod
+	SeasonalityError error
+}
+
+// Autocorrelate runs every diagnostic of a single series
+func Autocorrelate(values []float64, interval time.Duration, opts AutocorrelationOptions) (*AutocorrelationReport, error) {
+	n := len(values)
+	if n < 3 {
+		return nil, fmt.Errorf("%w: need at least 3 points, have %d", ErrTooFewPoints, n)
+	}
+
+	maxLag := opts.MaxLag
+	if maxLag <= 0 {
+		maxLag = min(max(n/4, 1), defaultMaxLag)
+	}
+	if maxLag > MaxAutocorrelationLag {
+		return nil, fmt.Errorf("max lag of %d steps is more than %d", maxLag, MaxAutocorrelationLag)
+	}
+	maxLag = min(maxLag, n-1)
+
+	lags := opts.LjungBoxLags
+	if lags <= 0 {
+		lags = min(10, max(n/5, 1))
+	}
+	if lags > MaxAutocorrelationLag {
+		return nil, fmt.Errorf("Ljung-Box lags of %d is more than %d", lags, MaxAutocorrelationLag)
+	}
+	ljungBox, err := LjungBox(values, lags)
+	if err != nil {
+		return nil, err
+	}
+
+	acf := ACF(values, maxLag)
+	report := &AutocorrelationReport{
+		N:        n,
+		ACF:      acf,
+		PACF:     PACF(acf),
+		ACFBands: ACFConfidence(acf, n),
+		PACFBand: PACFConfidence(n),
+		LjungBox: ljungBox,
+	}
+	if opts.Seasonality > 0 {
+		report.Seasonality, report.SeasonalityError = DetectSeasonality(values, interval, opts.Seasonality)
+	}
+	return report, nil
+}
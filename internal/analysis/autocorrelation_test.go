@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// ar1 is n steps of x[t] = phi*x[t-1] + noise
+func ar1(phi float64, n int) []float64 {
+	noise := whiteNoise(3, n)
+	values := make([]float64, n)
+	for i := 1; i < n; i++ {
+		values[i] = phi*values[i-1] + noise[i]
+	}
+	return values
+}
+
+func TestPACFOfAR1Autocorrelation(t *testing.T) {
+	// The autocorrelation of an AR(1) process is phi^k, and its partial
+	// autocorrelation is phi at lag 1 and zero beyond
+	const phi = 0.7
+	acf := []float64{1, phi, phi * phi, phi * phi * phi, phi * phi * phi * phi}
+	for k, want := range []float64{1, phi, 0, 0, 0} {
+		if got := PACF(acf)[k]; math.Abs(got-want) > 1e-12 {
+			t.Errorf("PACF[%d] = %v, want %v", k, got, want)
+		}
+	}
+}
+
+func TestACFOfAR1Process(t *testing.T) {
+	const phi = 0.7
+	acf := ACF(ar1(phi, 5000), 5)
+	pacf := PACF(acf)
+	for k := 0; k <= 5; k++ {
+		if want := math.Pow(phi, float64(k)); math.Abs(acf[k]-want) > 0.05 {
+			t.Errorf("ACF[%d] = %v, want about %v", k, acf[k], want)
+		}
+		want := 0.0
+		switch k {
+		case 0:
+			want = 1
+		case 1:
+			want = phi
+		}
+		if math.Abs(pacf[k]-want) > 0.05 {
+			t.Errorf("PACF[%d] = %v, want about %v", k, pacf[k], want)
+		}
+	}
+}
+
+func TestACFMatchesDirectSum(t *testing.T) {
+	values := []float64{2, 7, 1, 8, 2, 8, 1, 8, 2, 8}
+	acf := ACF(values, 3)
+
+	m := mean(values)
+	c0 := 0.0
+	for _, v := range values {
+		c0 += (v - m) * (v - m)
+	}
+	for k := 0; k <= 3; k++ {
+		ck := 0.0
+		for i := 0; i+k < len(values); i++ {
+			ck += (values[i] - m) * (values[i+k] - m)
+		}
+		if math.Abs(acf[k]-ck/c0) > 1e-12 {
+			t.Errorf("ACF[%d] = %v, want %v", k, acf[k], ck/c0)
+		}
+	}
+}
+
+func TestLjungBox(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		values         []float64
+		autocorrelated bool
+	}{
+		{"white noise", whiteNoise(4, 1000), false},
+		{"AR(1)", ar1(0.5, 1000), true},
+	} {
+		lb, err := LjungBox(tc.values, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := lb.PValue < Significance; got != tc.autocorrelated {
+			t.Errorf("%s: Q = %v, p = %v, want autocorrelated %v", tc.name, lb.Q, lb.PValue, tc.autocorrelated)
+		}
+	}
+}
+
+func TestDetectSeasonalityFindsDailyPeriod(t *testing.T) {
+	noise := whiteNoise(5, 30*24)
+	values := make([]float64, len(noise))
+	for i := range values {
+		values[i] = 10*math.Sin(2*math.Pi*float64(i)/24) + noise[i]
+	}
+
+	periods, err := DetectSeasonality(values, time.Hour, 1)
+	if err != nil {
+		t.Fatalf("DetectSeasonality: %v", err)
+	}
+	if len(periods) != 1 || periods[0].Period != 24*time.Hour || periods[0].Strength < 0.8 {
+		t.Errorf("periods = %+v, want 24h with a strong autocorrelation", periods)
+	}
+}
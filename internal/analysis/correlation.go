@@ -0,0 +1,211 @@
+// Package analysis holds the statistics behind the analysis endpoints and tools.
+// Functions take evenly spaced series as slices of values, where a lag or window
+// is a number of steps; NaN values are skipped where noted.
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// Correlation is a correlation coefficient with its significance
+type Correlation struct {
+	// R is the coefficient, NaN when it is undefined, e.g. for a constant series
+	R float64
+	// PValue of the null hypothesis that the series are uncorrelated
+	PValue float64
+	// N is the number of pairs it was computed over
+	N int
+}
+
+// LagCorrelation is the Pearson correlation of x[t] with y[t+Lag]. A positive lag
+// correlates x with later values of y, so a peak at a positive lag means x leads y.
+type LagCorrelation struct {
+	Lag int
+	R   float64
+	N   int
+}
+
+// Pearson is the linear correlation of x and y over the pairs where both are numbers
+func Pearson(x, y []float64) Correlation {
+	r, n := pearson(x, y)
+	return Correlation{R: r, PValue: correlationPValue(r, n), N: n}
+}
+
+// Spearman is the rank correlation of x and y over the pairs where both are numbers.
+// Tied values get their average rank.
+func Spearman(x, y []float64) Correlation {
+	xs, ys := finitePairs(x, y)
+	r, n := pearson(ranks(xs), ranks(ys))
+	return Correlation{R: r, PValue: correlationPValue(r, n), N: n}
+}
+
+// CrossCorrelation correlates x[t] with y[t+lag] for every lag from -maxLag to maxLag
+func CrossCorrelation(x, y []float64, maxLag int) []LagCorrelation {
+	n := min(len(x), len(y))
+	maxLag = min(maxLag, n-1)
+
+	lags := make([]LagCorrelation, 0, 2*maxLag+1)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		var xs, ys []float64
+		if lag >= 0 {
+			xs, ys = x[:n-lag], y[lag:n]
+		} else {
+			xs, ys = x[-lag:n], y[:n+lag]
+		}
+		r, count := pearson(xs, ys)
+		lags = append(lags, LagCorrelation{Lag: lag, R: r, N: count})
+	}
+	return lags
+}
+
+// BestLag is the lag with the strongest correlation, positive or negative. Of equally
+// strong lags the one closest to zero wins. ok is false when no lag has a correlation.
+func BestLag(lags []LagCorrelation) (best LagCorrelation, ok bool) {
+	for _, l := range lags {
+		if math.IsNaN(l.R) {
+			continue
+		}
+		stronger := math.Abs(l.R) > math.Abs(best.R)
+		tie := math.Abs(l.R) == math.Abs(best.R) && abs(l.Lag) < abs(best.Lag)
+		if !ok || stronger || tie {
+			best, ok = l, true
+		}
+	}
+	return best, ok
+}
+
+// RollingCorrelation is the Pearson correlation of x and y over the window steps
+// ending at each step, NaN until a window has at least 3 pairs
+func RollingCorrelation(x, y []float64, window int) []float64 {
+	n := min(len(x), len(y))
+	out := make([]float64, n)
+
+	var sx, sy, sxx, syy, sxy float64
+	count := 0
+	update := func(i int, sign float64) {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			return
+		}
+		sx += sign * x[i]
+		sy += sign * y[i]
+		sxx += sign * x[i] * x[i]
+		syy += sign * y[i] * y[i]
+		sxy += sign * x[i] * y[i]
+		count += int(sign)
+	}
+
+	for i := 0; i < n; i++ {
+		update(i, 1)
+		if i >= window {
+			update(i-window, -1)
+		}
+		out[i] = math.NaN()
+		if count < 3 || i < window-1 {
+			continue
+		}
+		c := float64(count)
+		cov := sxy - sx*sy/c
+		vx := sxx - sx*sx/c
+		vy := syy - sy*sy/c
+		if vx > 0 && vy > 0 {
+			out[i] = clampUnit(cov / math.Sqrt(vx*vy))
+		}
+	}
+	return out
+}
+
+// pearson returns the correlation of the pairs of x and y that are both numbers, and
+// how many there are
+func pearson(x, y []float64) (float64, int) {
+	xs, ys := finitePairs(x, y)
+	n := len(xs)
+	if n < 3 {
+		return math.NaN(), n
+	}
+
+	mx, my := mean(xs), mean(ys)
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return math.NaN(), n
+	}
+	return clampUnit(cov / math.Sqrt(vx*vy)), n
+}
+
+// correlationPValue tests a correlation of r over n pairs against zero with the t
+// distribution
+func correlationPValue(r float64, n int) float64 {
+	if math.IsNaN(r) || n < 3 {
+		return math.NaN()
+	}
+	if math.Abs(r) == 1 {
+		return 0
+	}
+	df := float64(n - 2)
+	return StudentTPValue(r*math.Sqrt(df/(1-r*r)), df)
+}
+
+func finitePairs(x, y []float64) ([]float64, []float64) {
+	n := min(len(x), len(y))
+	xs := make([]float64, 0, n)
+	ys := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		if isFinite(x[i]) && isFinite(y[i]) {
+			xs = append(xs, x[i])
+			ys = append(ys, y[i])
+		}
+	}
+	return xs, ys
+}
+
+// ranks returns the rank of each value, 1-based, averaging the ranks of ties
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+
+	out := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			out[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return out
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func clampUnit(r float64) float64 {
+	return math.Max(-1, math.Min(1, r))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
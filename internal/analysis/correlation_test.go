@@ -0,0 +1,61 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// whiteNoise is n standard normal values from a fixed seed
+func whiteNoise(seed int64, n int) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = rng.NormFloat64()
+	}
+	return values
+}
+
+func TestCorrelation(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5}
+	for _, tc := range []struct {
+		name string
+		got  Correlation
+		want float64
+	}{
+		{"pearson linear", Pearson(x, []float64{2, 4, 6, 8, 10}), 1},
+		{"pearson inverse", Pearson(x, []float64{10, 8, 6, 4, 2}), -1},
+		{"pearson", Pearson(x, []float64{2, 4, 5, 4, 5}), math.Sqrt(0.6)},
+		{"spearman monotonic", Spearman(x, []float64{1, 8, 27, 64, 125}), 1},
+		// Ranks 1, 2, 4, 2, 4 with ties averaged: 1, 2.5, 4.5, 2.5, 4.5
+		{"spearman ties", Spearman(x, []float64{2, 4, 5, 4, 5}), 0.7378647873726218},
+		{"skips NaN", Pearson([]float64{1, 2, math.NaN(), 3}, []float64{2, 4, 0, 6}), 1},
+	} {
+		if math.Abs(tc.got.R-tc.want) > 1e-12 {
+			t.Errorf("%s: r = %v, want %v", tc.name, tc.got.R, tc.want)
+		}
+	}
+
+	// r = sqrt(0.6) over 5 pairs is t = r*sqrt(3/(1-r^2)) with 3 degrees of freedom
+	c := Pearson(x, []float64{2, 4, 5, 4, 5})
+	if want := StudentTPValue(math.Sqrt(0.6)*math.Sqrt(3/0.4), 3); c.N != 5 || math.Abs(c.PValue-want) > 1e-12 {
+		t.Errorf("p = %v over %d pairs, want %v over 5", c.PValue, c.N, want)
+	}
+	if c := Pearson(x, []float64{3, 3, 3, 3, 3}); !math.IsNaN(c.R) {
+		t.Errorf("r with a constant series = %v, want NaN", c.R)
+	}
+}
+
+func TestBestLagFindsLead(t *testing.T) {
+	// y follows x three steps later
+	x := whiteNoise(1, 300)
+	y := make([]float64, len(x))
+	for i := 3; i < len(y); i++ {
+		y[i] = x[i-3]
+	}
+
+	best, ok := BestLag(CrossCorrelation(x, y, 10))
+	if !ok || best.Lag != 3 || best.R < 0.99 {
+		t.Errorf("best lag = %+v, want 3 with r near 1", best)
+	}
+}
@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPValues(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		// Student's t with 1 degree of freedom is the Cauchy distribution
+		{"t=1 df=1", StudentTPValue(1, 1), 0.5},
+		{"t=2 df=10", StudentTPValue(2, 10), 0.0733880347707404},
+		{"t=2.228 df=10", StudentTPValue(2.228138851986274, 10), 0.05},
+		{"t=-2.228 df=10", StudentTPValue(-2.228138851986274, 10), 0.05},
+		// With 2 numerator degrees of freedom P(F > f) = (1 + 2f/d2)^(-d2/2)
+		{"F=3 df=2,10", FPValue(3, 2, 10), math.Pow(1.6, -5)},
+		{"F=4.965 df=1,10", FPValue(4.9646027437307145, 1, 10), 0.05},
+		{"F=0 df=2,10", FPValue(0, 2, 10), 1},
+		{"chi2=3.841 df=1", ChiSquarePValue(3.841458820694124, 1), 0.05},
+		// With 2 degrees of freedom P(X > x) = exp(-x/2)
+		{"chi2=4 df=2", ChiSquarePValue(4, 2), math.Exp(-2)},
+		{"chi2=18.307 df=10", ChiSquarePValue(18.307038053275146, 10), 0.05},
+		// With 10 degrees of freedom P(X > x) = exp(-x/2) * sum of (x/2)^i/i! for i < 5
+		{"chi2=0.5 df=10", ChiSquarePValue(0.5, 10), math.Exp(-0.25) * (1 + 0.25 + 0.25*0.25/2 + 0.25*0.25*0.25/6 + 0.25*0.25*0.25*0.25/24)},
+	} {
+		if math.Abs(tc.got-tc.want) > 1e-8 {
+			t.Errorf("%s: p = %.12g, want %.12g", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestPValuesOfUndefinedStatistics(t *testing.T) {
+	if p := StudentTPValue(math.NaN(), 10); !math.IsNaN(p) {
+		t.Errorf("StudentTPValue(NaN) = %v, want NaN", p)
+	}
+	if p := StudentTPValue(math.Inf(1), 10); p != 0 {
+		t.Errorf("StudentTPValue(+Inf) = %v, want 0", p)
+	}
+	if p := FPValue(math.Inf(1), 2, 10); p != 0 {
+		t.Errorf("FPValue(+Inf) = %v, want 0", p)
+	}
+	if p := ChiSquarePValue(2, 0); !math.IsNaN(p) {
+		t.Errorf("ChiSquarePValue with df 0 = %v, want NaN", p)
+	}
+}
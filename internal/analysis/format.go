@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// FormatDuration writes a duration the way people say it: 45m, 1h30m, 7d, 2d12h.
+// Durations under a second keep their fraction, e.g. 0.25s.
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + FormatDuration(-d)
+	}
+	if d < time.Second {
+		return fmt.Sprintf("%gs", d.Seconds())
+	}
+
+	var b strings.Builder
+	for _, u := range []struct {
+		suffix string
+		length time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	} {
+		if n := d / u.length; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, u.suffix)
+			d -= n * u.length
+		}
+	}
+	return b.String()
+}
+
+// DescribeLead summarizes the best lag of a cross-correlation between series named
+// x and y with the given interval, e.g. "Chiller load leads Zone temperature by 45m
+// (r = 0.82)"
+func DescribeLead(x, y string, best *LagCorrelation, interval time.Duration) string {
+	if best == nil {
+		return fmt.Sprintf("%s and %s are not correlated at any lag", x, y)
+	}
+	lag := time.Duration(best.Lag) * interval
+	r := fmt.Sprintf("r = %.2f", best.R)
+	switch {
+	case best.Lag > 0:
+		return fmt.Sprintf("%s leads %s by %s (%s)", x, y, FormatDuration(lag), r)
+	case best.Lag < 0:
+		return fmt.Sprintf("%s leads %s by %s (%s)", y, x, FormatDuration(-lag), r)
+	default:
+		return fmt.Sprintf("%s and %s move together without a lag (%s)", x, y, r)
+	}
+}
+
+// Significance is the p-value below which a Granger or Ljung-Box test is reported as
+// significant
+const Significance = 0.05
+
+// FiniteOrNil maps values JSON cannot hold, NaN and ±Inf, to null
+func FiniteOrNil(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+// CorrelationInfo reports a Correlation in the API and tool results
+type CorrelationInfo struct {
+	// R is null when the correlation is undefined, e.g. for a constant series
+	R      *float64 `json:"r"`
+	PValue *float64 `json:"p_value"`
+	N      int      `json:"n"`
+}
+
+func NewCorrelationInfo(c Correlation) CorrelationInfo {
+	return CorrelationInfo{R: FiniteOrNil(c.R), PValue: FiniteOrNil(c.PValue), N: c.N}
+}
+
+// LagCorrelationInfo reports a LagCorrelation, with the lag in steps of interval and
+// in time
+type LagCorrelationInfo struct {
+	LagSteps   int      `json:"lag_steps"`
+	Lag        string   `json:"lag"`
+	LagSeconds float64  `json:"lag_seconds"`
+	R          *float64 `json:"r"`
+	N          int      `json:"n"`
+}
+
+func NewLagCorrelationInfo(l LagCorrelation, interval time.Duration) LagCorrelationInfo {
+	lag := time.Duration(l.Lag) * interval
+	return LagCorrelationInfo{
+		LagSteps:   l.Lag,
+		Lag:        FormatDuration(lag),
+		LagSeconds: lag.Seconds(),
+		R:          FiniteOrNil(l.R),
+		N:          l.N,
+	}
+}
+
+// GrangerInfo reports a GrangerResult between two datasources
+type GrangerInfo struct {
+	// Cause is tested for helping predict Effect
+	Cause       int64    `json:"cause"`
+	Effect      int64    `json:"effect"`
+	Lags        int      `json:"lags"`
+	Lag         string   `json:"lag"`
+	F           *float64 `json:"f"`
+	PValue      *float64 `json:"p_value"`
+	DfNum       int      `json:"df_num"`
+	DfDen       int      `json:"df_den"`
+	Significant bool     `json:"significant"`
+}
+
+func NewGrangerInfo(cause, effect int64, g *GrangerResult, interval time.Duration) GrangerInfo {
+	return GrangerInfo{
+		Cause:       cause,
+		Effect:      effect,
+		Lags:        g.Lags,
+		Lag:         FormatDuration(time.Duration(g.Lags) * interval),
+		F:           FiniteOrNil(g.F),
+		PValue:      FiniteOrNil(g.PValue),
+		DfNum:       g.DfNum,
+		DfDen:       g.DfDen,
+		Significant: g.PValue < Significance,
+	}
+}
+
+// LjungBoxInfo reports a LjungBoxResult, with its lags in steps of interval and in
+// time
+type LjungBoxInfo struct {
+	Lags   int      `json:"lags"`
+	Lag    string   `json:"lag"`
+	Q      *float64 `json:"q"`
+	PValue *float64 `json:"p_value"`
+	// Autocorrelated is set when the p-value rejects white noise at 5%
+	Autocorrelated bool `json:"autocorrelated"`
+}
+
+func NewLjungBoxInfo(lb *LjungBoxResult, interval time.Duration) LjungBoxInfo {
+	return LjungBoxInfo{
+		Lags:           lb.Lags,
+		Lag:            FormatDuration(time.Duration(lb.Lags) * interval),
+		Q:              FiniteOrNil(lb.Q),
+		PValue:         FiniteOrNil(lb.PValue),
+		Autocorrelated: lb.PValue < Significance,
+	}
+}
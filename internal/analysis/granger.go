@@ -0,0 +1,181 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrTooFewPoints is returned when a series is too short for an analysis
+	ErrTooFewPoints = errors.New("not enough points")
+	// ErrSingular is returned when a regression has no unique solution, such as
+	// for a constant series
+	ErrSingular = errors.New("singular regression")
+)
+
+// GrangerResult is an F test of whether past values of one series improve the
+// prediction of another beyond its own past values
+type GrangerResult struct {
+	Lags   int
+	F      float64
+	PValue float64
+	DfNum  int
+	DfDen  int
+}
+
+// Granger tests whether cause Granger-causes effect with the given number of lags.
+// It compares the regression of effect[t] on effect[t-1..t-lags] against the one
+// that adds cause[t-1..t-lags]; a small p-value means cause helps predict effect.
+// Both series must be numbers at every step.
+func Granger(cause, effect []float64, lags int) (*GrangerResult, error) {
+	n := min(len(cause), len(effect))
+	if lags < 1 {
+		return nil, errors.New("lags must be at least 1")
+	}
+	rows := n - lags
+	dfDen := rows - (2*lags + 1)
+	if dfDen < 1 {
+		return nil, fmt.Errorf("%w: %d lags need more than %d points, have %d", ErrTooFewPoints, lags, 3*lags+1, n)
+	}
+	for i := 0; i < n; i++ {
+		if !isFinite(cause[i]) || !isFinite(effect[i]) {
+			return nil, errors.New("series must not have missing values")
+		}
+	}
+
+	// Standardizing does not change the F statistic but keeps the normal equations
+	// well conditioned for values like 1e6 W
+	x, y := standardize(cause[:n]), standardize(effect[:n])
+
+	target := make([]float64, rows)
+	restricted := make([][]float64, rows)
+	unrestricted := make([][]float64, rows)
+	for r := 0; r < rows; r++ {
+		t := r + lags
+		target[r] = y[t]
+		row := make([]float64, 1, 2*lags+1)
+		row[0] = 1
+		for k := 1; k <= lags; k++ {
+			row = append(row, y[t-k])
+		}
+		restricted[r] = row
+		full := append(append([]float64(nil), row...), make([]float64, lags)...)
+		for k := 1; k <= lags; k++ {
+			full[lags+k] = x[t-k]
+		}
+		unrestricted[r] = full
+	}
+
+	rssRestricted, err := residualSumOfSquares(restricted, target)
+	if err != nil {
+		return nil, err
+	}
+	rssUnrestricted, err := residualSumOfSquares(unrestricted, target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GrangerResult{Lags: lags, DfNum: lags, DfDen: dfDen}
+	improvement := math.Max(rssRestricted-rssUnrestricted, 0)
+	if rssUnrestricted <= 0 {
+		result.F = math.Inf(1)
+	} else {
+		result.F = (improvement / float64(lags)) / (rssUnrestricted / float64(dfDen))
+	}
+	result.PValue = FPValue(result.F, float64(lags), float64(dfDen))
+	return result, nil
+}
+
+// residualSumOfSquares fits target to the columns of rows by least squares, solving
+// the normal equations, and returns the sum of squared residuals
+func residualSumOfSquares(rows [][]float64, target []float64) (float64, error) {
+	k := len(rows[0])
+	xtx := make([][]float64, k)
+	for i := range xtx {
+		xtx[i] = make([]float64, k+1)
+	}
+	for r, row := range rows {
+		for i := 0; i < k; i++ {
+			for j := i; j < k; j++ {
+				xtx[i][j] += row[i] * row[j]
+			}
+			xtx[i][k] += row[i] * target[r]
+		}
+	}
+	for i := 0; i < k; i++ {
+		for j := 0; j < i; j++ {
+			xtx[i][j] = xtx[j][i]
+		}
+	}
+
+	beta, err := solve(xtx)
+	if err != nil {
+		return 0, err
+	}
+
+	rss := 0.0
+	for r, row := range rows {
+		fitted := 0.0
+		for i, v := range row {
+			fitted += beta[i] * v
+		}
+		d := target[r] - fitted
+		rss += d * d
+	}
+	return rss, nil
+}
+
+// solve solves the augmented system a by Gaussian elimination with partial pivoting
+func solve(a [][]float64) ([]float64, error) {
+	k := len(a)
+	for col := 0; col < k; col++ {
+		pivot := col
+		for r := col + 1; r < k; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-10 {
+			return nil, ErrSingular
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for r := col + 1; r < k; r++ {
+			f := a[r][col] / a[col][col]
+			for c := col; c <= k; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+		}
+	}
+
+	x := make([]float64, k)
+	for r := k - 1; r >= 0; r-- {
+		sum := a[r][k]
+		for c := r + 1; c < k; c++ {
+			sum -= a[r][c] * x[c]
+		}
+		x[r] = sum / a[r][r]
+	}
+	return x, nil
+}
+
+// standardize returns values shifted to mean 0 and scaled to standard deviation 1,
+// or only shifted when they are constant
+func standardize(values []float64) []float64 {
+	m := mean(values)
+	sq := 0.0
+	for _, v := range values {
+		sq += (v - m) * (v - m)
+	}
+	sd := math.Sqrt(sq / float64(len(values)))
+	if sd == 0 {
+		sd = 1
+	}
+
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = (v - m) / sd
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGrangerDetectsKnownLag(t *testing.T) {
+	// effect follows cause two steps later, plus its own noise
+	cause := whiteNoise(1, 500)
+	noise := whiteNoise(2, 500)
+	effect := make([]float64, len(cause))
+	for i := range effect {
+		effect[i] = 0.3 * noise[i]
+		if i >= 2 {
+			effect[i] += 0.8 * cause[i-2]
+		}
+	}
+
+	forward, err := Granger(cause, effect, 2)
+	if err != nil {
+		t.Fatalf("Granger(cause, effect): %v", err)
+	}
+	if forward.PValue > 1e-6 || forward.DfNum != 2 || forward.DfDen != 500-2-5 {
+		t.Errorf("cause -> effect = %+v, want a significant F with 2 and 493 degrees of freedom", forward)
+	}
+
+	backward, err := Granger(effect, cause, 2)
+	if err != nil {
+		t.Fatalf("Granger(effect, cause): %v", err)
+	}
+	if backward.PValue < 0.05 {
+		t.Errorf("effect -> cause = %+v, want no significant F", backward)
+	}
+
+	// One lag misses the cause entirely
+	short, err := Granger(cause, effect, 1)
+	if err != nil {
+		t.Fatalf("Granger with 1 lag: %v", err)
+	}
+	if short.PValue < 0.01 {
+		t.Errorf("cause -> effect with 1 lag = %+v, want no significant F", short)
+	}
+}
+
+func TestGrangerErrors(t *testing.T) {
+	if _, err := Granger(whiteNoise(1, 10), whiteNoise(2, 10), 4); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("4 lags over 10 points: error = %v, want ErrTooFewPoints", err)
+	}
+	constant := make([]float64, 50)
+	if _, err := Granger(constant, whiteNoise(2, 50), 2); !errors.Is(err, ErrSingular) {
+		t.Errorf("constant cause: error = %v, want ErrSingular", err)
+	}
+}
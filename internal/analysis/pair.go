@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits of Correlate, in steps
+const (
+	MaxCrossCorrelationLag = 1000
+	MaxGrangerLags         = 24
+	defaultMaxLag          = 200
+)
+
+// CorrelationOptions select the analyses of Correlate. Lags and windows are steps
+// of the evenly spaced series.
+type CorrelationOptions struct {
+	// MaxLag bounds the cross-correlation; zero uses a quarter of the series, at
+	// most 200 steps
+	MaxLag int
+	// RollingWindow computes a rolling correlation over this many steps; zero skips it
+	RollingWindow int
+	// GrangerLags is the order of the Granger tests; zero uses the best lag of the
+	// cross-correlation, at least 1 and at most MaxGrangerLags
+	GrangerLags int
+}
+
+// CorrelationReport relates two evenly spaced series x and y
+type CorrelationReport struct {
+	N                int
+	Pearson          Correlation
+	Spearman         Correlation
+	CrossCorrelation []LagCorrelation
+	// BestLag is nil when no lag has a correlation, e.g. for a constant series
+	BestLag *LagCorrelation
+	// Rolling is aligned with the series, NaN until the first full window
+	Rolling []float64
+	// GrangerXY tests whether x helps predict y, and GrangerYX the reverse. They are
+	// nil, and GrangerError says why, when the tests cannot be run.
+	GrangerXY    *GrangerResult
+	GrangerYX    *GrangerResult
+	GrangerError error
+}
+
+// Correlate runs every pairwise analysis on x and y
+func Correlate(x, y []float64, opts CorrelationOptions) (*CorrelationReport, error) {
+	n := min(len(x), len(y))
+	if n < 3 {
+		return nil, fmt.Errorf("%w: need at least 3 aligned points, have %d", ErrTooFewPoints, n)
+	}
+	x, y = x[:n], y[:n]
+
+	maxLag := opts.MaxLag
+	if maxLag <= 0 {
+		maxLag = min(max(n/4, 1), defaultMaxLag)
+	}
+	if maxLag > MaxCrossCorrelationLag {
+		return nil, fmt.Errorf("max lag of %d steps is more than %d", maxLag, MaxCrossCorrelationLag)
+	}
+	if opts.GrangerLags > MaxGrangerLags {
+		return nil, fmt.Errorf("granger lags of %d is more than %d", opts.GrangerLags, MaxGrangerLags)
+	}
+
+	report := &CorrelationReport{
+		N:                n,
+		Pearson:          Pearson(x, y),
+		Spearman:         Spearman(x, y),
+		CrossCorrelation: CrossCorrelation(x, y, maxLag),
+	}
+	if best, ok := BestLag(report.CrossCorrelation); ok {
+		report.BestLag = &best
+	}
+	if opts.RollingWindow > 0 {
+		report.Rolling = RollingCorrelation(x, y, opts.RollingWindow)
+	}
+
+	lags := opts.GrangerLags
+	if lags <= 0 {
+		lags = 1
+		if report.BestLag != nil {
+			lags = min(max(abs(report.BestLag.Lag), 1), MaxGrangerLags)
+		}
+	}
+	report.GrangerXY, report.GrangerError = Granger(x, y, lags)
+	if report.GrangerError == nil {
+		report.GrangerYX, report.GrangerError = Granger(y, x, lags)
+	}
+	if report.GrangerError != nil {
+		report.GrangerXY = nil
+	}
+	return report, nil
+}
+
+// Steps converts a duration to a whole number of steps of interval, rounding up
+func Steps(d, interval time.Duration) int {
+	if interval <= 0 || d <= 0 {
+		return 0
+	}
+	return int((d + interval - 1) / interval)
+}
@@ -0,0 +1,290 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Spectrum methods
+const (
+	// MethodPeriodogram transforms the whole series at once, for the finest frequency
+	// resolution
+	MethodPeriodogram = "periodogram"
+	// MethodWelch averages the periodograms of overlapping segments, trading
+	// resolution for a less noisy estimate
+	MethodWelch = "welch"
+)
+
+// MinPeakShare is the share of the total power below which Peaks ignores a peak as noise
+const MinPeakShare = 0.01
+
+// Spectrum is the one-sided power spectral density of an evenly spaced series.
+// Power is in squared value units per hertz.
+type Spectrum struct {
+	Method      string
+	Frequencies []float64
+	Power       []float64
+	// SegmentLength is the number of steps transformed at once, zero padded to a
+	// power of two, and Segments how many were averaged
+	SegmentLength int
+	Segments      int
+	// Span is the time covered by one segment; longer periods cannot be resolved
+	Span time.Duration
+}
+
+// SpectralPeak is a frequency where the spectrum has a local maximum
+type SpectralPeak struct {
+	Frequency float64
+	Period    time.Duration
+	Power     float64
+	// Share is the peak's power as a share of the total power without the mean
+	Share float64
+}
+
+// FFT computes the discrete Fourier transform of x in place. len(x) must be a power
+// of two.
+func FFT(x []complex128) {
+	n := len(x)
+	if n&(n-1) != 0 {
+		panic(fmt.Sprintf("analysis: FFT of length %d, not a power of two", n))
+	}
+
+	// Bit reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// ComputeSpectrum estimates the spectrum of evenly spaced values with method, the
+// periodogram when it is empty. segment is the length of the Welch segments; zero
+// uses a quarter of the series.
+func ComputeSpectrum(values []float64, interval time.Duration, method string, segment time.Duration) (*Spectrum, error) {
+	switch method {
+	case "", MethodPeriodogram:
+		return Periodogram(values, interval)
+	case MethodWelch:
+		return Welch(values, interval, Steps(segment, interval))
+	default:
+		return nil, fmt.Errorf("unknown method %q, expected %s or %s", method, MethodPeriodogram, MethodWelch)
+	}
+}
+
+// Periodogram estimates the spectrum of evenly spaced values from a single
+// Hann-windowed transform of the whole series
+func Periodogram(values []float64, interval time.Duration) (*Spectrum, error) {
+	return welch(values, interval, len(values), MethodPeriodogram)
+}
+
+// Welch estimates the spectrum of evenly spaced values by averaging the
+// periodograms of Hann-windowed segments of segmentLength steps overlapping by
+// half. A segmentLength of zero uses a quarter of the series.
+func Welch(values []float64, interval time.Duration, segmentLength int) (*Spectrum, error) {
+	if segmentLength <= 0 {
+		segmentLength = len(values) / 4
+	}
+	return welch(values, interval, min(segmentLength, len(values)), MethodWelch)
+}
+
+func welch(values []float64, interval time.Duration, segmentLength int, method string) (*Spectrum, error) {
+	if segmentLength < 8 {
+		return nil, fmt.Errorf("%w: a spectrum needs segments of at least 8 points, have %d", ErrTooFewPoints, segmentLength)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+	for _, v := range values {
+		if !isFinite(v) {
+			return nil, fmt.Errorf("series must not have missing values")
+		}
+	}
+
+	size := 1
+	for size < segmentLength {
+		size <<= 1
+	}
+	fs := 1 / interval.Seconds()
+
+	window := make([]float64, segmentLength)
+	windowPower := 0.0
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(segmentLength))
+		windowPower += window[i] * window[i]
+	}
+
+	bins := size/2 + 1
+	spectrum := &Spectrum{
+		Method:        method,
+		Frequencies:   make([]float64, bins),
+		Power:         make([]float64, bins),
+		SegmentLength: size,
+		Span:          time.Duration(segmentLength) * interval,
+	}
+	for k := range spectrum.Frequencies {
+		spectrum.Frequencies[k] = float64(k) * fs / float64(size)
+	}
+
+	buf := make([]complex128, size)
+	hop := max(segmentLength/2, 1)
+	for start := 0; start+segmentLength <= len(values); start += hop {
+		segment := detrend(values[start : start+segmentLength])
+		for i := range buf {
+			buf[i] = 0
+		}
+		for i, v := range segment {
+			buf[i] = complex(v*window[i], 0)
+		}
+		FFT(buf)
+		for k := 0; k < bins; k++ {
+			p := real(buf[k])*real(buf[k]) + imag(buf[k])*imag(buf[k])
+			// One-sided density: every bin but the mean and the Nyquist frequency
+			// stands for its negative frequency too
+			if k != 0 && !(size%2 == 0 && k == size/2) {
+				p *= 2
+			}
+			spectrum.Power[k] += p / (fs * windowPower)
+		}
+		spectrum.Segments++
+	}
+	for k := range spectrum.Power {
+		spectrum.Power[k] /= float64(spectrum.Segments)
+	}
+	return spectrum, nil
+}
+
+// Peaks returns up to n local maxima of the spectrum, strongest first. The mean,
+// periods longer than half a segment, which are poorly resolved trends, and peaks
+// with less than MinPeakShare of the power are left out. Peak frequencies are refined by fitting a parabola to the log power.
+func (s *Spectrum) Peaks(n int) []SpectralPeak {
+	total := 0.0
+	for k := 1; k < len(s.Power); k++ {
+		total += s.Power[k]
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	df := s.Frequencies[1] - s.Frequencies[0]
+	minFrequency := 2 / s.Span.Seconds()
+
+	var peaks []SpectralPeak
+	for k := 1; k < len(s.Power); k++ {
+		p := s.Power[k]
+		if s.Frequencies[k] < minFrequency || p <= s.Power[k-1] || (k+1 < len(s.Power) && p < s.Power[k+1]) {
+			continue
+		}
+
+		frequency := s.Frequencies[k]
+		if k+1 < len(s.Power) && s.Power[k-1] > 0 && s.Power[k+1] > 0 {
+			a, b, c := math.Log(s.Power[k-1]), math.Log(p), math.Log(s.Power[k+1])
+			if denom := a - 2*b + c; denom < 0 {
+				frequency += 0.5 * (a - c) / denom * df
+			}
+		}
+
+		// A peak's power is spread over the neighbouring bins by the window
+		share := p
+		if k > 1 {
+			share += s.Power[k-1]
+		}
+		if k+1 < len(s.Power) {
+			share += s.Power[k+1]
+		}
+		if share/total < MinPeakShare {
+			continue
+		}
+		peaks = append(peaks, SpectralPeak{
+			Frequency: frequency,
+			Period:    time.Duration(float64(time.Second) / frequency),
+			Power:     p,
+			Share:     math.Min(share/total, 1),
+		})
+	}
+
+	sort.Slice(peaks, func(i, j int) bool { return peaks[i].Power > peaks[j].Power })
+	if len(peaks) > n {
+		peaks = peaks[:n]
+	}
+	return peaks
+}
+
+// detrend returns values minus their least squares line
+func detrend(values []float64) []float64 {
+	n := float64(len(values))
+	var sx, sy, sxx, sxy float64
+	for i, v := range values {
+		x := float64(i)
+		sx += x
+		sy += v
+		sxx += x * x
+		sxy += x * v
+	}
+	slope := 0.0
+	if d := n*sxx - sx*sx; d != 0 {
+		slope = (n*sxy - sx*sy) / d
+	}
+	intercept := (sy - slope*sx) / n
+
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = v - intercept - slope*float64(i)
+	}
+	return out
+}
+
+// FormatPeriod writes a period rounded to two significant digits in the largest
+// unit it is at least two of, as people name cycles: 90m, 24h, 7d, 12 months, 2 years
+func FormatPeriod(d time.Duration) string {
+	const (
+		day   = 24 * time.Hour
+		month = time.Duration(30.436875 * float64(day))
+		year  = time.Duration(365.2425 * float64(day))
+	)
+	units := []struct {
+		length time.Duration
+		suffix string
+	}{
+		{year, " years"},
+		{month, " months"},
+		{day, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+	}
+
+	for _, u := range units {
+		if d >= 2*u.length {
+			return formatCount(float64(d)/float64(u.length), u.suffix)
+		}
+	}
+	return formatCount(d.Seconds(), "s")
+}
+
+func formatCount(v float64, suffix string) string {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 2, 64), 64)
+	if err != nil {
+		rounded = v
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64) + suffix
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+	"time"
+)
+
+func TestFFTOfSinusoid(t *testing.T) {
+	const n, cycles = 64, 5
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(math.Cos(2*math.Pi*cycles*float64(i)/n), 0)
+	}
+	FFT(x)
+
+	// A cosine of amplitude 1 puts n/2 in its bin and its mirror, nothing elsewhere
+	for k, c := range x {
+		want := 0.0
+		if k == cycles || k == n-cycles {
+			want = n / 2
+		}
+		if cmplx.Abs(c-complex(want, 0)) > 1e-9 {
+			t.Errorf("bin %d = %v, want %v", k, c, want)
+		}
+	}
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+	values := []float64{3, -1, 4, 1, -5, 9, 2, -6}
+	x := make([]complex128, len(values))
+	for i, v := range values {
+		x[i] = complex(v, 0)
+	}
+	FFT(x)
+
+	n := float64(len(values))
+	for k := range x {
+		var want complex128
+		for i, v := range values {
+			want += complex(v, 0) * cmplx.Exp(complex(0, -2*math.Pi*float64(k*i)/n))
+		}
+		if cmplx.Abs(x[k]-want) > 1e-9 {
+			t.Errorf("bin %d = %v, want %v", k, x[k], want)
+		}
+	}
+}
+
+func TestSpectrumFindsDailyCycle(t *testing.T) {
+	// 60 days of hourly readings with a daily and a weaker 12 hour cycle
+	values := make([]float64, 60*24)
+	for i := range values {
+		h := float64(i)
+		values[i] = 20 + 5*math.Sin(2*math.Pi*h/24) + 2*math.Cos(2*math.Pi*h/12)
+	}
+
+	for _, method := range []string{MethodPeriodogram, MethodWelch} {
+		spectrum, err := ComputeSpectrum(values, time.Hour, method, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		peaks := spectrum.Peaks(2)
+		if len(peaks) != 2 {
+			t.Fatalf("%s: peaks = %+v, want 2", method, peaks)
+		}
+		for i, want := range []time.Duration{24 * time.Hour, 12 * time.Hour} {
+			if got := peaks[i].Period; math.Abs(got.Hours()-want.Hours()) > 0.02*want.Hours() {
+				t.Errorf("%s: peak %d period = %s, want %s", method, i, got, want)
+			}
+		}
+		if peaks[0].Share < 0.7 {
+			t.Errorf("%s: daily cycle share = %v, want about 25/29 of the power", method, peaks[0].Share)
+		}
+	}
+}
+
+func TestSpectrumRejectsShortSeries(t *testing.T) {
+	if _, err := Periodogram([]float64{1, 2, 3}, time.Minute); err == nil {
+		t.Error("Periodogram of 3 points succeeded, want an error")
+	}
+}
@@ -0,0 +1,357 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+)
+
+// Scopes granted to API keys. Read allows fetching resources, write allows creating,
+// changing and deleting them, and admin allows everything including user, key and
+// vault management.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every valid scope
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+const (
+	// keyPrefix marks API keys so they are recognisable in logs and secret scanners
+	keyPrefix = "iotk"
+	// lookupBytes and secretBytes size the public and secret parts of a key
+	lookupBytes = 6
+	secretBytes = 32
+	// touchInterval limits how often a key's last-used time is written
+	touchInterval = time.Minute
+)
+
+var (
+	// ErrInvalidKey is returned for missing, malformed, unknown, revoked or expired keys
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrInvalidScope is returned when a key is requested with an unknown scope
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrUserExists is returned when creating a user whose username is taken
+	ErrUserExists = errors.New("user already exists")
+	// ErrUserNotFound is returned when a user ID does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrKeyNotFound is returned when an API key ID does not exist or is already revoked
+	ErrKeyNotFound = errors.New("API key not found")
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserId   int64
+	Username string
+	KeyId    int64
+	Scopes   []string
+}
+
+// HasScope reports whether the principal was granted scope. Admin implies every scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of ctx, or nil if the context is unauthenticated
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// ActingUser returns the ID of the user on whose behalf ctx runs, for recording who
+// created a resource. It is nil for unauthenticated contexts such as the MCP stdio
+// server.
+func ActingUser(ctx context.Context) *int64 {
+	p := FromContext(ctx)
+	if p == nil || p.UserId == 0 {
+		return nil
+	}
+	id := p.UserId
+	return &id
+}
+
+// ParseScopes validates a list of scopes, removing duplicates
+func ParseScopes(scopes []string) ([]string, error) {
+	var parsed []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q (valid: %s)", ErrInvalidScope, scope, strings.Join(Scopes, ", "))
+		}
+		if !slices.Contains(parsed, scope) {
+			parsed = append(parsed, scope)
+		}
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	return parsed, nil
+}
+
+// Authenticator manages users and their API keys. Keys are random tokens of the
+// form iotk_<lookup>_<secret>; only the lookup part and a SHA-256 hash of the whole
+// token are stored, so a leaked database does not reveal usable keys.
+type Authenticator struct {
+	store *persistence.Store
+}
+
+func NewAuthenticator(store *persistence.Store) *Authenticator {
+	return &Authenticator{
+		store: store,
+	}
+}
+
+// Authenticate resolves a bearer token to its principal
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	lookup, ok := parseToken(token)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	schema, err := a.store.LoadApiKeyByPrefix(lookup)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	key := &models.ApiKey{}
+	key.FromSchema(schema)
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(key.HashedKey)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	now := time.Now()
+	if !key.IsValid(now) {
+		return nil, ErrInvalidKey
+	}
+
+	user, err := a.User(key.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidKey
+	}
+
+	if key.WhenLastUsed == nil || now.Sub(*key.WhenLastUsed) > touchInterval {
+		if err := a.store.TouchApiKey(key.ApiKeyId, now); err != nil {
+			slog.Error("Failed to record use of API key", "api_key_id", key.ApiKeyId, "error", err)
+		}
+	}
+
+	return &Principal{
+		UserId:   user.UserId,
+		Username: user.Username,
+		KeyId:    key.ApiKeyId,
+		Scopes:   key.Scopes,
+	}, nil
+}
+
+// CreateUser adds a user
+func (a *Authenticator) CreateUser(username string, createdBy *int64) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if _, err := a.store.LoadUserByUsername(username); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserExists, username)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:    username,
+		IsActive:    true,
+		CreatedBy:   createdBy,
+		WhenCreated: time.Now(),
+	}
+	schema := user.ToSchema()
+	if err := a.store.SaveUser(schema); err != nil {
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+	user.UserId = schema.UserId
+	return user, nil
+}
+
+// User loads a user by ID
+func (a *Authenticator) User(id int64) (*models.User, error) {
+	schema, err := a.store.LoadUser(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{}
+	user.FromSchema(schema)
+	return user, nil
+}
+
+// Users loads all users
+func (a *Authenticator) Users() ([]*models.User, error) {
+	schemas, err := a.store.LoadAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*models.User, 0, len(schemas))
+	for _, schema := range schemas {
+		user := &models.User{}
+		user.FromSchema(schema)
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// HasUsers reports whether any user exists
+func (a *Authenticator) HasUsers() (bool, error) {
+	n, err := a.store.CountUsers()
+	return n > 0, err
+}
+
+// CreateKey issues a new API key for a user and returns it with the plaintext
+// token. The token cannot be recovered later.
+func (a *Authenticator) CreateKey(userId int64, name string, scopes []string, expires *time.Time, createdBy *int64) (*models.ApiKey, string, error) {
+	scopes, err := ParseScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := a.User(userId); err != nil {
+		return nil, "", err
+	}
+
+	lookup, token, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.ApiKey{
+		UserId:      userId,
+		Name:        name,
+		Prefix:      lookup,
+		HashedKey:   hashToken(token),
+		Scopes:      scopes,
+		CreatedBy:   createdBy,
+		WhenCreated: time.Now(),
+		WhenExpires: expires,
+	}
+	schema := key.ToSchema()
+	if err := a.store.SaveApiKey(schema); err != nil {
+		return nil, "", fmt.Errorf("failed to save API key: %w", err)
+	}
+	key.ApiKeyId = schema.ApiKeyId
+	return key, token, nil
+}
+
+// Key loads an API key by ID
+func (a *Authenticator) Key(id int64) (*models.ApiKey, error) {
+	schema, err := a.store.LoadApiKey(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrKeyNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.ApiKey{}
+	key.FromSchema(schema)
+	return key, nil
+}
+
+// Keys loads the API keys of a user, or of all users when userId is 0
+func (a *Authenticator) Keys(userId int64) ([]*models.ApiKey, error) {
+	schemas, err := a.store.LoadApiKeys(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*models.ApiKey, 0, len(schemas))
+	for _, schema := range schemas {
+		key := &models.ApiKey{}
+		key.FromSchema(schema)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// RevokeKey revokes an API key. Revoked keys are kept for auditing.
+func (a *Authenticator) RevokeKey(id int64) error {
+	err := a.store.RevokeApiKey(id, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrKeyNotFound, id)
+	}
+	return err
+}
+
+// BootstrapAdmin issues an admin key for username, creating the user if needed.
+// It is how the first key is created, since every API route requires one.
+func (a *Authenticator) BootstrapAdmin(username string) (string, error) {
+	var userId int64
+	schema, err := a.store.LoadUserByUsername(username)
+	switch {
+	case err == nil:
+		userId = schema.UserId
+	case errors.Is(err, sql.ErrNoRows):
+		user, err := a.CreateUser(username, nil)
+		if err != nil {
+			return "", err
+		}
+		userId = user.UserId
+	default:
+		return "", err
+	}
+
+	_, token, err := a.CreateKey(userId, "bootstrap", []string{ScopeAdmin}, nil, nil)
+	return token, err
+}
+
+func newToken() (lookup, token string, err error) {
+	buf := make([]byte, lookupBytes+secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	lookup = hex.EncodeToString(buf[:lookupBytes])
+	secret := base64.RawURLEncoding.EncodeToString(buf[lookupBytes:])
+	return lookup, keyPrefix + "_" + lookup + "_" + secret, nil
+}
+
+// parseToken returns the lookup part of a well-formed token
+func parseToken(token string) (string, bool) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix || len(parts[1]) != 2*lookupBytes || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// hashToken hashes a token for storage. Keys carry 256 random bits, so a fast
+// unsalted hash cannot be brute-forced; a slow password hash would only add latency
+// to every request.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,469 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigFile names the config file when -config is not given
+const EnvConfigFile = "SANDBOX_CONFIG"
+
+// StorageBackendLocal stores uploads in a directory on the local filesystem. It is
+// currently the only backend.
+const StorageBackendLocal = "local"
+
+// Log formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Tracing exporters. With none, spans are not recorded but incoming trace context
+// is still propagated.
+const (
+	TracingExporterNone = "none"
+	TracingExporterOTLP = "otlp"
+)
+
+// Config holds the server settings. They are layered: built-in defaults, then the
+// YAML config file, then environment variables, then command-line flags.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Storage  StorageConfig  `yaml:"storage"`
+	CORS     CORSConfig     `yaml:"cors"`
+	LLM      LLMConfig      `yaml:"llm"`
+	Jobs     JobsConfig     `yaml:"jobs"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	Log      LogConfig      `yaml:"log"`
+}
+
+type ServerConfig struct {
+	Addr        string `yaml:"addr"`
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// SwaggerURL is where the Swagger UI loads the API description from
+	SwaggerURL string `yaml:"swagger_url"`
+
+	// Timeouts of the HTTP server; zero disables a timeout. Event streams are exempt
+	// from the write timeout.
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests and jobs are drained on
+	// SIGINT or SIGTERM before they are cut off
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	Path string `yaml:"path"`
+}
+
+type StorageConfig struct {
+	Backend string `yaml:"backend"`
+	// Dir is where uploads are stored. Empty selects a per-OS default.
+	Dir           string   `yaml:"dir"`
+	MaxUploadSize ByteSize `yaml:"max_upload_size"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins lists the origins browsers may call the API from; "*" allows any
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type LLMConfig struct {
+	Provider string `yaml:"provider"`
+	Backend  string `yaml:"backend"`
+	Model    string `yaml:"model"`
+	APIKey   string `yaml:"api_key"`
+	BaseURL  string `yaml:"base_url"`
+}
+
+type JobsConfig struct {
+	Workers int `yaml:"workers"`
+}
+
+type LogConfig struct {
+	// Level is the least severe level logged: debug, info, warn or error
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector URL. Empty uses the standard
+	// OTEL_EXPORTER_OTLP_* environment variables, or http://localhost:4318.
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"service_name"`
+	// SampleRatio is the fraction of new traces recorded; requests that arrive
+	// with a sampled trace context are always recorded
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// TLSEnabled reports whether the server should serve HTTPS
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" || s.TLSKeyFile != ""
+}
+
+// Default returns the built-in settings
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:       ":8080",
+			SwaggerURL: "/swagger/doc.json",
+			// Reads and writes are generous so large uploads and slow tools finish
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(10 * time.Minute),
+			WriteTimeout:      Duration(10 * time.Minute),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Path: "./sandbox.db",
+		},
+		Storage: StorageConfig{
+			Backend:       StorageBackendLocal,
+			MaxUploadSize: 500 * MB, // large enough for practical datasets
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+		},
+		Jobs: JobsConfig{
+			Workers: 4,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterNone,
+			ServiceName: "iot-data-sandbox",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatJSON,
+		},
+	}
+}
+
+// setting maps one configuration value to its environment variable and flag
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	get    func(c *Config) string
+	set    func(c *Config, v string) error
+}
+
+func str(field func(c *Config) *string) (func(c *Config) string, func(c *Config, v string) error) {
+	return func(c *Config) string { return *field(c) },
+		func(c *Config, v string) error { *field(c) = v; return nil }
+}
+
+func newSetting(key, env, flagName, usage string, secret bool, field func(c *Config) *string) setting {
+	get, set := str(field)
+	return setting{key: key, env: env, flag: flagName, usage: usage, secret: secret, get: get, set: set}
+}
+
+var settings = []setting{
+	newSetting("server.addr", "SANDBOX_ADDR", "addr", "Listen address", false,
+		func(c *Config) *string { return &c.Server.Addr }),
+	newSetting("server.tls_cert_file", "SANDBOX_TLS_CERT_FILE", "tls-cert", "TLS certificate file; enables HTTPS together with -tls-key", false,
+		func(c *Config) *string { return &c.Server.TLSCertFile }),
+	newSetting("server.tls_key_file", "SANDBOX_TLS_KEY_FILE", "tls-key", "TLS private key file", false,
+		func(c *Config) *string { return &c.Server.TLSKeyFile }),
+	newSetting("server.swagger_url", "SANDBOX_SWAGGER_URL", "swagger-url", "URL the Swagger UI loads doc.json from", false,
+		func(c *Config) *string { return &c.Server.SwaggerURL }),
+	durationSetting("server.read_header_timeout", "SANDBOX_READ_HEADER_TIMEOUT", "read-header-timeout", "Time allowed to read request headers",
+		func(c *Config) *Duration { return &c.Server.ReadHeaderTimeout }),
+	durationSetting("server.read_timeout", "SANDBOX_READ_TIMEOUT", "read-timeout", "Time allowed to read a whole request, including uploads",
+		func(c *Config) *Duration { return &c.Server.ReadTimeout }),
+	durationSetting("server.write_timeout", "SANDBOX_WRITE_TIMEOUT", "write-timeout", "Time allowed to write a response (event streams are exempt)",
+		func(c *Config) *Duration { return &c.Server.WriteTimeout }),
+	durationSetting("server.idle_timeout", "SANDBOX_IDLE_TIMEOUT", "idle-timeout", "How long idle keep-alive connections stay open",
+		func(c *Config) *Duration { return &c.Server.IdleTimeout }),
+	durationSetting("server.shutdown_timeout", "SANDBOX_SHUTDOWN_TIMEOUT", "shutdown-timeout", "How long to drain requests and jobs on shutdown",
+		func(c *Config) *Duration { return &c.Server.ShutdownTimeout }),
+	newSetting("database.path", "SANDBOX_DB_PATH", "db", "SQLite database path", false,
+		func(c *Config) *string { return &c.Database.Path }),
+	newSetting("storage.backend", "SANDBOX_STORAGE_BACKEND", "storage-backend", "Upload storage backend (local)", false,
+		func(c *Config) *string { return &c.Storage.Backend }),
+	newSetting("storage.dir", "SANDBOX_STORAGE_DIR", "storage-dir", "Upload storage directory (default depends on the OS)", false,
+		func(c *Config) *string { return &c.Storage.Dir }),
+	{
+		key: "storage.max_upload_size", env: "SANDBOX_MAX_UPLOAD_SIZE", flag: "max-upload-size",
+		usage: "Largest accepted upload, in bytes or with a KB, MB or GB suffix",
+		get:   func(c *Config) string { return c.Storage.MaxUploadSize.String() },
+		set: func(c *Config, v string) error {
+			size, err := ParseByteSize(v)
+			c.Storage.MaxUploadSize = size
+			return err
+		},
+	},
+	{
+		key: "cors.allowed_origins", env: "SANDBOX_CORS_ORIGINS", flag: "cors-origins",
+		usage: "Comma-separated origins allowed to call the API, or *",
+		get:   func(c *Config) string { return strings.Join(c.CORS.AllowedOrigins, ",") },
+		set: func(c *Config, v string) error {
+			c.CORS.AllowedOrigins = splitList(v)
+			return nil
+		},
+	},
+	newSetting("llm.provider", "LLM_PROVIDER", "llm-provider", "LLM provider (mock, langchaingo)", false,
+		func(c *Config) *string { return &c.LLM.Provider }),
+	newSetting("llm.backend", "LLM_BACKEND", "llm-backend", "Model vendor for multi-vendor providers (openai, anthropic, ollama)", false,
+		func(c *Config) *string { return &c.LLM.Backend }),
+	newSetting("llm.model", "LLM_MODEL", "llm-model", "LLM model name", false,
+		func(c *Config) *string { return &c.LLM.Model }),
+	newSetting("llm.api_key", "LLM_API_KEY", "", "", true,
+		func(c *Config) *string { return &c.LLM.APIKey }),
+	newSetting("llm.base_url", "LLM_BASE_URL", "llm-base-url", "LLM endpoint override", false,
+		func(c *Config) *string { return &c.LLM.BaseURL }),
+	{
+		key: "jobs.workers", env: "SANDBOX_JOB_WORKERS", flag: "job-workers",
+		usage: "Number of background job workers",
+		get:   func(c *Config) string { return strconv.Itoa(c.Jobs.Workers) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("not an integer: %q", v)
+			}
+			c.Jobs.Workers = n
+			return nil
+		},
+	},
+	newSetting("tracing.exporter", "SANDBOX_TRACING_EXPORTER", "tracing-exporter", "Trace exporter (none, otlp)", false,
+		func(c *Config) *string { return &c.Tracing.Exporter }),
+	newSetting("tracing.endpoint", "SANDBOX_TRACING_ENDPOINT", "tracing-endpoint", "OTLP/HTTP collector URL, e.g. http://localhost:4318", false,
+		func(c *Config) *string { return &c.Tracing.Endpoint }),
+	newSetting("tracing.service_name", "SANDBOX_TRACING_SERVICE_NAME", "tracing-service-name", "Service name reported with spans", false,
+		func(c *Config) *string { return &c.Tracing.ServiceName }),
+	{
+		key: "tracing.sample_ratio", env: "SANDBOX_TRACING_SAMPLE_RATIO", flag: "tracing-sample-ratio",
+		usage: "Fraction of new traces to record, between 0 and 1",
+		get:   func(c *Config) string { return strconv.FormatFloat(c.Tracing.SampleRatio, 'g', -1, 64) },
+		set: func(c *Config, v string) error {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("not a number: %q", v)
+			}
+			c.Tracing.SampleRatio = f
+			return nil
+		},
+	},
+	newSetting("log.level", "SANDBOX_LOG_LEVEL", "log-level", "Least severe level logged (debug, info, warn, error)", false,
+		func(c *Config) *string { return &c.Log.Level }),
+	newSetting("log.format", "SANDBOX_LOG_FORMAT", "log-format", "Log format (json, text)", false,
+		func(c *Config) *string { return &c.Log.Format }),
+}
+
+// Flags are the command-line overrides registered by BindFlags
+type Flags struct {
+	fs   *flag.FlagSet
+	file *string
+}
+
+// BindFlags registers -config and one flag per setting on fs. Secrets such as the
+// LLM API key have no flag, since command lines are visible to other users.
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{
+		fs:   fs,
+		file: fs.String("config", "", "YAML config file (also "+EnvConfigFile+")"),
+	}
+	for _, s := range settings {
+		if s.flag != "" {
+			fs.String(s.flag, "", fmt.Sprintf("%s (%s)", s.usage, s.env))
+		}
+	}
+	return f
+}
+
+// Load builds the configuration from defaults, the config file, the environment
+// and the flags, then validates it. flags may be nil.
+func Load(flags *Flags) (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv(EnvConfigFile)
+	if flags != nil && *flags.file != "" {
+		path = *flags.file
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	if flags != nil {
+		var err error
+		flags.fs.Visit(func(fl *flag.Flag) {
+			for _, s := range settings {
+				if s.flag == fl.Name && err == nil {
+					if setErr := s.set(cfg, fl.Value.String()); setErr != nil {
+						err = fmt.Errorf("invalid -%s: %w", s.flag, setErr)
+					}
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks that the settings are usable, reporting every problem at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("server.addr: %w", err))
+	}
+	if c.Server.TLSEnabled() {
+		if c.Server.TLSCertFile == "" || c.Server.TLSKeyFile == "" {
+			errs = append(errs, errors.New("server.tls_cert_file and server.tls_key_file must be set together"))
+		}
+		for _, file := range []string{c.Server.TLSCertFile, c.Server.TLSKeyFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				errs = append(errs, fmt.Errorf("server TLS file: %w", err))
+			}
+		}
+	}
+	for _, timeout := range []struct {
+		name string
+		d    Duration
+	}{
+		{"read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"read_timeout", c.Server.ReadTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+	} {
+		if timeout.d < 0 {
+			errs = append(errs, fmt.Errorf("server.%s must not be negative", timeout.name))
+		}
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.SwaggerURL == "" {
+		errs = append(errs, errors.New("server.swagger_url is required"))
+	}
+
+	if c.Database.Path == "" {
+		errs = append(errs, errors.New("database.path is required"))
+	}
+
+	if c.Storage.Backend != StorageBackendLocal {
+		errs = append(errs, fmt.Errorf("storage.backend: unsupported backend %q (available: %s)", c.Storage.Backend, StorageBackendLocal))
+	}
+	if c.Storage.MaxUploadSize <= 0 {
+		errs = append(errs, errors.New("storage.max_upload_size must be positive"))
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins: %q is not an origin like https://example.com", origin))
+		}
+	}
+
+	if c.LLM.BaseURL != "" {
+		if u, err := url.Parse(c.LLM.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("llm.base_url: %q is not an absolute URL", c.LLM.BaseURL))
+		}
+	}
+
+	if c.Jobs.Workers <= 0 {
+		errs = append(errs, errors.New("jobs.workers must be positive"))
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: unsupported exporter %q (available: %s, %s)", c.Tracing.Exporter, TracingExporterNone, TracingExporterOTLP))
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %q is not an absolute URL", c.Tracing.Endpoint))
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: unknown level %q (valid: debug, info, warn, error)", c.Log.Level))
+	}
+	if c.Log.Format != LogFormatJSON && c.Log.Format != LogFormatText {
+		errs = append(errs, fmt.Errorf("log.format: unsupported format %q (available: %s, %s)", c.Log.Format, LogFormatJSON, LogFormatText))
+	}
+
+	return errors.Join(errs...)
+}
+
+// String lists every setting as key=value with secrets redacted, for logging
+func (c *Config) String() string {
+	parts := make([]string, 0, len(settings))
+	for _, s := range settings {
+		v := s.get(c)
+		if s.secret && v != "" {
+			v = "[redacted]"
+		}
+		parts = append(parts, fmt.Sprintf("%s=%q", s.key, v))
+	}
+	return strings.Join(parts, " ")
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as a Go duration string such as 30s or 5m
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalYAML parses duration strings such as 30s or 5m
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func durationSetting(key, env, flagName, usage string, field func(c *Config) *Duration) setting {
+	return setting{
+		key: key, env: env, flag: flagName, usage: usage,
+		get: func(c *Config) string { return field(c).String() },
+		set: func(c *Config, v string) error {
+			parsed, err := time.ParseDuration(v)
+			*field(c) = Duration(parsed)
+			return err
+		},
+	}
+}
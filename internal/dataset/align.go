@@ -0,0 +1,133 @@
+package dataset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MaxAlignedDataSources limits how many datasources Align combines
+const MaxAlignedDataSources = 16
+
+// ErrTooFewPoints is returned when datasources have too few points in a range to
+// resample them
+var ErrTooFewPoints = errors.New("not enough points")
+
+// AlignedTable holds several datasources sampled at common times. Columns[i] holds
+// the values of DataSources[i] in Units[i], NaN where it has no value.
+type AlignedTable struct {
+	DataSources []*models.DataSource
+	Units       []string
+	Times       []time.Time
+	Columns     [][]float64
+	// Interval is the step between times, zero when they are not evenly spaced
+	Interval time.Duration
+}
+
+// Align reads the datasources ids and puts them on common times. Each datasource is
+// read in full, so values at the edges of opts.Start and opts.End are interpolated
+// from the points outside them. unit, if set, converts every datasource to it.
+func (l *Loader) Align(ctx context.Context, ids []int64, opts timeseries.AlignOptions, unit string) (table *AlignedTable, err error) {
+	ctx, span := tracing.Start(ctx, "dataset.Align",
+		attribute.Int64Slice("datasource.ids", ids), attribute.String("interpolation", string(opts.Interpolation)))
+	defer func() { tracing.End(span, err) }()
+
+	table, inputs, err := l.readAll(ctx, ids, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	if table.Times, table.Columns, err = timeseries.AlignWith(opts, inputs...); err != nil {
+		return nil, err
+	}
+	table.Interval = opts.Interval
+	span.SetAttributes(attribute.Int("rows", len(table.Times)))
+	return table, nil
+}
+
+// Resample puts the datasources ids on a regular grid over [startTime, endTime] for
+// analyses that need evenly spaced values. Values are interpolated linearly, and the
+// grid is trimmed to the times where every datasource has a value. A zero interval
+// uses the largest median sampling interval of the datasources.
+func (l *Loader) Resample(ctx context.Context, ids []int64, startTime, endTime *time.Time, interval time.Duration, unit string) (table *AlignedTable, err error) {
+	ctx, span := tracing.Start(ctx, "dataset.Resample", attribute.Int64Slice("datasource.ids", ids))
+	defer func() { tracing.End(span, err) }()
+
+	table, inputs, err := l.readAll(ctx, ids, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		for _, points := range inputs {
+			interval = max(interval, timeseries.MedianInterval(timeseries.FilterPoints(points, startTime, endTime)))
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("%w in the range to infer an interval", ErrTooFewPoints)
+		}
+	}
+
+	opts := timeseries.AlignOptions{Interval: interval, Start: startTime, End: endTime}
+	if table.Times, table.Columns, err = timeseries.AlignWith(opts, inputs...); err != nil {
+		return nil, err
+	}
+	table.Interval = interval
+
+	// Keep the times where every datasource is covered. Linear interpolation without
+	// a tolerance leaves no gaps inside a series, so these are contiguous.
+	first, last := len(table.Times), -1
+	for i := range table.Times {
+		complete := true
+		for _, column := range table.Columns {
+			complete = complete && !math.IsNaN(column[i])
+		}
+		if complete {
+			first, last = min(first, i), i
+		}
+	}
+	if last < first {
+		first, last = 0, -1
+	}
+	table.Times = table.Times[first : last+1]
+	for i, column := range table.Columns {
+		table.Columns[i] = column[first : last+1]
+	}
+
+	span.SetAttributes(attribute.Int("rows", len(table.Times)), attribute.String("interval", interval.String()))
+	return table, nil
+}
+
+// readAll reads every point of the datasources ids, converted to unit if it is set
+func (l *Loader) readAll(ctx context.Context, ids []int64, unit string) (*AlignedTable, [][]timeseries.Point, error) {
+	if len(ids) == 0 {
+		return nil, nil, errors.New("no datasources to align")
+	}
+	if len(ids) > MaxAlignedDataSources {
+		return nil, nil, fmt.Errorf("at most %d datasources can be aligned", MaxAlignedDataSources)
+	}
+
+	table := &AlignedTable{
+		DataSources: make([]*models.DataSource, len(ids)),
+		Units:       make([]string, len(ids)),
+	}
+	inputs := make([][]timeseries.Point, len(ids))
+	for i, id := range ids {
+		ds, points, err := l.Points(ctx, id, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if table.Units[i], err = ConvertPoints(ds, points, unit); err != nil {
+			return nil, nil, err
+		}
+		table.DataSources[i] = ds
+		inputs[i] = points
+	}
+	return table, inputs, nil
+}
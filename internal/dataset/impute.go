@@ -0,0 +1,152 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ImputeError is returned for imputation options that do not fit the datasource,
+// such as the seasonal method without a season
+type ImputeError struct {
+	Err error
+}
+
+func (e *ImputeError) Error() string {
+	return fmt.Sprintf("cannot fill gaps: %v", e.Err)
+}
+
+func (e *ImputeError) Unwrap() error {
+	return e.Err
+}
+
+// ImputeResult is a datasource put on a regular grid with its gaps filled
+type ImputeResult struct {
+	DataSource *models.DataSource
+	Unit       string
+	// Options are those used, with the interval and season defaults filled in
+	Options timeseries.ImputeOptions
+	Points  []timeseries.ImputedPoint
+	// Imputed counts the filled times, and Missing the times left empty
+	Imputed int
+	Missing int
+}
+
+// Impute reads the datasource id within [startTime, endTime] and fills its gaps. A
+// zero opts.Interval uses the median sampling interval, and a zero opts.Season the
+// strongest seasonal period detected for the datasource. unit, if set, converts the
+// values to it.
+func (l *Loader) Impute(ctx context.Context, id int64, startTime, endTime *time.Time, opts timeseries.ImputeOptions, unit string) (result *ImputeResult, err error) {
+	ctx, span := tracing.Start(ctx, "dataset.Impute",
+		attribute.Int64("datasource.id", id), attribute.String("method", string(opts.Method)))
+	defer func() { tracing.End(span, err) }()
+
+	ds, points, err := l.Points(ctx, id, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	if unit, err = ConvertPoints(ds, points, unit); err != nil {
+		return nil, err
+	}
+
+	if opts.Interval <= 0 {
+		if opts.Interval = timeseries.MedianInterval(points); opts.Interval <= 0 {
+			return nil, fmt.Errorf("%w in the range to infer an interval", ErrTooFewPoints)
+		}
+	}
+	if opts.Method == timeseries.ImputeSeasonal && opts.Season <= 0 {
+		if len(ds.Seasonality) == 0 {
+			return nil, &ImputeError{Err: fmt.Errorf("datasource %d has no detected seasonality, set the season", id)}
+		}
+		opts.Season = ds.Seasonality[0].Period
+	}
+
+	imputed, err := timeseries.Impute(points, opts)
+	if err != nil {
+		if errors.Is(err, timeseries.ErrTooManyTimes) {
+			return nil, err
+		}
+		return nil, &ImputeError{Err: err}
+	}
+
+	result = &ImputeResult{DataSource: ds, Unit: unit, Options: opts, Points: imputed}
+	for _, p := range imputed {
+		switch {
+		case p.Imputed:
+			result.Imputed++
+		case math.IsNaN(p.Value):
+			result.Missing++
+		}
+	}
+	span.SetAttributes(attribute.Int("rows", len(imputed)), attribute.Int("imputed", result.Imputed))
+	return result, nil
+}
+
+// SaveImputed stores the values of result as a new CSV datasource named name. The
+// file has an imputed column flagging the filled values; gaps left empty are left
+// out.
+func (l *Loader) SaveImputed(ctx context.Context, name string, result *ImputeResult) (*models.DataSource, error) {
+	rows := make([]string, 0, len(result.Points))
+	for _, p := range result.Points {
+		if math.IsNaN(p.Value) {
+			continue
+		}
+		rows = append(rows, csvRow(p.Timestamp, p.Value)+","+strconv.FormatBool(p.Imputed))
+	}
+
+	source := result.DataSource
+	description := fmt.Sprintf("%s with gaps filled by %s every %s", source.Name,
+		result.Options.Method, analysis.FormatDuration(result.Options.Interval))
+	if result.Options.MaxGap > 0 {
+		description += fmt.Sprintf(" up to %s long", analysis.FormatDuration(result.Options.MaxGap))
+	}
+	ds, err := l.saveCSV(ctx, name, result.Unit, description, fmt.Sprintf("imputed_%d.csv", source.DataSourceId),
+		"timestamp,value,imputed", rows)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("Saved imputed datasource", "datasource_id", ds.DataSourceId,
+		"source_id", source.DataSourceId, "method", result.Options.Method, "imputed", result.Imputed)
+	return ds, nil
+}
+
+// saveCSV stores rows under header as a file named filename and ingests it as a
+// datasource named name, in unit and with description
+func (l *Loader) saveCSV(ctx context.Context, name, unit, description, filename, header string, rows []string) (*models.DataSource, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header + "\n")
+	for _, row := range rows {
+		buf.WriteString(row + "\n")
+	}
+
+	filename, err := l.fileStore.SaveFile(filename, &buf, int64(buf.Len())+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	ds, err := l.Ingest(ctx, name, unit, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Description = description
+	if err := l.store.SaveDataSource(ds.ToSchema()); err != nil {
+		return nil, fmt.Errorf("failed to save datasource: %w", err)
+	}
+	return ds, nil
+}
+
+// csvRow formats a point as the timestamp and value columns of a CSV row
+func csvRow(t time.Time, v float64) string {
+	return t.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,85 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/auth"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/metrics"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+// ValidationError is returned when an uploaded file fails validation
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid CSV: %v", e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Ingest validates a file already written to the file store and registers it as a
+// datasource named name, created by the acting user of ctx. unit, if set, is the
+// unit of the values and must be in the unit registry. The file is removed if it
+// cannot be ingested.
+func (l *Loader) Ingest(ctx context.Context, name, unit, filename string) (*models.DataSource, error) {
+	if !l.fileStore.FileExists(filename) {
+		return nil, fmt.Errorf("uploaded file %s not found", filename)
+	}
+
+	if unit != "" {
+		var err error
+		if unit, err = units.Canonical(unit); err != nil {
+			l.fileStore.DeleteFile(filename)
+			return nil, err
+		}
+	}
+
+	logger := logging.FromContext(ctx).With("file", filename)
+
+	tsData, err := l.parse(ctx, filename, "ingest")
+	if err != nil {
+		logger.Info("Rejected invalid upload", "error", err)
+		l.fileStore.DeleteFile(filename)
+		return nil, &ValidationError{Err: err}
+	}
+
+	dataSource := &models.DataSource{
+		Name:           name,
+		DataSourceType: models.DataSourceTypeCSV,
+		DataSourcePath: filename,
+		RowCount:       tsData.RowCount,
+		TimeLabel:      tsData.TimeLabel,
+		ValueLabel:     tsData.ValueLabel,
+		Unit:           unit,
+		CreatedBy:      auth.ActingUser(ctx),
+		WhenCreated:    time.Now(),
+	}
+
+	if tsData.RowCount > 0 {
+		dataSource.StartTime = &tsData.StartTime
+		dataSource.EndTime = &tsData.EndTime
+	}
+	if points, err := tsData.Points(); err == nil {
+		detectSeasonality(ctx, dataSource, points)
+	}
+
+	schema := dataSource.ToSchema()
+	if err := l.store.SaveDataSource(schema); err != nil {
+		l.fileStore.DeleteFile(filename)
+		return nil, fmt.Errorf("failed to save datasource: %w", err)
+	}
+	dataSource.DataSourceId = schema.DataSourceId
+	metrics.RowsIngested.Add(float64(dataSource.RowCount))
+	logger.Info("Ingested datasource", "datasource_id", dataSource.DataSourceId, "rows", dataSource.RowCount,
+		"seasonal_periods", len(dataSource.Seasonality))
+
+	return dataSource, nil
+}
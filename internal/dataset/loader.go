@@ -0,0 +1,142 @@
+package dataset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/metrics"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/storage"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrNotFound is returned when a datasource ID does not exist
+	ErrNotFound = errors.New("datasource not found")
+	// ErrFileNotFound is returned when a datasource's file is missing from the file store
+	ErrFileNotFound = errors.New("data file not found")
+)
+
+// Loader resolves datasource IDs to their metadata and parsed time series. It is the
+// shared read path for the API handlers, tools and the MCP server.
+type Loader struct {
+	store     *persistence.Store
+	fileStore *storage.FileStore
+}
+
+func NewLoader(store *persistence.Store, fileStore *storage.FileStore) *Loader {
+	return &Loader{
+		store:     store,
+		fileStore: fileStore,
+	}
+}
+
+// DataSource loads the metadata of a datasource
+func (l *Loader) DataSource(id int64) (*models.DataSource, error) {
+	schema, err := l.store.LoadDataSource(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+
+	ds := &models.DataSource{}
+	ds.FromSchema(schema)
+	return ds, nil
+}
+
+// Load reads a datasource's series, limited to [startTime, endTime] when either is set
+func (l *Loader) Load(ctx context.Context, id int64, startTime, endTime *time.Time) (ds *models.DataSource, filtered *timeseries.TimeSeriesData, err error) {
+	ctx, span := tracing.Start(ctx, "dataset.Load", attribute.Int64("datasource.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	ds, err = l.DataSource(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tsData *timeseries.TimeSeriesData
+	if ds.IsVirtual() {
+		points, err := l.series(ctx, ds, 0)
+		if err != nil {
+			return ds, nil, err
+		}
+		tsData = timeseries.FromPoints(points, ds.TimeLabel, ds.ValueLabel)
+
+		// Virtual datasources are recomputed on every read, so their stored
+		// coverage may be stale
+		ds.RowCount = tsData.RowCount
+		ds.StartTime, ds.EndTime = nil, nil
+		if tsData.RowCount > 0 {
+			ds.StartTime, ds.EndTime = &tsData.StartTime, &tsData.EndTime
+		}
+	} else if tsData, err = l.read(ctx, ds); err != nil {
+		return ds, nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return ds, nil, err
+	}
+
+	_, filterSpan := tracing.Start(ctx, "timeseries.FilterByTimeRange", attribute.Int("rows.in", tsData.RowCount))
+	filtered, err = timeseries.FilterByTimeRange(tsData, startTime, endTime)
+	if err == nil {
+		filterSpan.SetAttributes(attribute.Int("rows.out", filtered.RowCount))
+	}
+	tracing.End(filterSpan, err)
+	if err != nil {
+		return ds, nil, fmt.Errorf("failed to filter data: %w", err)
+	}
+
+	logging.FromContext(ctx).Debug("Loaded datasource", "datasource_id", id,
+		"rows", tsData.RowCount, "rows_in_range", filtered.RowCount)
+	return ds, filtered, nil
+}
+
+// read parses the file of a file-backed datasource
+func (l *Loader) read(ctx context.Context, ds *models.DataSource) (*timeseries.TimeSeriesData, error) {
+	if !l.fileStore.FileExists(ds.DataSourcePath) {
+		return nil, fmt.Errorf("%w for datasource %d", ErrFileNotFound, ds.DataSourceId)
+	}
+
+	tsData, err := l.parse(ctx, ds.DataSourcePath, "load")
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load datasource", "datasource_id", ds.DataSourceId, "error", err)
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	return tsData, nil
+}
+
+// parse reads and validates a file in the file store. operation labels the parse
+// duration metric.
+func (l *Loader) parse(ctx context.Context, filename, operation string) (tsData *timeseries.TimeSeriesData, err error) {
+	_, span := tracing.Start(ctx, "timeseries.LoadAndValidateCSV", attribute.String("file", filename))
+	started := time.Now()
+	defer func() {
+		metrics.LoaderParseDuration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+		if err == nil {
+			span.SetAttributes(attribute.Int("rows", tsData.RowCount))
+		}
+		tracing.End(span, err)
+	}()
+
+	return timeseries.LoadAndValidateCSV(l.fileStore.GetFilePath(filename))
+}
+
+// Points is Load followed by TimeSeriesData.Points
+func (l *Loader) Points(ctx context.Context, id int64, startTime, endTime *time.Time) (*models.DataSource, []timeseries.Point, error) {
+	ds, tsData, err := l.Load(ctx, id, startTime, endTime)
+	if err != nil {
+		return ds, nil, err
+	}
+
+	points, err := tsData.Points()
+	if err != nil {
+		return ds, nil, err
+	}
+	return ds, points, nil
+}
@@ -0,0 +1,65 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+)
+
+const (
+	// MaxSeasonalPeriods is how many seasonal periods are recorded for a datasource
+	MaxSeasonalPeriods = 3
+	// maxSeasonalityPoints limits the grid seasonality is detected on; longer series
+	// are resampled at a coarser interval than they were recorded at
+	maxSeasonalityPoints = 1 << 16
+)
+
+// seasonalGrid resamples points linearly at their median interval, or coarser to
+// keep at most maxSeasonalityPoints, for analyses that need evenly spaced values
+func seasonalGrid(points []timeseries.Point) ([]float64, time.Duration, error) {
+	interval := timeseries.MedianInterval(points)
+	if interval <= 0 {
+		return nil, 0, fmt.Errorf("%w to infer an interval", ErrTooFewPoints)
+	}
+	first, last := points[0].Timestamp, points[0].Timestamp
+	for _, p := range points {
+		if p.Timestamp.Before(first) {
+			first = p.Timestamp
+		}
+		if p.Timestamp.After(last) {
+			last = p.Timestamp
+		}
+	}
+	interval = max(interval, last.Sub(first)/maxSeasonalityPoints+1)
+
+	_, columns, err := timeseries.AlignWith(timeseries.AlignOptions{Interval: interval, Start: &first, End: &last}, points)
+	if err != nil {
+		return nil, 0, err
+	}
+	return columns[0], interval, nil
+}
+
+// detectSeasonality records the seasonal periods of points on ds. A series too short
+// or too irregular to tell is left without seasonality; detection never fails the
+// creation of a datasource.
+func detectSeasonality(ctx context.Context, ds *models.DataSource, points []timeseries.Point) {
+	ds.Seasonality = nil
+
+	values, interval, err := seasonalGrid(points)
+	if err == nil {
+		var periods []analysis.SeasonalPeriod
+		if periods, err = analysis.DetectSeasonality(values, interval, MaxSeasonalPeriods); err == nil {
+			for _, p := range periods {
+				ds.Seasonality = append(ds.Seasonality, models.SeasonalPeriod{Period: p.Period, Strength: p.Strength})
+			}
+		}
+	}
+	if err != nil {
+		logging.FromContext(ctx).Debug("Skipped seasonality detection", "name", ds.Name, "error", err)
+	}
+}
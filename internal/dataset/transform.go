@@ -0,0 +1,154 @@
+package dataset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Default time units of rates and integrals
+const (
+	DefaultRatePer     = time.Second
+	DefaultIntegralPer = time.Hour
+)
+
+// TransformOptions configure Transform
+type TransformOptions struct {
+	Transform timeseries.Transform
+	// Per is the time unit of rates and integrals
+	Per time.Duration
+	// Rollover is the value at which a counter wraps to zero, 0 if it never does
+	Rollover float64
+	// Period names the calendar period of period totals, and PeriodStart truncates a
+	// time to the start of its period
+	Period      string
+	PeriodStart func(time.Time) time.Time
+}
+
+// TransformResult is a datasource transformed into rates, differences, integrals or
+// totals
+type TransformResult struct {
+	DataSource *models.DataSource
+	// SourceUnit is the unit the datasource was read in
+	SourceUnit string
+	// Unit of the transformed values, empty if the registry has none for them
+	Unit string
+	// Options are those used, with the default Per filled in
+	Options TransformOptions
+	Points  []timeseries.Point
+	// Total is the change or the consumption over the range, nil for rates
+	Total *float64
+	// Counter describes the decreases found by counter_delta
+	Counter timeseries.CounterStats
+}
+
+// Transform reads the datasource id within [startTime, endTime] and applies
+// opts.Transform. unit, if set, converts the values to it first, so that the
+// integral of a series read in kW is in kWh.
+func (l *Loader) Transform(ctx context.Context, id int64, startTime, endTime *time.Time, opts TransformOptions, unit string) (result *TransformResult, err error) {
+	ctx, span := tracing.Start(ctx, "dataset.Transform",
+		attribute.Int64("datasource.id", id), attribute.String("transform", string(opts.Transform)))
+	defer func() { tracing.End(span, err) }()
+
+	ds, points, err := l.Points(ctx, id, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	if unit, err = ConvertPoints(ds, points, unit); err != nil {
+		return nil, err
+	}
+	if len(points) < 2 {
+		return nil, fmt.Errorf("%w in the range to transform, need at least 2", ErrTooFewPoints)
+	}
+
+	result = &TransformResult{DataSource: ds, SourceUnit: unit, Unit: changeUnit(unit), Options: opts}
+	switch opts.Transform {
+	case timeseries.TransformRate:
+		if result.Options.Per <= 0 {
+			result.Options.Per = DefaultRatePer
+		}
+		result.Points = timeseries.Rate(points, result.Options.Per)
+		result.Unit = units.Derivative(unit, result.Options.Per)
+	case timeseries.TransformDifference:
+		result.Points = timeseries.Difference(points)
+		result.Total = sumPoints(result.Points)
+	case timeseries.TransformIntegral:
+		if result.Options.Per <= 0 {
+			result.Options.Per = DefaultIntegralPer
+		}
+		result.Points = timeseries.Integral(points, result.Options.Per)
+		result.Unit = units.Integral(unit, result.Options.Per)
+		total := result.Points[len(result.Points)-1].Value
+		result.Total = &total
+	case timeseries.TransformCounterDelta:
+		result.Points, result.Counter = timeseries.CounterDeltas(points, opts.Rollover)
+		result.Total = sumPoints(result.Points)
+	case timeseries.TransformRunningTotal:
+		result.Points = timeseries.RunningTotal(points)
+		result.Total = sumPoints(points)
+	case timeseries.TransformPeriodTotal:
+		if opts.PeriodStart == nil {
+			return nil, errors.New("period_total needs a period")
+		}
+		result.Points = timeseries.PeriodTotal(points, opts.PeriodStart)
+		result.Total = sumPoints(points)
+	default:
+		return nil, fmt.Errorf("unknown transform %q", opts.Transform)
+	}
+
+	span.SetAttributes(attribute.Int("rows", len(result.Points)))
+	return result, nil
+}
+
+// SaveTransformed stores the values of result as a new CSV datasource named name
+func (l *Loader) SaveTransformed(ctx context.Context, name string, result *TransformResult) (*models.DataSource, error) {
+	rows := make([]string, 0, len(result.Points))
+	for _, p := range result.Points {
+		rows = append(rows, csvRow(p.Timestamp, p.Value))
+	}
+
+	source := result.DataSource
+	description := fmt.Sprintf("%s of %s", result.Options.Transform, source.Name)
+	switch result.Options.Transform {
+	case timeseries.TransformRate, timeseries.TransformIntegral:
+		description += " per " + analysis.FormatDuration(result.Options.Per)
+	case timeseries.TransformPeriodTotal:
+		description += " by " + result.Options.Period
+	}
+	ds, err := l.saveCSV(ctx, name, result.Unit, description, fmt.Sprintf("%s_%d.csv", result.Options.Transform, source.DataSourceId),
+		"timestamp,value", rows)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("Saved transformed datasource", "datasource_id", ds.DataSourceId,
+		"source_id", source.DataSourceId, "transform", result.Options.Transform, "rows", len(rows))
+	return ds, nil
+}
+
+// changeUnit is the unit of differences and sums of values in unit. Values in a unit
+// with an offset, like degC, are readings: their differences and sums are not, and
+// converting them would apply the offset again, so they have no unit.
+func changeUnit(unit string) string {
+	if units.HasOffset(unit) {
+		return ""
+	}
+	return unit
+}
+
+// sumPoints is the sum of the values of points
+func sumPoints(points []timeseries.Point) *float64 {
+	total := 0.0
+	for _, p := range points {
+		total += p.Value
+	}
+	return &total
+}
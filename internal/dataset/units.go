@@ -0,0 +1,45 @@
+package dataset
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+// ErrNoUnit is returned when converting a datasource that has no unit recorded
+var ErrNoUnit = errors.New("datasource has no unit")
+
+// UnitConverter returns a function converting the values of ds to unit, and the unit
+// of the converted values. An empty unit leaves the values in the datasource's unit.
+func UnitConverter(ds *models.DataSource, unit string) (func(float64) float64, string, error) {
+	if unit == "" {
+		return func(v float64) float64 { return v }, ds.Unit, nil
+	}
+	if ds.Unit == "" {
+		return nil, "", fmt.Errorf("%w, cannot convert datasource %d to %s", ErrNoUnit, ds.DataSourceId, unit)
+	}
+
+	convert, err := units.Converter(ds.Unit, unit)
+	if err != nil {
+		return nil, "", err
+	}
+	canonical, _ := units.Canonical(unit)
+	return convert, canonical, nil
+}
+
+// ConvertPoints converts points of ds to unit in place, and returns their unit
+func ConvertPoints(ds *models.DataSource, points []timeseries.Point, unit string) (string, error) {
+	convert, converted, err := UnitConverter(ds, unit)
+	if err != nil {
+		return "", err
+	}
+	if unit != "" {
+		for i := range points {
+			points[i].Value = convert(points[i].Value)
+		}
+	}
+	return converted, nil
+}
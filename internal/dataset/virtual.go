@@ -0,0 +1,204 @@
+package dataset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/auth"
+	"github.com/nathanaday/iot-data-sandbox/internal/expr"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxVirtualDepth limits how deep virtual datasources can be built on each other
+const maxVirtualDepth = 8
+
+// ExpressionError is returned for an expression that cannot be parsed or evaluated,
+// such as one referring to a missing datasource or mixing incompatible units
+type ExpressionError struct {
+	Err error
+}
+
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("invalid expression: %v", e.Err)
+}
+
+func (e *ExpressionError) Unwrap() error {
+	return e.Err
+}
+
+// Evaluate computes an expression over the datasources it refers to, limited to
+// [startTime, endTime] when either is set and converted to unit if it is set. It
+// returns the points where the result is a number, and their unit.
+func (l *Loader) Evaluate(ctx context.Context, expression string, startTime, endTime *time.Time, unit string) ([]timeseries.Point, string, error) {
+	e, err := expr.Parse(expression)
+	if err != nil {
+		return nil, "", &ExpressionError{Err: err}
+	}
+
+	points, resultUnit, err := l.evaluate(ctx, e, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	points = timeseries.FilterPoints(points, startTime, endTime)
+
+	if unit == "" {
+		return points, resultUnit, nil
+	}
+	if resultUnit == "" {
+		return nil, "", fmt.Errorf("%w: the result of the expression has no unit to convert to %s", units.ErrIncompatible, unit)
+	}
+	convert, err := units.Converter(resultUnit, unit)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range points {
+		points[i].Value = convert(points[i].Value)
+	}
+	canonical, _ := units.Canonical(unit)
+	return points, canonical, nil
+}
+
+// CreateVirtual registers a virtual datasource named name that computes expression
+// whenever it is read. unit, if set, is the unit its values are reported in and must
+// have the dimension of the expression's result.
+func (l *Loader) CreateVirtual(ctx context.Context, name, expression, unit string) (*models.DataSource, error) {
+	e, err := expr.Parse(expression)
+	if err != nil {
+		return nil, &ExpressionError{Err: err}
+	}
+
+	points, resultUnit, err := l.evaluate(ctx, e, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if unit != "" {
+		if unit, err = units.Canonical(unit); err != nil {
+			return nil, err
+		}
+		if resultUnit != "" {
+			if err := units.CheckCompatible(resultUnit, unit); err != nil {
+				return nil, &ExpressionError{Err: err}
+			}
+		}
+	} else {
+		unit = resultUnit
+	}
+
+	tsData := timeseries.FromPoints(points, timeseries.TimestampCol, timeseries.ValueCol)
+	dataSource := &models.DataSource{
+		Name:           name,
+		DataSourceType: models.DataSourceTypeVirtual,
+		Expression:     e.String(),
+		RowCount:       tsData.RowCount,
+		TimeLabel:      tsData.TimeLabel,
+		ValueLabel:     tsData.ValueLabel,
+		Unit:           unit,
+		CreatedBy:      auth.ActingUser(ctx),
+		WhenCreated:    time.Now(),
+	}
+	if tsData.RowCount > 0 {
+		dataSource.StartTime = &tsData.StartTime
+		dataSource.EndTime = &tsData.EndTime
+	}
+	detectSeasonality(ctx, dataSource, points)
+
+	schema := dataSource.ToSchema()
+	if err := l.store.SaveDataSource(schema); err != nil {
+		return nil, fmt.Errorf("failed to save datasource: %w", err)
+	}
+	dataSource.DataSourceId = schema.DataSourceId
+	logging.FromContext(ctx).Info("Created virtual datasource", "datasource_id", dataSource.DataSourceId,
+		"expression", dataSource.Expression, "rows", dataSource.RowCount)
+
+	return dataSource, nil
+}
+
+// evaluate computes e over the full series of its inputs. depth counts the virtual
+// datasources being evaluated above this one.
+func (l *Loader) evaluate(ctx context.Context, e *expr.Expr, depth int) (points []timeseries.Point, unit string, err error) {
+	ctx, span := tracing.Start(ctx, "expr.Evaluate", attribute.String("expression", e.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if depth >= maxVirtualDepth {
+		return nil, "", &ExpressionError{Err: fmt.Errorf("virtual datasources are nested more than %d deep", maxVirtualDepth)}
+	}
+
+	ids := e.DataSourceIds()
+	inputs := make([][]timeseries.Point, len(ids))
+	inputUnits := make([]string, len(ids))
+	for i, id := range ids {
+		ds, err := l.DataSource(id)
+		if err != nil {
+			return nil, "", &ExpressionError{Err: fmt.Errorf("ds(%d): %w", id, err)}
+		}
+		if inputs[i], err = l.series(ctx, ds, depth+1); err != nil {
+			return nil, "", err
+		}
+		inputUnits[i] = ds.Unit
+	}
+
+	times, columns := timeseries.Align(inputs...)
+	values := make(map[int64]expr.Input, len(ids))
+	for i, id := range ids {
+		values[id] = expr.Input{Values: columns[i], Unit: inputUnits[i]}
+	}
+
+	result, err := e.Eval(times, values)
+	if err != nil {
+		return nil, "", &ExpressionError{Err: err}
+	}
+
+	points = make([]timeseries.Point, 0, len(times))
+	for i, v := range result.Values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		points = append(points, timeseries.Point{Timestamp: times[i], Value: v})
+	}
+	return points, result.Unit, nil
+}
+
+// series reads every point of a datasource, evaluating it if it is virtual
+func (l *Loader) series(ctx context.Context, ds *models.DataSource, depth int) ([]timeseries.Point, error) {
+	if !ds.IsVirtual() {
+		tsData, err := l.read(ctx, ds)
+		if err != nil {
+			return nil, err
+		}
+		return tsData.Points()
+	}
+
+	e, err := expr.Parse(ds.Expression)
+	if err != nil {
+		return nil, &ExpressionError{Err: fmt.Errorf("datasource %d: %w", ds.DataSourceId, err)}
+	}
+	points, unit, err := l.evaluate(ctx, e, depth)
+	if err != nil {
+		var exprErr *ExpressionError
+		if errors.As(err, &exprErr) && depth > 0 {
+			return nil, &ExpressionError{Err: fmt.Errorf("datasource %d: %w", ds.DataSourceId, exprErr.Err)}
+		}
+		return nil, err
+	}
+
+	// Report the values in the unit recorded for the datasource
+	if unit != "" && ds.Unit != "" && unit != ds.Unit {
+		convert, err := units.Converter(unit, ds.Unit)
+		if err != nil {
+			return nil, &ExpressionError{Err: err}
+		}
+		for i := range points {
+			points[i].Value = convert(points[i].Value)
+		}
+	}
+	return points, nil
+}
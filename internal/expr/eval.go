@@ -0,0 +1,429 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+// Input holds the values of a datasource at the aligned times, in Unit. An empty
+// Unit means the datasource has no recorded unit.
+type Input struct {
+	Values []float64
+	Unit   string
+}
+
+// Result is the value of an expression at each aligned time. Values are NaN or ±Inf
+// where an input is missing or the arithmetic is undefined, such as log(-1).
+type Result struct {
+	Values []float64
+	Unit   string
+}
+
+// EvalError reports an expression that cannot be evaluated, such as one adding
+// values of incompatible units
+type EvalError struct {
+	Pos int
+	Err error
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("at position %d: %v", e.Pos+1, e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// Eval evaluates the expression at times, given the aligned inputs of every
+// datasource it refers to. Units are checked as the expression is evaluated:
+// adding or subtracting values of different dimensions is rejected, and
+// values of the same dimension are converted to the unit of the left operand.
+// Multiplying or dividing by a plain number keeps the unit, and dividing values of
+// the same dimension gives a fraction. Other products, ratios and powers, such as
+// kW per m3/h, have a derived unit outside the registry: the result then has no
+// unit, but adding it to or comparing it with a value that has one is rejected.
+// Units with an offset, like degC, only hold readings: a difference, multiple,
+// ratio, negation or absolute value of readings has a derived unit too, while
+// adding or subtracting a plain number shifts a reading and keeps its unit.
+func (e *Expr) Eval(times []time.Time, inputs map[int64]Input) (*Result, error) {
+	ev := &evaluator{times: times, inputs: inputs}
+	v, err := ev.eval(e.root)
+	if err != nil {
+		return nil, err
+	}
+	unit := v.unit
+	if unit == derivedUnit {
+		unit = ""
+	}
+	return &Result{Values: ev.materialize(v), Unit: unit}, nil
+}
+
+// Unit checks the units of the expression without data, given the unit of each
+// datasource it refers to, and returns the unit of its result
+func (e *Expr) Unit(inputUnits map[int64]string) (string, error) {
+	inputs := make(map[int64]Input, len(inputUnits))
+	for id, unit := range inputUnits {
+		inputs[id] = Input{Unit: unit}
+	}
+	result, err := e.Eval(nil, inputs)
+	if err != nil {
+		return "", err
+	}
+	return result.Unit, nil
+}
+
+// derivedUnit marks a value whose unit, such as kW*h/m3, is not in the registry. It
+// cannot be added to or compared with a value in a registry unit.
+const derivedUnit = "derived"
+
+// value is an intermediate result: a series aligned with the evaluator's times, or a
+// scalar that applies at every time
+type value struct {
+	series []float64
+	scalar float64
+	isScal bool
+	unit   string
+}
+
+func (v value) at(i int) float64 {
+	if v.isScal {
+		return v.scalar
+	}
+	return v.series[i]
+}
+
+type evaluator struct {
+	times  []time.Time
+	inputs map[int64]Input
+}
+
+func (ev *evaluator) materialize(v value) []float64 {
+	if !v.isScal {
+		return v.series
+	}
+	values := make([]float64, len(ev.times))
+	for i := range values {
+		values[i] = v.scalar
+	}
+	return values
+}
+
+// apply combines args element by element
+func (ev *evaluator) apply(args []value, unit string, f func(xs []float64) float64) value {
+	xs := make([]float64, len(args))
+	scalar := true
+	for _, a := range args {
+		scalar = scalar && a.isScal
+	}
+	if scalar {
+		for j, a := range args {
+			xs[j] = a.scalar
+		}
+		return value{scalar: f(xs), isScal: true, unit: unit}
+	}
+
+	out := make([]float64, len(ev.times))
+	for i := range out {
+		for j, a := range args {
+			xs[j] = a.at(i)
+		}
+		out[i] = f(xs)
+	}
+	return value{series: out, unit: unit}
+}
+
+func (ev *evaluator) eval(n node) (value, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return value{scalar: n.value, isScal: true}, nil
+
+	case *refNode:
+		input, ok := ev.inputs[n.id]
+		if !ok {
+			return value{}, &EvalError{Pos: n.pos, Err: fmt.Errorf("no data for ds(%d)", n.id)}
+		}
+		v := value{series: input.Values, unit: input.Unit}
+		if v.series == nil {
+			v.series = make([]float64, len(ev.times))
+		}
+		if n.unit == "" || n.unit == input.Unit {
+			return v, nil
+		}
+		if input.Unit == "" {
+			return value{}, &EvalError{Pos: n.pos, Err: fmt.Errorf("ds(%d) has no unit to convert to %s", n.id, n.unit)}
+		}
+		return ev.convert(n.pos, v, n.unit)
+
+	case *negNode:
+		x, err := ev.eval(n.x)
+		if err != nil {
+			return value{}, err
+		}
+		return ev.apply([]value{x}, scaled(x), func(xs []float64) float64 { return -xs[0] }), nil
+
+	case *binaryNode:
+		x, err := ev.eval(n.x)
+		if err != nil {
+			return value{}, err
+		}
+		y, err := ev.eval(n.y)
+		if err != nil {
+			return value{}, err
+		}
+		return ev.binary(n, x, y)
+
+	case *callNode:
+		args := make([]value, 0, len(n.args))
+		for _, a := range n.args {
+			v, err := ev.eval(a)
+			if err != nil {
+				return value{}, err
+			}
+			args = append(args, v)
+		}
+		return n.fn.eval(ev, n.pos, args)
+	}
+	return value{}, fmt.Errorf("unexpected node %T", n)
+}
+
+func (ev *evaluator) binary(n *binaryNode, x, y value) (value, error) {
+	switch n.op {
+	case '+', '-':
+		unit, args, err := ev.common(n.pos, x, y)
+		if err != nil {
+			return value{}, err
+		}
+		if n.op == '+' {
+			return ev.apply(args, unit, func(xs []float64) float64 { return xs[0] + xs[1] }), nil
+		}
+		if x.unit != "" && y.unit != "" && (hasOffset(x) || hasOffset(y)) {
+			// The difference of two readings, such as a delta T, is not a reading
+			unit = derivedUnit
+		}
+		return ev.apply(args, unit, func(xs []float64) float64 { return xs[0] - xs[1] }), nil
+	case '*':
+		unit := derivedUnit
+		switch {
+		case x.unit == "" && y.unit == "":
+			unit = ""
+		case isNumber(x):
+			unit = scaled(y)
+		case isNumber(y):
+			unit = scaled(x)
+		}
+		return ev.apply([]value{x, y}, unit, func(xs []float64) float64 { return xs[0] * xs[1] }), nil
+	case '/':
+		unit := derivedUnit
+		switch {
+		case x.unit == "" && y.unit == "":
+			unit = ""
+		case isNumber(y):
+			unit = scaled(x)
+		case hasOffset(x) || hasOffset(y):
+		case x.unit != derivedUnit && y.unit != derivedUnit && x.unit != "" && y.unit != "" && units.CheckCompatible(x.unit, y.unit) == nil:
+			// The ratio of two values of the same dimension, such as kW out over
+			// kW in, is a plain fraction
+			var err error
+			if y, err = ev.convert(n.pos, y, x.unit); err != nil {
+				return value{}, err
+			}
+			unit = "fraction"
+		}
+		return ev.apply([]value{x, y}, unit, func(xs []float64) float64 { return xs[0] / xs[1] }), nil
+	case '^':
+		return ev.pow(x, y), nil
+	}
+	return value{}, fmt.Errorf("unexpected operator %c", n.op)
+}
+
+// pow raises x to the power y. Only a power of exactly 1 keeps the unit of x.
+func (ev *evaluator) pow(x, y value) value {
+	unit := derivedUnit
+	switch {
+	case x.unit == "" && y.unit == "":
+		unit = ""
+	case isNumber(y) && y.scalar == 1:
+		unit = x.unit
+	}
+	return ev.apply([]value{x, y}, unit, func(xs []float64) float64 { return math.Pow(xs[0], xs[1]) })
+}
+
+// isNumber reports whether v is a plain number, such as a literal or a constant
+func isNumber(v value) bool {
+	return v.isScal && v.unit == ""
+}
+
+// hasOffset reports whether v is in a unit with an offset, like degC
+func hasOffset(v value) bool {
+	return v.unit != "" && v.unit != derivedUnit && units.HasOffset(v.unit)
+}
+
+// scaled is the unit of a multiple of v: the unit of v, unless it has an offset
+func scaled(v value) string {
+	if hasOffset(v) {
+		return derivedUnit
+	}
+	return v.unit
+}
+
+// common converts values to the unit of the first one that has a unit, so that they
+// can be added, subtracted or compared. Values without a unit are taken as is, and
+// values of a derived unit only combine with each other.
+func (ev *evaluator) common(pos int, args ...value) (string, []value, error) {
+	unit := ""
+	for _, a := range args {
+		if a.unit != "" {
+			unit = a.unit
+			break
+		}
+	}
+
+	converted := make([]value, len(args))
+	for i, a := range args {
+		if a.unit == "" || a.unit == unit {
+			converted[i] = a
+			continue
+		}
+		if a.unit == derivedUnit || unit == derivedUnit {
+			known := unit
+			if known == derivedUnit {
+				known = a.unit
+			}
+			return "", nil, &EvalError{Pos: pos, Err: fmt.Errorf("%w: cannot combine %s with a product, ratio or power of units", units.ErrIncompatible, known)}
+		}
+		c, err := ev.convert(pos, a, unit)
+		if err != nil {
+			return "", nil, err
+		}
+		converted[i] = c
+	}
+	return unit, converted, nil
+}
+
+func (ev *evaluator) convert(pos int, v value, unit string) (value, error) {
+	convert, err := units.Converter(v.unit, unit)
+	if err != nil {
+		return value{}, &EvalError{Pos: pos, Err: err}
+	}
+	return ev.apply([]value{v}, unit, func(xs []float64) float64 { return convert(xs[0]) }), nil
+}
+
+// function is a function that can be called in expressions
+type function struct {
+	minArgs int
+	maxArgs int // -1 for any number
+	eval    func(ev *evaluator, pos int, args []value) (value, error)
+}
+
+func (f *function) arity() string {
+	switch {
+	case f.minArgs == f.maxArgs && f.minArgs == 1:
+		return "1 argument"
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d arguments", f.minArgs)
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+	}
+}
+
+// unitPreserving applies f to one argument and keeps its unit, like round()
+func unitPreserving(f func(float64) float64) *function {
+	return &function{minArgs: 1, maxArgs: 1, eval: func(ev *evaluator, pos int, args []value) (value, error) {
+		return ev.apply(args, args[0].unit, func(xs []float64) float64 { return f(xs[0]) }), nil
+	}}
+}
+
+// magnitude is abs(), which keeps the unit unless it has an offset: the absolute
+// value of a degC reading depends on where the scale puts zero
+var magnitude = &function{minArgs: 1, maxArgs: 1, eval: func(ev *evaluator, pos int, args []value) (value, error) {
+	return ev.apply(args, scaled(args[0]), func(xs []float64) float64 { return math.Abs(xs[0]) }), nil
+}}
+
+// unitless applies f to one argument, like log(); the result has a derived unit
+// unless the argument has no unit
+func unitless(f func(float64) float64) *function {
+	return &function{minArgs: 1, maxArgs: 1, eval: func(ev *evaluator, pos int, args []value) (value, error) {
+		unit := ""
+		if args[0].unit != "" {
+			unit = derivedUnit
+		}
+		return ev.apply(args, unit, func(xs []float64) float64 { return f(xs[0]) }), nil
+	}}
+}
+
+// extremum is min() or max() over arguments of compatible units
+func extremum(f func(a, b float64) float64) *function {
+	return &function{minArgs: 2, maxArgs: -1, eval: func(ev *evaluator, pos int, args []value) (value, error) {
+		unit, args, err := ev.common(pos, args...)
+		if err != nil {
+			return value{}, err
+		}
+		return ev.apply(args, unit, func(xs []float64) float64 {
+			m := xs[0]
+			for _, x := range xs[1:] {
+				m = f(m, x)
+			}
+			return m
+		}), nil
+	}}
+}
+
+var functions = map[string]*function{
+	"abs":   magnitude,
+	"round": unitPreserving(math.Round),
+	"floor": unitPreserving(math.Floor),
+	"ceil":  unitPreserving(math.Ceil),
+	"sqrt":  unitless(math.Sqrt),
+	"exp":   unitless(math.Exp),
+	"log":   unitless(math.Log),
+	"log10": unitless(math.Log10),
+	"min":   extremum(math.Min),
+	"max":   extremum(math.Max),
+	"pow": {minArgs: 2, maxArgs: 2, eval: func(ev *evaluator, pos int, args []value) (value, error) {
+		return ev.pow(args[0], args[1]), nil
+	}},
+	// clamp(x, lo, hi) limits x to [lo, hi]
+	"clamp": {minArgs: 3, maxArgs: 3, eval: func(ev *evaluator, pos int, args []value) (value, error) {
+		unit, args, err := ev.common(pos, args...)
+		if err != nil {
+			return value{}, err
+		}
+		return ev.apply(args, unit, func(xs []float64) float64 { return math.Max(xs[1], math.Min(xs[2], xs[0])) }), nil
+	}},
+	// rate(x) is the change of x per second since the previous aligned time, in the
+	// registry unit of that rate if there is one: the rate of J is W
+	"rate": {minArgs: 1, maxArgs: 1, eval: func(ev *evaluator, pos int, args []value) (value, error) {
+		x := args[0]
+		unit := ""
+		if x.unit != "" {
+			if unit = units.Derivative(x.unit, time.Second); unit == "" {
+				unit = derivedUnit
+			}
+		}
+		out := make([]float64, len(ev.times))
+		for i := range out {
+			if i == 0 {
+				out[i] = math.NaN()
+				continue
+			}
+			out[i] = (x.at(i) - x.at(i-1)) / ev.times[i].Sub(ev.times[i-1]).Seconds()
+		}
+		return value{series: out, unit: unit}, nil
+	}},
+}
+
+// Functions returns the names of the functions expressions can call
+func Functions() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
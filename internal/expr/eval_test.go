@@ -0,0 +1,105 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+// testUnits are the units of the datasources the tests refer to
+var testUnits = map[int64]string{1: "kW", 2: "kWh", 3: "degC", 4: "m3/h", 5: "W", 6: ""}
+
+func TestUnitRejectsMismatchedArithmetic(t *testing.T) {
+	for _, src := range []string{
+		"ds(1) + ds(2)",
+		"2*ds(1) + ds(2)",
+		"ds(1)*1 - ds(3)",
+		"ds(1)/2 - ds(3)",
+		"ds(1)*ds(1) + ds(1)",
+		"ds(1)^2 - ds(1)",
+		"pow(ds(1), 2) - ds(1)",
+		"sqrt(ds(1)) + ds(1)",
+		"ds(1)/ds(4) + ds(1)",
+		"max(ds(1)*ds(5), ds(1))",
+		"ds(3) - ds(3) + ds(3)",
+		"-ds(3) + ds(3)",
+		"ds(3)*1.8 + 32 - ds(3)",
+		"abs(ds(3)) - ds(3)",
+	} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", src, err)
+		}
+		if unit, err := e.Unit(testUnits); !errors.Is(err, units.ErrIncompatible) {
+			t.Errorf("Unit(%q) = %q, %v, want an incompatible units error", src, unit, err)
+		}
+	}
+}
+
+func TestUnitOfArithmetic(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want string
+	}{
+		{"2*ds(1) + ds(5)", "kW"},
+		{"ds(1)*0.5", "kW"},
+		{"ds(1)/2", "kW"},
+		{"ds(3) + 5", "degC"},
+		{"ds(3) - 5", "degC"},
+		{"-ds(3) + 5", ""},
+		{"ds(3) - ds(3)", ""},
+		{"ds(3) - ds(3, \"K\")", ""},
+		{"ds(3) * 1.8 + 32", ""},
+		{"ds(3) / 2", ""},
+		{"ds(3) / ds(3)", ""},
+		{"abs(ds(3))", ""},
+		{"round(ds(3))", "degC"},
+		{"abs(ds(1))", "kW"},
+		{"ds(1)^1", "kW"},
+		{"ds(1)/ds(5)", "fraction"},
+		{"ds(1)/ds(4)", ""},
+		{"ds(1)/ds(4) - ds(5)/ds(4)", ""},
+		{"ds(6)*ds(6) + 1", ""},
+		{"ds(1)*ds(6)", ""},
+		{"rate(ds(2)) + 1", ""},
+	} {
+		e, err := Parse(tc.src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.src, err)
+		}
+		unit, err := e.Unit(testUnits)
+		if err != nil {
+			t.Errorf("Unit(%q): %v", tc.src, err)
+			continue
+		}
+		if unit != tc.want {
+			t.Errorf("Unit(%q) = %q, want %q", tc.src, unit, tc.want)
+		}
+	}
+}
+
+func TestEvalRatioOfDifferentDimensions(t *testing.T) {
+	e, err := Parse("ds(1) / ds(4)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	times := []time.Time{time.Unix(0, 0), time.Unix(60, 0)}
+	result, err := e.Eval(times, map[int64]Input{
+		1: {Values: []float64{100, 90}, Unit: "kW"},
+		4: {Values: []float64{20, 30}, Unit: "m3/h"},
+	})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if result.Unit != "" {
+		t.Errorf("Unit = %q, want none", result.Unit)
+	}
+	for i, want := range []float64{5, 3} {
+		if math.Abs(result.Values[i]-want) > 1e-12 {
+			t.Errorf("Values[%d] = %v, want %v", i, result.Values[i], want)
+		}
+	}
+}
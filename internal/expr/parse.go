@@ -0,0 +1,421 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+// Limits that keep expressions cheap to parse and evaluate
+const (
+	MaxLength = 2000
+	MaxRefs   = 16
+	maxDepth  = 64
+)
+
+// SyntaxError reports an expression that could not be parsed
+type SyntaxError struct {
+	Pos     int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos+1, e.Message)
+}
+
+// Expr is a parsed expression over datasources, such as ds(3) * 1.8 + 32
+type Expr struct {
+	src  string
+	root node
+	ids  []int64
+}
+
+// Parse parses an expression. Datasources are referenced as ds(id), or as
+// ds(id, "unit") to read them converted to a unit.
+func Parse(src string) (*Expr, error) {
+	if len(src) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Message: fmt.Sprintf("expression is longer than %d characters", MaxLength)}
+	}
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, seen: map[int64]bool{}}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Message: fmt.Sprintf("unexpected %s", tok)}
+	}
+	if len(p.ids) == 0 {
+		return nil, &SyntaxError{Pos: 0, Message: "expression must reference at least one datasource, e.g. ds(1)"}
+	}
+
+	return &Expr{src: src, root: root, ids: p.ids}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// DataSourceIds returns the datasources the expression refers to, in order of first use
+func (e *Expr) DataSourceIds() []int64 {
+	return append([]int64(nil), e.ids...)
+}
+
+type node interface {
+	position() int
+}
+
+type numberNode struct {
+	pos   int
+	value float64
+}
+
+// refNode is ds(id), optionally converted to unit
+type refNode struct {
+	pos  int
+	id   int64
+	unit string
+}
+
+type negNode struct {
+	pos int
+	x   node
+}
+
+type binaryNode struct {
+	pos  int
+	op   byte
+	x, y node
+}
+
+type callNode struct {
+	pos  int
+	name string
+	fn   *function
+	args []node
+}
+
+func (n *numberNode) position() int { return n.pos }
+func (n *refNode) position() int    { return n.pos }
+func (n *negNode) position() int    { return n.pos }
+func (n *binaryNode) position() int { return n.pos }
+func (n *callNode) position() int   { return n.pos }
+
+// constants can be used by name
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					for i = j; i < len(src) && isDigit(src[i]); i++ {
+					}
+				}
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, &SyntaxError{Pos: start, Message: fmt.Sprintf("invalid number %q", src[start:i])}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: num, pos: start})
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, &SyntaxError{Pos: i, Message: "unterminated string"}
+			}
+			tokens = append(tokens, token{kind: tokString, text: src[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case strings.IndexByte("+-*/^(),", c) >= 0:
+			tokens = append(tokens, token{kind: tokOp, text: string(c), pos: i})
+			i++
+		default:
+			return nil, &SyntaxError{Pos: i, Message: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parser is a recursive descent parser for
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = ("-" | "+") unary | power
+//	power   = primary [ "^" unary ]
+//	primary = number | constant | name "(" [ expr { "," expr } ] ")" | "(" expr ")"
+type parser struct {
+	tokens []token
+	i      int
+	depth  int
+	ids    []int64
+	seen   map[int64]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *parser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokOp && tok.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		tok := p.peek()
+		return &SyntaxError{Pos: tok.pos, Message: fmt.Sprintf("expected %q, found %s", op, tok)}
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseExpr() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, &SyntaxError{Pos: p.peek().pos, Message: "expression is nested too deeply"}
+	}
+
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next()
+		y, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: op.pos, op: op.text[0], x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") {
+		op := p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: op.pos, op: op.text[0], x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, &SyntaxError{Pos: p.peek().pos, Message: "expression is nested too deeply"}
+	}
+
+	if p.isOp("-") {
+		op := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{pos: op.pos, x: x}, nil
+	}
+	if p.isOp("+") {
+		p.next()
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("^") {
+		op := p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{pos: op.pos, op: '^', x: x, y: y}, nil
+	}
+	return x, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokNumber:
+		return &numberNode{pos: tok.pos, value: tok.num}, nil
+	case tok.kind == tokOp && tok.text == "(":
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case tok.kind == tokIdent && tok.text == "ds":
+		return p.parseRef(tok)
+	case tok.kind == tokIdent:
+		if !p.isOp("(") {
+			if value, ok := constants[tok.text]; ok {
+				return &numberNode{pos: tok.pos, value: value}, nil
+			}
+			return nil, &SyntaxError{Pos: tok.pos, Message: fmt.Sprintf("unknown name %q", tok.text)}
+		}
+		return p.parseCall(tok)
+	default:
+		return nil, &SyntaxError{Pos: tok.pos, Message: fmt.Sprintf("unexpected %s", tok)}
+	}
+}
+
+// parseRef parses the arguments of ds(id) or ds(id, "unit")
+func (p *parser) parseRef(name token) (node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	tok := p.next()
+	id, err := strconv.ParseInt(tok.text, 10, 64)
+	if tok.kind != tokNumber || err != nil || id < 1 {
+		return nil, &SyntaxError{Pos: tok.pos, Message: "ds() takes a datasource ID, e.g. ds(3)"}
+	}
+	ref := &refNode{pos: name.pos, id: id}
+
+	if p.isOp(",") {
+		p.next()
+		tok := p.next()
+		if tok.kind != tokString {
+			return nil, &SyntaxError{Pos: tok.pos, Message: `the second argument of ds() is a quoted unit, e.g. ds(3, "degF")`}
+		}
+		unit, err := units.Canonical(tok.text)
+		if err != nil {
+			return nil, &SyntaxError{Pos: tok.pos, Message: err.Error()}
+		}
+		ref.unit = unit
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if !p.seen[id] {
+		if len(p.ids) == MaxRefs {
+			return nil, &SyntaxError{Pos: name.pos, Message: fmt.Sprintf("expression references more than %d datasources", MaxRefs)}
+		}
+		p.seen[id] = true
+		p.ids = append(p.ids, id)
+	}
+	return ref, nil
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, &SyntaxError{Pos: name.pos, Message: fmt.Sprintf("unknown function %q", name.text)}
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	call := &callNode{pos: name.pos, name: name.text, fn: fn}
+	if !p.isOp(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if len(call.args) < fn.minArgs || (fn.maxArgs >= 0 && len(call.args) > fn.maxArgs) {
+		return nil, &SyntaxError{Pos: name.pos, Message: fmt.Sprintf("%s() takes %s", name.text, fn.arity())}
+	}
+	return call, nil
+}
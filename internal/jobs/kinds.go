@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+// Built-in job kinds
+const (
+	KindToolInvoke = "tool_invoke"
+	KindIngestCSV  = "ingest_csv"
+)
+
+// ParamsError reports job params rejected at submission time
+type ParamsError struct {
+	Err error
+}
+
+func (e *ParamsError) Error() string {
+	return fmt.Sprintf("invalid job params: %v", e.Err)
+}
+
+func (e *ParamsError) Unwrap() error {
+	return e.Err
+}
+
+// ToolInvokeParams are the params of a tool_invoke job
+type ToolInvokeParams struct {
+	FxName    string          `json:"fx_name"`
+	Arguments json.RawMessage `json:"arguments,omitempty" swaggertype:"object"`
+}
+
+// IngestCSVParams are the params of an ingest_csv job. File names the file the
+// upload handler wrote to the file store for this job alone; the file is deleted
+// if it cannot be ingested, so the kind is internal.
+type IngestCSVParams struct {
+	Name string `json:"name"`
+	Unit string `json:"unit,omitempty"`
+	File string `json:"file"`
+}
+
+// IngestCSVResult is the result of an ingest_csv job
+type IngestCSVResult struct {
+	DataSourceId int64  `json:"data_source_id"`
+	Name         string `json:"name"`
+	RowCount     int    `json:"row_count"`
+}
+
+// RegisterBuiltinKinds registers the tool invocation and CSV ingestion job kinds
+func RegisterBuiltinKinds(m *Manager, executor *tools.Executor, loader *dataset.Loader) {
+	m.Register(KindToolInvoke, Handler{
+		Validate: func(params json.RawMessage) error {
+			var p ToolInvokeParams
+			if err := decodeParams(params, &p); err != nil {
+				return err
+			}
+			if err := executor.Validate(p.FxName, p.Arguments); err != nil {
+				return &ParamsError{Err: err}
+			}
+			return nil
+		},
+		Run: func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+			var p ToolInvokeParams
+			if err := decodeParams(params, &p); err != nil {
+				return nil, err
+			}
+			progress(0, fmt.Sprintf("running %s", p.FxName))
+			return executor.Execute(ctx, p.FxName, p.Arguments)
+		},
+		// Only read-only calls are run again; a tool that saves a datasource or
+		// changes anything else could repeat its side effects
+		Resumable: true,
+		CanResume: func(params json.RawMessage) bool {
+			var p ToolInvokeParams
+			return json.Unmarshal(params, &p) == nil && executor.CanRepeat(p.FxName, p.Arguments)
+		},
+	})
+
+	m.Register(KindIngestCSV, Handler{
+		Validate: func(params json.RawMessage) error {
+			var p IngestCSVParams
+			return decodeParams(params, &p)
+		},
+		Run: func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+			var p IngestCSVParams
+			if err := decodeParams(params, &p); err != nil {
+				return nil, err
+			}
+
+			progress(0, "validating CSV")
+			ds, err := loader.Ingest(ctx, p.Name, p.Unit, p.File)
+			if err != nil {
+				return nil, err
+			}
+			return IngestCSVResult{
+				DataSourceId: ds.DataSourceId,
+				Name:         ds.Name,
+				RowCount:     ds.RowCount,
+			}, nil
+		},
+		// The staged file stays in the file store until ingestion finishes, so an
+		// interrupted ingestion can start over
+		Resumable: true,
+		Internal:  true,
+	})
+}
+
+func (p *ToolInvokeParams) validate() error {
+	if p.FxName == "" {
+		return fmt.Errorf("fx_name is required")
+	}
+	return nil
+}
+
+func (p *IngestCSVParams) validate() error {
+	if p.File == "" || filepath.Base(p.File) != p.File {
+		return fmt.Errorf("file must be the name of an uploaded file")
+	}
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if p.Unit != "" {
+		if _, err := units.Lookup(p.Unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeParams(params json.RawMessage, v interface{ validate() error }) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ParamsError{Err: err}
+	}
+	if err := v.validate(); err != nil {
+		return &ParamsError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,460 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/auth"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DefaultWorkers = 4
+	// MaxAttempts bounds how many times a resumable job is restarted after the
+	// server was stopped while it was running
+	MaxAttempts = 3
+
+	pollInterval = 2 * time.Second
+)
+
+var (
+	// ErrUnknownKind is returned when submitting a job kind without a handler
+	ErrUnknownKind = errors.New("unknown job kind")
+	// ErrNotFound is returned when a job ID does not exist
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that has already finished
+	ErrFinished = errors.New("job has already finished")
+)
+
+// ProgressFunc reports how far a job has got, as a fraction in [0, 1] and a short
+// human-readable message
+type ProgressFunc func(fraction float64, message string)
+
+// Handler runs one kind of job
+type Handler struct {
+	// Validate checks the params at submission time so bad requests fail fast.
+	// It is optional.
+	Validate func(params json.RawMessage) error
+	// Run executes the job and returns a JSON-encodable result. It must return
+	// promptly once ctx is cancelled.
+	Run func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error)
+	// Resumable jobs are queued again when the server restarts while they run;
+	// other interrupted jobs are marked failed
+	Resumable bool
+	// CanResume, if set, decides for each interrupted job of a Resumable kind
+	// whether it is queued again
+	CanResume func(params json.RawMessage) bool
+	// Internal kinds are only submitted by the server itself, never through the
+	// jobs API
+	Internal bool
+}
+
+// Manager owns the persisted job queue and the worker pool that drains it. Jobs
+// are claimed from the jobs table, so the queue survives restarts.
+type Manager struct {
+	store   *persistence.Store
+	workers int
+
+	mu        sync.Mutex
+	handlers  map[string]Handler
+	running   map[int64]context.CancelFunc
+	cancelled map[int64]bool
+	watchers  map[int64]map[chan struct{}]bool
+
+	wake     chan struct{}
+	stop     chan struct{}
+	ctx      context.Context
+	shutdown context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewManager(store *persistence.Store, workers int) *Manager {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		store:     store,
+		workers:   workers,
+		handlers:  make(map[string]Handler),
+		running:   make(map[int64]context.CancelFunc),
+		cancelled: make(map[int64]bool),
+		watchers:  make(map[int64]map[chan struct{}]bool),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		ctx:       ctx,
+		shutdown:  cancel,
+	}
+}
+
+// Register sets the handler for a job kind. Register all kinds before Start.
+func (m *Manager) Register(kind string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[kind] = handler
+}
+
+// Start recovers jobs interrupted by the previous shutdown and starts the workers
+func (m *Manager) Start() error {
+	if err := m.recover(); err != nil {
+		return fmt.Errorf("failed to recover interrupted jobs: %w", err)
+	}
+
+	for i := 0; i < m.workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	slog.Info("Job manager started", "workers", m.workers)
+	return nil
+}
+
+// Shutdown stops claiming new jobs and waits for running ones to finish. If ctx
+// expires first the running jobs are cancelled; they stay in the running state and
+// are recovered on the next Start.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	close(m.stop)
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.shutdown()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Submit queues a new job on behalf of the acting user of ctx
+func (m *Manager) Submit(ctx context.Context, kind string, params any) (*models.Job, error) {
+	m.mu.Lock()
+	handler, ok := m.handlers[kind]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job params: %w", err)
+	}
+	if handler.Validate != nil {
+		if err := handler.Validate(encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	job := &models.Job{
+		Kind:        kind,
+		Status:      models.JobStatusQueued,
+		Params:      string(encoded),
+		CreatedBy:   auth.ActingUser(ctx),
+		WhenCreated: time.Now(),
+	}
+	schema := job.ToSchema()
+	if err := m.store.SaveJob(schema); err != nil {
+		return nil, fmt.Errorf("failed to save job: %w", err)
+	}
+	job.JobId = schema.JobId
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// IsInternal reports whether kind is a registered kind the jobs API must not accept
+func (m *Manager) IsInternal(kind string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.handlers[kind].Internal
+}
+
+// Get loads a job
+func (m *Manager) Get(id int64) (*models.Job, error) {
+	schema, err := m.store.LoadJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{}
+	job.FromSchema(schema)
+	return job, nil
+}
+
+// List loads jobs, newest first, optionally limited to one status
+func (m *Manager) List(status string) ([]*models.Job, error) {
+	schemas, err := m.store.LoadAllJobs(status)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*models.Job, 0, len(schemas))
+	for _, schema := range schemas {
+		job := &models.Job{}
+		job.FromSchema(schema)
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Cancel cancels a queued or running job. Running jobs are signalled through their
+// context and marked cancelled once their handler returns. A job a worker has
+// claimed but not started yet is marked cancelled by that worker without running.
+func (m *Manager) Cancel(id int64) (*models.Job, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.IsFinished() {
+		return job, ErrFinished
+	}
+
+	// Hold the lock so that a claimed job cannot start between the checks below
+	m.mu.Lock()
+	cancel, running := m.running[id]
+	switch {
+	case running:
+		m.cancelled[id] = true
+		cancel()
+	default:
+		err = m.store.CancelQueuedJob(id, "cancelled before it started", time.Now())
+		if errors.Is(err, sql.ErrNoRows) {
+			// Claimed by a worker that has not registered it yet; run skips it
+			m.cancelled[id] = true
+			err = nil
+		}
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify(id)
+	if job, err = m.Get(id); err == nil && job.IsFinished() && job.Status != models.JobStatusCancelled {
+		m.mu.Lock()
+		delete(m.cancelled, id)
+		m.mu.Unlock()
+		return job, ErrFinished
+	}
+	return job, err
+}
+
+// Watch returns a channel that receives a signal whenever the job's status or
+// progress changes, and a function that stops watching. Signals are coalesced, so
+// receivers should reload the job with Get after each one.
+func (m *Manager) Watch(id int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	m.mu.Lock()
+	if m.watchers[id] == nil {
+		m.watchers[id] = make(map[chan struct{}]bool)
+	}
+	m.watchers[id][ch] = true
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.watchers[id], ch)
+		if len(m.watchers[id]) == 0 {
+			delete(m.watchers, id)
+		}
+	}
+}
+
+func (m *Manager) notify(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.watchers[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Manager) recover() error {
+	interrupted, err := m.store.LoadAllJobs(models.JobStatusRunning)
+	if err != nil {
+		return err
+	}
+
+	for _, schema := range interrupted {
+		m.mu.Lock()
+		handler, ok := m.handlers[schema.Kind]
+		m.mu.Unlock()
+
+		resumable := ok && handler.Resumable && (handler.CanResume == nil || handler.CanResume(json.RawMessage(schema.Params)))
+		if resumable && schema.Attempts < MaxAttempts {
+			slog.Info("Resuming job interrupted by restart", "job_id", schema.JobId, "job_kind", schema.Kind)
+			if err := m.store.RequeueJob(schema.JobId); err != nil {
+				return err
+			}
+			continue
+		}
+
+		slog.Warn("Marking job failed: interrupted by restart", "job_id", schema.JobId, "job_kind", schema.Kind)
+		msg := "interrupted by server restart"
+		if err := m.store.FinishJob(schema.JobId, models.JobStatusFailed, nil, &msg, time.Now()); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+
+		schema, err := m.store.ClaimNextJob(time.Now())
+		if err == nil {
+			m.run(schema)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Failed to claim job", "error", err)
+		}
+
+		select {
+		case <-m.stop:
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) run(job *schemas.JobSchema) {
+	id, kind, params := job.JobId, job.Kind, json.RawMessage(job.Params)
+
+	m.mu.Lock()
+	handler, ok := m.handlers[kind]
+	ctx, cancel := context.WithCancel(m.ctx)
+	ctx = logging.With(ctx, "job_id", id, "job_kind", kind)
+	if job.CreatedBy != nil {
+		// Resources the job creates are attributed to the user who submitted it
+		ctx = auth.WithPrincipal(ctx, &auth.Principal{UserId: *job.CreatedBy})
+		ctx = logging.With(ctx, "user_id", *job.CreatedBy)
+	}
+	logger := logging.FromContext(ctx)
+	m.running[id] = cancel
+	// Cancel may have been called after the job was claimed and before this point
+	skip := m.cancelled[id]
+	m.mu.Unlock()
+	m.notify(id)
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, id)
+		delete(m.cancelled, id)
+		m.mu.Unlock()
+		cancel()
+		m.notify(id)
+	}()
+
+	var (
+		result any
+		err    error
+	)
+	started := time.Now()
+	switch {
+	case skip:
+		logger.Info("Job cancelled before it started")
+	case !ok:
+		err = fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	default:
+		progress := func(fraction float64, message string) {
+			if err := m.store.UpdateJobProgress(id, fraction, message); err != nil {
+				logger.Error("Failed to update job progress", "error", err)
+				return
+			}
+			m.notify(id)
+		}
+		// Jobs outlive the request that submitted them, so each starts its own trace
+		runCtx, span := tracing.Start(ctx, "job.Run", attribute.Int64("job.id", id), attribute.String("job.kind", kind))
+		logger.Info("Job started")
+		result, err = safeRun(runCtx, handler, params, progress)
+		tracing.End(span, err)
+	}
+
+	m.mu.Lock()
+	userCancelled := m.cancelled[id]
+	m.mu.Unlock()
+
+	now := time.Now()
+	var status string
+	switch {
+	case userCancelled:
+		status = models.JobStatusCancelled
+		msg := "cancelled"
+		err = m.store.FinishJob(id, status, nil, &msg, now)
+	case m.ctx.Err() != nil:
+		// Server shutdown: leave the job running so recover() picks it up
+		logger.Warn("Job interrupted by shutdown")
+		return
+	case err != nil:
+		status = models.JobStatusFailed
+		msg := err.Error()
+		logger.Warn("Job failed", "error", msg)
+		err = m.store.FinishJob(id, status, nil, &msg, now)
+	default:
+		status = models.JobStatusSucceeded
+		var encoded []byte
+		encoded, err = json.Marshal(result)
+		if err != nil {
+			status = models.JobStatusFailed
+			msg := fmt.Sprintf("failed to encode job result: %v", err)
+			err = m.store.FinishJob(id, status, nil, &msg, now)
+			break
+		}
+		res := string(encoded)
+		err = m.store.FinishJob(id, status, &res, nil, now)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Failed to record job result", "error", err)
+		return
+	}
+	logger.Info("Job finished", "status", status, "duration_ms", now.Sub(started).Milliseconds())
+}
+
+func safeRun(ctx context.Context, handler Handler, params json.RawMessage, progress ProgressFunc) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.FromContext(ctx).Error("Job panicked", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler.Run(ctx, params, progress)
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestRunRecordsJobSpan(t *testing.T) {
+	exporter := tracing.SetupInMemory()
+
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+
+	m := NewManager(store, 1)
+	m.Register("noop", Handler{Run: func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		return "done", nil
+	}})
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer m.Shutdown(context.Background())
+
+	job, err := m.Submit(context.Background(), "noop", map[string]string{})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	changed, stop := m.Watch(job.JobId)
+	defer stop()
+	for deadline := time.After(5 * time.Second); ; {
+		if job, err = m.Get(job.JobId); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if job.IsFinished() {
+			break
+		}
+		select {
+		case <-changed:
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("job is still %s", job.Status)
+		}
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "job.Run" {
+		t.Fatalf("recorded spans %v, want one job.Run", spans)
+	}
+	attrs := attribute.NewSet(spans[0].Attributes...)
+	if v, _ := attrs.Value("job.id"); v.AsInt64() != job.JobId {
+		t.Errorf("job.id = %d, want %d", v.AsInt64(), job.JobId)
+	}
+	if v, _ := attrs.Value("job.kind"); v.AsString() != "noop" {
+		t.Errorf("job.kind = %q, want noop", v.AsString())
+	}
+}
+
+func TestRecoverResumesOnlyRepeatableToolCalls(t *testing.T) {
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+
+	registry := tools.NewRegistry()
+	for _, def := range []*tools.Definition{
+		{FxName: "read", ReadOnly: true, Fn: func(ctx context.Context, args json.RawMessage) (any, error) { return nil, nil }},
+		{FxName: "write", Fn: func(ctx context.Context, args json.RawMessage) (any, error) { return nil, nil }},
+	} {
+		if err := registry.Register(def); err != nil {
+			t.Fatalf("Register(%s): %v", def.FxName, err)
+		}
+	}
+	m := NewManager(store, 1)
+	RegisterBuiltinKinds(m, tools.NewExecutor(store, registry, nil), nil)
+
+	for _, tc := range []struct {
+		params string
+		want   string
+	}{
+		{`{"fx_name": "read", "arguments": {}}`, models.JobStatusQueued},
+		{`{"fx_name": "read", "arguments": {"save_as": "copy"}}`, models.JobStatusFailed},
+		{`{"fx_name": "write", "arguments": {}}`, models.JobStatusFailed},
+	} {
+		job := &schemas.JobSchema{Kind: KindToolInvoke, Status: models.JobStatusRunning, Params: tc.params, WhenCreated: time.Now()}
+		if err := store.SaveJob(job); err != nil {
+			t.Fatalf("SaveJob: %v", err)
+		}
+		if err := m.recover(); err != nil {
+			t.Fatalf("recover: %v", err)
+		}
+		got, err := m.Get(job.JobId)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != tc.want {
+			t.Errorf("job with params %s is %s after a restart, want %s", tc.params, got.Status, tc.want)
+		}
+	}
+}
+
+func TestCancelSkipsClaimedJob(t *testing.T) {
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+
+	ran := false
+	m := NewManager(store, 1)
+	m.Register("ingest", Handler{Run: func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		ran = true
+		return nil, nil
+	}})
+
+	job, err := m.Submit(context.Background(), "ingest", map[string]string{})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	// A worker claims the job, then it is cancelled before the worker starts it
+	claimed, err := store.ClaimNextJob(time.Now())
+	if err != nil {
+		t.Fatalf("ClaimNextJob: %v", err)
+	}
+	if _, err := m.Cancel(job.JobId); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	m.run(claimed)
+
+	if ran {
+		t.Error("cancelled job ran")
+	}
+	if job, err = m.Get(job.JobId); err != nil || job.Status != models.JobStatusCancelled {
+		t.Errorf("job = %+v, %v, want it cancelled", job, err)
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+
+	m := NewManager(store, 1)
+	m.Register("noop", Handler{Run: func(ctx context.Context, params json.RawMessage, progress ProgressFunc) (any, error) {
+		return nil, nil
+	}})
+	job, err := m.Submit(context.Background(), "noop", map[string]string{})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	if job, err = m.Cancel(job.JobId); err != nil || job.Status != models.JobStatusCancelled {
+		t.Fatalf("Cancel = %+v, %v, want the job cancelled", job, err)
+	}
+	if _, err := store.ClaimNextJob(time.Now()); err == nil {
+		t.Error("a worker claimed the cancelled job")
+	}
+	if _, err := m.Cancel(job.JobId); !errors.Is(err, ErrFinished) {
+		t.Errorf("second Cancel error = %v, want ErrFinished", err)
+	}
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/config"
+)
+
+// Setup makes a logger with the configured level and format the slog default.
+// Output of the standard log package, used by some dependencies, goes through it
+// too.
+func Setup(w io.Writer, cfg config.LogConfig) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch cfg.Format {
+	case config.LogFormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (valid: debug, info, warn, error)", s)
+	}
+	return level, nil
+}
+
+// entry holds the logger of a context. The request entry is shared by everything
+// that handles the request, so attributes learnt along the way, such as the
+// authenticated user, also reach the request's access log line.
+type entry struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+type entryKey struct{}
+
+// NewContext returns a context carrying logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, entryKey{}, &entry{logger: logger})
+}
+
+// FromContext returns the logger of ctx, or the default logger if ctx has none
+func FromContext(ctx context.Context) *slog.Logger {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.logger
+	}
+	return slog.Default()
+}
+
+// With returns a context whose logger adds args to every line, for work scoped to
+// part of a request such as one tool call
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
+
+// Annotate adds args to the logger of ctx in place, so they also appear on lines
+// logged by callers that hold an outer context, such as the access log. Contexts
+// derived with With before the call are not affected. If ctx has no logger of its
+// own, Annotate is With.
+func Annotate(ctx context.Context, args ...any) context.Context {
+	e, ok := ctx.Value(entryKey{}).(*entry)
+	if !ok {
+		return With(ctx, args...)
+	}
+	e.mu.Lock()
+	e.logger = e.logger.With(args...)
+	e.mu.Unlock()
+	return ctx
+}
@@ -0,0 +1,256 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/tools"
+)
+
+const (
+	serverName = "iot-data-sandbox"
+	// HTTPPath is where the streamable HTTP transport is mounted
+	HTTPPath = "/mcp"
+
+	dataSourceURIPrefix = "datasource://"
+	instructions        = `IoT time series sandbox. Each datasource is a resource at datasource://{id}
+holding its metadata and (timestamp, value) points. Use the tools to query and analyze datasources.`
+)
+
+// Server exposes datasources as MCP resources and enabled tools as MCP tools.
+// Tool calls run through the same executor as the agent, so the tools table policy
+// (enabled flag, timeouts and call quotas) applies to MCP clients as well.
+type Server struct {
+	store    *persistence.Store
+	loader   *dataset.Loader
+	executor *tools.Executor
+	mcp      *server.MCPServer
+}
+
+func NewServer(store *persistence.Store, loader *dataset.Loader, executor *tools.Executor, version string) *Server {
+	s := &Server{
+		store:    store,
+		loader:   loader,
+		executor: executor,
+	}
+
+	// The datasource list changes as files are uploaded, so refresh the concrete
+	// resources every time a client lists them
+	hooks := &server.Hooks{}
+	hooks.AddBeforeListResources(func(ctx context.Context, id any, message *mcp.ListResourcesRequest) {
+		if err := s.refreshResources(); err != nil {
+			logging.FromContext(ctx).Error("MCP: failed to refresh datasource resources", "error", err)
+		}
+	})
+
+	s.mcp = server.NewMCPServer(serverName, version,
+		server.WithInstructions(instructions),
+		server.WithResourceCapabilities(false, false),
+		server.WithToolCapabilities(false),
+		server.WithToolFilter(s.filterEnabledTools),
+		server.WithHooks(hooks),
+		server.WithRecovery(),
+	)
+
+	s.mcp.AddResourceTemplate(
+		mcp.NewResourceTemplate(dataSourceURIPrefix+"{id}", "Datasource",
+			mcp.WithTemplateDescription("Metadata and (timestamp, value) points of a datasource"),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		s.readDataSource,
+	)
+
+	for _, def := range executor.Registry().Definitions() {
+		s.mcp.AddTool(s.newTool(def), s.callTool(def.FxName))
+	}
+
+	if err := s.refreshResources(); err != nil {
+		slog.Error("MCP: failed to load datasource resources", "error", err)
+	}
+
+	return s
+}
+
+// ServeStdio serves MCP over stdin/stdout until the input is closed or the process is signalled
+func (s *Server) ServeStdio() error {
+	return server.ServeStdio(s.mcp)
+}
+
+// HTTPHandler returns the streamable HTTP transport, to be mounted at HTTPPath
+func (s *Server) HTTPHandler() http.Handler {
+	return server.NewStreamableHTTPServer(s.mcp, server.WithEndpointPath(HTTPPath))
+}
+
+func (s *Server) newTool(def *tools.Definition) mcp.Tool {
+	tool := mcp.NewToolWithRawSchema(def.FxName, def.Description, def.InputSchema)
+	tool.Annotations.Title = def.Name
+	return tool
+}
+
+func (s *Server) callTool(fxName string) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, err := json.Marshal(request.GetRawArguments())
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+		}
+
+		result, err := s.executor.Execute(ctx, fxName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to encode result: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(encoded)), nil
+	}
+}
+
+// filterEnabledTools hides tools that are disabled in the tools table
+func (s *Server) filterEnabledTools(ctx context.Context, all []mcp.Tool) []mcp.Tool {
+	defs, err := s.executor.EnabledDefinitions()
+	if err != nil {
+		logging.FromContext(ctx).Error("MCP: failed to load enabled tools", "error", err)
+		return nil
+	}
+
+	enabled := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		enabled[def.FxName] = true
+	}
+
+	filtered := make([]mcp.Tool, 0, len(all))
+	for _, tool := range all {
+		if enabled[tool.Name] {
+			filtered = append(filtered, tool)
+		}
+	}
+	return filtered
+}
+
+func (s *Server) refreshResources() error {
+	schemas, err := s.store.LoadAllDataSources()
+	if err != nil {
+		return err
+	}
+
+	resources := make([]server.ServerResource, 0, len(schemas))
+	for _, schema := range schemas {
+		ds := &models.DataSource{}
+		ds.FromSchema(schema)
+
+		resources = append(resources, server.ServerResource{
+			Resource: mcp.NewResource(
+				dataSourceURI(ds.DataSourceId), ds.Name,
+				mcp.WithResourceDescription(describeDataSource(ds)),
+				mcp.WithMIMEType("application/json"),
+			),
+			Handler: s.readDataSource,
+		})
+	}
+	s.mcp.SetResources(resources...)
+	return nil
+}
+
+type dataSourceContents struct {
+	DataSourceId int64            `json:"data_source_id"`
+	Name         string           `json:"name"`
+	Type         string           `json:"type"`
+	RowCount     int              `json:"row_count"`
+	StartTime    *time.Time       `json:"start_time,omitempty"`
+	EndTime      *time.Time       `json:"end_time,omitempty"`
+	TimeLabel    string           `json:"time_label"`
+	ValueLabel   string           `json:"value_label"`
+	Data         []dataPointEntry `json:"data"`
+}
+
+type dataPointEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+func (s *Server) readDataSource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := parseDataSourceURI(request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, points, err := s.loader.Points(ctx, id, nil, nil)
+	if err != nil {
+		if errors.Is(err, dataset.ErrNotFound) {
+			return nil, fmt.Errorf("resource %s not found", request.Params.URI)
+		}
+		return nil, err
+	}
+
+	contents := dataSourceContents{
+		DataSourceId: ds.DataSourceId,
+		Name:         ds.Name,
+		Type:         models.DataSourceTypes[ds.DataSourceType],
+		RowCount:     ds.RowCount,
+		StartTime:    ds.StartTime,
+		EndTime:      ds.EndTime,
+		TimeLabel:    ds.TimeLabel,
+		ValueLabel:   ds.ValueLabel,
+		Data:         make([]dataPointEntry, 0, len(points)),
+	}
+	for _, p := range points {
+		contents.Data = append(contents.Data, dataPointEntry{Timestamp: p.Timestamp, Value: p.Value})
+	}
+
+	encoded, err := json.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(encoded),
+		},
+	}, nil
+}
+
+func dataSourceURI(id int64) string {
+	return fmt.Sprintf("%s%d", dataSourceURIPrefix, id)
+}
+
+func parseDataSourceURI(uri string) (int64, error) {
+	if !strings.HasPrefix(uri, dataSourceURIPrefix) {
+		return 0, fmt.Errorf("unsupported resource URI %q", uri)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(uri, dataSourceURIPrefix), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid datasource URI %q", uri)
+	}
+	return id, nil
+}
+
+func describeDataSource(ds *models.DataSource) string {
+	desc := fmt.Sprintf("%d rows of %s over %s", ds.RowCount, ds.ValueLabel, ds.TimeLabel)
+	if ds.Unit != "" {
+		desc += fmt.Sprintf(" in %s", ds.Unit)
+	}
+	if ds.StartTime != nil && ds.EndTime != nil {
+		desc += fmt.Sprintf(" from %s to %s", ds.StartTime.Format(time.RFC3339), ds.EndTime.Format(time.RFC3339))
+	}
+	if ds.Description != "" {
+		desc = strings.TrimSuffix(ds.Description, ".") + ". " + desc
+	}
+	return desc
+}
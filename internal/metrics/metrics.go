@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "sandbox"
+
+// Registry holds every sandbox metric together with the Go runtime and process
+// collectors. It is separate from the Prometheus default registry so that libraries
+// cannot add metrics to /metrics behind our back.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	UploadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of uploaded files written to the file store.",
+	})
+
+	RowsIngested = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_ingested_total",
+		Help:      "Rows in files successfully ingested as datasources.",
+	})
+
+	LoaderParseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "loader_parse_duration_seconds",
+		Help:      "Time spent parsing and validating datasource files, by operation (ingest or load).",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"operation"})
+
+	ToolInvocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_invocations_total",
+		Help:      "Tool invocations by tool.",
+	}, []string{"tool"})
+
+	ToolErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_errors_total",
+		Help:      "Tool invocations that returned an error, by tool.",
+	}, []string{"tool"})
+
+	ToolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_duration_seconds",
+		Help:      "Tool invocation latency by tool.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"tool"})
+
+	LLMRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of LLM completion requests by provider.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"provider"})
+
+	LLMErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_errors_total",
+		Help:      "Failed LLM completion requests by provider.",
+	}, []string{"provider"})
+
+	LLMTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens used by LLM completion requests, by provider and type (prompt or completion).",
+	}, []string{"provider", "type"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		UploadBytes,
+		RowsIngested,
+		LoaderParseDuration,
+		ToolInvocations,
+		ToolErrors,
+		ToolDuration,
+		LLMRequestDuration,
+		LLMErrors,
+		LLMTokens,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveTool records one tool invocation
+func ObserveTool(tool string, elapsed time.Duration, err error) {
+	ToolInvocations.WithLabelValues(tool).Inc()
+	ToolDuration.WithLabelValues(tool).Observe(elapsed.Seconds())
+	if err != nil {
+		ToolErrors.WithLabelValues(tool).Inc()
+	}
+}
+
+// ObserveLLM records one LLM completion request
+func ObserveLLM(provider string, elapsed time.Duration, err error) {
+	LLMRequestDuration.WithLabelValues(provider).Observe(elapsed.Seconds())
+	if err != nil {
+		LLMErrors.WithLabelValues(provider).Inc()
+	}
+}
+
+// AddLLMTokens records the tokens used by a completion request
+func AddLLMTokens(provider string, promptTokens, completionTokens int) {
+	LLMTokens.WithLabelValues(provider, "prompt").Add(float64(promptTokens))
+	LLMTokens.WithLabelValues(provider, "completion").Add(float64(completionTokens))
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobQueueDepthDesc = prometheus.NewDesc(namespace+"_job_queue_depth",
+		"Jobs waiting for a worker.", nil, nil)
+	jobsRunningDesc = prometheus.NewDesc(namespace+"_jobs_running",
+		"Jobs currently running.", nil, nil)
+	datasourceBytesDesc = prometheus.NewDesc(namespace+"_datasource_storage_bytes",
+		"Size of each datasource's file in the file store.", []string{"datasource_id"}, nil)
+)
+
+// StateCollector reports metrics read from the database and file store at scrape
+// time: the job queue and the storage used by each datasource. Counting at scrape
+// time keeps the values right across restarts and when jobs are changed directly in
+// the database.
+type StateCollector struct {
+	store     *persistence.Store
+	fileStore *storage.FileStore
+}
+
+func NewStateCollector(store *persistence.Store, fileStore *storage.FileStore) *StateCollector {
+	return &StateCollector{
+		store:     store,
+		fileStore: fileStore,
+	}
+}
+
+func (c *StateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobQueueDepthDesc
+	ch <- jobsRunningDesc
+	ch <- datasourceBytesDesc
+}
+
+func (c *StateCollector) Collect(ch chan<- prometheus.Metric) {
+	if queued, err := c.store.CountJobs(models.JobStatusQueued); err != nil {
+		slog.Error("Failed to count queued jobs for metrics", "error", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(jobQueueDepthDesc, prometheus.GaugeValue, float64(queued))
+	}
+	if running, err := c.store.CountJobs(models.JobStatusRunning); err != nil {
+		slog.Error("Failed to count running jobs for metrics", "error", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(jobsRunningDesc, prometheus.GaugeValue, float64(running))
+	}
+
+	sources, err := c.store.LoadAllDataSources()
+	if err != nil {
+		slog.Error("Failed to load datasources for metrics", "error", err)
+		return
+	}
+	for _, ds := range sources {
+		// Virtual datasources have no file
+		if ds.DataSourcePath == "" {
+			continue
+		}
+		info, err := os.Stat(c.fileStore.GetFilePath(ds.DataSourcePath))
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(datasourceBytesDesc, prometheus.GaugeValue,
+			float64(info.Size()), strconv.FormatInt(ds.DataSourceId, 10))
+	}
+}
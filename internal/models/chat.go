@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+type ChatSession struct {
+	SessionId   int64
+	Title       string
+	CreatedBy   *int64
+	WhenCreated time.Time
+	WhenUpdated time.Time
+}
+
+type ChatMessage struct {
+	MessageId        int64
+	SessionId        int64
+	Seq              int
+	Role             string
+	Content          string
+	ToolCalls        *string
+	ToolCallId       *string
+	Name             *string
+	PromptTokens     int
+	CompletionTokens int
+	WhenCreated      time.Time
+}
+
+type ToolInvocation struct {
+	InvocationId  int64
+	SessionId     int64
+	MessageId     *int64
+	ToolCallId    string
+	ToolName      string
+	Arguments     string
+	ResultSummary *string
+	DurationMs    int64
+	Error         *string
+	WhenCreated   time.Time
+}
+
+func (s *ChatSession) ToSchema() *schemas.ChatSessionSchema {
+	return &schemas.ChatSessionSchema{
+		SessionId:   s.SessionId,
+		Title:       s.Title,
+		CreatedBy:   s.CreatedBy,
+		WhenCreated: s.WhenCreated,
+		WhenUpdated: s.WhenUpdated,
+	}
+}
+
+func (s *ChatSession) FromSchema(schema *schemas.ChatSessionSchema) {
+	s.SessionId = schema.SessionId
+	s.Title = schema.Title
+	s.CreatedBy = schema.CreatedBy
+	s.WhenCreated = schema.WhenCreated
+	s.WhenUpdated = schema.WhenUpdated
+}
+
+func (m *ChatMessage) ToSchema() *schemas.ChatMessageSchema {
+	return &schemas.ChatMessageSchema{
+		MessageId:        m.MessageId,
+		SessionId:        m.SessionId,
+		Seq:              m.Seq,
+		Role:             m.Role,
+		Content:          m.Content,
+		ToolCalls:        m.ToolCalls,
+		ToolCallId:       m.ToolCallId,
+		Name:             m.Name,
+		PromptTokens:     m.PromptTokens,
+		CompletionTokens: m.CompletionTokens,
+		WhenCreated:      m.WhenCreated,
+	}
+}
+
+func (m *ChatMessage) FromSchema(schema *schemas.ChatMessageSchema) {
+	m.MessageId = schema.MessageId
+	m.SessionId = schema.SessionId
+	m.Seq = schema.Seq
+	m.Role = schema.Role
+	m.Content = schema.Content
+	m.ToolCalls = schema.ToolCalls
+	m.ToolCallId = schema.ToolCallId
+	m.Name = schema.Name
+	m.PromptTokens = schema.PromptTokens
+	m.CompletionTokens = schema.CompletionTokens
+	m.WhenCreated = schema.WhenCreated
+}
+
+func (ti *ToolInvocation) ToSchema() *schemas.ToolInvocationSchema {
+	return &schemas.ToolInvocationSchema{
+		InvocationId:  ti.InvocationId,
+		SessionId:     ti.SessionId,
+		MessageId:     ti.MessageId,
+		ToolCallId:    ti.ToolCallId,
+		ToolName:      ti.ToolName,
+		Arguments:     ti.Arguments,
+		ResultSummary: ti.ResultSummary,
+		DurationMs:    ti.DurationMs,
+		Error:         ti.Error,
+		WhenCreated:   ti.WhenCreated,
+	}
+}
+
+func (ti *ToolInvocation) FromSchema(schema *schemas.ToolInvocationSchema) {
+	ti.InvocationId = schema.InvocationId
+	ti.SessionId = schema.SessionId
+	ti.MessageId = schema.MessageId
+	ti.ToolCallId = schema.ToolCallId
+	ti.ToolName = schema.ToolName
+	ti.Arguments = schema.Arguments
+	ti.ResultSummary = schema.ResultSummary
+	ti.DurationMs = schema.DurationMs
+	ti.Error = schema.Error
+	ti.WhenCreated = schema.WhenCreated
+}
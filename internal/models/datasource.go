@@ -12,12 +12,28 @@ type DataSource struct {
 	Name             string
 	DataSourceType   int
 	DataSourcePath   string
+	Expression       string
 	RowCount         int
 	StartTime        *time.Time
 	EndTime          *time.Time
 	TimeLabel        string
 	ValueLabel       string
+	CreatedBy        *int64
 	WhenCreated      time.Time
+	Description      string
+	Unit             string
+	SensorType       string
+	Location         string
+	Tags             map[string]string
+	// Seasonality is detected when the datasource is created, strongest first
+	Seasonality      []SeasonalPeriod
+}
+
+// SeasonalPeriod is a period a datasource's values repeat with. Strength is the
+// autocorrelation of the values one period apart.
+type SeasonalPeriod struct {
+	Period   time.Duration
+	Strength float64
 }
 
 func (ds *DataSource) ToSchema() *schemas.DataSourceSchema {
@@ -26,12 +42,22 @@ func (ds *DataSource) ToSchema() *schemas.DataSourceSchema {
 		Name:           ds.Name,
 		DataSourceType: ds.DataSourceType,
 		DataSourcePath: ds.DataSourcePath,
+		Expression:     ds.Expression,
 		RowCount:       ds.RowCount,
 		StartTime:      ds.StartTime,
 		EndTime:        ds.EndTime,
 		TimeLabel:      ds.TimeLabel,
 		ValueLabel:     ds.ValueLabel,
+		CreatedBy:      ds.CreatedBy,
 		WhenCreated:    ds.WhenCreated,
+		Description:    ds.Description,
+		Unit:           ds.Unit,
+		SensorType:     ds.SensorType,
+		Location:       ds.Location,
+		Tags:           ds.Tags,
+	}
+	for _, p := range ds.Seasonality {
+		s.Seasonality = append(s.Seasonality, schemas.SeasonalPeriodSchema{PeriodSeconds: p.Period.Seconds(), Strength: p.Strength})
 	}
 
 	if ds.Project != nil {
@@ -46,16 +72,46 @@ func (ds *DataSource) FromSchema(schema *schemas.DataSourceSchema) {
 	ds.Name = schema.Name
 	ds.DataSourceType = schema.DataSourceType
 	ds.DataSourcePath = schema.DataSourcePath
+	ds.Expression = schema.Expression
 	ds.RowCount = schema.RowCount
 	ds.StartTime = schema.StartTime
 	ds.EndTime = schema.EndTime
 	ds.TimeLabel = schema.TimeLabel
 	ds.ValueLabel = schema.ValueLabel
+	ds.CreatedBy = schema.CreatedBy
 	ds.WhenCreated = schema.WhenCreated
-	// Note: Project object is not populated here, must be set separately if needed
+	ds.Description = schema.Description
+	ds.Unit = schema.Unit
+	ds.SensorType = schema.SensorType
+	ds.Location = schema.Location
+	ds.Tags = schema.Tags
+	ds.Seasonality = nil
+	for _, p := range schema.Seasonality {
+		ds.Seasonality = append(ds.Seasonality, SeasonalPeriod{
+			Period:   time.Duration(p.PeriodSeconds * float64(time.Second)),
+			Strength: p.Strength,
+		})
+	}
+	// Note: only the project ID is populated here, the rest must be loaded separately if needed
 	ds.Project = nil
+	if schema.ProjectId != 0 {
+		ds.Project = &Project{ProjectId: schema.ProjectId}
+	}
 }
 
+// Datasource types
+const (
+	DataSourceTypeCSV = iota
+	DataSourceTypeVirtual
+)
+
 var DataSourceTypes = map[int]string{
-	0: "csv",
+	DataSourceTypeCSV:     "csv",
+	DataSourceTypeVirtual: "virtual",
+}
+
+// IsVirtual reports whether the datasource is computed from an expression rather
+// than read from a file
+func (ds *DataSource) IsVirtual() bool {
+	return ds.DataSourceType == DataSourceTypeVirtual
 }
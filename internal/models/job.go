@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+// Job statuses. Queued and running jobs are active; the others are final.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+type Job struct {
+	JobId        int64
+	Kind         string
+	Status       string
+	Params       string
+	Progress     float64
+	Message      string
+	Result       *string
+	Error        *string
+	Attempts     int
+	CreatedBy    *int64
+	WhenCreated  time.Time
+	WhenStarted  *time.Time
+	WhenFinished *time.Time
+}
+
+// IsFinished reports whether the job has reached a final status
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+func (j *Job) ToSchema() *schemas.JobSchema {
+	return &schemas.JobSchema{
+		JobId:        j.JobId,
+		Kind:         j.Kind,
+		Status:       j.Status,
+		Params:       j.Params,
+		Progress:     j.Progress,
+		Message:      j.Message,
+		Result:       j.Result,
+		Error:        j.Error,
+		Attempts:     j.Attempts,
+		CreatedBy:    j.CreatedBy,
+		WhenCreated:  j.WhenCreated,
+		WhenStarted:  j.WhenStarted,
+		WhenFinished: j.WhenFinished,
+	}
+}
+
+func (j *Job) FromSchema(schema *schemas.JobSchema) {
+	j.JobId = schema.JobId
+	j.Kind = schema.Kind
+	j.Status = schema.Status
+	j.Params = schema.Params
+	j.Progress = schema.Progress
+	j.Message = schema.Message
+	j.Result = schema.Result
+	j.Error = schema.Error
+	j.Attempts = schema.Attempts
+	j.CreatedBy = schema.CreatedBy
+	j.WhenCreated = schema.WhenCreated
+	j.WhenStarted = schema.WhenStarted
+	j.WhenFinished = schema.WhenFinished
+}
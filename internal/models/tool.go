@@ -10,20 +10,27 @@ type Tool struct {
 	ToolId       int64
 	Name         string
 	FxName       string
+	Description  string
+	Category     string
+	Version      string
+	InputSchema  string
+	OutputSchema string
 	TimeoutS     int
 	IsEnabled    bool
 	WhenLastCall *time.Time
 	NumCalls     int
 	MaxCalls     *int
 	NumCallReset *int
-	AuthProps    *ToolAuthProps
 }
 
-type ToolAuthProps struct {
-	ToolId         int64
-	HashedApiKey   *string
-	HashedUsername *string
-	HashedPassword *string
+type ToolSecret struct {
+	ToolId      int64
+	Name        string
+	Ciphertext  []byte
+	WrappedKey  []byte
+	KeyId       string
+	WhenCreated time.Time
+	WhenUpdated time.Time
 }
 
 func (t *Tool) ToSchema() *schemas.ToolSchema {
@@ -31,6 +38,11 @@ func (t *Tool) ToSchema() *schemas.ToolSchema {
 		ToolId:       t.ToolId,
 		Name:         t.Name,
 		FxName:       t.FxName,
+		Description:  t.Description,
+		Category:     t.Category,
+		Version:      t.Version,
+		InputSchema:  t.InputSchema,
+		OutputSchema: t.OutputSchema,
 		TimeoutS:     t.TimeoutS,
 		IsEnabled:    t.IsEnabled,
 		WhenLastCall: t.WhenLastCall,
@@ -39,15 +51,6 @@ func (t *Tool) ToSchema() *schemas.ToolSchema {
 		NumCallReset: t.NumCallReset,
 	}
 
-	if t.AuthProps != nil {
-		ts.AuthProps = &schemas.ToolAuthPropsSchema{
-			ToolId:         t.AuthProps.ToolId,
-			HashedApiKey:   t.AuthProps.HashedApiKey,
-			HashedUsername: t.AuthProps.HashedUsername,
-			HashedPassword: t.AuthProps.HashedPassword,
-		}
-	}
-
 	return ts
 }
 
@@ -55,21 +58,37 @@ func (t *Tool) FromSchema(schema *schemas.ToolSchema) {
 	t.ToolId = schema.ToolId
 	t.Name = schema.Name
 	t.FxName = schema.FxName
+	t.Description = schema.Description
+	t.Category = schema.Category
+	t.Version = schema.Version
+	t.InputSchema = schema.InputSchema
+	t.OutputSchema = schema.OutputSchema
 	t.TimeoutS = schema.TimeoutS
 	t.IsEnabled = schema.IsEnabled
 	t.WhenLastCall = schema.WhenLastCall
 	t.NumCalls = schema.NumCalls
 	t.MaxCalls = schema.MaxCalls
 	t.NumCallReset = schema.NumCallReset
+}
 
-	if schema.AuthProps != nil {
-		t.AuthProps = &ToolAuthProps{
-			ToolId:         schema.AuthProps.ToolId,
-			HashedApiKey:   schema.AuthProps.HashedApiKey,
-			HashedUsername: schema.AuthProps.HashedUsername,
-			HashedPassword: schema.AuthProps.HashedPassword,
-		}
-	} else {
-		t.AuthProps = nil
+func (s *ToolSecret) ToSchema() *schemas.ToolSecretSchema {
+	return &schemas.ToolSecretSchema{
+		ToolId:      s.ToolId,
+		Name:        s.Name,
+		Ciphertext:  s.Ciphertext,
+		WrappedKey:  s.WrappedKey,
+		KeyId:       s.KeyId,
+		WhenCreated: s.WhenCreated,
+		WhenUpdated: s.WhenUpdated,
 	}
 }
+
+func (s *ToolSecret) FromSchema(schema *schemas.ToolSecretSchema) {
+	s.ToolId = schema.ToolId
+	s.Name = schema.Name
+	s.Ciphertext = schema.Ciphertext
+	s.WrappedKey = schema.WrappedKey
+	s.KeyId = schema.KeyId
+	s.WhenCreated = schema.WhenCreated
+	s.WhenUpdated = schema.WhenUpdated
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+type User struct {
+	UserId      int64
+	Username    string
+	IsActive    bool
+	CreatedBy   *int64
+	WhenCreated time.Time
+}
+
+type ApiKey struct {
+	ApiKeyId     int64
+	UserId       int64
+	Name         string
+	Prefix       string
+	HashedKey    string
+	Scopes       []string
+	CreatedBy    *int64
+	WhenCreated  time.Time
+	WhenExpires  *time.Time
+	WhenLastUsed *time.Time
+	WhenRevoked  *time.Time
+}
+
+// IsValid reports whether the key can be used at time now
+func (k *ApiKey) IsValid(now time.Time) bool {
+	if k.WhenRevoked != nil {
+		return false
+	}
+	return k.WhenExpires == nil || now.Before(*k.WhenExpires)
+}
+
+func (u *User) ToSchema() *schemas.UserSchema {
+	return &schemas.UserSchema{
+		UserId:      u.UserId,
+		Username:    u.Username,
+		IsActive:    u.IsActive,
+		CreatedBy:   u.CreatedBy,
+		WhenCreated: u.WhenCreated,
+	}
+}
+
+func (u *User) FromSchema(schema *schemas.UserSchema) {
+	u.UserId = schema.UserId
+	u.Username = schema.Username
+	u.IsActive = schema.IsActive
+	u.CreatedBy = schema.CreatedBy
+	u.WhenCreated = schema.WhenCreated
+}
+
+func (k *ApiKey) ToSchema() *schemas.ApiKeySchema {
+	return &schemas.ApiKeySchema{
+		ApiKeyId:     k.ApiKeyId,
+		UserId:       k.UserId,
+		Name:         k.Name,
+		Prefix:       k.Prefix,
+		HashedKey:    k.HashedKey,
+		Scopes:       strings.Join(k.Scopes, ","),
+		CreatedBy:    k.CreatedBy,
+		WhenCreated:  k.WhenCreated,
+		WhenExpires:  k.WhenExpires,
+		WhenLastUsed: k.WhenLastUsed,
+		WhenRevoked:  k.WhenRevoked,
+	}
+}
+
+func (k *ApiKey) FromSchema(schema *schemas.ApiKeySchema) {
+	k.ApiKeyId = schema.ApiKeyId
+	k.UserId = schema.UserId
+	k.Name = schema.Name
+	k.Prefix = schema.Prefix
+	k.HashedKey = schema.HashedKey
+	k.Scopes = nil
+	if schema.Scopes != "" {
+		k.Scopes = strings.Split(schema.Scopes, ",")
+	}
+	k.CreatedBy = schema.CreatedBy
+	k.WhenCreated = schema.WhenCreated
+	k.WhenExpires = schema.WhenExpires
+	k.WhenLastUsed = schema.WhenLastUsed
+	k.WhenRevoked = schema.WhenRevoked
+}
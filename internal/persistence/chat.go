@@ -0,0 +1,176 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+// SaveChatSession inserts or updates a ChatSession
+func (s *Store) SaveChatSession(session *schemas.ChatSessionSchema) error {
+	if session.SessionId == 0 {
+		result, err := s.db.Exec(`
+            INSERT INTO chat_sessions (title, created_by, when_created, when_updated)
+            VALUES (?, ?, ?, ?)`,
+			session.Title, session.CreatedBy, session.WhenCreated, session.WhenUpdated,
+		)
+		if err != nil {
+			return err
+		}
+		session.SessionId, _ = result.LastInsertId()
+	} else {
+		_, err := s.db.Exec(`
+            UPDATE chat_sessions SET title=?, created_by=?, when_created=?, when_updated=?
+            WHERE session_id=?`,
+			session.Title, session.CreatedBy, session.WhenCreated, session.WhenUpdated, session.SessionId,
+		)
+		return err
+	}
+	return nil
+}
+
+// LoadChatSession retrieves a ChatSession by ID
+func (s *Store) LoadChatSession(id int64) (*schemas.ChatSessionSchema, error) {
+	session := &schemas.ChatSessionSchema{}
+	err := s.db.QueryRow(`
+        SELECT session_id, title, created_by, when_created, when_updated
+        FROM chat_sessions WHERE session_id=?`, id,
+	).Scan(&session.SessionId, &session.Title, &session.CreatedBy, &session.WhenCreated, &session.WhenUpdated)
+
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// LoadAllChatSessions retrieves all ChatSessions, most recently updated first
+func (s *Store) LoadAllChatSessions() ([]*schemas.ChatSessionSchema, error) {
+	rows, err := s.db.Query(`
+        SELECT session_id, title, created_by, when_created, when_updated
+        FROM chat_sessions ORDER BY when_updated DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*schemas.ChatSessionSchema
+	for rows.Next() {
+		session := &schemas.ChatSessionSchema{}
+		if err := rows.Scan(&session.SessionId, &session.Title, &session.CreatedBy, &session.WhenCreated, &session.WhenUpdated); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// AppendChatTurn stores the messages and tool invocations of one prompt in a single
+// transaction. Messages are numbered after the session's existing messages, and each
+// invocation is linked to the tool message carrying the same tool call ID.
+func (s *Store) AppendChatTurn(sessionId int64, messages []*schemas.ChatMessageSchema, invocations []*schemas.ToolInvocationSchema) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var seq int
+	if err := tx.QueryRow(`
+        SELECT COALESCE(MAX(seq), 0) FROM chat_messages WHERE session_id=?`, sessionId,
+	).Scan(&seq); err != nil {
+		return err
+	}
+
+	toolMessageIds := make(map[string]int64)
+	for _, msg := range messages {
+		seq++
+		msg.SessionId = sessionId
+		msg.Seq = seq
+		result, err := tx.Exec(`
+            INSERT INTO chat_messages (session_id, seq, role, content, tool_calls, tool_call_id, name,
+                                       prompt_tokens, completion_tokens, when_created)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			msg.SessionId, msg.Seq, msg.Role, msg.Content, msg.ToolCalls, msg.ToolCallId, msg.Name,
+			msg.PromptTokens, msg.CompletionTokens, msg.WhenCreated,
+		)
+		if err != nil {
+			return err
+		}
+		msg.MessageId, _ = result.LastInsertId()
+		if msg.ToolCallId != nil {
+			toolMessageIds[*msg.ToolCallId] = msg.MessageId
+		}
+	}
+
+	for _, inv := range invocations {
+		inv.SessionId = sessionId
+		if id, ok := toolMessageIds[inv.ToolCallId]; ok {
+			inv.MessageId = &id
+		}
+		result, err := tx.Exec(`
+            INSERT INTO tool_invocations (session_id, message_id, tool_call_id, tool_name, arguments,
+                                          result_summary, duration_ms, error, when_created)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			inv.SessionId, inv.MessageId, inv.ToolCallId, inv.ToolName, inv.Arguments,
+			inv.ResultSummary, inv.DurationMs, inv.Error, inv.WhenCreated,
+		)
+		if err != nil {
+			return err
+		}
+		inv.InvocationId, _ = result.LastInsertId()
+	}
+
+	if _, err := tx.Exec(`UPDATE chat_sessions SET when_updated=? WHERE session_id=?`, time.Now(), sessionId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// LoadChatMessages retrieves the messages of a session in conversation order
+func (s *Store) LoadChatMessages(sessionId int64) ([]*schemas.ChatMessageSchema, error) {
+	rows, err := s.db.Query(`
+        SELECT message_id, session_id, seq, role, content, tool_calls, tool_call_id, name,
+               prompt_tokens, completion_tokens, when_created
+        FROM chat_messages WHERE session_id=? ORDER BY seq`, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*schemas.ChatMessageSchema
+	for rows.Next() {
+		msg := &schemas.ChatMessageSchema{}
+		if err := rows.Scan(&msg.MessageId, &msg.SessionId, &msg.Seq, &msg.Role, &msg.Content,
+			&msg.ToolCalls, &msg.ToolCallId, &msg.Name, &msg.PromptTokens, &msg.CompletionTokens,
+			&msg.WhenCreated); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// LoadToolInvocations retrieves the tool invocations of a session in call order
+func (s *Store) LoadToolInvocations(sessionId int64) ([]*schemas.ToolInvocationSchema, error) {
+	rows, err := s.db.Query(`
+        SELECT invocation_id, session_id, message_id, tool_call_id, tool_name, arguments,
+               result_summary, duration_ms, error, when_created
+        FROM tool_invocations WHERE session_id=? ORDER BY invocation_id`, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invocations []*schemas.ToolInvocationSchema
+	for rows.Next() {
+		inv := &schemas.ToolInvocationSchema{}
+		if err := rows.Scan(&inv.InvocationId, &inv.SessionId, &inv.MessageId, &inv.ToolCallId,
+			&inv.ToolName, &inv.Arguments, &inv.ResultSummary, &inv.DurationMs, &inv.Error,
+			&inv.WhenCreated); err != nil {
+			return nil, err
+		}
+		invocations = append(invocations, inv)
+	}
+	return invocations, rows.Err()
+}
@@ -1,70 +1,311 @@
 package persistence
 
-import "github.com/nathanaday/iot-data-sandbox/internal/schemas"
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 
-// SaveDataSource inserts or updates a DataSource
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+const dataSourceColumns = `d.data_source_id, d.project_id, d.name, d.data_source_type, d.data_source_path, d.expression, d.row_count, d.start_time, d.end_time,
+            d.time_label, d.value_label, d.created_by, d.when_created,
+            COALESCE(m.description, ''), COALESCE(m.unit, ''), COALESCE(m.sensor_type, ''), COALESCE(m.location, '')`
+
+const dataSourceTables = `data_sources d LEFT JOIN data_source_metadata m ON m.data_source_id = d.data_source_id`
+
+// DataSourceSortColumns are the columns ListDataSources can sort by
+var DataSourceSortColumns = map[string]string{
+	"name":         "d.name COLLATE NOCASE",
+	"when_created": "d.when_created",
+	"start_time":   "d.start_time",
+	"end_time":     "d.end_time",
+	"row_count":    "d.row_count",
+}
+
+// DataSourceFilter selects, orders and pages the DataSources returned by
+// ListDataSources. Zero values select everything.
+type DataSourceFilter struct {
+	// Tags a datasource must all have. An empty value matches any value of the key.
+	Tags map[string]string
+	// Search is a case-insensitive substring of the name
+	Search string
+	// From and To select datasources whose time coverage overlaps them
+	From *time.Time
+	To   *time.Time
+	// SortBy is a key of DataSourceSortColumns, when_created by default
+	SortBy     string
+	Descending bool
+	// Limit of 0 returns every match
+	Limit  int
+	Offset int
+}
+
+// SaveDataSource inserts or updates a DataSource, with its metadata, tags and seasonality
 func (s *Store) SaveDataSource(ds *schemas.DataSourceSchema) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	if ds.DataSourceId == 0 {
-		result, err := s.db.Exec(`
-            INSERT INTO data_sources (name, data_source_type, data_source_path, row_count, start_time, end_time, time_label, value_label, when_created)
-            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			ds.Name, ds.DataSourceType, ds.DataSourcePath, ds.RowCount, ds.StartTime, ds.EndTime, ds.TimeLabel, ds.ValueLabel, ds.WhenCreated,
+		result, err := tx.Exec(`
+            INSERT INTO data_sources (project_id, name, data_source_type, data_source_path, expression, row_count, start_time, end_time, time_label, value_label, created_by, when_created)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			nullId(ds.ProjectId), ds.Name, ds.DataSourceType, ds.DataSourcePath, ds.Expression, ds.RowCount, ds.StartTime, ds.EndTime, ds.TimeLabel, ds.ValueLabel, ds.CreatedBy, ds.WhenCreated,
 		)
 		if err != nil {
 			return err
 		}
 		ds.DataSourceId, _ = result.LastInsertId()
 	} else {
-		_, err := s.db.Exec(`
+		_, err := tx.Exec(`
             UPDATE data_sources
-            SET name=?, data_source_type=?, data_source_path=?, row_count=?, start_time=?, end_time=?, time_label=?, value_label=?, when_created=?
+            SET project_id=?, name=?, data_source_type=?, data_source_path=?, expression=?, row_count=?, start_time=?, end_time=?, time_label=?, value_label=?, created_by=?, when_created=?
             WHERE data_source_id=?`,
-			ds.Name, ds.DataSourceType, ds.DataSourcePath, ds.RowCount, ds.StartTime, ds.EndTime, ds.TimeLabel, ds.ValueLabel, ds.WhenCreated, ds.DataSourceId,
+			nullId(ds.ProjectId), ds.Name, ds.DataSourceType, ds.DataSourcePath, ds.Expression, ds.RowCount, ds.StartTime, ds.EndTime, ds.TimeLabel, ds.ValueLabel, ds.CreatedBy, ds.WhenCreated, ds.DataSourceId,
 		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO data_source_metadata (data_source_id, description, unit, sensor_type, location, when_updated)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT(data_source_id) DO UPDATE SET
+            description=excluded.description, unit=excluded.unit, sensor_type=excluded.sensor_type,
+            location=excluded.location, when_updated=excluded.when_updated`,
+		ds.DataSourceId, ds.Description, ds.Unit, ds.SensorType, ds.Location, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Replace the tags
+	if _, err := tx.Exec(`DELETE FROM data_source_tags WHERE data_source_id=?`, ds.DataSourceId); err != nil {
+		return err
+	}
+	for key, value := range ds.Tags {
+		if _, err := tx.Exec(`INSERT INTO data_source_tags (data_source_id, key, value) VALUES (?, ?, ?)`,
+			ds.DataSourceId, key, value); err != nil {
+			return err
+		}
+	}
+
+	// Replace the seasonality
+	if _, err := tx.Exec(`DELETE FROM data_source_seasonality WHERE data_source_id=?`, ds.DataSourceId); err != nil {
 		return err
 	}
-	return nil
+	for _, p := range ds.Seasonality {
+		if _, err := tx.Exec(`INSERT INTO data_source_seasonality (data_source_id, period_seconds, strength) VALUES (?, ?, ?)`,
+			ds.DataSourceId, p.PeriodSeconds, p.Strength); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 // LoadDataSource retrieves a DataSource by ID
 func (s *Store) LoadDataSource(id int64) (*schemas.DataSourceSchema, error) {
-	ds := &schemas.DataSourceSchema{}
-	err := s.db.QueryRow(`
-        SELECT data_source_id, name, data_source_type, data_source_path, row_count, start_time, end_time, time_label, value_label, when_created
-        FROM data_sources WHERE data_source_id=?`, id,
-	).Scan(&ds.DataSourceId, &ds.Name, &ds.DataSourceType, &ds.DataSourcePath, &ds.RowCount, &ds.StartTime, &ds.EndTime, &ds.TimeLabel, &ds.ValueLabel, &ds.WhenCreated)
-
+	ds, err := scanDataSource(s.db.QueryRow(`SELECT `+dataSourceColumns+` FROM `+dataSourceTables+` WHERE d.data_source_id=?`, id))
 	if err != nil {
 		return nil, err
 	}
+	if err := s.loadDataSourceDetails([]*schemas.DataSourceSchema{ds}); err != nil {
+		return nil, err
+	}
 	return ds, nil
 }
 
 // LoadAllDataSources retrieves all DataSources ordered by creation date
 func (s *Store) LoadAllDataSources() ([]*schemas.DataSourceSchema, error) {
-	rows, err := s.db.Query(`
-        SELECT data_source_id, name, data_source_type, data_source_path, row_count, start_time, end_time, time_label, value_label, when_created
-        FROM data_sources ORDER BY when_created DESC`)
+	sources, _, err := s.ListDataSources(DataSourceFilter{Descending: true})
+	return sources, err
+}
+
+// ListDataSources retrieves the DataSources matching filter, and how many match in
+// total regardless of the limit and offset
+func (s *Store) ListDataSources(filter DataSourceFilter) ([]*schemas.DataSourceSchema, int, error) {
+	var where []string
+	var args []any
+
+	for key, value := range filter.Tags {
+		if value == "" {
+			where = append(where, `EXISTS (SELECT 1 FROM data_source_tags t WHERE t.data_source_id = d.data_source_id AND t.key = ?)`)
+			args = append(args, key)
+		} else {
+			where = append(where, `EXISTS (SELECT 1 FROM data_source_tags t WHERE t.data_source_id = d.data_source_id AND t.key = ? AND t.value = ?)`)
+			args = append(args, key, value)
+		}
+	}
+	if filter.Search != "" {
+		where = append(where, `d.name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(filter.Search)+"%")
+	}
+	// Times are compared as julian days since stored timestamps may carry any offset
+	if filter.From != nil {
+		where = append(where, `julianday(d.end_time) >= julianday(?)`)
+		args = append(args, filter.From.UTC())
+	}
+	if filter.To != nil {
+		where = append(where, `julianday(d.start_time) <= julianday(?)`)
+		args = append(args, filter.To.UTC())
+	}
+
+	from := ` FROM ` + dataSourceTables
+	if len(where) > 0 {
+		from += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = "when_created"
+	}
+	column, ok := DataSourceSortColumns[sortBy]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort column %q", sortBy)
+	}
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+	query := `SELECT ` + dataSourceColumns + from + ` ORDER BY ` + column + ` ` + direction + `, d.data_source_id ` + direction
+
+	// SQLite needs a LIMIT to accept an OFFSET; -1 means no limit
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query += ` LIMIT ? OFFSET ?`
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var sources []*schemas.DataSourceSchema
 	for rows.Next() {
-		ds := &schemas.DataSourceSchema{}
-		if err := rows.Scan(&ds.DataSourceId, &ds.Name, &ds.DataSourceType,
-			&ds.DataSourcePath, &ds.RowCount, &ds.StartTime, &ds.EndTime, &ds.TimeLabel, &ds.ValueLabel, &ds.WhenCreated); err != nil {
-			return nil, err
+		ds, err := scanDataSource(rows)
+		if err != nil {
+			return nil, 0, err
 		}
 		sources = append(sources, ds)
 	}
-	return sources, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.loadDataSourceDetails(sources); err != nil {
+		return nil, 0, err
+	}
+	return sources, total, nil
 }
 
-// DeleteDataSource removes a DataSource by ID
+// DeleteDataSource removes a DataSource by ID, with its metadata, tags and seasonality
 func (s *Store) DeleteDataSource(id int64) error {
-	_, err := s.db.Exec("DELETE FROM data_sources WHERE data_source_id=?", id)
-	return err
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM data_source_tags WHERE data_source_id=?",
+		"DELETE FROM data_source_seasonality WHERE data_source_id=?",
+		"DELETE FROM data_source_metadata WHERE data_source_id=?",
+		"DELETE FROM data_sources WHERE data_source_id=?",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
+// loadDataSourceDetails fills in the tags and seasonality of sources with one query
+// each
+func (s *Store) loadDataSourceDetails(sources []*schemas.DataSourceSchema) error {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	byId := make(map[int64]*schemas.DataSourceSchema, len(sources))
+	placeholders := make([]string, 0, len(sources))
+	args := make([]any, 0, len(sources))
+	for _, ds := range sources {
+		ds.Tags = map[string]string{}
+		byId[ds.DataSourceId] = ds
+		placeholders = append(placeholders, "?")
+		args = append(args, ds.DataSourceId)
+	}
+
+	rows, err := s.db.Query(`SELECT data_source_id, key, value FROM data_source_tags
+        WHERE data_source_id IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var key, value string
+		if err := rows.Scan(&id, &key, &value); err != nil {
+			return err
+		}
+		byId[id].Tags[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(`SELECT data_source_id, period_seconds, strength FROM data_source_seasonality
+        WHERE data_source_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY strength DESC`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var p schemas.SeasonalPeriodSchema
+		if err := rows.Scan(&id, &p.PeriodSeconds, &p.Strength); err != nil {
+			return err
+		}
+		byId[id].Seasonality = append(byId[id].Seasonality, p)
+	}
+	return rows.Err()
+}
+
+func scanDataSource(row rowScanner) (*schemas.DataSourceSchema, error) {
+	ds := &schemas.DataSourceSchema{}
+	var projectId sql.NullInt64
+	err := row.Scan(&ds.DataSourceId, &projectId, &ds.Name, &ds.DataSourceType, &ds.DataSourcePath, &ds.Expression, &ds.RowCount,
+		&ds.StartTime, &ds.EndTime, &ds.TimeLabel, &ds.ValueLabel, &ds.CreatedBy, &ds.WhenCreated,
+		&ds.Description, &ds.Unit, &ds.SensorType, &ds.Location)
+	if err != nil {
+		return nil, err
+	}
+	ds.ProjectId = projectId.Int64
+	return ds, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// nullId stores an unset (zero) foreign key as NULL
+func nullId(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
@@ -0,0 +1,155 @@
+package persistence
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+const jobColumns = `job_id, kind, status, params, progress, message, result, error, attempts, created_by, when_created, when_started, when_finished`
+
+// SaveJob inserts or updates a Job
+func (s *Store) SaveJob(job *schemas.JobSchema) error {
+	if job.JobId == 0 {
+		result, err := s.db.Exec(`
+            INSERT INTO jobs (kind, status, params, progress, message, result, error, attempts, created_by, when_created, when_started, when_finished)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			job.Kind, job.Status, job.Params, job.Progress, job.Message, job.Result, job.Error, job.Attempts, job.CreatedBy, job.WhenCreated, job.WhenStarted, job.WhenFinished,
+		)
+		if err != nil {
+			return err
+		}
+		job.JobId, _ = result.LastInsertId()
+	} else {
+		_, err := s.db.Exec(`
+            UPDATE jobs
+            SET kind=?, status=?, params=?, progress=?, message=?, result=?, error=?, attempts=?, created_by=?, when_created=?, when_started=?, when_finished=?
+            WHERE job_id=?`,
+			job.Kind, job.Status, job.Params, job.Progress, job.Message, job.Result, job.Error, job.Attempts, job.CreatedBy, job.WhenCreated, job.WhenStarted, job.WhenFinished, job.JobId,
+		)
+		return err
+	}
+	return nil
+}
+
+// LoadJob retrieves a Job by ID
+func (s *Store) LoadJob(id int64) (*schemas.JobSchema, error) {
+	return scanJob(s.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE job_id=?`, id))
+}
+
+// LoadAllJobs retrieves Jobs, newest first, optionally limited to one status
+func (s *Store) LoadAllJobs(status string) ([]*schemas.JobSchema, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	var args []any
+	if status != "" {
+		query += ` WHERE status=?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY job_id DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*schemas.JobSchema
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// CountJobs counts the Jobs with the given status
+func (s *Store) CountJobs(status string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE status=?`, status).Scan(&n)
+	return n, err
+}
+
+// ClaimNextJob marks the oldest queued Job as running and returns it. It returns
+// sql.ErrNoRows when the queue is empty. The status guard on the update makes the
+// claim safe when several workers poll at once.
+func (s *Store) ClaimNextJob(now time.Time) (*schemas.JobSchema, error) {
+	for {
+		var id int64
+		err := s.db.QueryRow(`SELECT job_id FROM jobs WHERE status='queued' ORDER BY job_id LIMIT 1`).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := s.db.Exec(`
+            UPDATE jobs SET status='running', attempts=attempts+1, when_started=?, progress=0, message=''
+            WHERE job_id=? AND status='queued'`, now, id)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			return s.LoadJob(id)
+		}
+	}
+}
+
+// UpdateJobProgress records the progress of a running Job
+func (s *Store) UpdateJobProgress(id int64, progress float64, message string) error {
+	_, err := s.db.Exec(`UPDATE jobs SET progress=?, message=? WHERE job_id=? AND status='running'`, progress, message, id)
+	return err
+}
+
+// FinishJob moves an active Job to a final status. It returns sql.ErrNoRows if the
+// job has already finished.
+func (s *Store) FinishJob(id int64, status string, result, errMsg *string, now time.Time) error {
+	res, err := s.db.Exec(`
+        UPDATE jobs SET status=?, result=?, error=?, when_finished=?,
+            progress=CASE WHEN ?='succeeded' THEN 1 ELSE progress END
+        WHERE job_id=? AND status IN ('queued', 'running')`,
+		status, result, errMsg, now, status, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CancelQueuedJob marks a Job that no worker has claimed yet as cancelled. It returns
+// sql.ErrNoRows if the Job is no longer queued.
+func (s *Store) CancelQueuedJob(id int64, errMsg string, now time.Time) error {
+	res, err := s.db.Exec(`
+        UPDATE jobs SET status='cancelled', error=?, when_finished=?
+        WHERE job_id=? AND status='queued'`,
+		errMsg, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RequeueJob puts a running Job back in the queue
+func (s *Store) RequeueJob(id int64) error {
+	_, err := s.db.Exec(`UPDATE jobs SET status='queued', when_started=NULL WHERE job_id=? AND status='running'`, id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*schemas.JobSchema, error) {
+	job := &schemas.JobSchema{}
+	err := row.Scan(&job.JobId, &job.Kind, &job.Status, &job.Params, &job.Progress, &job.Message,
+		&job.Result, &job.Error, &job.Attempts, &job.CreatedBy, &job.WhenCreated, &job.WhenStarted, &job.WhenFinished)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
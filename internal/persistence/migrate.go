@@ -0,0 +1,264 @@
+package persistence
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change, read from migrations/NNNN_name.sql.
+// Migrations only go up; to undo one, add a new migration.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Version     int
+	Name        string
+	WhenApplied *time.Time
+}
+
+// Migrations returns the embedded migrations in version order
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		version, name, ok := parseMigrationName(entry.Name())
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %s, want NNNN_name.sql", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be consecutive from 1, found %d at position %d", m.Version, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// LatestVersion returns the version of the newest embedded migration
+func LatestVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	return len(migrations), nil
+}
+
+// SchemaVersion returns the version of the newest applied migration, 0 for a new
+// database
+func (s *Store) SchemaVersion() (int, error) {
+	// The table is only created by Migrate, so that a database from before
+	// versioned migrations is still recognised as one
+	if versioned, err := tableExists(s.db, "schema_migrations"); err != nil || !versioned {
+		return 0, err
+	}
+	var version int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// MigrationStatus lists every embedded migration with the time it was applied
+func (s *Store) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		statuses = append(statuses, MigrationStatus{Version: m.Version, Name: m.Name})
+	}
+	if versioned, err := tableExists(s.db, "schema_migrations"); err != nil || !versioned {
+		return statuses, err
+	}
+
+	applied := make(map[int]time.Time)
+	rows, err := s.db.Query(`SELECT version, when_applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			version int
+			when    time.Time
+		)
+		if err := rows.Scan(&version, &when); err != nil {
+			return nil, err
+		}
+		applied[version] = when
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range statuses {
+		if when, ok := applied[statuses[i].Version]; ok {
+			statuses[i].WhenApplied = &when
+		}
+	}
+	return statuses, nil
+}
+
+// Migrate applies the migrations after the current version up to and including
+// target, each in its own transaction. It returns the versions applied. Migrating
+// down is not supported.
+func (s *Store) Migrate(target int) ([]int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if target < 0 || target > len(migrations) {
+		return nil, fmt.Errorf("unknown schema version %d (latest is %d)", target, len(migrations))
+	}
+
+	if err := adoptLegacySchema(s.db); err != nil {
+		return nil, fmt.Errorf("failed to upgrade pre-migration schema: %w", err)
+	}
+
+	if err := ensureMigrationsTable(s.db); err != nil {
+		return nil, err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current > len(migrations) {
+		return nil, fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, len(migrations))
+	}
+	if target < current {
+		return nil, fmt.Errorf("database is at schema version %d; migrating down to %d is not supported", current, target)
+	}
+
+	var applied []int
+	for _, m := range migrations[current:target] {
+		if err := applyMigration(s.db, m); err != nil {
+			return applied, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m.Version)
+	}
+	return applied, nil
+}
+
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, when_applied) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            when_applied TIMESTAMP NOT NULL
+        )`)
+	return err
+}
+
+// adoptLegacySchema brings a database created before versioned migrations up to
+// the initial schema. Those databases have tables but no schema_migrations table,
+// and may lack columns added over time, which the idempotent initial migration
+// cannot add.
+func adoptLegacySchema(db *sql.DB) error {
+	if versioned, err := tableExists(db, "schema_migrations"); err != nil || versioned {
+		return err
+	}
+	if legacy, err := tableExists(db, "data_sources"); err != nil || !legacy {
+		return err
+	}
+
+	// users must exist before columns referencing it are added
+	if _, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS users (
+            user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            username TEXT NOT NULL,
+            is_active BOOLEAN NOT NULL DEFAULT 1,
+            created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+            when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE(username)
+        )`); err != nil {
+		return err
+	}
+
+	if err := ensureColumns(db, "tools", []columnDef{
+		{"description", "TEXT NOT NULL DEFAULT ''"},
+		{"category", "TEXT NOT NULL DEFAULT ''"},
+		{"version", "TEXT NOT NULL DEFAULT ''"},
+		{"input_schema", "TEXT NOT NULL DEFAULT ''"},
+		{"output_schema", "TEXT NOT NULL DEFAULT ''"},
+	}); err != nil {
+		return err
+	}
+
+	createdBy := []columnDef{{"created_by", "INTEGER REFERENCES users(user_id) ON DELETE SET NULL"}}
+	for _, table := range []string{"data_sources", "chat_sessions", "jobs"} {
+		exists, err := tableExists(db, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if err := ensureColumns(db, table, createdBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tableExists(db *sql.DB, table string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?`, table).Scan(&n)
+	return n > 0, err
+}
+
+func parseMigrationName(file string) (int, string, bool) {
+	base, ok := strings.CutSuffix(file, ".sql")
+	if !ok {
+		return 0, "", false
+	}
+	num, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(num)
+	if err != nil || version <= 0 {
+		return 0, "", false
+	}
+	return version, name, true
+}
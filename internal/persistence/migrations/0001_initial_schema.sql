@@ -0,0 +1,136 @@
+-- Initial schema. Databases created before versioned migrations already hold some
+-- or all of these tables, so every statement is idempotent.
+
+CREATE TABLE IF NOT EXISTS users (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT 1,
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(username)
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    api_key_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL,
+    hashed_key TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    when_expires TIMESTAMP,
+    when_last_used TIMESTAMP,
+    when_revoked TIMESTAMP,
+    UNIQUE(prefix),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS data_sources (
+    data_source_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    data_source_type INTEGER NOT NULL,
+    data_source_path TEXT NOT NULL,
+    row_count INTEGER NOT NULL DEFAULT 0,
+    start_time TIMESTAMP,
+    end_time TIMESTAMP,
+    time_label TEXT NOT NULL DEFAULT 'time',
+    value_label TEXT NOT NULL DEFAULT 'value',
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tools (
+    tool_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    fx_name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    version TEXT NOT NULL DEFAULT '',
+    input_schema TEXT NOT NULL DEFAULT '',
+    output_schema TEXT NOT NULL DEFAULT '',
+    timeout_s INTEGER NOT NULL DEFAULT 30,
+    is_enabled BOOLEAN NOT NULL DEFAULT 1,
+    when_last_call TIMESTAMP,
+    num_calls INTEGER NOT NULL DEFAULT 0,
+    max_calls INTEGER,
+    num_call_reset INTEGER,
+    UNIQUE(fx_name)
+);
+
+-- Hashed outbound credentials could not be used to call a downstream API;
+-- they are replaced by the encrypted tool_secrets table
+DROP TABLE IF EXISTS tool_auth_props;
+
+CREATE TABLE IF NOT EXISTS tool_secrets (
+    tool_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    ciphertext BLOB NOT NULL,
+    wrapped_key BLOB NOT NULL,
+    key_id TEXT NOT NULL,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    when_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tool_id, name),
+    FOREIGN KEY (tool_id) REFERENCES tools(tool_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS chat_sessions (
+    session_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    when_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    message_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    role TEXT NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    tool_calls TEXT,
+    tool_call_id TEXT,
+    name TEXT,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(session_id, seq),
+    FOREIGN KEY (session_id) REFERENCES chat_sessions(session_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tool_invocations (
+    invocation_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id INTEGER NOT NULL,
+    message_id INTEGER,
+    tool_call_id TEXT NOT NULL,
+    tool_name TEXT NOT NULL,
+    arguments TEXT NOT NULL DEFAULT '{}',
+    result_summary TEXT,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES chat_sessions(session_id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES chat_messages(message_id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    job_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    params TEXT NOT NULL DEFAULT '{}',
+    progress REAL NOT NULL DEFAULT 0,
+    message TEXT NOT NULL DEFAULT '',
+    result TEXT,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_by INTEGER REFERENCES users(user_id) ON DELETE SET NULL,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    when_started TIMESTAMP,
+    when_finished TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tools_enabled ON tools(is_enabled);
+CREATE INDEX IF NOT EXISTS idx_data_sources_type ON data_sources(data_source_type);
+CREATE INDEX IF NOT EXISTS idx_tool_invocations_session ON tool_invocations(session_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
@@ -0,0 +1,11 @@
+-- Datasources can be grouped into projects
+
+CREATE TABLE projects (
+    project_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE data_sources ADD COLUMN project_id INTEGER REFERENCES projects(project_id) ON DELETE SET NULL;
+
+CREATE INDEX idx_data_sources_project ON data_sources(project_id);
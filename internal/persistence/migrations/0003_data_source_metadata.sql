@@ -0,0 +1,19 @@
+-- Editable descriptive metadata and free-form tags of datasources
+
+CREATE TABLE data_source_metadata (
+    data_source_id INTEGER PRIMARY KEY REFERENCES data_sources(data_source_id) ON DELETE CASCADE,
+    description TEXT NOT NULL DEFAULT '',
+    unit TEXT NOT NULL DEFAULT '',
+    sensor_type TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT '',
+    when_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE data_source_tags (
+    data_source_id INTEGER NOT NULL REFERENCES data_sources(data_source_id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (data_source_id, key)
+);
+
+CREATE INDEX idx_data_source_tags_key_value ON data_source_tags(key, value);
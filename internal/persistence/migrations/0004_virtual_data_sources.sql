@@ -0,0 +1,4 @@
+-- Virtual datasources are computed from other datasources by an expression instead
+-- of being read from a file
+
+ALTER TABLE data_sources ADD COLUMN expression TEXT NOT NULL DEFAULT '';
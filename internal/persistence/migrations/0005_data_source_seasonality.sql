@@ -0,0 +1,9 @@
+-- Seasonal periods detected in datasources when they are created, which analyses
+-- default to
+
+CREATE TABLE data_source_seasonality (
+    data_source_id INTEGER NOT NULL REFERENCES data_sources(data_source_id) ON DELETE CASCADE,
+    period_seconds REAL NOT NULL,
+    strength REAL NOT NULL,
+    PRIMARY KEY (data_source_id, period_seconds)
+);
@@ -2,6 +2,8 @@ package persistence
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -11,9 +13,17 @@ type Store struct {
 	db *sql.DB
 }
 
-// NewStore creates a new Store instance with initialized database
-func NewStore(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+// OpenStore opens the database without migrating it, for inspecting or migrating
+// the schema explicitly
+func OpenStore(dbPath string) (*Store, error) {
+	// Job workers write concurrently with request handlers, so wait for locks
+	// instead of failing immediately with SQLITE_BUSY
+	dsn := dbPath
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_busy_timeout=5000"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -24,56 +34,71 @@ func NewStore(dbPath string) (*Store, error) {
 		return nil, err
 	}
 
-	// Create tables
-	if err := createTables(db); err != nil {
+	return &Store{db: db}, nil
+}
+
+// NewStore opens the database and migrates it to the latest schema version
+func NewStore(dbPath string) (*Store, error) {
+	store, err := OpenStore(dbPath)
+	if err != nil {
 		return nil, err
 	}
 
-	return &Store{db: db}, nil
+	latest, err := LatestVersion()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if _, err := store.Migrate(latest); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return store, nil
 }
 
-func createTables(db *sql.DB) error {
-	schema := `
-    CREATE TABLE IF NOT EXISTS data_sources (
-        data_source_id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        data_source_type INTEGER NOT NULL,
-        data_source_path TEXT NOT NULL,
-        row_count INTEGER NOT NULL DEFAULT 0,
-        start_time TIMESTAMP,
-        end_time TIMESTAMP,
-        time_label TEXT NOT NULL DEFAULT 'time',
-        value_label TEXT NOT NULL DEFAULT 'value',
-        when_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-    );
-
-    CREATE TABLE IF NOT EXISTS tools (
-        tool_id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        fx_name TEXT NOT NULL,
-        timeout_s INTEGER NOT NULL DEFAULT 30,
-        is_enabled BOOLEAN NOT NULL DEFAULT 1,
-        when_last_call TIMESTAMP,
-        num_calls INTEGER NOT NULL DEFAULT 0,
-        max_calls INTEGER,
-        num_call_reset INTEGER,
-        UNIQUE(fx_name)
-    );
-
-    CREATE TABLE IF NOT EXISTS tool_auth_props (
-        tool_id INTEGER PRIMARY KEY,
-        hashed_api_key TEXT,
-        hashed_username TEXT,
-        hashed_password TEXT,
-        FOREIGN KEY (tool_id) REFERENCES tools(tool_id) ON DELETE CASCADE
-    );
-
-    CREATE INDEX IF NOT EXISTS idx_tools_enabled ON tools(is_enabled);
-    CREATE INDEX IF NOT EXISTS idx_data_sources_type ON data_sources(data_source_type);
-    `
-
-	_, err := db.Exec(schema)
-	return err
+type columnDef struct {
+	name string
+	decl string
+}
+
+// ensureColumns adds any of the given columns that are missing from table
+func ensureColumns(db *sql.DB, table string, columns []columnDef) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, col := range columns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, col.name, col.decl)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Close closes the database connection
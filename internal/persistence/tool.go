@@ -1,12 +1,23 @@
 package persistence
 
 import (
-	"database/sql"
+	"errors"
+	"time"
 
 	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
 )
 
-// SaveTool inserts or updates a Tool with its auth properties
+var (
+	// ErrToolDisabled is returned when a call is reserved against a disabled tool
+	ErrToolDisabled = errors.New("tool is disabled")
+	// ErrToolCallLimit is returned when a tool has used up its calls for the current period
+	ErrToolCallLimit = errors.New("tool call limit reached")
+)
+
+// reserveToolCallAttempts bounds the optimistic retries in ReserveToolCall
+const reserveToolCallAttempts = 5
+
+// SaveTool inserts or updates a Tool
 func (s *Store) SaveTool(tool *schemas.ToolSchema) error {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -17,10 +28,10 @@ func (s *Store) SaveTool(tool *schemas.ToolSchema) error {
 	if tool.ToolId == 0 {
 		// Insert tool
 		result, err := tx.Exec(`
-            INSERT INTO tools (name, fx_name, timeout_s, is_enabled, when_last_call, 
-                             num_calls, max_calls, num_call_reset)
-            VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-			tool.Name, tool.FxName, tool.TimeoutS, tool.IsEnabled, tool.WhenLastCall,
+            INSERT INTO tools (name, fx_name, description, category, version, input_schema, output_schema,
+                             timeout_s, is_enabled, when_last_call, num_calls, max_calls, num_call_reset)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tool.Name, tool.FxName, tool.Description, tool.Category, tool.Version, tool.InputSchema, tool.OutputSchema, tool.TimeoutS, tool.IsEnabled, tool.WhenLastCall,
 			tool.NumCalls, tool.MaxCalls, tool.NumCallReset,
 		)
 		if err != nil {
@@ -31,10 +42,10 @@ func (s *Store) SaveTool(tool *schemas.ToolSchema) error {
 		// Update tool
 		_, err := tx.Exec(`
             UPDATE tools 
-            SET name=?, fx_name=?, timeout_s=?, is_enabled=?, when_last_call=?,
-                num_calls=?, max_calls=?, num_call_reset=?
+            SET name=?, fx_name=?, description=?, category=?, version=?, input_schema=?, output_schema=?,
+                timeout_s=?, is_enabled=?, when_last_call=?, num_calls=?, max_calls=?, num_call_reset=?
             WHERE tool_id=?`,
-			tool.Name, tool.FxName, tool.TimeoutS, tool.IsEnabled, tool.WhenLastCall,
+			tool.Name, tool.FxName, tool.Description, tool.Category, tool.Version, tool.InputSchema, tool.OutputSchema, tool.TimeoutS, tool.IsEnabled, tool.WhenLastCall,
 			tool.NumCalls, tool.MaxCalls, tool.NumCallReset, tool.ToolId,
 		)
 		if err != nil {
@@ -42,60 +53,123 @@ func (s *Store) SaveTool(tool *schemas.ToolSchema) error {
 		}
 	}
 
-	// Save auth props if they exist
-	if tool.AuthProps != nil {
-		tool.AuthProps.ToolId = tool.ToolId
-		_, err := tx.Exec(`
-            INSERT OR REPLACE INTO tool_auth_props 
-            (tool_id, hashed_api_key, hashed_username, hashed_password)
-            VALUES (?, ?, ?, ?)`,
-			tool.AuthProps.ToolId, tool.AuthProps.HashedApiKey,
-			tool.AuthProps.HashedUsername, tool.AuthProps.HashedPassword,
-		)
-		if err != nil {
-			return err
-		}
-	}
-
 	return tx.Commit()
 }
 
-// LoadTool retrieves a Tool by ID including auth properties
+// LoadTool retrieves a Tool by ID
 func (s *Store) LoadTool(id int64) (*schemas.ToolSchema, error) {
 	tool := &schemas.ToolSchema{}
 	err := s.db.QueryRow(`
-        SELECT tool_id, name, fx_name, timeout_s, is_enabled, when_last_call,
-               num_calls, max_calls, num_call_reset
+        SELECT tool_id, name, fx_name, description, category, version, input_schema, output_schema,
+               timeout_s, is_enabled, when_last_call, num_calls, max_calls, num_call_reset
         FROM tools WHERE tool_id=?`, id,
-	).Scan(&tool.ToolId, &tool.Name, &tool.FxName, &tool.TimeoutS, &tool.IsEnabled,
+	).Scan(&tool.ToolId, &tool.Name, &tool.FxName, &tool.Description, &tool.Category,
+		&tool.Version, &tool.InputSchema, &tool.OutputSchema, &tool.TimeoutS, &tool.IsEnabled,
+		&tool.WhenLastCall, &tool.NumCalls, &tool.MaxCalls, &tool.NumCallReset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tool, nil
+}
+
+// LoadToolByFxName retrieves a Tool by its function name
+func (s *Store) LoadToolByFxName(fxName string) (*schemas.ToolSchema, error) {
+	tool := &schemas.ToolSchema{}
+	err := s.db.QueryRow(`
+        SELECT tool_id, name, fx_name, description, category, version, input_schema, output_schema,
+               timeout_s, is_enabled, when_last_call, num_calls, max_calls, num_call_reset
+        FROM tools WHERE fx_name=?`, fxName,
+	).Scan(&tool.ToolId, &tool.Name, &tool.FxName, &tool.Description, &tool.Category,
+		&tool.Version, &tool.InputSchema, &tool.OutputSchema, &tool.TimeoutS, &tool.IsEnabled,
 		&tool.WhenLastCall, &tool.NumCalls, &tool.MaxCalls, &tool.NumCallReset)
 
 	if err != nil {
 		return nil, err
 	}
+	return tool, nil
+}
 
-	// Load auth props if they exist
-	authProps := &schemas.ToolAuthPropsSchema{}
-	err = s.db.QueryRow(`
-        SELECT tool_id, hashed_api_key, hashed_username, hashed_password
-        FROM tool_auth_props WHERE tool_id=?`, id,
-	).Scan(&authProps.ToolId, &authProps.HashedApiKey,
-		&authProps.HashedUsername, &authProps.HashedPassword)
-
-	if err == nil {
-		tool.AuthProps = authProps
-	} else if err != sql.ErrNoRows {
+// LoadAllTools retrieves all Tools ordered by name
+func (s *Store) LoadAllTools() ([]*schemas.ToolSchema, error) {
+	rows, err := s.db.Query(`
+        SELECT tool_id, name, fx_name, description, category, version, input_schema, output_schema,
+               timeout_s, is_enabled, when_last_call, num_calls, max_calls, num_call_reset
+        FROM tools ORDER BY name`)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return tool, nil
+	var tools []*schemas.ToolSchema
+	for rows.Next() {
+		tool := &schemas.ToolSchema{}
+		if err := rows.Scan(&tool.ToolId, &tool.Name, &tool.FxName, &tool.Description, &tool.Category,
+			&tool.Version, &tool.InputSchema, &tool.OutputSchema, &tool.TimeoutS, &tool.IsEnabled,
+			&tool.WhenLastCall, &tool.NumCalls, &tool.MaxCalls, &tool.NumCallReset); err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool)
+	}
+	return tools, rows.Err()
+}
+
+// ReserveToolCall checks that a tool is enabled and within its call quota and,
+// if so, increments num_calls and stamps when_last_call in a single guarded update.
+// When num_call_reset is set, the counter restarts at the beginning of every
+// num_call_reset-second window (windows are aligned to the Unix epoch).
+func (s *Store) ReserveToolCall(fxName string, now time.Time) (*schemas.ToolSchema, error) {
+	for attempt := 0; attempt < reserveToolCallAttempts; attempt++ {
+		tool, err := s.LoadToolByFxName(fxName)
+		if err != nil {
+			return nil, err
+		}
+
+		if !tool.IsEnabled {
+			return nil, ErrToolDisabled
+		}
+
+		numCalls := tool.NumCalls
+		if toolCallWindowElapsed(tool, now) {
+			numCalls = 0
+		}
+
+		if tool.MaxCalls != nil && numCalls >= *tool.MaxCalls {
+			return nil, ErrToolCallLimit
+		}
+
+		// Only apply the update if nobody else changed the counter since we read it
+		result, err := s.db.Exec(`
+            UPDATE tools SET num_calls=?, when_last_call=?
+            WHERE tool_id=? AND num_calls=? AND is_enabled=1`,
+			numCalls+1, now, tool.ToolId, tool.NumCalls,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			tool.NumCalls = numCalls + 1
+			tool.WhenLastCall = &now
+			return tool, nil
+		}
+	}
+	return nil, errors.New("tool call reservation conflicted too many times")
+}
+
+func toolCallWindowElapsed(tool *schemas.ToolSchema, now time.Time) bool {
+	if tool.NumCallReset == nil || *tool.NumCallReset <= 0 || tool.WhenLastCall == nil {
+		return false
+	}
+	period := int64(*tool.NumCallReset)
+	return tool.WhenLastCall.Unix()/period != now.Unix()/period
 }
 
 // LoadEnabledTools retrieves all enabled Tools
 func (s *Store) LoadEnabledTools() ([]*schemas.ToolSchema, error) {
 	rows, err := s.db.Query(`
-        SELECT tool_id, name, fx_name, timeout_s, is_enabled, when_last_call,
-               num_calls, max_calls, num_call_reset
+        SELECT tool_id, name, fx_name, description, category, version, input_schema, output_schema,
+               timeout_s, is_enabled, when_last_call, num_calls, max_calls, num_call_reset
         FROM tools WHERE is_enabled=1`)
 	if err != nil {
 		return nil, err
@@ -105,9 +179,9 @@ func (s *Store) LoadEnabledTools() ([]*schemas.ToolSchema, error) {
 	var tools []*schemas.ToolSchema
 	for rows.Next() {
 		tool := &schemas.ToolSchema{}
-		if err := rows.Scan(&tool.ToolId, &tool.Name, &tool.FxName, &tool.TimeoutS,
-			&tool.IsEnabled, &tool.WhenLastCall, &tool.NumCalls,
-			&tool.MaxCalls, &tool.NumCallReset); err != nil {
+		if err := rows.Scan(&tool.ToolId, &tool.Name, &tool.FxName, &tool.Description, &tool.Category,
+			&tool.Version, &tool.InputSchema, &tool.OutputSchema, &tool.TimeoutS, &tool.IsEnabled,
+			&tool.WhenLastCall, &tool.NumCalls, &tool.MaxCalls, &tool.NumCallReset); err != nil {
 			return nil, err
 		}
 		tools = append(tools, tool)
@@ -0,0 +1,71 @@
+package persistence
+
+import (
+	"database/sql"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+const toolSecretColumns = `tool_id, name, ciphertext, wrapped_key, key_id, when_created, when_updated`
+
+// SaveToolSecret inserts or replaces a ToolSecret
+func (s *Store) SaveToolSecret(secret *schemas.ToolSecretSchema) error {
+	_, err := s.db.Exec(`
+        INSERT INTO tool_secrets (`+toolSecretColumns+`)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(tool_id, name) DO UPDATE SET
+            ciphertext=excluded.ciphertext, wrapped_key=excluded.wrapped_key,
+            key_id=excluded.key_id, when_updated=excluded.when_updated`,
+		secret.ToolId, secret.Name, secret.Ciphertext, secret.WrappedKey, secret.KeyId, secret.WhenCreated, secret.WhenUpdated,
+	)
+	return err
+}
+
+// UpdateToolSecretKey replaces the wrapped data key of a ToolSecret, as done when
+// rotating the master key. The ciphertext is unchanged.
+func (s *Store) UpdateToolSecretKey(toolId int64, name string, wrappedKey []byte, keyId string) error {
+	_, err := s.db.Exec(`UPDATE tool_secrets SET wrapped_key=?, key_id=? WHERE tool_id=? AND name=?`,
+		wrappedKey, keyId, toolId, name)
+	return err
+}
+
+// LoadToolSecrets retrieves the ToolSecrets of a tool ordered by name
+func (s *Store) LoadToolSecrets(toolId int64) ([]*schemas.ToolSecretSchema, error) {
+	return s.queryToolSecrets(`SELECT `+toolSecretColumns+` FROM tool_secrets WHERE tool_id=? ORDER BY name`, toolId)
+}
+
+// LoadAllToolSecrets retrieves every ToolSecret
+func (s *Store) LoadAllToolSecrets() ([]*schemas.ToolSecretSchema, error) {
+	return s.queryToolSecrets(`SELECT ` + toolSecretColumns + ` FROM tool_secrets ORDER BY tool_id, name`)
+}
+
+// DeleteToolSecret removes a ToolSecret. It returns sql.ErrNoRows if it did not exist.
+func (s *Store) DeleteToolSecret(toolId int64, name string) error {
+	result, err := s.db.Exec(`DELETE FROM tool_secrets WHERE tool_id=? AND name=?`, toolId, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) queryToolSecrets(query string, args ...any) ([]*schemas.ToolSecretSchema, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*schemas.ToolSecretSchema
+	for rows.Next() {
+		secret := &schemas.ToolSecretSchema{}
+		if err := rows.Scan(&secret.ToolId, &secret.Name, &secret.Ciphertext, &secret.WrappedKey,
+			&secret.KeyId, &secret.WhenCreated, &secret.WhenUpdated); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
@@ -0,0 +1,162 @@
+package persistence
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/schemas"
+)
+
+const (
+	userColumns   = `user_id, username, is_active, created_by, when_created`
+	apiKeyColumns = `api_key_id, user_id, name, prefix, hashed_key, scopes, created_by, when_created, when_expires, when_last_used, when_revoked`
+)
+
+// SaveUser inserts or updates a User
+func (s *Store) SaveUser(user *schemas.UserSchema) error {
+	if user.UserId == 0 {
+		result, err := s.db.Exec(`
+            INSERT INTO users (username, is_active, created_by, when_created)
+            VALUES (?, ?, ?, ?)`,
+			user.Username, user.IsActive, user.CreatedBy, user.WhenCreated,
+		)
+		if err != nil {
+			return err
+		}
+		user.UserId, _ = result.LastInsertId()
+	} else {
+		_, err := s.db.Exec(`
+            UPDATE users SET username=?, is_active=?, created_by=?, when_created=?
+            WHERE user_id=?`,
+			user.Username, user.IsActive, user.CreatedBy, user.WhenCreated, user.UserId,
+		)
+		return err
+	}
+	return nil
+}
+
+// LoadUser retrieves a User by ID
+func (s *Store) LoadUser(id int64) (*schemas.UserSchema, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE user_id=?`, id))
+}
+
+// LoadUserByUsername retrieves a User by username
+func (s *Store) LoadUserByUsername(username string) (*schemas.UserSchema, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username=?`, username))
+}
+
+// LoadAllUsers retrieves all Users ordered by username
+func (s *Store) LoadAllUsers() ([]*schemas.UserSchema, error) {
+	rows, err := s.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*schemas.UserSchema
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// CountUsers returns the number of Users
+func (s *Store) CountUsers() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&n)
+	return n, err
+}
+
+// SaveApiKey inserts an ApiKey. Keys are immutable apart from their usage and
+// revocation timestamps.
+func (s *Store) SaveApiKey(key *schemas.ApiKeySchema) error {
+	result, err := s.db.Exec(`
+        INSERT INTO api_keys (user_id, name, prefix, hashed_key, scopes, created_by, when_created, when_expires)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.UserId, key.Name, key.Prefix, key.HashedKey, key.Scopes, key.CreatedBy, key.WhenCreated, key.WhenExpires,
+	)
+	if err != nil {
+		return err
+	}
+	key.ApiKeyId, _ = result.LastInsertId()
+	return nil
+}
+
+// LoadApiKey retrieves an ApiKey by ID
+func (s *Store) LoadApiKey(id int64) (*schemas.ApiKeySchema, error) {
+	return scanApiKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE api_key_id=?`, id))
+}
+
+// LoadApiKeyByPrefix retrieves an ApiKey by its public prefix
+func (s *Store) LoadApiKeyByPrefix(prefix string) (*schemas.ApiKeySchema, error) {
+	return scanApiKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix=?`, prefix))
+}
+
+// LoadApiKeys retrieves ApiKeys, newest first, optionally limited to one user
+// (userId 0 loads the keys of all users)
+func (s *Store) LoadApiKeys(userId int64) ([]*schemas.ApiKeySchema, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys`
+	var args []any
+	if userId != 0 {
+		query += ` WHERE user_id=?`
+		args = append(args, userId)
+	}
+	query += ` ORDER BY api_key_id DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*schemas.ApiKeySchema
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// TouchApiKey records when an ApiKey was last used
+func (s *Store) TouchApiKey(id int64, now time.Time) error {
+	_, err := s.db.Exec(`UPDATE api_keys SET when_last_used=? WHERE api_key_id=?`, now, id)
+	return err
+}
+
+// RevokeApiKey marks an ApiKey revoked. It returns sql.ErrNoRows if the key does
+// not exist or was already revoked.
+func (s *Store) RevokeApiKey(id int64, now time.Time) error {
+	result, err := s.db.Exec(`UPDATE api_keys SET when_revoked=? WHERE api_key_id=? AND when_revoked IS NULL`, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanUser(row rowScanner) (*schemas.UserSchema, error) {
+	user := &schemas.UserSchema{}
+	if err := row.Scan(&user.UserId, &user.Username, &user.IsActive, &user.CreatedBy, &user.WhenCreated); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func scanApiKey(row rowScanner) (*schemas.ApiKeySchema, error) {
+	key := &schemas.ApiKeySchema{}
+	err := row.Scan(&key.ApiKeyId, &key.UserId, &key.Name, &key.Prefix, &key.HashedKey, &key.Scopes,
+		&key.CreatedBy, &key.WhenCreated, &key.WhenExpires, &key.WhenLastUsed, &key.WhenRevoked)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,38 @@
+package schemas
+
+import "time"
+
+type ChatSessionSchema struct {
+	SessionId   int64
+	Title       string
+	CreatedBy   *int64
+	WhenCreated time.Time
+	WhenUpdated time.Time
+}
+
+type ChatMessageSchema struct {
+	MessageId        int64
+	SessionId        int64
+	Seq              int
+	Role             string
+	Content          string
+	ToolCalls        *string
+	ToolCallId       *string
+	Name             *string
+	PromptTokens     int
+	CompletionTokens int
+	WhenCreated      time.Time
+}
+
+type ToolInvocationSchema struct {
+	InvocationId  int64
+	SessionId     int64
+	MessageId     *int64
+	ToolCallId    string
+	ToolName      string
+	Arguments     string
+	ResultSummary *string
+	DurationMs    int64
+	Error         *string
+	WhenCreated   time.Time
+}
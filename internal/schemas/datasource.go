@@ -8,14 +8,30 @@ type DataSourceSchema struct {
 	Name           string
 	DataSourceType int
 	DataSourcePath string
+	Expression     string
 	RowCount       int
 	StartTime      *time.Time
 	EndTime        *time.Time
 	TimeLabel      string
 	ValueLabel     string
+	CreatedBy      *int64
 	WhenCreated    time.Time
+
+	// Stored in data_source_metadata, data_source_tags and data_source_seasonality
+	Description string
+	Unit        string
+	SensorType  string
+	Location    string
+	Tags        map[string]string
+	Seasonality []SeasonalPeriodSchema
+}
+
+type SeasonalPeriodSchema struct {
+	PeriodSeconds float64
+	Strength      float64
 }
 
 var DataSourceTypes = map[int]string{
 	0: "csv",
+	1: "virtual",
 }
@@ -0,0 +1,19 @@
+package schemas
+
+import "time"
+
+type JobSchema struct {
+	JobId        int64
+	Kind         string
+	Status       string
+	Params       string
+	Progress     float64
+	Message      string
+	Result       *string
+	Error        *string
+	Attempts     int
+	CreatedBy    *int64
+	WhenCreated  time.Time
+	WhenStarted  *time.Time
+	WhenFinished *time.Time
+}
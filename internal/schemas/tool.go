@@ -6,18 +6,27 @@ type ToolSchema struct {
 	ToolId       int64
 	Name         string
 	FxName       string
+	Description  string
+	Category     string
+	Version      string
+	InputSchema  string
+	OutputSchema string
 	TimeoutS     int
 	IsEnabled    bool
 	WhenLastCall *time.Time
 	NumCalls     int
 	MaxCalls     *int
 	NumCallReset *int
-	AuthProps    *ToolAuthPropsSchema
 }
 
-type ToolAuthPropsSchema struct {
-	ToolId         int64
-	HashedApiKey   *string
-	HashedUsername *string
-	HashedPassword *string
+// ToolSecretSchema is one encrypted outbound credential of a tool. Ciphertext is
+// sealed with a per-secret data key, which is stored wrapped by the master key KeyId.
+type ToolSecretSchema struct {
+	ToolId      int64
+	Name        string
+	Ciphertext  []byte
+	WrappedKey  []byte
+	KeyId       string
+	WhenCreated time.Time
+	WhenUpdated time.Time
 }
@@ -0,0 +1,27 @@
+package schemas
+
+import "time"
+
+type UserSchema struct {
+	UserId      int64
+	Username    string
+	IsActive    bool
+	CreatedBy   *int64
+	WhenCreated time.Time
+}
+
+// ApiKeySchema is an inbound API key. Only a one-way hash of the key is stored;
+// Prefix is the public part of the key used to look it up.
+type ApiKeySchema struct {
+	ApiKeyId     int64
+	UserId       int64
+	Name         string
+	Prefix       string
+	HashedKey    string
+	Scopes       string
+	CreatedBy    *int64
+	WhenCreated  time.Time
+	WhenExpires  *time.Time
+	WhenLastUsed *time.Time
+	WhenRevoked  *time.Time
+}
@@ -1,23 +1,37 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
 )
 
 const (
 	MaxFileSize = 500 * 1024 * 1024 // 500MB - large enough for practical datasets
 )
 
+var (
+	// ErrFileTooLarge is returned by SaveFile when the file exceeds the size limit
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrClosed is returned by SaveFile after Close
+	ErrClosed = errors.New("file store is closed")
+)
+
 type FileStore struct {
 	baseDir string
+	closed  atomic.Bool
 }
 
-func NewFileStore() (*FileStore, error) {
-	baseDir := getStorageDir()
+// NewFileStore stores files in baseDir, or in a per-OS default directory when
+// baseDir is empty
+func NewFileStore(baseDir string) (*FileStore, error) {
+	if baseDir == "" {
+		baseDir = getStorageDir()
+	}
 
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
@@ -54,6 +68,9 @@ func getStorageDir() string {
 }
 
 func (fs *FileStore) SaveFile(filename string, reader io.Reader, maxSize int64) (string, error) {
+	if fs.closed.Load() {
+		return "", ErrClosed
+	}
 	if maxSize <= 0 {
 		maxSize = MaxFileSize
 	}
@@ -91,12 +108,19 @@ func (fs *FileStore) SaveFile(filename string, reader io.Reader, maxSize int64)
 
 	if written == maxSize {
 		os.Remove(destPath)
-		return "", fmt.Errorf("file size exceeds maximum allowed size of %d bytes", maxSize)
+		return "", fmt.Errorf("%w: maximum allowed size is %d bytes", ErrFileTooLarge, maxSize)
 	}
 
 	return filename, nil
 }
 
+// Close stops the file store from accepting new files, so a request still running
+// after shutdown cannot leave a file behind. Existing files stay readable.
+func (fs *FileStore) Close() error {
+	fs.closed.Store(true)
+	return nil
+}
+
 func (fs *FileStore) GetFilePath(filename string) string {
 	return filepath.Join(fs.baseDir, filename)
 }
@@ -0,0 +1,475 @@
+package timerange
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range is a resolved time range. Both ends are inclusive, matching
+// timeseries.FilterByTimeRange; calendar periods end one nanosecond before the
+// next period starts.
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ParseError reports an expression that could not be understood
+type ParseError struct {
+	Expr    string
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid time expression %q: %s", e.Expr, e.Message)
+}
+
+type unit int
+
+const (
+	unitSecond unit = iota
+	unitMinute
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitQuarter
+	unitYear
+)
+
+var unitNames = map[string]unit{
+	"s": unitSecond, "sec": unitSecond, "secs": unitSecond, "second": unitSecond, "seconds": unitSecond,
+	"min": unitMinute, "mins": unitMinute, "minute": unitMinute, "minutes": unitMinute,
+	"h": unitHour, "hr": unitHour, "hrs": unitHour, "hour": unitHour, "hours": unitHour,
+	"d": unitDay, "day": unitDay, "days": unitDay,
+	"w": unitWeek, "wk": unitWeek, "wks": unitWeek, "week": unitWeek, "weeks": unitWeek,
+	"mo": unitMonth, "mon": unitMonth, "month": unitMonth, "months": unitMonth,
+	"q": unitQuarter, "quarter": unitQuarter, "quarters": unitQuarter,
+	"y": unitYear, "yr": unitYear, "yrs": unitYear, "year": unitYear, "years": unitYear,
+}
+
+var (
+	nowOffsetRe  = regexp.MustCompile(`^now((?:\s*[+-]\s*\d+\s*[a-zA-Z]+)*)$`)
+	offsetTermRe = regexp.MustCompile(`([+-])\s*(\d+)\s*([a-zA-Z]+)`)
+	rollingRe    = regexp.MustCompile(`^(?:last|past|previous|prev)\s+(\d+)\s+([a-z]+)$`)
+	calendarRe   = regexp.MustCompile(`^(this|current|last|previous|prev)\s+([a-z]+)$`)
+	quarterRe    = regexp.MustCompile(`^(\d{4})-?q([1-4])$`)
+	yearRe       = regexp.MustCompile(`^\d{4}$`)
+	monthRe      = regexp.MustCompile(`^\d{4}-\d{2}$`)
+	clockRe      = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?::(\d{2}))?$`)
+	rangeSepRe   = regexp.MustCompile(`\s+(?:to|until)\s+|\s*\.\.\s*`)
+	durationRe   = regexp.MustCompile(`^(\d+)\s*([a-z]+)$`)
+)
+
+var absoluteLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// span is the result of parsing one side of an expression: either an instant
+// (Start == End) or a calendar period
+type span struct {
+	Range
+	instant bool
+	clock   bool
+}
+
+// Parse resolves a range expression relative to ref in loc. Supported forms:
+//
+//	now-3d, now-6h+30min          instant; the range runs from it to ref
+//	last 6 hours, past 2 weeks    rolling window ending at ref
+//	this week, this month         calendar period to date (weeks start on Monday)
+//	last week, previous month     the full previous calendar period
+//	today, yesterday, tomorrow    full day
+//	2024, 2024-03, 2024-03-15     full year, month or day
+//	2024-Q1                       full quarter
+//	X to Y, X..Y                  from the start of X to the end of Y,
+//	                              e.g. "yesterday 09:00 to 17:00"
+//	RFC3339 timestamps            instant
+func Parse(expr string, ref time.Time, loc *time.Location) (Range, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	ref = ref.In(loc)
+
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return Range{}, &ParseError{Expr: expr, Message: "empty expression"}
+	}
+
+	if parts := splitRange(expr); len(parts) == 2 {
+		left, err := parseSpan(parts[0], ref, loc)
+		if err != nil {
+			return Range{}, err
+		}
+		right, err := parseSpan(parts[1], ref, loc)
+		if err != nil {
+			return Range{}, err
+		}
+
+		end := right.End
+		if right.clock {
+			// A bare clock time on the right takes its date from the left side
+			end = atClock(left.Start, right.End)
+		}
+		if end.Before(left.Start) {
+			return Range{}, &ParseError{Expr: expr, Message: "range ends before it starts"}
+		}
+		return Range{Start: left.Start, End: end}, nil
+	}
+
+	s, err := parseSpan(expr, ref, loc)
+	if err != nil {
+		return Range{}, err
+	}
+	if s.instant {
+		if s.Start.After(ref) {
+			return Range{Start: ref, End: s.Start}, nil
+		}
+		return Range{Start: s.Start, End: ref}, nil
+	}
+	return s.Range, nil
+}
+
+// ParseBound resolves an expression used as one end of a range. Calendar periods
+// resolve to their start, or to their end when end is true, so that
+// start_time=yesterday&end_time=yesterday covers the whole day.
+func ParseBound(expr string, ref time.Time, loc *time.Location, end bool) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	ref = ref.In(loc)
+
+	expr = strings.TrimSpace(expr)
+	if parts := splitRange(expr); len(parts) == 2 {
+		r, err := Parse(expr, ref, loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if end {
+			return r.End, nil
+		}
+		return r.Start, nil
+	}
+
+	s, err := parseSpan(expr, ref, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		return s.End, nil
+	}
+	return s.Start, nil
+}
+
+// Resolve combines the optional range, start and end parameters accepted by the API
+// and tools. Explicit start or end expressions override the corresponding end of the
+// range expression. Unset ends are returned as nil.
+func Resolve(rangeExpr, startExpr, endExpr string, ref time.Time, loc *time.Location) (*time.Time, *time.Time, error) {
+	var start, end *time.Time
+
+	if strings.TrimSpace(rangeExpr) != "" {
+		r, err := Parse(rangeExpr, ref, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		start, end = &r.Start, &r.End
+	}
+
+	if strings.TrimSpace(startExpr) != "" {
+		t, err := ParseBound(startExpr, ref, loc, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = &t
+	}
+
+	if strings.TrimSpace(endExpr) != "" {
+		t, err := ParseBound(endExpr, ref, loc, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		end = &t
+	}
+
+	if start != nil && end != nil && end.Before(*start) {
+		return nil, nil, fmt.Errorf("end time %s is before start time %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	return start, end, nil
+}
+
+// ParseDuration parses a fixed length of time, either a Go duration such as 90s or
+// 1h30m, or a count of seconds, minutes, hours, days or weeks such as 1d or 2 weeks.
+// Months and longer have no fixed length and are rejected.
+func ParseDuration(expr string) (time.Duration, error) {
+	raw := strings.TrimSpace(expr)
+	if d, err := time.ParseDuration(raw); err == nil {
+		return d, nil
+	}
+
+	m := durationRe.FindStringSubmatch(strings.ToLower(raw))
+	if m == nil {
+		return 0, &ParseError{Expr: expr, Message: "expected a duration such as 30s, 5m, 1h or 1d"}
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, &ParseError{Expr: expr, Message: "invalid duration"}
+	}
+	u, ok := unitNames[m[2]]
+	if !ok {
+		return 0, &ParseError{Expr: expr, Message: fmt.Sprintf("unknown unit %q", m[2])}
+	}
+
+	lengths := map[unit]time.Duration{
+		unitSecond: time.Second,
+		unitMinute: time.Minute,
+		unitHour:   time.Hour,
+		unitDay:    24 * time.Hour,
+		unitWeek:   7 * 24 * time.Hour,
+	}
+	length, ok := lengths[u]
+	if !ok {
+		return 0, &ParseError{Expr: expr, Message: "months, quarters and years have no fixed length"}
+	}
+	return time.Duration(n) * length, nil
+}
+
+// LoadLocation resolves an IANA timezone name; the empty string means UTC
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+func splitRange(expr string) []string {
+	// RFC3339 timestamps never contain " to " or "..", so splitting first is safe
+	return rangeSepRe.Split(expr, 2)
+}
+
+func parseSpan(expr string, ref time.Time, loc *time.Location) (span, error) {
+	raw := strings.TrimSpace(expr)
+	lower := strings.Join(strings.Fields(strings.ToLower(raw)), " ")
+
+	// Offsets are case sensitive: "M" is months and "m" is minutes
+	if m := nowOffsetRe.FindStringSubmatch(strings.Join(strings.Fields(raw), "")); m != nil {
+		t, err := applyOffsets(ref, m[1], raw)
+		if err != nil {
+			return span{}, err
+		}
+		return instant(t), nil
+	}
+
+	for _, layout := range absoluteLayouts {
+		if t, err := time.ParseInLocation(layout, raw, loc); err == nil {
+			return instant(t.In(loc)), nil
+		}
+	}
+
+	if m := clockRe.FindStringSubmatch(lower); m != nil {
+		t, err := clockOn(startOf(ref, unitDay), m)
+		if err != nil {
+			return span{}, &ParseError{Expr: expr, Message: err.Error()}
+		}
+		s := instant(t)
+		s.clock = true
+		return s, nil
+	}
+
+	fields := strings.Fields(lower)
+	if len(fields) == 2 {
+		// "yesterday 09:00", "2024-03-15 9:30"
+		if m := clockRe.FindStringSubmatch(fields[1]); m != nil {
+			day, err := parseSpan(fields[0], ref, loc)
+			if err == nil && !day.instant {
+				t, err := clockOn(day.Start, m)
+				if err != nil {
+					return span{}, &ParseError{Expr: expr, Message: err.Error()}
+				}
+				return instant(t), nil
+			}
+		}
+	}
+
+	switch lower {
+	case "now":
+		return instant(ref), nil
+	case "today":
+		return period(startOf(ref, unitDay), unitDay), nil
+	case "yesterday":
+		return period(startOf(ref, unitDay).AddDate(0, 0, -1), unitDay), nil
+	case "tomorrow":
+		return period(startOf(ref, unitDay).AddDate(0, 0, 1), unitDay), nil
+	}
+
+	if m := rollingRe.FindStringSubmatch(lower); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		u, ok := unitNames[m[2]]
+		if !ok {
+			return span{}, &ParseError{Expr: expr, Message: fmt.Sprintf("unknown unit %q", m[2])}
+		}
+		return span{Range: Range{Start: add(ref, u, -n), End: ref}}, nil
+	}
+
+	if m := calendarRe.FindStringSubmatch(lower); m != nil {
+		u, ok := unitNames[m[2]]
+		if !ok {
+			return span{}, &ParseError{Expr: expr, Message: fmt.Sprintf("unknown unit %q", m[2])}
+		}
+		current := startOf(ref, u)
+		if m[1] == "this" || m[1] == "current" {
+			return span{Range: Range{Start: current, End: ref}}, nil
+		}
+		return period(add(current, u, -1), u), nil
+	}
+
+	if m := quarterRe.FindStringSubmatch(lower); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		q, _ := strconv.Atoi(m[2])
+		return period(time.Date(year, time.Month(3*(q-1)+1), 1, 0, 0, 0, 0, loc), unitQuarter), nil
+	}
+
+	if yearRe.MatchString(lower) {
+		year, _ := strconv.Atoi(lower)
+		return period(time.Date(year, 1, 1, 0, 0, 0, 0, loc), unitYear), nil
+	}
+
+	if monthRe.MatchString(lower) {
+		t, err := time.ParseInLocation("2006-01", lower, loc)
+		if err != nil {
+			return span{}, &ParseError{Expr: expr, Message: "invalid month"}
+		}
+		return period(t, unitMonth), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", lower, loc); err == nil {
+		return period(t, unitDay), nil
+	}
+
+	return span{}, &ParseError{Expr: expr, Message: "unrecognized expression"}
+}
+
+func applyOffsets(t time.Time, offsets, expr string) (time.Time, error) {
+	for _, m := range offsetTermRe.FindAllStringSubmatch(offsets, -1) {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return time.Time{}, &ParseError{Expr: expr, Message: "invalid offset"}
+		}
+		if m[1] == "-" {
+			n = -n
+		}
+
+		var u unit
+		switch m[3] {
+		case "m":
+			u = unitMinute
+		case "M":
+			u = unitMonth
+		default:
+			var ok bool
+			u, ok = unitNames[strings.ToLower(m[3])]
+			if !ok {
+				return time.Time{}, &ParseError{Expr: expr, Message: fmt.Sprintf("unknown unit %q", m[3])}
+			}
+		}
+		t = add(t, u, n)
+	}
+	return t, nil
+}
+
+func instant(t time.Time) span {
+	return span{Range: Range{Start: t, End: t}, instant: true}
+}
+
+func period(start time.Time, u unit) span {
+	return span{Range: Range{Start: start, End: add(start, u, 1).Add(-time.Nanosecond)}}
+}
+
+// add moves t by n units, using calendar arithmetic for days and longer so that
+// DST transitions in loc do not shift day boundaries
+func add(t time.Time, u unit, n int) time.Time {
+	switch u {
+	case unitSecond:
+		return t.Add(time.Duration(n) * time.Second)
+	case unitMinute:
+		return t.Add(time.Duration(n) * time.Minute)
+	case unitHour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case unitDay:
+		return t.AddDate(0, 0, n)
+	case unitWeek:
+		return t.AddDate(0, 0, 7*n)
+	case unitMonth:
+		return t.AddDate(0, n, 0)
+	case unitQuarter:
+		return t.AddDate(0, 3*n, 0)
+	default:
+		return t.AddDate(n, 0, 0)
+	}
+}
+
+// startOf truncates t to the start of its calendar unit in t's location
+func startOf(t time.Time, u unit) time.Time {
+	y, mo, d := t.Date()
+	loc := t.Location()
+	switch u {
+	case unitSecond:
+		return t.Truncate(time.Second)
+	case unitMinute:
+		return time.Date(y, mo, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case unitHour:
+		return time.Date(y, mo, d, t.Hour(), 0, 0, 0, loc)
+	case unitDay:
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc)
+	case unitWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // Monday = 0
+		return time.Date(y, mo, d-offset, 0, 0, 0, 0, loc)
+	case unitMonth:
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc)
+	case unitQuarter:
+		return time.Date(y, mo-(mo-1)%3, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	}
+}
+
+func clockOn(day time.Time, m []string) (time.Time, error) {
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	second := 0
+	if m[3] != "" {
+		second, _ = strconv.Atoi(m[3])
+	}
+	if hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, fmt.Errorf("invalid time of day %s", m[0])
+	}
+	y, mo, d := day.Date()
+	return time.Date(y, mo, d, hour, minute, second, 0, day.Location()), nil
+}
+
+// atClock returns the date of day combined with the time of day of clock
+func atClock(day, clock time.Time) time.Time {
+	y, mo, d := day.Date()
+	return time.Date(y, mo, d, clock.Hour(), clock.Minute(), clock.Second(), 0, day.Location())
+}
+
+// PeriodStart returns a function truncating times to the start of their calendar
+// period in loc, named as in expressions: hour, day, week (starting on Monday),
+// month, quarter or year
+func PeriodStart(name string, loc *time.Location) (func(time.Time) time.Time, error) {
+	u, ok := unitNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok || u < unitHour {
+		return nil, fmt.Errorf("unknown period %q, expected hour, day, week, month, quarter or year", name)
+	}
+	return func(t time.Time) time.Time { return startOf(t.In(loc), u) }, nil
+}
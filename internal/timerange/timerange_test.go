@@ -0,0 +1,195 @@
+package timerange
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	// Tuesday, two days after clocks went forward on Sunday 2024-03-10
+	ref := time.Date(2024, 3, 12, 14, 30, 0, 0, loc)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, loc)
+	}
+	endOf := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day+1, 0, 0, 0, 0, loc).Add(-time.Nanosecond)
+	}
+
+	for _, tc := range []struct {
+		expr       string
+		start, end time.Time
+	}{
+		// Days are calendar days, so 3 days back is 71 hours across the DST change
+		{"now-3d", at(3, 9, 14, 30), ref},
+		{"now-72h", at(3, 9, 13, 30), ref},
+		{"now - 1d + 30m", at(3, 11, 15, 0), ref},
+		{"now+2h", ref, at(3, 12, 16, 30)},
+		{"last 6 hours", at(3, 12, 8, 30), ref},
+		{"past 2 days", at(3, 10, 14, 30), ref},
+		{"today", at(3, 12, 0, 0), endOf(3, 12)},
+		{"yesterday", at(3, 11, 0, 0), endOf(3, 11)},
+		{"yesterday 09:00 to 17:00", at(3, 11, 9, 0), at(3, 11, 17, 0)},
+		{"yesterday 22:00 .. today 06:00", at(3, 11, 22, 0), at(3, 12, 6, 0)},
+		{"this week", at(3, 11, 0, 0), ref},
+		{"last week", at(3, 4, 0, 0), endOf(3, 10)},
+		{"this month", at(3, 1, 0, 0), ref},
+		{"last month", at(2, 1, 0, 0), endOf(2, 29)},
+		{"2024-Q1", at(1, 1, 0, 0), endOf(3, 31)},
+		{"2024q4", at(10, 1, 0, 0), endOf(12, 31)},
+		{"2024", at(1, 1, 0, 0), endOf(12, 31)},
+		{"2024-02", at(2, 1, 0, 0), endOf(2, 29)},
+		// The day the clocks went forward is 23 hours long
+		{"2024-03-10", at(3, 10, 0, 0), endOf(3, 10)},
+		{"2024-03-01T12:00:00Z", at(3, 1, 7, 0), ref},
+		{"2024-03-01 12:00", at(3, 1, 12, 0), ref},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			r, err := Parse(tc.expr, ref, loc)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !r.Start.Equal(tc.start) || !r.End.Equal(tc.end) {
+				t.Errorf("Parse(%q) = %s to %s, want %s to %s", tc.expr, r.Start, r.End, tc.start, tc.end)
+			}
+			if r.Start.Location() != loc {
+				t.Errorf("start is in %s, want %s", r.Start.Location(), loc)
+			}
+		})
+	}
+
+	if day, _ := Parse("2024-03-10", ref, loc); day.End.Sub(day.Start) != 23*time.Hour-time.Nanosecond {
+		t.Errorf("2024-03-10 lasts %s, want 23h", day.End.Sub(day.Start))
+	}
+}
+
+func TestParseInUTC(t *testing.T) {
+	ref := time.Date(2024, 3, 12, 2, 0, 0, 0, time.UTC)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// At 02:00 UTC it is still the 11th in New York
+	for _, tc := range []struct {
+		loc  *time.Location
+		want time.Time
+	}{
+		{nil, time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{loc, time.Date(2024, 3, 11, 0, 0, 0, 0, loc)},
+	} {
+		r, err := Parse("today", ref, tc.loc)
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		if !r.Start.Equal(tc.want) {
+			t.Errorf("today in %v starts %s, want %s", tc.loc, r.Start, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	ref := time.Date(2024, 3, 12, 14, 30, 0, 0, time.UTC)
+	for _, expr := range []string{"", "soon", "last 3 fortnights", "now-3x", "today 25:00", "today to yesterday"} {
+		var perr *ParseError
+		if _, err := Parse(expr, ref, time.UTC); !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) error = %v, want a ParseError", expr, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ref := time.Date(2024, 3, 12, 14, 30, 0, 0, time.UTC)
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name                  string
+		rangeExpr, start, end string
+		wantStart, wantEnd    *time.Time
+	}{
+		{"nothing", "", "", "", nil, nil},
+		{"bounds cover the whole day", "", "yesterday", "yesterday", &day, ptr(day.Add(24*time.Hour - time.Nanosecond))},
+		{"start overrides range", "this week", "yesterday 12:00", "", ptr(day.Add(12 * time.Hour)), &ref},
+		{"open end", "", "yesterday", "", &day, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, err := Resolve(tc.rangeExpr, tc.start, tc.end, ref, time.UTC)
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if !sameTime(start, tc.wantStart) || !sameTime(end, tc.wantEnd) {
+				t.Errorf("Resolve = %v, %v, want %v, %v", start, end, tc.wantStart, tc.wantEnd)
+			}
+		})
+	}
+
+	if _, _, err := Resolve("", "today", "yesterday", ref, time.UTC); err == nil {
+		t.Error("Resolve with the end before the start succeeded")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"1d", 24 * time.Hour},
+		{"2 weeks", 14 * 24 * time.Hour},
+		{"15 min", 15 * time.Minute},
+	} {
+		if got, err := ParseDuration(tc.expr); err != nil || got != tc.want {
+			t.Errorf("ParseDuration(%q) = %s, %v, want %s", tc.expr, got, err, tc.want)
+		}
+	}
+	for _, expr := range []string{"1 month", "soon", ""} {
+		if _, err := ParseDuration(expr); err == nil {
+			t.Errorf("ParseDuration(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	// 03:30 UTC on Monday is still Sunday evening in New York
+	instant := time.Date(2024, 3, 11, 3, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		want time.Time
+	}{
+		{"hour", time.Date(2024, 3, 10, 23, 0, 0, 0, loc)},
+		{"day", time.Date(2024, 3, 10, 0, 0, 0, 0, loc)},
+		{"week", time.Date(2024, 3, 4, 0, 0, 0, 0, loc)},
+		{"quarter", time.Date(2024, 1, 1, 0, 0, 0, 0, loc)},
+	} {
+		truncate, err := PeriodStart(tc.name, loc)
+		if err != nil {
+			t.Fatalf("PeriodStart(%q): %v", tc.name, err)
+		}
+		if got := truncate(instant); !got.Equal(tc.want) {
+			t.Errorf("%s start = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+	if _, err := PeriodStart("minute", loc); err == nil {
+		t.Error("PeriodStart(minute) succeeded")
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
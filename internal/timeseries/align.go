@@ -0,0 +1,276 @@
+package timeseries
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Interpolation is how a series is sampled at times between its points
+type Interpolation string
+
+const (
+	// InterpolationLinear draws a straight line between the neighbouring points
+	InterpolationLinear Interpolation = "linear"
+	// InterpolationPrevious carries the last point forward, for states and setpoints
+	InterpolationPrevious Interpolation = "previous"
+	// InterpolationNearest takes the closest point
+	InterpolationNearest Interpolation = "nearest"
+	// InterpolationNone only uses points at the time, or within the tolerance of it
+	InterpolationNone Interpolation = "none"
+)
+
+// Interpolations lists every supported interpolation
+var Interpolations = []Interpolation{InterpolationLinear, InterpolationPrevious, InterpolationNearest, InterpolationNone}
+
+// MaxAlignedTimes limits the number of times AlignWith produces
+const MaxAlignedTimes = 1_000_000
+
+// ErrTooManyTimes is returned when a grid would have more than MaxAlignedTimes times
+var ErrTooManyTimes = errors.New("too many aligned times")
+
+// AlignOptions control how AlignWith combines series
+type AlignOptions struct {
+	// Interval puts the series on a regular grid with this step. Zero uses the
+	// union of their timestamps.
+	Interval time.Duration
+	// Start and End limit the times. The grid starts at Start, or at the earliest
+	// point rounded down to the interval.
+	Start *time.Time
+	End   *time.Time
+	// Interpolation defaults to linear
+	Interpolation Interpolation
+	// Tolerance is how far from a time the points used for it may be. Zero means
+	// no limit, except with InterpolationNone where only exact matches count.
+	Tolerance time.Duration
+}
+
+// ParseInterpolation validates an interpolation name; the empty string is linear
+func ParseInterpolation(name string) (Interpolation, error) {
+	if name == "" {
+		return InterpolationLinear, nil
+	}
+	for _, method := range Interpolations {
+		if string(method) == name {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("unknown interpolation %q, expected linear, previous, nearest or none", name)
+}
+
+// Align puts several series on the union of their timestamps. Each series is
+// interpolated linearly between its neighbouring points, and is NaN before its first
+// and after its last point. Of several points at the same time, the last one counts.
+func Align(inputs ...[]Point) ([]time.Time, [][]float64) {
+	times, columns, _ := AlignWith(AlignOptions{}, inputs...)
+	return times, columns
+}
+
+// AlignWith puts several series on common times, returning the times and one column
+// of values per input. Values are NaN where a series has no point close enough to
+// sample: before its first point, after its last one (except for previous), and
+// where the points are further away than the tolerance.
+func AlignWith(opts AlignOptions, inputs ...[]Point) ([]time.Time, [][]float64, error) {
+	method := opts.Interpolation
+	if method == "" {
+		method = InterpolationLinear
+	}
+	if _, err := ParseInterpolation(string(method)); err != nil {
+		return nil, nil, err
+	}
+	if opts.Interval < 0 || opts.Tolerance < 0 {
+		return nil, nil, errors.New("interval and tolerance must not be negative")
+	}
+
+	sorted := make([][]Point, len(inputs))
+	for i, points := range inputs {
+		sorted[i] = sortPoints(points)
+	}
+
+	var times []time.Time
+	var err error
+	if opts.Interval > 0 {
+		times, err = gridTimes(sorted, opts.Interval, opts.Start, opts.End)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		times = unionTimes(sorted, opts.Start, opts.End)
+	}
+
+	columns := make([][]float64, len(sorted))
+	for i, points := range sorted {
+		columns[i] = sample(points, times, method, opts.Tolerance)
+	}
+	return times, columns, nil
+}
+
+// MedianInterval is the median time between consecutive points, the typical
+// sampling interval of a series. It is zero for fewer than two distinct times.
+func MedianInterval(points []Point) time.Duration {
+	sorted := sortPoints(points)
+	if len(sorted) < 2 {
+		return 0
+	}
+	gaps := make([]time.Duration, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		gaps = append(gaps, sorted[i].Timestamp.Sub(sorted[i-1].Timestamp))
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+// unionTimes returns every timestamp of the sorted series within [start, end]
+func unionTimes(sorted [][]Point, start, end *time.Time) []time.Time {
+	var times []time.Time
+	for _, points := range sorted {
+		for _, p := range points {
+			if (start != nil && p.Timestamp.Before(*start)) || (end != nil && p.Timestamp.After(*end)) {
+				continue
+			}
+			times = append(times, p.Timestamp)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return dedupeTimes(times)
+}
+
+// gridTimes returns the times from start to end every interval, defaulting to the
+// coverage of the sorted series
+func gridTimes(sorted [][]Point, interval time.Duration, start, end *time.Time) ([]time.Time, error) {
+	var first, last time.Time
+	for _, points := range sorted {
+		if len(points) == 0 {
+			continue
+		}
+		if first.IsZero() || points[0].Timestamp.Before(first) {
+			first = points[0].Timestamp
+		}
+		if last.IsZero() || points[len(points)-1].Timestamp.After(last) {
+			last = points[len(points)-1].Timestamp
+		}
+	}
+
+	if start != nil {
+		first = *start
+	} else {
+		first = first.Truncate(interval)
+	}
+	if end != nil {
+		last = *end
+	}
+	if first.IsZero() || last.Before(first) {
+		return nil, nil
+	}
+
+	if count := last.Sub(first)/interval + 1; count > MaxAlignedTimes {
+		return nil, fmt.Errorf("%w: an interval of %s gives %d times, at most %d are allowed", ErrTooManyTimes, interval, count, MaxAlignedTimes)
+	}
+	var times []time.Time
+	for t := first; !t.After(last); t = t.Add(interval) {
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// sortPoints returns a copy of points in time order, keeping the last of several
+// points at the same time
+func sortPoints(points []Point) []Point {
+	sorted := append([]Point(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	deduped := sorted[:0]
+	for _, p := range sorted {
+		if n := len(deduped); n > 0 && deduped[n-1].Timestamp.Equal(p.Timestamp) {
+			deduped[n-1] = p
+			continue
+		}
+		deduped = append(deduped, p)
+	}
+	return deduped
+}
+
+func dedupeTimes(times []time.Time) []time.Time {
+	deduped := times[:0]
+	for _, t := range times {
+		if n := len(deduped); n > 0 && deduped[n-1].Equal(t) {
+			continue
+		}
+		deduped = append(deduped, t)
+	}
+	return deduped
+}
+
+// sample samples sorted points at the sorted times
+func sample(points []Point, times []time.Time, method Interpolation, tolerance time.Duration) []float64 {
+	within := func(t time.Time, p *Point) bool {
+		return p != nil && (tolerance == 0 || absDuration(t.Sub(p.Timestamp)) <= tolerance)
+	}
+
+	values := make([]float64, len(times))
+	j := 0
+	for i, t := range times {
+		for j < len(points) && points[j].Timestamp.Before(t) {
+			j++
+		}
+		// prev is the last point before t and next the first at or after it
+		var prev, next *Point
+		if j > 0 {
+			prev = &points[j-1]
+		}
+		if j < len(points) {
+			next = &points[j]
+		}
+
+		values[i] = math.NaN()
+		if next != nil && next.Timestamp.Equal(t) {
+			values[i] = next.Value
+			continue
+		}
+
+		switch method {
+		case InterpolationLinear:
+			if within(t, prev) && within(t, next) {
+				frac := float64(t.Sub(prev.Timestamp)) / float64(next.Timestamp.Sub(prev.Timestamp))
+				values[i] = prev.Value + frac*(next.Value-prev.Value)
+			}
+		case InterpolationPrevious:
+			if within(t, prev) {
+				values[i] = prev.Value
+			}
+		case InterpolationNearest, InterpolationNone:
+			// Without a tolerance, nearest does not extend a series past its ends
+			// and none only matches exactly
+			if tolerance == 0 && (method == InterpolationNone || prev == nil || next == nil) {
+				continue
+			}
+			if p := closest(t, prev, next); within(t, p) {
+				values[i] = p.Value
+			}
+		}
+	}
+	return values
+}
+
+// closest returns whichever of prev and next is closer to t, prev on a tie
+func closest(t time.Time, prev, next *Point) *Point {
+	switch {
+	case prev == nil:
+		return next
+	case next == nil:
+		return prev
+	case next.Timestamp.Sub(t) < t.Sub(prev.Timestamp):
+		return next
+	default:
+		return prev
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
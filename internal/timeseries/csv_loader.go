@@ -150,14 +150,9 @@ func normalizeTimestamps(df dataframe.DataFrame) (dataframe.DataFrame, string, s
 	normalizedTimestamps := make([]string, len(records))
 
 	for i, record := range records {
-		if i == 0 {
-			normalizedTimestamps[i] = "timestamp"
-			continue
-		}
-
 		parsedTime, err := parseTimestamp(record)
 		if err != nil {
-			return df, timeLabel, valueLabel, fmt.Errorf("invalid timestamp at row %d: %w", i, err)
+			return df, timeLabel, valueLabel, fmt.Errorf("invalid timestamp at row %d: %w", i+1, err)
 		}
 		normalizedTimestamps[i] = parsedTime.Format(time.RFC3339)
 	}
@@ -269,9 +264,9 @@ func validateValues(df dataframe.DataFrame) error {
 	}
 
 	records := valueSeries.Records()
-	for i := 1; i < len(records); i++ {
-		if _, err := strconv.ParseFloat(records[i], 64); err != nil {
-			return &ValidationError{Message: fmt.Sprintf("invalid value at row %d: must be a number", i)}
+	for i, record := range records {
+		if _, err := strconv.ParseFloat(record, 64); err != nil {
+			return &ValidationError{Message: fmt.Sprintf("invalid value at row %d: must be a number", i+1)}
 		}
 	}
 
@@ -282,22 +277,22 @@ func getTimeRange(df dataframe.DataFrame) (time.Time, time.Time, error) {
 	timestampSeries := df.Col("timestamp")
 	records := timestampSeries.Records()
 
-	if len(records) <= 1 {
+	if len(records) == 0 {
 		return time.Time{}, time.Time{}, &ValidationError{Message: "insufficient data"}
 	}
 
 	var minTime, maxTime time.Time
 
-	for i := 1; i < len(records); i++ {
-		t, err := time.Parse(time.RFC3339, records[i])
+	for i, record := range records {
+		t, err := time.Parse(time.RFC3339, record)
 		if err != nil {
-			return time.Time{}, time.Time{}, fmt.Errorf("failed to parse timestamp at row %d: %w", i, err)
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to parse timestamp at row %d: %w", i+1, err)
 		}
 
-		if i == 1 || t.Before(minTime) {
+		if i == 0 || t.Before(minTime) {
 			minTime = t
 		}
-		if i == 1 || t.After(maxTime) {
+		if i == 0 || t.After(maxTime) {
 			maxTime = t
 		}
 	}
@@ -316,10 +311,9 @@ func FilterByTimeRange(tsData *TimeSeriesData, startTime, endTime *time.Time) (*
 	records := timestampSeries.Records()
 
 	mask := make([]bool, len(records))
-	mask[0] = true
 
-	for i := 1; i < len(records); i++ {
-		t, err := time.Parse(time.RFC3339, records[i])
+	for i, record := range records {
+		t, err := time.Parse(time.RFC3339, record)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
 		}
@@ -362,7 +356,7 @@ func FilterByTimeRange(tsData *TimeSeriesData, startTime, endTime *time.Time) (*
 func getFilteredTimestamps(records []string, mask []bool) []string {
 	var filtered []string
 	for i, include := range mask {
-		if include && i > 0 {
+		if include {
 			filtered = append(filtered, records[i])
 		}
 	}
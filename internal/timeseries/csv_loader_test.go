@@ -0,0 +1,58 @@
+package timeseries
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadAndValidateCSVKeepsFirstRow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meter.csv")
+	csv := "time,kwh\n2024-01-01T00:00:00Z,10\n2024-01-01T01:00:00Z,12.5\n2024-01-01T02:00:00Z,15\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tsData, err := LoadAndValidateCSV(path)
+	if err != nil {
+		t.Fatalf("LoadAndValidateCSV: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if tsData.RowCount != 3 || !tsData.StartTime.Equal(start) || !tsData.EndTime.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("loaded %d rows from %s to %s, want 3 from %s", tsData.RowCount, tsData.StartTime, tsData.EndTime, start)
+	}
+	if tsData.TimeLabel != "time" || tsData.ValueLabel != "kwh" {
+		t.Errorf("labels = %s, %s", tsData.TimeLabel, tsData.ValueLabel)
+	}
+
+	points, err := tsData.Points()
+	if err != nil {
+		t.Fatalf("Points: %v", err)
+	}
+	if len(points) != 3 || points[0].Value != 10 || !points[0].Timestamp.Equal(start) {
+		t.Errorf("points = %v, want 3 starting with 10 at %s", points, start)
+	}
+
+	from := start.Add(time.Hour)
+	filtered, err := FilterByTimeRange(tsData, &from, nil)
+	if err != nil {
+		t.Fatalf("FilterByTimeRange: %v", err)
+	}
+	if points, err = filtered.Points(); err != nil || len(points) != 2 || points[0].Value != 12.5 {
+		t.Errorf("filtered points = %v, %v, want 2 starting with 12.5", points, err)
+	}
+}
+
+func TestFromPointsRoundTrips(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []Point{{start, 1}, {start.Add(time.Minute), 2}}
+
+	got, err := FromPoints(points, TimestampCol, ValueCol).Points()
+	if err != nil {
+		t.Fatalf("Points: %v", err)
+	}
+	if len(got) != 2 || got[0] != points[0] || got[1] != points[1] {
+		t.Errorf("Points() = %v, want %v", got, points)
+	}
+}
@@ -0,0 +1,251 @@
+package timeseries
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ImputeMethod is how Impute fills the gaps of a series
+type ImputeMethod string
+
+const (
+	// ImputeLinear draws a straight line between the values around a gap
+	ImputeLinear ImputeMethod = "linear"
+	// ImputeSpline follows a natural cubic spline through every known value
+	ImputeSpline ImputeMethod = "spline"
+	// ImputeLOCF carries the last observation before a gap forward
+	ImputeLOCF ImputeMethod = "locf"
+	// ImputeSeasonal repeats the value one season earlier, or one season later where
+	// the earlier one is missing
+	ImputeSeasonal ImputeMethod = "seasonal"
+	// ImputeRollingMean takes the mean of the known values in a window centred on
+	// each missing time
+	ImputeRollingMean ImputeMethod = "rolling_mean"
+)
+
+// ImputeMethods lists every supported imputation method
+var ImputeMethods = []ImputeMethod{ImputeLinear, ImputeSpline, ImputeLOCF, ImputeSeasonal, ImputeRollingMean}
+
+// DefaultRollingSteps is the window of ImputeRollingMean, in steps, when none is set
+const DefaultRollingSteps = 10
+
+// ImputeOptions control how Impute fills a series
+type ImputeOptions struct {
+	Method ImputeMethod
+	// Interval is the step of the regular grid the series is put on. Points within
+	// half an interval of a grid time are its value; the other times are gaps.
+	Interval time.Duration
+	// Start and End limit the grid, which defaults to the coverage of the series
+	Start *time.Time
+	End   *time.Time
+	// MaxGap is the longest gap that is filled, measured between the known values
+	// around it. Longer gaps are left empty; zero fills every gap.
+	MaxGap time.Duration
+	// Season is the period of ImputeSeasonal
+	Season time.Duration
+	// Window is the width of ImputeRollingMean, DefaultRollingSteps intervals when zero
+	Window time.Duration
+}
+
+// ImputedPoint is a time of the grid. Value is NaN for a gap left empty, and
+// Imputed is set for a value filled in by the method.
+type ImputedPoint struct {
+	Timestamp time.Time
+	Value     float64
+	Imputed   bool
+}
+
+// ParseImputeMethod validates an imputation method name; the empty string is linear
+func ParseImputeMethod(name string) (ImputeMethod, error) {
+	if name == "" {
+		return ImputeLinear, nil
+	}
+	for _, method := range ImputeMethods {
+		if string(method) == name {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("unknown method %q, expected linear, spline, locf, seasonal or rolling_mean", name)
+}
+
+// Impute puts points on a regular grid and fills the gaps between known values with
+// the method. Times before the first and after the last known value are not gaps
+// and stay empty.
+func Impute(points []Point, opts ImputeOptions) ([]ImputedPoint, error) {
+	method, err := ParseImputeMethod(string(opts.Method))
+	if err != nil {
+		return nil, err
+	}
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+	if opts.MaxGap < 0 {
+		return nil, fmt.Errorf("max gap must not be negative")
+	}
+
+	var seasonSteps int
+	if method == ImputeSeasonal {
+		if opts.Season < opts.Interval {
+			return nil, fmt.Errorf("the seasonal method needs a season of at least one interval (%s)", opts.Interval)
+		}
+		seasonSteps = int(math.Round(float64(opts.Season) / float64(opts.Interval)))
+	}
+	windowSteps := DefaultRollingSteps
+	if opts.Window > 0 {
+		windowSteps = max(int(math.Round(float64(opts.Window)/float64(opts.Interval))), 1)
+	}
+
+	times, columns, err := AlignWith(AlignOptions{
+		Interval:      opts.Interval,
+		Start:         opts.Start,
+		End:           opts.End,
+		Interpolation: InterpolationNone,
+		Tolerance:     opts.Interval / 2,
+	}, points)
+	if err != nil {
+		return nil, err
+	}
+	known := columns[0]
+
+	filled := append([]float64(nil), known...)
+	var fill func(i, j int)
+	switch method {
+	case ImputeLinear:
+		fill = func(i, j int) {
+			before, after := known[i-1], known[j+1]
+			for k := i; k <= j; k++ {
+				frac := float64(k-i+1) / float64(j-i+2)
+				filled[k] = before + frac*(after-before)
+			}
+		}
+	case ImputeSpline:
+		fill = splineFill(known, filled)
+	case ImputeLOCF:
+		fill = func(i, j int) {
+			for k := i; k <= j; k++ {
+				filled[k] = known[i-1]
+			}
+		}
+	case ImputeSeasonal:
+		fill = func(i, j int) {
+			for k := i; k <= j; k++ {
+				// Earlier values include those already filled, so a gap longer than a
+				// season repeats the last full one
+				if k >= seasonSteps && !math.IsNaN(filled[k-seasonSteps]) {
+					filled[k] = filled[k-seasonSteps]
+				} else if k+seasonSteps < len(known) {
+					filled[k] = known[k+seasonSteps]
+				}
+			}
+		}
+	case ImputeRollingMean:
+		fill = rollingMeanFill(known, filled, windowSteps)
+	}
+
+	for i := 0; i < len(known); {
+		if !math.IsNaN(known[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j+1 < len(known) && math.IsNaN(known[j+1]) {
+			j++
+		}
+		inside := i > 0 && j+1 < len(known)
+		if inside && (opts.MaxGap == 0 || times[j+1].Sub(times[i-1]) <= opts.MaxGap) {
+			fill(i, j)
+		}
+		i = j + 1
+	}
+
+	imputed := make([]ImputedPoint, len(times))
+	for k, t := range times {
+		imputed[k] = ImputedPoint{
+			Timestamp: t,
+			Value:     filled[k],
+			Imputed:   math.IsNaN(known[k]) && !math.IsNaN(filled[k]),
+		}
+	}
+	return imputed, nil
+}
+
+// splineFill fills gaps from the natural cubic spline through the known values,
+// with the grid index as the x axis
+func splineFill(known, filled []float64) func(i, j int) {
+	var xs, ys []float64
+	for k, v := range known {
+		if !math.IsNaN(v) {
+			xs = append(xs, float64(k))
+			ys = append(ys, v)
+		}
+	}
+	m := naturalSpline(xs, ys)
+
+	// ordinal maps a grid index with a known value to its position in xs
+	ordinal := make(map[int]int, len(xs))
+	for n, x := range xs {
+		ordinal[int(x)] = n
+	}
+
+	return func(i, j int) {
+		n := ordinal[i-1]
+		x0, x1 := xs[n], xs[n+1]
+		y0, y1 := ys[n], ys[n+1]
+		h := x1 - x0
+		for k := i; k <= j; k++ {
+			x := float64(k)
+			a, b := x1-x, x-x0
+			filled[k] = m[n]*a*a*a/(6*h) + m[n+1]*b*b*b/(6*h) + (y0/h-m[n]*h/6)*a + (y1/h-m[n+1]*h/6)*b
+		}
+	}
+}
+
+// naturalSpline returns the second derivatives at xs of the natural cubic spline
+// through (xs, ys), solving the tridiagonal system with the Thomas algorithm
+func naturalSpline(xs, ys []float64) []float64 {
+	n := len(xs)
+	m := make([]float64, n)
+	if n < 3 {
+		return m
+	}
+
+	c := make([]float64, n)
+	d := make([]float64, n)
+	for i := 1; i < n-1; i++ {
+		h0, h1 := xs[i]-xs[i-1], xs[i+1]-xs[i]
+		rhs := 6 * ((ys[i+1]-ys[i])/h1 - (ys[i]-ys[i-1])/h0)
+		den := 2*(h0+h1) - h0*c[i-1]
+		c[i] = h1 / den
+		d[i] = (rhs - h0*d[i-1]) / den
+	}
+	for i := n - 2; i >= 1; i-- {
+		m[i] = d[i] - c[i]*m[i+1]
+	}
+	return m
+}
+
+// rollingMeanFill fills each missing time with the mean of the known values within
+// window steps centred on it
+func rollingMeanFill(known, filled []float64, window int) func(i, j int) {
+	// sums[k] and counts[k] accumulate the known values before index k
+	sums := make([]float64, len(known)+1)
+	counts := make([]int, len(known)+1)
+	for k, v := range known {
+		sums[k+1], counts[k+1] = sums[k], counts[k]
+		if !math.IsNaN(v) {
+			sums[k+1] += v
+			counts[k+1]++
+		}
+	}
+
+	half := window / 2
+	return func(i, j int) {
+		for k := i; k <= j; k++ {
+			lo, hi := max(k-half, 0), min(k+half+1, len(known))
+			if n := counts[hi] - counts[lo]; n > 0 {
+				filled[k] = (sums[hi] - sums[lo]) / float64(n)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package timeseries
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+)
+
+// Point is a single parsed observation of a time series
+type Point struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Points parses the normalized timestamp and value columns into a slice of Points
+func (ts *TimeSeriesData) Points() ([]Point, error) {
+	if ts.DataFrame.Nrow() == 0 {
+		return []Point{}, nil
+	}
+
+	timestampRecords := ts.DataFrame.Col(TimestampCol).Records()
+	valueRecords := ts.DataFrame.Col(ValueCol).Records()
+
+	points := make([]Point, 0, len(timestampRecords))
+	for i := range timestampRecords {
+		t, err := time.Parse(time.RFC3339, timestampRecords[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp at row %d: %w", i+1, err)
+		}
+
+		v, err := strconv.ParseFloat(valueRecords[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value at row %d: %w", i+1, err)
+		}
+
+		points = append(points, Point{Timestamp: t, Value: v})
+	}
+
+	return points, nil
+}
+
+// FromPoints builds TimeSeriesData from points
+func FromPoints(points []Point, timeLabel, valueLabel string) *TimeSeriesData {
+	timestamps := make([]string, 0, len(points))
+	values := make([]float64, 0, len(points))
+
+	tsData := &TimeSeriesData{
+		RowCount:   len(points),
+		TimeLabel:  timeLabel,
+		ValueLabel: valueLabel,
+	}
+	for i, p := range points {
+		timestamps = append(timestamps, p.Timestamp.UTC().Format(time.RFC3339))
+		values = append(values, p.Value)
+
+		if i == 0 || p.Timestamp.Before(tsData.StartTime) {
+			tsData.StartTime = p.Timestamp
+		}
+		if i == 0 || p.Timestamp.After(tsData.EndTime) {
+			tsData.EndTime = p.Timestamp
+		}
+	}
+
+	tsData.DataFrame = dataframe.New(
+		series.New(timestamps, series.String, TimestampCol),
+		series.New(values, series.Float, ValueCol),
+	)
+	return tsData
+}
+
+// FilterPoints returns the points within [startTime, endTime]; either bound may be nil
+func FilterPoints(points []Point, startTime, endTime *time.Time) []Point {
+	if startTime == nil && endTime == nil {
+		return points
+	}
+
+	filtered := make([]Point, 0, len(points))
+	for _, p := range points {
+		if startTime != nil && p.Timestamp.Before(*startTime) {
+			continue
+		}
+		if endTime != nil && p.Timestamp.After(*endTime) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
@@ -0,0 +1,161 @@
+package timeseries
+
+import (
+	"fmt"
+	"time"
+)
+
+// Transform derives a series from the changes or the accumulation of another
+type Transform string
+
+const (
+	// TransformRate is the change per unit of time since the previous point
+	TransformRate Transform = "rate"
+	// TransformDifference is the change since the previous point
+	TransformDifference Transform = "difference"
+	// TransformIntegral is the area under the series since its first point, by the
+	// trapezoidal rule, e.g. energy from power
+	TransformIntegral Transform = "integral"
+	// TransformCounterDelta is the increase of a cumulative counter since the
+	// previous point, accounting for resets and rollovers
+	TransformCounterDelta Transform = "counter_delta"
+	// TransformRunningTotal is the sum of the values so far
+	TransformRunningTotal Transform = "running_total"
+	// TransformPeriodTotal is the sum of the values since the start of the calendar
+	// period, e.g. the energy used today
+	TransformPeriodTotal Transform = "period_total"
+)
+
+// Transforms lists every supported transform
+var Transforms = []Transform{TransformRate, TransformDifference, TransformIntegral, TransformCounterDelta, TransformRunningTotal, TransformPeriodTotal}
+
+// ParseTransform validates a transform name
+func ParseTransform(name string) (Transform, error) {
+	for _, t := range Transforms {
+		if string(t) == name {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown transform %q, expected rate, difference, integral, counter_delta, running_total or period_total", name)
+}
+
+// Rate is the change of the series per per between consecutive points, at the later
+// point of each pair
+func Rate(points []Point, per time.Duration) []Point {
+	sorted := sortPoints(points)
+	if len(sorted) < 2 {
+		return []Point{}
+	}
+	rates := make([]Point, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		dt := float64(sorted[i].Timestamp.Sub(sorted[i-1].Timestamp)) / float64(per)
+		rates = append(rates, Point{Timestamp: sorted[i].Timestamp, Value: (sorted[i].Value - sorted[i-1].Value) / dt})
+	}
+	return rates
+}
+
+// Difference is the change of the series between consecutive points, at the later
+// point of each pair
+func Difference(points []Point) []Point {
+	sorted := sortPoints(points)
+	if len(sorted) < 2 {
+		return []Point{}
+	}
+	diffs := make([]Point, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		diffs = append(diffs, Point{Timestamp: sorted[i].Timestamp, Value: sorted[i].Value - sorted[i-1].Value})
+	}
+	return diffs
+}
+
+// Integral is the running integral of the series from its first point, with time
+// measured in per and the series taken as linear between points: kW integrated per
+// hour gives kWh
+func Integral(points []Point, per time.Duration) []Point {
+	sorted := sortPoints(points)
+	integral := make([]Point, len(sorted))
+	total := 0.0
+	for i, p := range sorted {
+		if i > 0 {
+			dt := float64(p.Timestamp.Sub(sorted[i-1].Timestamp)) / float64(per)
+			total += (p.Value + sorted[i-1].Value) / 2 * dt
+		}
+		integral[i] = Point{Timestamp: p.Timestamp, Value: total}
+	}
+	return integral
+}
+
+// CounterStats counts the decreases CounterDeltas found in a counter
+type CounterStats struct {
+	// Resets are drops to less than half the previous reading, after which the
+	// counter is taken to have restarted from zero
+	Resets int
+	// Rollovers are drops from the top tenth of the counter's range to its bottom
+	// tenth, after which it is taken to have wrapped around
+	Rollovers int
+	// Glitches are smaller drops, taken as meter noise and ignored
+	Glitches int
+}
+
+// CounterDeltas turns the readings of a cumulative counter, such as an energy or
+// flow meter register, into the consumption between consecutive readings. A
+// decrease is a rollover when rollover, the value at which the counter wraps to
+// zero, is set, the previous reading was in its top tenth and the new one is in
+// its bottom tenth; otherwise a drop to less than half the previous reading is a
+// reset, and smaller drops are ignored until the counter is back above its
+// previous reading.
+func CounterDeltas(points []Point, rollover float64) ([]Point, CounterStats) {
+	var stats CounterStats
+	sorted := sortPoints(points)
+	if len(sorted) < 2 {
+		return []Point{}, stats
+	}
+
+	deltas := make([]Point, 0, len(sorted)-1)
+	reference := sorted[0].Value
+	for _, p := range sorted[1:] {
+		var delta float64
+		switch {
+		case p.Value >= reference:
+			delta = p.Value - reference
+			reference = p.Value
+		case rollover > 0 && reference >= 0.9*rollover && p.Value < 0.1*rollover:
+			delta = rollover - reference + p.Value
+			reference = p.Value
+			stats.Rollovers++
+		case p.Value < reference/2:
+			delta = p.Value
+			reference = p.Value
+			stats.Resets++
+		default:
+			stats.Glitches++
+		}
+		deltas = append(deltas, Point{Timestamp: p.Timestamp, Value: delta})
+	}
+	return deltas, stats
+}
+
+// RunningTotal is the sum of the values up to and including each point
+func RunningTotal(points []Point) []Point {
+	return PeriodTotal(points, nil)
+}
+
+// PeriodTotal is the sum of the values since the start of the period of each point,
+// with periodStart truncating a time to the start of its period. A nil periodStart
+// never restarts the sum.
+func PeriodTotal(points []Point, periodStart func(time.Time) time.Time) []Point {
+	sorted := sortPoints(points)
+	totals := make([]Point, len(sorted))
+	total := 0.0
+	var period time.Time
+	for i, p := range sorted {
+		if periodStart != nil {
+			if start := periodStart(p.Timestamp); !start.Equal(period) {
+				period, total = start, 0
+			}
+		}
+		total += p.Value
+		totals[i] = Point{Timestamp: p.Timestamp, Value: total}
+	}
+	return totals
+}
@@ -0,0 +1,44 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterDeltas(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		readings []float64
+		rollover float64
+		want     []float64
+		stats    CounterStats
+	}{
+		{"increasing", []float64{100, 150, 175}, 65536, []float64{50, 25}, CounterStats{}},
+		{"rollover", []float64{65400, 65500, 100}, 65536, []float64{100, 136}, CounterStats{Rollovers: 1}},
+		{"reset", []float64{5000, 5100, 5, 25}, 65536, []float64{100, 5, 20}, CounterStats{Resets: 1}},
+		{"glitch near the top", []float64{64980, 65000, 64990, 65010}, 65536, []float64{20, 0, 10}, CounterStats{Glitches: 1}},
+		{"glitch", []float64{1000, 1010, 1005, 1020}, 65536, []float64{10, 0, 10}, CounterStats{Glitches: 1}},
+		{"wrap without rollover", []float64{65500, 100}, 0, []float64{100}, CounterStats{Resets: 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			points := make([]Point, len(tc.readings))
+			for i, v := range tc.readings {
+				points[i] = Point{Timestamp: start.Add(time.Duration(i) * time.Minute), Value: v}
+			}
+
+			deltas, stats := CounterDeltas(points, tc.rollover)
+			if stats != tc.stats {
+				t.Errorf("stats = %+v, want %+v", stats, tc.stats)
+			}
+			if len(deltas) != len(tc.want) {
+				t.Fatalf("got %d deltas, want %d", len(deltas), len(tc.want))
+			}
+			for i, d := range deltas {
+				if d.Value != tc.want[i] || !d.Timestamp.Equal(points[i+1].Timestamp) {
+					t.Errorf("delta %d = %v at %s, want %v at %s", i, d.Value, d.Timestamp, tc.want[i], points[i+1].Timestamp)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+)
+
+// analysisDefinitions are the built-in tools that run the analysis package on
+// resampled datasources
+func (b *builtins) analysisDefinitions() []*Definition {
+	return []*Definition{
+		{
+			FxName:       "correlate_datasources",
+			Name:         "Correlate datasources",
+			Description:  "Relate two datasources over a time range: Pearson and Spearman correlation, the lag at which they correlate most (positive when x leads y) and Granger causality tests in both directions. Use it to answer whether one signal leads another and by how much.",
+			Category:     CategoryAnalysis,
+			InputSchema:  json.RawMessage(correlateArgsSchema),
+			OutputSchema: json.RawMessage(correlateResultSchema),
+			ArtifactKind: ArtifactTable,
+			ReadOnly:     true,
+			Fn:           b.correlateDataSources,
+		},
+		{
+			FxName:       "find_cycles",
+			Name:         "Find cycles",
+			Description:  "Find recurring cycles in a datasource from its power spectrum, such as a daily (24h) or weekly (7d) pattern or a machine vibration. Returns the dominant periods with the share of the signal's variation each explains.",
+			Category:     CategoryAnalysis,
+			InputSchema:  json.RawMessage(findCyclesArgsSchema),
+			OutputSchema: json.RawMessage(findCyclesResultSchema),
+			ArtifactKind: ArtifactTable,
+			ReadOnly:     true,
+			Fn:           b.findCycles,
+		},
+		{
+			FxName:       "detect_seasonality",
+			Name:         "Detect seasonality",
+			Description:  "Check whether a datasource repeats itself and with which periods, such as daily or weekly, from its autocorrelation. Returns candidate periods with their strength (the correlation of values one period apart) and a Ljung-Box test of whether the series depends on its past at all. The periods found when a datasource was created are also listed in its metadata.",
+			Category:     CategoryAnalysis,
+			InputSchema:  json.RawMessage(detectSeasonalityArgsSchema),
+			OutputSchema: json.RawMessage(detectSeasonalityResultSchema),
+			ArtifactKind: ArtifactTable,
+			ReadOnly:     true,
+			Fn:           b.detectSeasonality,
+		},
+	}
+}
+
+const (
+	intervalProperty = `"interval": {"type": "string", "description": "Resampling step, e.g. 1m, 15m or 1h; default the coarser sampling interval of the datasources"}`
+
+	correlateArgsSchema = `{
+	"type": "object",
+	"properties": {
+		"x_datasource_id": {"type": "integer", "minimum": 1, "description": "Datasource that may lead, e.g. chiller load"},
+		"y_datasource_id": {"type": "integer", "minimum": 1, "description": "Datasource that may follow, e.g. zone temperature"},` + rangeProperties + `,
+		` + intervalProperty + `,
+		"max_lag": {"type": "string", "description": "Largest lag to test, e.g. 2h; default a quarter of the range"},
+		"granger_lags": {"type": "integer", "minimum": 1, "maximum": 24, "description": "Number of lagged steps in the Granger tests; default the best lag"}
+	},
+	"required": ["x_datasource_id", "y_datasource_id"],
+	"additionalProperties": false
+}`
+
+	correlationSchema = `{"type": "object", "properties": {"r": {"type": ["number", "null"]}, "p_value": {"type": ["number", "null"]}, "n": {"type": "integer"}}}`
+
+	correlateResultSchema = `{
+	"type": "object",
+	"properties": {
+		"x_datasource_id": {"type": "integer"},
+		"y_datasource_id": {"type": "integer"},
+		"start_time": {"type": "string", "format": "date-time"},
+		"end_time": {"type": "string", "format": "date-time"},
+		"interval": {"type": "string"},
+		"points": {"type": "integer"},
+		"summary": {"type": "string"},
+		"pearson": ` + correlationSchema + `,
+		"spearman": ` + correlationSchema + `,
+		"best_lag": {
+			"type": "object",
+			"properties": {
+				"lag_steps": {"type": "integer"},
+				"lag": {"type": "string"},
+				"lag_seconds": {"type": "number"},
+				"r": {"type": ["number", "null"]},
+				"n": {"type": "integer"}
+			}
+		},
+		"granger": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"cause": {"type": "integer"},
+					"effect": {"type": "integer"},
+					"lags": {"type": "integer"},
+					"lag": {"type": "string"},
+					"f": {"type": ["number", "null"]},
+					"p_value": {"type": ["number", "null"]},
+					"df_num": {"type": "integer"},
+					"df_den": {"type": "integer"},
+					"significant": {"type": "boolean"}
+				}
+			}
+		},
+		"granger_error": {"type": "string"}
+	}
+}`
+
+	findCyclesArgsSchema = `{
+	"type": "object",
+	"properties": {` + dataSourceIdProperty + `,` + rangeProperties + `,
+		` + intervalProperty + `,
+		"method": {"type": "string", "enum": ["periodogram", "welch"], "description": "periodogram (default) for the finest resolution, welch for a less noisy estimate of long noisy series"},
+		"segment": {"type": "string", "description": "Length of the Welch segments, e.g. 7d"},
+		"top": {"type": "integer", "minimum": 1, "maximum": 20, "description": "Number of cycles to return, default 5"}
+	},
+	"required": ["datasource_id"],
+	"additionalProperties": false
+}`
+
+	findCyclesResultSchema = `{
+	"type": "object",
+	"properties": {
+		"data_source_id": {"type": "integer"},
+		"start_time": {"type": "string", "format": "date-time"},
+		"end_time": {"type": "string", "format": "date-time"},
+		"interval": {"type": "string"},
+		"points": {"type": "integer"},
+		"method": {"type": "string"},
+		"longest_detectable": {"type": "string"},
+		"cycles": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"period": {"type": "string"},
+					"period_seconds": {"type": "number"},
+					"share": {"type": "number"}
+				}
+			}
+		}
+	}
+}`
+
+	detectSeasonalityArgsSchema = `{
+	"type": "object",
+	"properties": {` + dataSourceIdProperty + `,` + rangeProperties + `,
+		` + intervalProperty + `,
+		"top": {"type": "integer", "minimum": 1, "maximum": 10, "description": "Number of periods to return, default 3"}
+	},
+	"required": ["datasource_id"],
+	"additionalProperties": false
+}`
+
+	detectSeasonalityResultSchema = `{
+	"type": "object",
+	"properties": {
+		"data_source_id": {"type": "integer"},
+		"start_time": {"type": "string", "format": "date-time"},
+		"end_time": {"type": "string", "format": "date-time"},
+		"interval": {"type": "string"},
+		"points": {"type": "integer"},
+		"ljung_box": {
+			"type": "object",
+			"properties": {
+				"lags": {"type": "integer"},
+				"lag": {"type": "string"},
+				"q": {"type": ["number", "null"]},
+				"p_value": {"type": ["number", "null"]},
+				"autocorrelated": {"type": "boolean"}
+			}
+		},
+		"seasonality": {"type": "array", "items": ` + seasonalPeriodSchema + `},
+		"seasonality_error": {"type": "string"}
+	}
+}`
+)
+
+type correlateArgs struct {
+	XDataSourceId int64  `json:"x_datasource_id"`
+	YDataSourceId int64  `json:"y_datasource_id"`
+	Range         string `json:"range"`
+	StartTime     string `json:"start_time"`
+	EndTime       string `json:"end_time"`
+	Timezone      string `json:"timezone"`
+	Interval      string `json:"interval"`
+	MaxLag        string `json:"max_lag"`
+	GrangerLags   int    `json:"granger_lags"`
+}
+
+type correlateResult struct {
+	XDataSourceId int64                        `json:"x_datasource_id"`
+	YDataSourceId int64                        `json:"y_datasource_id"`
+	StartTime     time.Time                    `json:"start_time"`
+	EndTime       time.Time                    `json:"end_time"`
+	Interval      string                       `json:"interval"`
+	Points        int                          `json:"points"`
+	Summary       string                       `json:"summary"`
+	Pearson       analysis.CorrelationInfo     `json:"pearson"`
+	Spearman      analysis.CorrelationInfo     `json:"spearman"`
+	BestLag       *analysis.LagCorrelationInfo `json:"best_lag,omitempty"`
+	Granger       []analysis.GrangerInfo       `json:"granger,omitempty"`
+	GrangerError  string                       `json:"granger_error,omitempty"`
+}
+
+func (b *builtins) correlateDataSources(ctx context.Context, args json.RawMessage) (any, error) {
+	var a correlateArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.XDataSourceId == a.YDataSourceId {
+		return nil, &ArgumentError{Message: "x_datasource_id and y_datasource_id must be different"}
+	}
+	if a.GrangerLags < 0 || a.GrangerLags > analysis.MaxGrangerLags {
+		return nil, &ArgumentError{Message: fmt.Sprintf("granger_lags must be 1 to %d", analysis.MaxGrangerLags)}
+	}
+	maxLag, err := parseDurationArg("max_lag", a.MaxLag)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := b.resample(ctx, []int64{a.XDataSourceId, a.YDataSourceId}, a.Range, a.StartTime, a.EndTime, a.Timezone, a.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := analysis.Correlate(table.Columns[0], table.Columns[1], analysis.CorrelationOptions{
+		MaxLag:      analysis.Steps(maxLag, table.Interval),
+		GrangerLags: a.GrangerLags,
+	})
+	if err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+
+	x, y := table.DataSources[0], table.DataSources[1]
+	result := correlateResult{
+		XDataSourceId: x.DataSourceId,
+		YDataSourceId: y.DataSourceId,
+		StartTime:     table.Times[0],
+		EndTime:       table.Times[len(table.Times)-1],
+		Interval:      analysis.FormatDuration(table.Interval),
+		Points:        report.N,
+		Summary:       analysis.DescribeLead(x.Name, y.Name, report.BestLag, table.Interval),
+		Pearson:       analysis.NewCorrelationInfo(report.Pearson),
+		Spearman:      analysis.NewCorrelationInfo(report.Spearman),
+	}
+	if report.BestLag != nil {
+		best := analysis.NewLagCorrelationInfo(*report.BestLag, table.Interval)
+		result.BestLag = &best
+	}
+	if report.GrangerError != nil {
+		result.GrangerError = report.GrangerError.Error()
+	} else {
+		result.Granger = []analysis.GrangerInfo{
+			analysis.NewGrangerInfo(x.DataSourceId, y.DataSourceId, report.GrangerXY, table.Interval),
+			analysis.NewGrangerInfo(y.DataSourceId, x.DataSourceId, report.GrangerYX, table.Interval),
+		}
+	}
+	return result, nil
+}
+
+type findCyclesArgs struct {
+	DataSourceId int64  `json:"datasource_id"`
+	Range        string `json:"range"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	Timezone     string `json:"timezone"`
+	Interval     string `json:"interval"`
+	Method       string `json:"method"`
+	Segment      string `json:"segment"`
+	Top          int    `json:"top"`
+}
+
+type cycle struct {
+	Period        string  `json:"period"`
+	PeriodSeconds float64 `json:"period_seconds"`
+	Share         float64 `json:"share"`
+}
+
+type findCyclesResult struct {
+	DataSourceId int64     `json:"data_source_id"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	Interval     string    `json:"interval"`
+	Points       int       `json:"points"`
+	Method       string    `json:"method"`
+	// LongestDetectable is the longest period the window can resolve
+	LongestDetectable string  `json:"longest_detectable"`
+	Cycles            []cycle `json:"cycles"`
+}
+
+func (b *builtins) findCycles(ctx context.Context, args json.RawMessage) (any, error) {
+	var a findCyclesArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Top == 0 {
+		a.Top = 5
+	}
+	segment, err := parseDurationArg("segment", a.Segment)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := b.resample(ctx, []int64{a.DataSourceId}, a.Range, a.StartTime, a.EndTime, a.Timezone, a.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	spectrum, err := analysis.ComputeSpectrum(table.Columns[0], table.Interval, a.Method, segment)
+	if err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+
+	result := findCyclesResult{
+		DataSourceId:      a.DataSourceId,
+		StartTime:         table.Times[0],
+		EndTime:           table.Times[len(table.Times)-1],
+		Interval:          analysis.FormatDuration(table.Interval),
+		Points:            len(table.Times),
+		Method:            spectrum.Method,
+		LongestDetectable: analysis.FormatPeriod(spectrum.Span / 2),
+		Cycles:            []cycle{},
+	}
+	for _, p := range spectrum.Peaks(a.Top) {
+		result.Cycles = append(result.Cycles, cycle{
+			Period:        analysis.FormatPeriod(p.Period),
+			PeriodSeconds: p.Period.Seconds(),
+			Share:         p.Share,
+		})
+	}
+	return result, nil
+}
+
+type detectSeasonalityArgs struct {
+	DataSourceId int64  `json:"datasource_id"`
+	Range        string `json:"range"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	Timezone     string `json:"timezone"`
+	Interval     string `json:"interval"`
+	Top          int    `json:"top"`
+}
+
+type detectSeasonalityResult struct {
+	DataSourceId     int64                 `json:"data_source_id"`
+	StartTime        time.Time             `json:"start_time"`
+	EndTime          time.Time             `json:"end_time"`
+	Interval         string                `json:"interval"`
+	Points           int                   `json:"points"`
+	LjungBox         analysis.LjungBoxInfo `json:"ljung_box"`
+	Seasonality      []seasonalPeriod      `json:"seasonality"`
+	SeasonalityError string                `json:"seasonality_error,omitempty"`
+}
+
+func (b *builtins) detectSeasonality(ctx context.Context, args json.RawMessage) (any, error) {
+	var a detectSeasonalityArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Top == 0 {
+		a.Top = 3
+	}
+
+	table, err := b.resample(ctx, []int64{a.DataSourceId}, a.Range, a.StartTime, a.EndTime, a.Timezone, a.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := analysis.Autocorrelate(table.Columns[0], table.Interval, analysis.AutocorrelationOptions{Seasonality: a.Top})
+	if err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+
+	result := detectSeasonalityResult{
+		DataSourceId: a.DataSourceId,
+		StartTime:    table.Times[0],
+		EndTime:      table.Times[len(table.Times)-1],
+		Interval:     analysis.FormatDuration(table.Interval),
+		Points:       report.N,
+		LjungBox:     analysis.NewLjungBoxInfo(report.LjungBox, table.Interval),
+		Seasonality:  []seasonalPeriod{},
+	}
+	for _, p := range report.Seasonality {
+		result.Seasonality = append(result.Seasonality, newSeasonalPeriod(p.Period, p.Strength))
+	}
+	if report.SeasonalityError != nil {
+		result.SeasonalityError = report.SeasonalityError.Error()
+	}
+	return result, nil
+}
+
+// resample loads datasources on a regular grid for an analysis, reporting problems
+// the caller can correct as argument errors
+func (b *builtins) resample(ctx context.Context, ids []int64, rangeExpr, startExpr, endExpr, timezone, intervalExpr string) (*dataset.AlignedTable, error) {
+	for _, id := range ids {
+		if _, err := b.loadDataSource(id); err != nil {
+			return nil, err
+		}
+	}
+	startTime, endTime, err := resolveTimeRange(rangeExpr, startExpr, endExpr, timezone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	interval, err := parseDurationArg("interval", intervalExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := b.loader.Resample(ctx, ids, startTime, endTime, interval, "")
+	if err != nil {
+		return nil, argumentErrorFor(err)
+	}
+	return table, nil
+}
+
+// argumentErrorFor reports the errors of reading and transforming a datasource that
+// the caller can correct as argument errors
+func argumentErrorFor(err error) error {
+	if errors.As(err, new(*dataset.ExpressionError)) || errors.As(err, new(*dataset.ImputeError)) ||
+		errors.Is(err, dataset.ErrTooFewPoints) || errors.Is(err, timeseries.ErrTooManyTimes) {
+		return &ArgumentError{Message: err.Error()}
+	}
+	return err
+}
+
+// parseDurationArg parses an optional positive duration argument, zero if it is unset
+func parseDurationArg(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := timerange.ParseDuration(value)
+	if err != nil {
+		return 0, &ArgumentError{Message: fmt.Sprintf("invalid %s: %v", name, err)}
+	}
+	if d <= 0 {
+		return 0, &ArgumentError{Message: fmt.Sprintf("%s must be a positive duration such as 5m", name)}
+	}
+	return d, nil
+}
@@ -0,0 +1,628 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+	"github.com/nathanaday/iot-data-sandbox/internal/units"
+)
+
+// RegisterBuiltins registers the tools that ship with the sandbox
+func RegisterBuiltins(registry *Registry, store *persistence.Store, loader *dataset.Loader) error {
+	b := &builtins{store: store, loader: loader}
+
+	defs := []*Definition{
+		{
+			FxName:       "list_datasources",
+			Name:         "List datasources",
+			Description:  "List every datasource with its id, name, row count, time coverage, column labels, description, unit, sensor type, location and tags.",
+			Category:     CategoryDataSources,
+			InputSchema:  json.RawMessage(`{"type": "object", "properties": {}, "additionalProperties": false}`),
+			OutputSchema: json.RawMessage(`{"type": "array", "items": ` + dataSourceInfoSchema + `}`),
+			ReadOnly:     true,
+			Fn:           b.listDataSources,
+		},
+		{
+			FxName:       "get_datasource",
+			Name:         "Get datasource",
+			Description:  "Get the metadata of one datasource: name, row count, time coverage, column labels, description, unit, sensor type, location and tags.",
+			Category:     CategoryDataSources,
+			InputSchema:  json.RawMessage(dataSourceArgsSchema),
+			OutputSchema: json.RawMessage(dataSourceInfoSchema),
+			ReadOnly:     true,
+			Fn:           b.getDataSource,
+		},
+		{
+			FxName:       "query_data",
+			Name:         "Query data",
+			Description:  "Return the raw (timestamp, value) points of a datasource, optionally limited to a time range and converted to another unit.",
+			Category:     CategoryDataSources,
+			InputSchema:  json.RawMessage(queryDataArgsSchema),
+			OutputSchema: json.RawMessage(queryDataResultSchema),
+			ArtifactKind: ArtifactSeries,
+			ReadOnly:     true,
+			Fn:           b.queryData,
+		},
+		{
+			FxName:       "summarize_data",
+			Name:         "Summarize data",
+			Description:  "Compute count, min, max, mean and standard deviation of a datasource over an optional time range, optionally in another unit.",
+			Category:     CategoryStatistics,
+			InputSchema:  json.RawMessage(timeRangeArgsSchema),
+			OutputSchema: json.RawMessage(summaryResultSchema),
+			ArtifactKind: ArtifactTable,
+			ReadOnly:     true,
+			Fn:           b.summarizeData,
+		},
+		{
+			FxName:       "evaluate_expression",
+			Name:         "Evaluate expression",
+			Description:  "Compute a derived series from datasources with an arithmetic expression such as ds(3) * 1.8 + 32, ds(4) - ds(5) or ds(1, \"kW\") / ds(2, \"kW\"). Inputs are aligned on their combined timestamps with linear interpolation. Units are checked: values of different dimensions cannot be added or compared. Functions: abs, round, floor, ceil, sqrt, exp, log, log10, pow, min, max, clamp, rate.",
+			Category:     CategoryDataSources,
+			InputSchema:  json.RawMessage(evaluateExpressionArgsSchema),
+			OutputSchema: json.RawMessage(evaluateExpressionResultSchema),
+			ArtifactKind: ArtifactSeries,
+			ReadOnly:     true,
+			Fn:           b.evaluateExpression,
+		},
+		{
+			FxName:       "resolve_time_range",
+			Name:         "Resolve time range",
+			Description:  "Resolve a relative or natural-language time expression (now-3d, last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1) into absolute RFC3339 start and end times.",
+			Category:     CategoryTime,
+			InputSchema:  json.RawMessage(resolveTimeRangeArgsSchema),
+			OutputSchema: json.RawMessage(resolveTimeRangeResultSchema),
+			ReadOnly:     true,
+			Fn:           b.resolveTimeRange,
+		},
+	}
+
+	defs = append(defs, b.analysisDefinitions()...)
+	defs = append(defs, b.transformDefinitions()...)
+
+	for _, def := range defs {
+		if err := registry.Register(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tool categories used by the built-in tools
+const (
+	CategoryDataSources = "datasources"
+	CategoryStatistics  = "statistics"
+	CategoryAnalysis    = "analysis"
+	CategoryTime        = "time"
+)
+
+// Artifact kinds produced by the built-in tools
+const (
+	ArtifactSeries = "series"
+	ArtifactTable  = "table"
+)
+
+const (
+	dataSourceIdProperty = `"datasource_id": {"type": "integer", "minimum": 1, "description": "Datasource ID"}`
+	rangeProperties      = `
+		"range": {"type": "string", "description": "Time range expression, e.g. last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1"},
+		"start_time": {"type": "string", "description": "Inclusive range start, RFC3339 or an expression such as now-3d; overrides the start of range"},
+		"end_time": {"type": "string", "description": "Inclusive range end, RFC3339 or an expression such as now; overrides the end of range"},
+		"timezone": {"type": "string", "description": "IANA timezone used to resolve expressions, default UTC"}`
+	timeRangeProperties = dataSourceIdProperty + `,` + rangeProperties + `,
+		"unit": {"type": "string", "description": "Convert values to this unit, e.g. degF or kW; must have the same dimension as the datasource's unit"}`
+
+	dataSourceArgsSchema = `{
+	"type": "object",
+	"properties": {` + dataSourceIdProperty + `},
+	"required": ["datasource_id"],
+	"additionalProperties": false
+}`
+
+	timeRangeArgsSchema = `{
+	"type": "object",
+	"properties": {` + timeRangeProperties + `},
+	"required": ["datasource_id"],
+	"additionalProperties": false
+}`
+
+	queryDataArgsSchema = `{
+	"type": "object",
+	"properties": {` + timeRangeProperties + `,
+		"limit": {"type": "integer", "minimum": 1, "description": "Maximum number of points to return"}
+	},
+	"required": ["datasource_id"],
+	"additionalProperties": false
+}`
+
+	evaluateExpressionArgsSchema = `{
+	"type": "object",
+	"properties": {
+		"expression": {"type": "string", "minLength": 1, "description": "Expression over datasources, e.g. ds(3) * 1.8 + 32, ds(4) - ds(5) or max(ds(1), ds(2, \"degC\"))"},` + rangeProperties + `,
+		"unit": {"type": "string", "description": "Convert the result to this unit; must have the same dimension as the result"},
+		"limit": {"type": "integer", "minimum": 1, "description": "Maximum number of points to return"}
+	},
+	"required": ["expression"],
+	"additionalProperties": false
+}`
+
+	evaluateExpressionResultSchema = `{
+	"type": "object",
+	"properties": {
+		"expression": {"type": "string"},
+		"row_count": {"type": "integer"},
+		"unit": {"type": "string"},
+		"truncated": {"type": "boolean"},
+		"data": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"timestamp": {"type": "string", "format": "date-time"},
+					"value": {"type": "number"}
+				}
+			}
+		}
+	}
+}`
+
+	dataSourceInfoSchema = `{
+	"type": "object",
+	"properties": {
+		"data_source_id": {"type": "integer"},
+		"name": {"type": "string"},
+		"type": {"type": "string"},
+		"expression": {"type": "string"},
+		"row_count": {"type": "integer"},
+		"start_time": {"type": "string", "format": "date-time"},
+		"end_time": {"type": "string", "format": "date-time"},
+		"time_label": {"type": "string"},
+		"value_label": {"type": "string"},
+		"description": {"type": "string"},
+		"unit": {"type": "string"},
+		"sensor_type": {"type": "string"},
+		"location": {"type": "string"},
+		"tags": {"type": "object", "additionalProperties": {"type": "string"}},
+		"seasonality": {"type": "array", "description": "Seasonal periods detected when the datasource was created, strongest first", "items": ` + seasonalPeriodSchema + `}
+	}
+}`
+
+	seasonalPeriodSchema = `{"type": "object", "properties": {"period": {"type": "string"}, "period_seconds": {"type": "number"}, "strength": {"type": "number"}}}`
+
+	queryDataResultSchema = `{
+	"type": "object",
+	"properties": {
+		"data_source_id": {"type": "integer"},
+		"row_count": {"type": "integer"},
+		"unit": {"type": "string"},
+		"truncated": {"type": "boolean"},
+		"data": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"timestamp": {"type": "string", "format": "date-time"},
+					"value": {"type": "number"}
+				}
+			}
+		}
+	}
+}`
+
+	resolveTimeRangeArgsSchema = `{
+	"type": "object",
+	"properties": {
+		"expression": {"type": "string", "minLength": 1, "description": "Time expression, e.g. now-3d, last 6 hours, yesterday 09:00 to 17:00, this week, 2024-Q1"},
+		"timezone": {"type": "string", "description": "IANA timezone used to resolve the expression, default UTC"},
+		"reference_time": {"type": "string", "description": "RFC3339 time that relative expressions are resolved against, default now"}
+	},
+	"required": ["expression"],
+	"additionalProperties": false
+}`
+
+	resolveTimeRangeResultSchema = `{
+	"type": "object",
+	"properties": {
+		"expression": {"type": "string"},
+		"timezone": {"type": "string"},
+		"start_time": {"type": "string", "format": "date-time"},
+		"end_time": {"type": "string", "format": "date-time"},
+		"duration_seconds": {"type": "number"}
+	}
+}`
+
+	summaryResultSchema = `{
+	"type": "object",
+	"properties": {
+		"data_source_id": {"type": "integer"},
+		"count": {"type": "integer"},
+		"unit": {"type": "string"},
+		"start_time": {"type": "string", "format": "date-time"},
+		"end_time": {"type": "string", "format": "date-time"},
+		"min": {"type": "number"},
+		"max": {"type": "number"},
+		"mean": {"type": "number"},
+		"std_dev": {"type": "number"}
+	}
+}`
+)
+
+type builtins struct {
+	store  *persistence.Store
+	loader *dataset.Loader
+}
+
+type dataSourceInfo struct {
+	DataSourceId int64             `json:"data_source_id"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Expression   string            `json:"expression,omitempty"`
+	RowCount     int               `json:"row_count"`
+	StartTime    *time.Time        `json:"start_time,omitempty"`
+	EndTime      *time.Time        `json:"end_time,omitempty"`
+	TimeLabel    string            `json:"time_label"`
+	ValueLabel   string            `json:"value_label"`
+	Description  string            `json:"description,omitempty"`
+	Unit         string            `json:"unit,omitempty"`
+	SensorType   string            `json:"sensor_type,omitempty"`
+	Location     string            `json:"location,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Seasonality  []seasonalPeriod  `json:"seasonality,omitempty"`
+}
+
+type seasonalPeriod struct {
+	Period        string  `json:"period"`
+	PeriodSeconds float64 `json:"period_seconds"`
+	Strength      float64 `json:"strength"`
+}
+
+type dataPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+type dataSourceArgs struct {
+	DataSourceId int64 `json:"datasource_id"`
+}
+
+type timeRangeArgs struct {
+	DataSourceId int64  `json:"datasource_id"`
+	Range        string `json:"range"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	Timezone     string `json:"timezone"`
+	Unit         string `json:"unit"`
+}
+
+type queryDataArgs struct {
+	timeRangeArgs
+	Limit int `json:"limit"`
+}
+
+type queryDataResult struct {
+	DataSourceId int64       `json:"data_source_id"`
+	RowCount     int         `json:"row_count"`
+	Unit         string      `json:"unit,omitempty"`
+	Truncated    bool        `json:"truncated"`
+	Data         []dataPoint `json:"data"`
+}
+
+type evaluateExpressionArgs struct {
+	Expression string `json:"expression"`
+	Range      string `json:"range"`
+	StartTime  string `json:"start_time"`
+	EndTime    string `json:"end_time"`
+	Timezone   string `json:"timezone"`
+	Unit       string `json:"unit"`
+	Limit      int    `json:"limit"`
+}
+
+type evaluateExpressionResult struct {
+	Expression string      `json:"expression"`
+	RowCount   int         `json:"row_count"`
+	Unit       string      `json:"unit,omitempty"`
+	Truncated  bool        `json:"truncated"`
+	Data       []dataPoint `json:"data"`
+}
+
+type resolveTimeRangeArgs struct {
+	Expression    string `json:"expression"`
+	Timezone      string `json:"timezone"`
+	ReferenceTime string `json:"reference_time"`
+}
+
+type resolveTimeRangeResult struct {
+	Expression      string    `json:"expression"`
+	Timezone        string    `json:"timezone"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+type summaryResult struct {
+	DataSourceId int64      `json:"data_source_id"`
+	Count        int        `json:"count"`
+	Unit         string     `json:"unit,omitempty"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	Min          float64    `json:"min"`
+	Max          float64    `json:"max"`
+	Mean         float64    `json:"mean"`
+	StdDev       float64    `json:"std_dev"`
+}
+
+func (b *builtins) listDataSources(ctx context.Context, args json.RawMessage) (any, error) {
+	schemas, err := b.store.LoadAllDataSources()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load datasources: %w", err)
+	}
+
+	infos := make([]dataSourceInfo, 0, len(schemas))
+	for _, schema := range schemas {
+		ds := &models.DataSource{}
+		ds.FromSchema(schema)
+		infos = append(infos, newDataSourceInfo(ds))
+	}
+	return infos, nil
+}
+
+func (b *builtins) getDataSource(ctx context.Context, args json.RawMessage) (any, error) {
+	var a dataSourceArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	ds, err := b.loadDataSource(a.DataSourceId)
+	if err != nil {
+		return nil, err
+	}
+	return newDataSourceInfo(ds), nil
+}
+
+func (b *builtins) queryData(ctx context.Context, args json.RawMessage) (any, error) {
+	var a queryDataArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	points, unit, err := b.loadPoints(ctx, a.timeRangeArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := queryDataResult{
+		DataSourceId: a.DataSourceId,
+		RowCount:     len(points),
+		Unit:         unit,
+	}
+	if a.Limit > 0 && len(points) > a.Limit {
+		points = points[:a.Limit]
+		result.Truncated = true
+	}
+
+	result.Data = make([]dataPoint, 0, len(points))
+	for _, p := range points {
+		result.Data = append(result.Data, dataPoint{Timestamp: p.Timestamp, Value: p.Value})
+	}
+	return result, nil
+}
+
+func (b *builtins) summarizeData(ctx context.Context, args json.RawMessage) (any, error) {
+	var a timeRangeArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	points, unit, err := b.loadPoints(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	result := summaryResult{
+		DataSourceId: a.DataSourceId,
+		Count:        len(points),
+		Unit:         unit,
+	}
+	if len(points) == 0 {
+		return result, nil
+	}
+
+	start, end := points[0].Timestamp, points[0].Timestamp
+	result.Min, result.Max = points[0].Value, points[0].Value
+	sum := 0.0
+	for _, p := range points {
+		if p.Timestamp.Before(start) {
+			start = p.Timestamp
+		}
+		if p.Timestamp.After(end) {
+			end = p.Timestamp
+		}
+		result.Min = math.Min(result.Min, p.Value)
+		result.Max = math.Max(result.Max, p.Value)
+		sum += p.Value
+	}
+	result.Mean = sum / float64(len(points))
+
+	sq := 0.0
+	for _, p := range points {
+		sq += (p.Value - result.Mean) * (p.Value - result.Mean)
+	}
+	result.StdDev = math.Sqrt(sq / float64(len(points)))
+	result.StartTime = &start
+	result.EndTime = &end
+
+	return result, nil
+}
+
+func (b *builtins) evaluateExpression(ctx context.Context, args json.RawMessage) (any, error) {
+	var a evaluateExpressionArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Expression == "" {
+		return nil, &ArgumentError{Message: "expression is required"}
+	}
+
+	startTime, endTime, err := resolveTimeRange(a.Range, a.StartTime, a.EndTime, a.Timezone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	points, unit, err := b.loader.Evaluate(ctx, a.Expression, startTime, endTime, a.Unit)
+	if err != nil {
+		if errors.As(err, new(*dataset.ExpressionError)) || errors.Is(err, units.ErrUnknownUnit) || errors.Is(err, units.ErrIncompatible) {
+			return nil, &ArgumentError{Message: err.Error()}
+		}
+		return nil, err
+	}
+
+	result := evaluateExpressionResult{
+		Expression: a.Expression,
+		RowCount:   len(points),
+		Unit:       unit,
+	}
+	if a.Limit > 0 && len(points) > a.Limit {
+		points = points[:a.Limit]
+		result.Truncated = true
+	}
+
+	result.Data = make([]dataPoint, 0, len(points))
+	for _, p := range points {
+		result.Data = append(result.Data, dataPoint{Timestamp: p.Timestamp, Value: p.Value})
+	}
+	return result, nil
+}
+
+func (b *builtins) resolveTimeRange(ctx context.Context, args json.RawMessage) (any, error) {
+	var a resolveTimeRangeArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	loc, err := timerange.LoadLocation(a.Timezone)
+	if err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+
+	ref := time.Now()
+	if a.ReferenceTime != "" {
+		ref, err = time.Parse(time.RFC3339, a.ReferenceTime)
+		if err != nil {
+			return nil, &ArgumentError{Message: "invalid reference_time format, use RFC3339"}
+		}
+	}
+
+	r, err := timerange.Parse(a.Expression, ref, loc)
+	if err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+
+	return resolveTimeRangeResult{
+		Expression:      a.Expression,
+		Timezone:        loc.String(),
+		StartTime:       r.Start,
+		EndTime:         r.End,
+		DurationSeconds: r.End.Sub(r.Start).Seconds(),
+	}, nil
+}
+
+func (b *builtins) loadDataSource(id int64) (*models.DataSource, error) {
+	if id <= 0 {
+		return nil, &ArgumentError{Message: "datasource_id is required"}
+	}
+
+	ds, err := b.loader.DataSource(id)
+	if errors.Is(err, dataset.ErrNotFound) {
+		return nil, &ArgumentError{Message: fmt.Sprintf("datasource %d not found", id)}
+	}
+	return ds, err
+}
+
+// loadPoints loads the points selected by a, converted to a.Unit if it is set, and
+// returns the unit they are in
+func (b *builtins) loadPoints(ctx context.Context, a timeRangeArgs) ([]timeseries.Point, string, error) {
+	ds, err := b.loadDataSource(a.DataSourceId)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, _, err := dataset.UnitConverter(ds, a.Unit); err != nil {
+		return nil, "", &ArgumentError{Message: err.Error()}
+	}
+
+	startTime, endTime, err := resolveTimeRange(a.Range, a.StartTime, a.EndTime, a.Timezone, time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+
+	ds, points, err := b.loader.Points(ctx, a.DataSourceId, startTime, endTime)
+	if errors.As(err, new(*dataset.ExpressionError)) {
+		return nil, "", &ArgumentError{Message: err.Error()}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	unit, err := dataset.ConvertPoints(ds, points, a.Unit)
+	return points, unit, err
+}
+
+func newDataSourceInfo(ds *models.DataSource) dataSourceInfo {
+	info := dataSourceInfo{
+		DataSourceId: ds.DataSourceId,
+		Name:         ds.Name,
+		Type:         models.DataSourceTypes[ds.DataSourceType],
+		Expression:   ds.Expression,
+		RowCount:     ds.RowCount,
+		StartTime:    ds.StartTime,
+		EndTime:      ds.EndTime,
+		TimeLabel:    ds.TimeLabel,
+		ValueLabel:   ds.ValueLabel,
+		Description:  ds.Description,
+		Unit:         ds.Unit,
+		SensorType:   ds.SensorType,
+		Location:     ds.Location,
+		Tags:         ds.Tags,
+	}
+	for _, p := range ds.Seasonality {
+		info.Seasonality = append(info.Seasonality, newSeasonalPeriod(p.Period, p.Strength))
+	}
+	return info
+}
+
+func newSeasonalPeriod(period time.Duration, strength float64) seasonalPeriod {
+	return seasonalPeriod{Period: analysis.FormatPeriod(period), PeriodSeconds: period.Seconds(), Strength: strength}
+}
+
+func decodeArgs(args json.RawMessage, v any) error {
+	if len(args) == 0 || string(args) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return &ArgumentError{Message: fmt.Sprintf("invalid arguments: %v", err)}
+	}
+	return nil
+}
+
+// resolveTimeRange parses the range, start_time and end_time arguments, reporting
+// unparseable expressions as argument errors so the caller can correct them
+func resolveTimeRange(rangeExpr, startExpr, endExpr, timezone string, now time.Time) (*time.Time, *time.Time, error) {
+	loc, err := timerange.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, &ArgumentError{Message: err.Error()}
+	}
+
+	start, end, err := timerange.Resolve(rangeExpr, startExpr, endExpr, now, loc)
+	if err != nil {
+		return nil, nil, &ArgumentError{Message: err.Error()}
+	}
+	return start, end, nil
+}
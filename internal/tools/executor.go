@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/logging"
+	"github.com/nathanaday/iot-data-sandbox/internal/metrics"
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"github.com/nathanaday/iot-data-sandbox/internal/vault"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrUnknownTool is returned when no implementation or tools row exists for an fx name
+	ErrUnknownTool = errors.New("unknown tool")
+	// ErrMissingSecret is returned when a tool needs a credential that is not in the vault
+	ErrMissingSecret = errors.New("missing tool secret")
+)
+
+// ArgumentError reports invalid arguments passed to a tool
+type ArgumentError struct {
+	Message string
+}
+
+func (e *ArgumentError) Error() string {
+	return e.Message
+}
+
+// Executor is the single entry point for running tools. It enforces the policy
+// stored in the tools table before handing off to the registered implementation.
+type Executor struct {
+	store    *persistence.Store
+	registry *Registry
+	vault    *vault.Vault
+}
+
+func NewExecutor(store *persistence.Store, registry *Registry, secrets *vault.Vault) *Executor {
+	return &Executor{
+		store:    store,
+		registry: registry,
+		vault:    secrets,
+	}
+}
+
+// Registry returns the registry the executor dispatches to
+func (e *Executor) Registry() *Registry {
+	return e.registry
+}
+
+// EnabledDefinitions returns the registered tools whose tools row is enabled
+func (e *Executor) EnabledDefinitions() ([]*Definition, error) {
+	schemas, err := e.store.LoadEnabledTools()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(schemas))
+	for _, schema := range schemas {
+		enabled[schema.FxName] = true
+	}
+
+	var defs []*Definition
+	for _, def := range e.registry.Definitions() {
+		if enabled[def.FxName] {
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+// revealSecrets decrypts the credentials a tool declares. This is the only place
+// vault secrets are decrypted.
+func (e *Executor) revealSecrets(toolId int64, def *Definition) (map[string]string, error) {
+	if e.vault == nil || !e.vault.Configured() {
+		return nil, vault.ErrNoMasterKey
+	}
+
+	all, err := e.vault.Reveal(toolId)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string]string, len(def.Secrets))
+	for _, name := range def.Secrets {
+		value, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingSecret, name)
+		}
+		secrets[name] = value
+	}
+	return secrets, nil
+}
+
+type secretsKey struct{}
+
+// Secret returns a credential declared in Definition.Secrets. It is only available
+// inside the tool implementation, through the context the executor passes in.
+func Secret(ctx context.Context, name string) (string, bool) {
+	secrets, _ := ctx.Value(secretsKey{}).(map[string]string)
+	value, ok := secrets[name]
+	return value, ok
+}
+
+// Validate checks that fxName is registered and args match its input schema,
+// without running the tool or counting a call
+func (e *Executor) Validate(fxName string, args json.RawMessage) error {
+	def, ok := e.registry.Lookup(fxName)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTool, fxName)
+	}
+	return def.ValidateArgs(args)
+}
+
+// CanRepeat reports whether running fxName again with args is safe: the tool is
+// read-only and args do not ask for the result to be saved
+func (e *Executor) CanRepeat(fxName string, args json.RawMessage) bool {
+	def, ok := e.registry.Lookup(fxName)
+	if !ok || !def.ReadOnly {
+		return false
+	}
+	var a struct {
+		SaveAs string `json:"save_as"`
+	}
+	if len(args) > 0 && json.Unmarshal(args, &a) != nil {
+		return false
+	}
+	return strings.TrimSpace(a.SaveAs) == ""
+}
+
+// Execute runs the tool registered under fxName. Arguments are validated against the
+// tool's input schema first. The call is refused if the tool is disabled or over
+// quota; otherwise the call counters are updated before the tool runs and the
+// implementation is given a deadline of TimeoutS seconds.
+func (e *Executor) Execute(ctx context.Context, fxName string, args json.RawMessage) (result any, err error) {
+	def, ok := e.registry.Lookup(fxName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, fxName)
+	}
+
+	// Only registered tools are counted, so callers cannot create metric labels
+	ctx, span := tracing.Start(ctx, "tool.Execute", attribute.String("tool.name", fxName))
+	ctx = logging.With(ctx, "tool", fxName)
+	started := time.Now()
+	defer func() {
+		elapsed := time.Since(started)
+		metrics.ObserveTool(fxName, elapsed, err)
+		tracing.End(span, err)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Tool failed", "duration_ms", elapsed.Milliseconds(), "error", err)
+		} else {
+			logging.FromContext(ctx).Info("Tool finished", "duration_ms", elapsed.Milliseconds())
+		}
+	}()
+
+	if err := def.ValidateArgs(args); err != nil {
+		return nil, err
+	}
+
+	schema, err := e.store.ReserveToolCall(fxName, time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTool, fxName)
+		}
+		return nil, fmt.Errorf("tool %s: %w", fxName, err)
+	}
+
+	tool := &models.Tool{}
+	tool.FromSchema(schema)
+
+	if len(def.Secrets) > 0 {
+		secrets, err := e.revealSecrets(tool.ToolId, def)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", fxName, err)
+		}
+		ctx = context.WithValue(ctx, secretsKey{}, secrets)
+	}
+
+	if tool.TimeoutS > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(tool.TimeoutS)*time.Second)
+		defer cancel()
+	}
+
+	type outcome struct {
+		result any
+		err    error
+	}
+
+	// Run in a goroutine so an implementation that ignores ctx still cannot hold
+	// the caller past the deadline
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("tool %s panicked: %v", fxName, r)}
+			}
+		}()
+		result, err := def.Fn(ctx, args)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("tool %s: %w", fxName, ctx.Err())
+	}
+}
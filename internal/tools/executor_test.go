@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/nathanaday/iot-data-sandbox/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestExecuteRecordsToolSpan(t *testing.T) {
+	exporter := tracing.SetupInMemory()
+
+	store, err := persistence.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+
+	registry := NewRegistry()
+	for _, def := range []*Definition{
+		{FxName: "ok", Fn: func(ctx context.Context, args json.RawMessage) (any, error) { return "done", nil }},
+		{FxName: "broken", Fn: func(ctx context.Context, args json.RawMessage) (any, error) { return nil, errors.New("sensor offline") }},
+	} {
+		if err := registry.Register(def); err != nil {
+			t.Fatalf("Register(%s): %v", def.FxName, err)
+		}
+	}
+	if err := registry.Sync(store); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	executor := NewExecutor(store, registry, nil)
+
+	if _, err := executor.Execute(context.Background(), "ok", json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("Execute(ok): %v", err)
+	}
+	if _, err := executor.Execute(context.Background(), "broken", json.RawMessage(`{}`)); err == nil {
+		t.Fatal("Execute(broken) succeeded, want an error")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want one per tool call", len(spans))
+	}
+	for i, want := range []struct {
+		tool   string
+		status codes.Code
+	}{
+		{"ok", codes.Unset},
+		{"broken", codes.Error},
+	} {
+		span := spans[i]
+		if span.Name != "tool.Execute" {
+			t.Errorf("span %d name = %q, want tool.Execute", i, span.Name)
+		}
+		attrs := attribute.NewSet(span.Attributes...)
+		if v, _ := attrs.Value("tool.name"); v.AsString() != want.tool {
+			t.Errorf("span %d tool.name = %q, want %s", i, v.AsString(), want.tool)
+		}
+		if span.Status.Code != want.status {
+			t.Errorf("span %d status = %v, want %v", i, span.Status.Code, want.status)
+		}
+	}
+}
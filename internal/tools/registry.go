@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	// DefaultTimeoutS matches the timeout_s column default in the tools table
+	DefaultTimeoutS = 30
+	// DefaultVersion is used for tools that do not declare a version
+	DefaultVersion = "1.0.0"
+)
+
+// Func is the Go implementation behind a tool. Args is the raw JSON argument object
+// supplied by the caller; the returned value must be JSON serializable.
+type Func func(ctx context.Context, args json.RawMessage) (any, error)
+
+// Definition describes a tool implementation and the defaults used when it is
+// first written to the tools table. InputSchema and OutputSchema are JSON Schema
+// (draft 2020-12) documents describing the argument object and the result.
+// When ArtifactKind is set, successful results are kept as artifacts by the agent.
+// Secrets names the credentials the tool needs from the vault; the executor
+// decrypts them for each call and the implementation reads them with Secret.
+// ReadOnly tools change nothing, so a call can safely run again, except that a
+// call with a save_as argument writes a new datasource.
+type Definition struct {
+	FxName       string
+	Name         string
+	Description  string
+	Category     string
+	Version      string
+	InputSchema  json.RawMessage
+	OutputSchema json.RawMessage
+	ArtifactKind string
+	TimeoutS     int
+	Secrets      []string
+	ReadOnly     bool
+	Fn           Func
+
+	compiled *jsonschema.Schema
+}
+
+// Registry maps tool function names (tools.fx_name) to their Go implementations
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]*Definition
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		tools: make(map[string]*Definition),
+	}
+}
+
+// Register adds a tool implementation. FxName must be unique within the registry.
+func (r *Registry) Register(def *Definition) error {
+	if def == nil || def.FxName == "" {
+		return errors.New("tool definition must have an fx name")
+	}
+	if def.Fn == nil {
+		return fmt.Errorf("tool %s has no implementation", def.FxName)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[def.FxName]; exists {
+		return fmt.Errorf("tool %s is already registered", def.FxName)
+	}
+	if def.Name == "" {
+		def.Name = def.FxName
+	}
+	if def.TimeoutS <= 0 {
+		def.TimeoutS = DefaultTimeoutS
+	}
+	if def.Version == "" {
+		def.Version = DefaultVersion
+	}
+	if len(def.InputSchema) == 0 {
+		def.InputSchema = emptyObjectSchema
+	}
+
+	compiled, err := compileSchema(def.FxName, def.InputSchema)
+	if err != nil {
+		return err
+	}
+	def.compiled = compiled
+
+	r.tools[def.FxName] = def
+	return nil
+}
+
+// Lookup returns the implementation registered under fxName
+func (r *Registry) Lookup(fxName string) (*Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.tools[fxName]
+	return def, ok
+}
+
+// Definitions returns all registered tools ordered by FxName
+func (r *Registry) Definitions() []*Definition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]*Definition, 0, len(r.tools))
+	for _, def := range r.tools {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].FxName < defs[j].FxName })
+	return defs
+}
+
+// Sync makes sure every registered tool has a row in the tools table. Descriptive
+// columns (name, description, category, version, schemas) are refreshed from the
+// registry; policy columns on existing rows are left untouched so that timeouts,
+// quotas and the enabled flag survive restarts.
+func (r *Registry) Sync(store *persistence.Store) error {
+	for _, def := range r.Definitions() {
+		schema, err := store.LoadToolByFxName(def.FxName)
+		tool := &models.Tool{}
+		switch {
+		case err == nil:
+			tool.FromSchema(schema)
+		case errors.Is(err, sql.ErrNoRows):
+			tool.FxName = def.FxName
+			tool.TimeoutS = def.TimeoutS
+			tool.IsEnabled = true
+		default:
+			return fmt.Errorf("failed to load tool %s: %w", def.FxName, err)
+		}
+
+		tool.Name = def.Name
+		tool.Description = def.Description
+		tool.Category = def.Category
+		tool.Version = def.Version
+		tool.InputSchema = string(def.InputSchema)
+		tool.OutputSchema = string(def.OutputSchema)
+
+		if err := store.SaveTool(tool.ToSchema()); err != nil {
+			return fmt.Errorf("failed to register tool %s: %w", def.FxName, err)
+		}
+	}
+	return nil
+}
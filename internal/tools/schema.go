@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// emptyObjectSchema is used for tools that declare no input schema
+var emptyObjectSchema = json.RawMessage(`{"type": "object", "properties": {}}`)
+
+// compileSchema compiles a tool's JSON Schema (draft 2020-12) for argument validation
+func compileSchema(fxName string, raw json.RawMessage) (*jsonschema.Schema, error) {
+	url := fmt.Sprintf("tool://%s/input.json", fxName)
+
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	if err := c.AddResource(url, bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("invalid input schema for tool %s: %w", fxName, err)
+	}
+	schema, err := c.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input schema for tool %s: %w", fxName, err)
+	}
+	return schema, nil
+}
+
+// ValidateArgs checks args against the tool's input schema. Empty args are treated
+// as an empty object.
+func (d *Definition) ValidateArgs(args json.RawMessage) error {
+	if d.compiled == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return &ArgumentError{Message: fmt.Sprintf("invalid arguments: %v", err)}
+	}
+
+	if err := d.compiled.Validate(v); err != nil {
+		var verr *jsonschema.ValidationError
+		if errors.As(err, &verr) {
+			return &ArgumentError{Message: fmt.Sprintf("invalid arguments for %s: %s", d.FxName, describeValidationError(verr))}
+		}
+		return &ArgumentError{Message: fmt.Sprintf("invalid arguments for %s: %v", d.FxName, err)}
+	}
+	return nil
+}
+
+// describeValidationError flattens a validation error tree into "location: message" pairs
+func describeValidationError(verr *jsonschema.ValidationError) string {
+	var msgs []string
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			loc := e.InstanceLocation
+			if loc == "" {
+				loc = "/"
+			}
+			msgs = append(msgs, fmt.Sprintf("%s: %s", loc, e.Message))
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(verr)
+	return strings.Join(msgs, "; ")
+}
+
+// OpenAIFunction is a tool in the OpenAI chat completions "tools" format
+type OpenAIFunction struct {
+	Type     string             `json:"type"`
+	Function OpenAIFunctionSpec `json:"function"`
+}
+
+type OpenAIFunctionSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// AnthropicTool is a tool in the Anthropic Messages API "tools" format
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// OpenAIFunctions converts definitions to the OpenAI function-calling format
+func OpenAIFunctions(defs []*Definition) []OpenAIFunction {
+	functions := make([]OpenAIFunction, 0, len(defs))
+	for _, def := range defs {
+		functions = append(functions, OpenAIFunction{
+			Type: "function",
+			Function: OpenAIFunctionSpec{
+				Name:        def.FxName,
+				Description: def.Description,
+				Parameters:  def.InputSchema,
+			},
+		})
+	}
+	return functions
+}
+
+// AnthropicTools converts definitions to the Anthropic tool-use format
+func AnthropicTools(defs []*Definition) []AnthropicTool {
+	tools := make([]AnthropicTool, 0, len(defs))
+	for _, def := range defs {
+		tools = append(tools, AnthropicTool{
+			Name:        def.FxName,
+			Description: def.Description,
+			InputSchema: def.InputSchema,
+		})
+	}
+	return tools
+}
@@ -0,0 +1,348 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/analysis"
+	"github.com/nathanaday/iot-data-sandbox/internal/dataset"
+	"github.com/nathanaday/iot-data-sandbox/internal/timerange"
+	"github.com/nathanaday/iot-data-sandbox/internal/timeseries"
+)
+
+// transformDefinitions are the built-in tools that derive new series from a datasource
+func (b *builtins) transformDefinitions() []*Definition {
+	return []*Definition{
+		{
+			FxName:       "fill_gaps",
+			Name:         "Fill gaps",
+			Description:  "Put a datasource on a regular grid and fill its gaps with linear or cubic spline interpolation, the last observation carried forward (locf), the value one season earlier (seasonal) or a rolling mean. Gaps longer than max_gap are left empty. Every filled value is flagged. Set save_as to keep the result as a new datasource for other tools.",
+			Category:     CategoryDataSources,
+			InputSchema:  json.RawMessage(fillGapsArgsSchema),
+			OutputSchema: json.RawMessage(fillGapsResultSchema),
+			ArtifactKind: ArtifactSeries,
+			ReadOnly:     true,
+			Fn:           b.fillGaps,
+		},
+		{
+			FxName:       "transform_series",
+			Name:         "Transform series",
+			Description:  "Derive rates, differences, integrals or totals from a datasource. rate is the change per unit of time, integral the trapezoidal area under the series (power in kW per 1h gives energy in kWh), and counter_delta the consumption between readings of a cumulative counter such as an energy or flow meter register, detecting resets and rollovers that plain differencing gets wrong. running_total and period_total (per hour, day, week, month, quarter or year) add values up. The total field is the change or consumption over the range. Set save_as to keep the result as a new datasource for other tools.",
+			Category:     CategoryDataSources,
+			InputSchema:  json.RawMessage(transformSeriesArgsSchema),
+			OutputSchema: json.RawMessage(transformSeriesResultSchema),
+			ArtifactKind: ArtifactSeries,
+			ReadOnly:     true,
+			Fn:           b.transformSeries,
+		},
+	}
+}
+
+const (
+	fillGapsArgsSchema = `{
+	"type": "object",
+	"properties": {` + timeRangeProperties + `,
+		"method": {"type": "string", "enum": ["linear", "spline", "locf", "seasonal", "rolling_mean"], "description": "How to fill gaps, default linear"},
+		"interval": {"type": "string", "description": "Grid step, e.g. 1m or 1h; default the median sampling interval"},
+		"max_gap": {"type": "string", "description": "Longest gap to fill, e.g. 2h; longer gaps are left empty. Default no limit"},
+		"season": {"type": "string", "description": "Period of the seasonal method, e.g. 1d; default the datasource's detected seasonality"},
+		"window": {"type": "string", "description": "Width of the rolling_mean window, e.g. 6h; default 10 intervals"},
+		"save_as": {"type": "string", "description": "Save the filled series as a new datasource with this name"},
+		"limit": {"type": "integer", "minimum": 1, "description": "Maximum number of points to return"}
+	},
+	"required": ["datasource_id"],
+	"additionalProperties": false
+}`
+
+	fillGapsResultSchema = `{
+	"type": "object",
+	"properties": {
+		"data_source_id": {"type": "integer"},
+		"method": {"type": "string"},
+		"interval": {"type": "string"},
+		"max_gap": {"type": "string"},
+		"season": {"type": "string"},
+		"unit": {"type": "string"},
+		"row_count": {"type": "integer"},
+		"imputed": {"type": "integer", "description": "Number of filled values"},
+		"missing": {"type": "integer", "description": "Number of values left empty"},
+		"truncated": {"type": "boolean"},
+		"data": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"timestamp": {"type": "string", "format": "date-time"},
+					"value": {"type": ["number", "null"]},
+					"imputed": {"type": "boolean"}
+				}
+			}
+		},
+		"saved": ` + dataSourceInfoSchema + `
+	}
+}`
+
+	transformSeriesArgsSchema = `{
+	"type": "object",
+	"properties": {` + timeRangeProperties + `,
+		"transform": {"type": "string", "enum": ["rate", "difference", "integral", "counter_delta", "running_total", "period_total"]},
+		"per": {"type": "string", "description": "Time unit of rate (default 1s) and integral (default 1h), e.g. 1h"},
+		"rollover": {"type": "number", "minimum": 0, "description": "Value at which a counter wraps to zero, e.g. 65536 or 99999.9"},
+		"period": {"type": "string", "enum": ["hour", "day", "week", "month", "quarter", "year"], "description": "Calendar period of period_total, in the timezone"},
+		"save_as": {"type": "string", "description": "Save the transformed series as a new datasource with this name"},
+		"limit": {"type": "integer", "minimum": 1, "description": "Maximum number of points to return"}
+	},
+	"required": ["datasource_id", "transform"],
+	"additionalProperties": false
+}`
+
+	transformSeriesResultSchema = `{
+	"type": "object",
+	"properties": {
+		"data_source_id": {"type": "integer"},
+		"transform": {"type": "string"},
+		"unit": {"type": "string"},
+		"per": {"type": "string"},
+		"period": {"type": "string"},
+		"row_count": {"type": "integer"},
+		"total": {"type": "number", "description": "Change or consumption over the range; absent for rates"},
+		"resets": {"type": "integer", "description": "Counter resets to zero"},
+		"rollovers": {"type": "integer", "description": "Counter rollovers"},
+		"glitches": {"type": "integer", "description": "Small counter decreases that were ignored"},
+		"truncated": {"type": "boolean"},
+		"data": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"timestamp": {"type": "string", "format": "date-time"},
+					"value": {"type": "number"}
+				}
+			}
+		},
+		"saved": ` + dataSourceInfoSchema + `
+	}
+}`
+)
+
+type fillGapsArgs struct {
+	timeRangeArgs
+	Method   string `json:"method"`
+	Interval string `json:"interval"`
+	MaxGap   string `json:"max_gap"`
+	Season   string `json:"season"`
+	Window   string `json:"window"`
+	SaveAs   string `json:"save_as"`
+	Limit    int    `json:"limit"`
+}
+
+type imputedPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     *float64  `json:"value"`
+	Imputed   bool      `json:"imputed,omitempty"`
+}
+
+type fillGapsResult struct {
+	DataSourceId int64           `json:"data_source_id"`
+	Method       string          `json:"method"`
+	Interval     string          `json:"interval"`
+	MaxGap       string          `json:"max_gap,omitempty"`
+	Season       string          `json:"season,omitempty"`
+	Unit         string          `json:"unit,omitempty"`
+	RowCount     int             `json:"row_count"`
+	Imputed      int             `json:"imputed"`
+	Missing      int             `json:"missing"`
+	Truncated    bool            `json:"truncated"`
+	Data         []imputedPoint  `json:"data"`
+	Saved        *dataSourceInfo `json:"saved,omitempty"`
+}
+
+func (b *builtins) fillGaps(ctx context.Context, args json.RawMessage) (any, error) {
+	var a fillGapsArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	ds, err := b.loadDataSource(a.DataSourceId)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := dataset.UnitConverter(ds, a.Unit); err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+
+	method, err := timeseries.ParseImputeMethod(a.Method)
+	if err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+	opts := timeseries.ImputeOptions{Method: method}
+	for _, d := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"interval", a.Interval, &opts.Interval},
+		{"max_gap", a.MaxGap, &opts.MaxGap},
+		{"season", a.Season, &opts.Season},
+		{"window", a.Window, &opts.Window},
+	} {
+		if *d.dest, err = parseDurationArg(d.name, d.value); err != nil {
+			return nil, err
+		}
+	}
+	startTime, endTime, err := resolveTimeRange(a.Range, a.StartTime, a.EndTime, a.Timezone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	imputed, err := b.loader.Impute(ctx, a.DataSourceId, startTime, endTime, opts, a.Unit)
+	if err != nil {
+		return nil, argumentErrorFor(err)
+	}
+
+	result := fillGapsResult{
+		DataSourceId: a.DataSourceId,
+		Method:       string(imputed.Options.Method),
+		Interval:     analysis.FormatDuration(imputed.Options.Interval),
+		Unit:         imputed.Unit,
+		RowCount:     len(imputed.Points),
+		Imputed:      imputed.Imputed,
+		Missing:      imputed.Missing,
+	}
+	if imputed.Options.MaxGap > 0 {
+		result.MaxGap = analysis.FormatDuration(imputed.Options.MaxGap)
+	}
+	if method == timeseries.ImputeSeasonal {
+		result.Season = analysis.FormatPeriod(imputed.Options.Season)
+	}
+
+	if name := strings.TrimSpace(a.SaveAs); name != "" {
+		saved, err := b.loader.SaveImputed(ctx, name, imputed)
+		if err != nil {
+			return nil, err
+		}
+		info := newDataSourceInfo(saved)
+		result.Saved = &info
+	}
+
+	points := imputed.Points
+	if a.Limit > 0 && len(points) > a.Limit {
+		points = points[:a.Limit]
+		result.Truncated = true
+	}
+	result.Data = make([]imputedPoint, len(points))
+	for i, p := range points {
+		result.Data[i] = imputedPoint{Timestamp: p.Timestamp, Value: analysis.FiniteOrNil(p.Value), Imputed: p.Imputed}
+	}
+	return result, nil
+}
+
+type transformSeriesArgs struct {
+	timeRangeArgs
+	Transform string  `json:"transform"`
+	Per       string  `json:"per"`
+	Rollover  float64 `json:"rollover"`
+	Period    string  `json:"period"`
+	SaveAs    string  `json:"save_as"`
+	Limit     int     `json:"limit"`
+}
+
+type transformSeriesResult struct {
+	DataSourceId int64           `json:"data_source_id"`
+	Transform    string          `json:"transform"`
+	Unit         string          `json:"unit,omitempty"`
+	Per          string          `json:"per,omitempty"`
+	Period       string          `json:"period,omitempty"`
+	RowCount     int             `json:"row_count"`
+	Total        *float64        `json:"total,omitempty"`
+	Resets       int             `json:"resets,omitempty"`
+	Rollovers    int             `json:"rollovers,omitempty"`
+	Glitches     int             `json:"glitches,omitempty"`
+	Truncated    bool            `json:"truncated"`
+	Data         []dataPoint     `json:"data"`
+	Saved        *dataSourceInfo `json:"saved,omitempty"`
+}
+
+func (b *builtins) transformSeries(ctx context.Context, args json.RawMessage) (any, error) {
+	var a transformSeriesArgs
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	ds, err := b.loadDataSource(a.DataSourceId)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := dataset.UnitConverter(ds, a.Unit); err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+
+	transform, err := timeseries.ParseTransform(a.Transform)
+	if err != nil {
+		return nil, &ArgumentError{Message: err.Error()}
+	}
+	if a.Rollover < 0 {
+		return nil, &ArgumentError{Message: "rollover must be a positive counter value"}
+	}
+	opts := dataset.TransformOptions{Transform: transform, Rollover: a.Rollover}
+	if opts.Per, err = parseDurationArg("per", a.Per); err != nil {
+		return nil, err
+	}
+	if transform == timeseries.TransformPeriodTotal {
+		if a.Period == "" {
+			return nil, &ArgumentError{Message: "period_total needs a period: hour, day, week, month, quarter or year"}
+		}
+		loc, err := timerange.LoadLocation(a.Timezone)
+		if err != nil {
+			return nil, &ArgumentError{Message: err.Error()}
+		}
+		if opts.PeriodStart, err = timerange.PeriodStart(a.Period, loc); err != nil {
+			return nil, &ArgumentError{Message: err.Error()}
+		}
+		opts.Period = strings.ToLower(strings.TrimSpace(a.Period))
+	}
+	startTime, endTime, err := resolveTimeRange(a.Range, a.StartTime, a.EndTime, a.Timezone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	transformed, err := b.loader.Transform(ctx, a.DataSourceId, startTime, endTime, opts, a.Unit)
+	if err != nil {
+		return nil, argumentErrorFor(err)
+	}
+
+	result := transformSeriesResult{
+		DataSourceId: a.DataSourceId,
+		Transform:    string(transform),
+		Unit:         transformed.Unit,
+		Period:       transformed.Options.Period,
+		RowCount:     len(transformed.Points),
+		Total:        transformed.Total,
+		Resets:       transformed.Counter.Resets,
+		Rollovers:    transformed.Counter.Rollovers,
+		Glitches:     transformed.Counter.Glitches,
+	}
+	if transformed.Options.Per > 0 {
+		result.Per = analysis.FormatDuration(transformed.Options.Per)
+	}
+
+	if name := strings.TrimSpace(a.SaveAs); name != "" {
+		saved, err := b.loader.SaveTransformed(ctx, name, transformed)
+		if err != nil {
+			return nil, err
+		}
+		info := newDataSourceInfo(saved)
+		result.Saved = &info
+	}
+
+	points := transformed.Points
+	if a.Limit > 0 && len(points) > a.Limit {
+		points = points[:a.Limit]
+		result.Truncated = true
+	}
+	result.Data = make([]dataPoint, len(points))
+	for i, p := range points {
+		result.Data[i] = dataPoint{Timestamp: p.Timestamp, Value: p.Value}
+	}
+	return result, nil
+}
@@ -0,0 +1,260 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dimension is the physical quantity a unit measures. Only units of the same
+// dimension can be converted into each other or combined.
+type Dimension string
+
+const (
+	Temperature Dimension = "temperature"
+	Pressure    Dimension = "pressure"
+	Energy      Dimension = "energy"
+	Power       Dimension = "power"
+	Flow        Dimension = "flow"
+	Speed       Dimension = "speed"
+	Ratio       Dimension = "ratio"
+)
+
+var (
+	// ErrUnknownUnit is returned for a unit that is not in the registry
+	ErrUnknownUnit = errors.New("unknown unit")
+	// ErrIncompatible is returned when converting or combining units of different dimensions
+	ErrIncompatible = errors.New("incompatible units")
+)
+
+// Unit is a unit of the registry. A value v in the unit is v*scale + offset in the
+// base unit of its dimension (K, Pa, J, W, m3/s, m/s and a plain fraction).
+type Unit struct {
+	Symbol    string    `json:"symbol"`
+	Name      string    `json:"name"`
+	Dimension Dimension `json:"dimension"`
+	Aliases   []string  `json:"aliases,omitempty"`
+
+	scale  float64
+	offset float64
+}
+
+// ToBase converts v from u to the base unit of its dimension
+func (u *Unit) ToBase(v float64) float64 {
+	return v*u.scale + u.offset
+}
+
+// FromBase converts v from the base unit of its dimension to u
+func (u *Unit) FromBase(v float64) float64 {
+	return (v - u.offset) / u.scale
+}
+
+// registry lists every unit. Temperatures convert absolute readings; a temperature
+// difference of 1 degC is 1.8 degF, not 33.8.
+var registry = []*Unit{
+	{Symbol: "K", Name: "kelvin", Dimension: Temperature, Aliases: []string{"kelvin"}, scale: 1},
+	{Symbol: "degC", Name: "degree Celsius", Dimension: Temperature, Aliases: []string{"°C", "C", "celsius"}, scale: 1, offset: 273.15},
+	{Symbol: "degF", Name: "degree Fahrenheit", Dimension: Temperature, Aliases: []string{"°F", "F", "fahrenheit"}, scale: 5.0 / 9, offset: 273.15 - 32*5.0/9},
+
+	{Symbol: "Pa", Name: "pascal", Dimension: Pressure, Aliases: []string{"pascal"}, scale: 1},
+	{Symbol: "hPa", Name: "hectopascal", Dimension: Pressure, scale: 1e2},
+	{Symbol: "kPa", Name: "kilopascal", Dimension: Pressure, scale: 1e3},
+	{Symbol: "MPa", Name: "megapascal", Dimension: Pressure, scale: 1e6},
+	{Symbol: "mbar", Name: "millibar", Dimension: Pressure, scale: 1e2},
+	{Symbol: "bar", Name: "bar", Dimension: Pressure, scale: 1e5},
+	{Symbol: "psi", Name: "pound per square inch", Dimension: Pressure, scale: 6894.757293168361},
+	{Symbol: "atm", Name: "standard atmosphere", Dimension: Pressure, scale: 101325},
+	{Symbol: "mmHg", Name: "millimetre of mercury", Dimension: Pressure, scale: 133.322387415},
+	{Symbol: "inHg", Name: "inch of mercury", Dimension: Pressure, scale: 3386.389},
+	{Symbol: "inH2O", Name: "inch of water", Dimension: Pressure, scale: 249.08891},
+
+	{Symbol: "J", Name: "joule", Dimension: Energy, Aliases: []string{"joule"}, scale: 1},
+	{Symbol: "kJ", Name: "kilojoule", Dimension: Energy, scale: 1e3},
+	{Symbol: "MJ", Name: "megajoule", Dimension: Energy, scale: 1e6},
+	{Symbol: "Wh", Name: "watt hour", Dimension: Energy, scale: 3600},
+	{Symbol: "kWh", Name: "kilowatt hour", Dimension: Energy, scale: 3.6e6},
+	{Symbol: "MWh", Name: "megawatt hour", Dimension: Energy, scale: 3.6e9},
+	{Symbol: "cal", Name: "calorie", Dimension: Energy, scale: 4.184},
+	{Symbol: "kcal", Name: "kilocalorie", Dimension: Energy, scale: 4184},
+	{Symbol: "BTU", Name: "British thermal unit", Dimension: Energy, Aliases: []string{"Btu"}, scale: 1055.05585262},
+	{Symbol: "therm", Name: "therm", Dimension: Energy, scale: 1.05505585262e8},
+
+	{Symbol: "W", Name: "watt", Dimension: Power, Aliases: []string{"watt"}, scale: 1},
+	{Symbol: "kW", Name: "kilowatt", Dimension: Power, scale: 1e3},
+	{Symbol: "MW", Name: "megawatt", Dimension: Power, scale: 1e6},
+	{Symbol: "hp", Name: "mechanical horsepower", Dimension: Power, scale: 745.6998715822702},
+	{Symbol: "BTU/h", Name: "British thermal unit per hour", Dimension: Power, Aliases: []string{"Btu/h"}, scale: 1055.05585262 / 3600},
+	{Symbol: "TR", Name: "ton of refrigeration", Dimension: Power, scale: 3516.852842067},
+
+	{Symbol: "m3/s", Name: "cubic metre per second", Dimension: Flow, Aliases: []string{"m³/s"}, scale: 1},
+	{Symbol: "m3/h", Name: "cubic metre per hour", Dimension: Flow, Aliases: []string{"m³/h"}, scale: 1.0 / 3600},
+	{Symbol: "L/s", Name: "litre per second", Dimension: Flow, Aliases: []string{"l/s"}, scale: 1e-3},
+	{Symbol: "L/min", Name: "litre per minute", Dimension: Flow, Aliases: []string{"l/min", "lpm"}, scale: 1e-3 / 60},
+	{Symbol: "gpm", Name: "US gallon per minute", Dimension: Flow, scale: 3.785411784e-3 / 60},
+	{Symbol: "cfm", Name: "cubic foot per minute", Dimension: Flow, scale: 0.028316846592 / 60},
+
+	{Symbol: "m/s", Name: "metre per second", Dimension: Speed, scale: 1},
+	{Symbol: "km/h", Name: "kilometre per hour", Dimension: Speed, Aliases: []string{"kph"}, scale: 1 / 3.6},
+	{Symbol: "mph", Name: "mile per hour", Dimension: Speed, scale: 0.44704},
+	{Symbol: "ft/s", Name: "foot per second", Dimension: Speed, scale: 0.3048},
+	{Symbol: "kn", Name: "knot", Dimension: Speed, Aliases: []string{"knot", "kt"}, scale: 1852.0 / 3600},
+
+	{Symbol: "%", Name: "percent", Dimension: Ratio, Aliases: []string{"percent", "pct"}, scale: 1e-2},
+	{Symbol: "fraction", Name: "fraction", Dimension: Ratio, Aliases: []string{"ratio"}, scale: 1},
+	{Symbol: "ppm", Name: "part per million", Dimension: Ratio, scale: 1e-6},
+}
+
+var (
+	bySymbol = map[string]*Unit{}
+	// byFold finds units regardless of case. Names of different units that only
+	// differ by case are left out and need the exact symbol.
+	byFold = map[string]*Unit{}
+)
+
+func init() {
+	ambiguous := map[string]bool{}
+	for _, u := range registry {
+		for _, name := range append([]string{u.Symbol}, u.Aliases...) {
+			if _, ok := bySymbol[name]; ok {
+				panic(fmt.Sprintf("units: %q registered twice", name))
+			}
+			bySymbol[name] = u
+
+			folded := strings.ToLower(name)
+			if other, ok := byFold[folded]; ok && other != u {
+				ambiguous[folded] = true
+			}
+			byFold[folded] = u
+		}
+	}
+	for folded := range ambiguous {
+		delete(byFold, folded)
+	}
+}
+
+// Lookup finds a unit by its symbol or an alias. Exact matches win; otherwise case
+// is ignored where that is unambiguous.
+func Lookup(symbol string) (*Unit, error) {
+	symbol = strings.TrimSpace(symbol)
+	if u, ok := bySymbol[symbol]; ok {
+		return u, nil
+	}
+	if u, ok := byFold[strings.ToLower(symbol)]; ok {
+		return u, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownUnit, symbol)
+}
+
+// Canonical returns the registry symbol of a unit given by symbol or alias
+func Canonical(symbol string) (string, error) {
+	u, err := Lookup(symbol)
+	if err != nil {
+		return "", err
+	}
+	return u.Symbol, nil
+}
+
+// All returns every unit, grouped by dimension
+func All() []*Unit {
+	all := append([]*Unit(nil), registry...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Dimension < all[j].Dimension })
+	return all
+}
+
+// HasOffset reports whether the zero of unit is not the zero of its dimension, as
+// for degC and degF. Differences and multiples of readings in such a unit are not
+// readings in it: converting them would apply the offset again.
+func HasOffset(unit string) bool {
+	u, err := Lookup(unit)
+	return err == nil && u.offset != 0
+}
+
+// Converter returns a function converting values from one unit to another of the
+// same dimension
+func Converter(from, to string) (func(float64) float64, error) {
+	fromUnit, toUnit, err := lookupPair(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if fromUnit == toUnit {
+		return func(v float64) float64 { return v }, nil
+	}
+	return func(v float64) float64 { return toUnit.FromBase(fromUnit.ToBase(v)) }, nil
+}
+
+// Convert converts v from one unit to another of the same dimension
+func Convert(v float64, from, to string) (float64, error) {
+	convert, err := Converter(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return convert(v), nil
+}
+
+// CheckCompatible reports whether values in units a and b can be compared, added or
+// subtracted once converted to a common unit. It rejects kW against kWh.
+func CheckCompatible(a, b string) error {
+	_, _, err := lookupPair(a, b)
+	return err
+}
+
+func lookupPair(a, b string) (*Unit, *Unit, error) {
+	ua, err := Lookup(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	ub, err := Lookup(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ua.Dimension != ub.Dimension {
+		return nil, nil, fmt.Errorf("%w: %s is %s but %s is %s", ErrIncompatible, ua.Symbol, ua.Dimension, ub.Symbol, ub.Dimension)
+	}
+	return ua, ub, nil
+}
+
+// timeIntegrals pairs each dimension with the dimension of its integral over time
+var timeIntegrals = map[Dimension]Dimension{Power: Energy}
+
+// Integral returns the unit of the integral over time of values in unit, with time
+// measured in per: kW integrated over hours is kWh and over seconds kJ. It is empty
+// when the registry has no such unit.
+func Integral(unit string, per time.Duration) string {
+	u, err := Lookup(unit)
+	if err != nil {
+		return ""
+	}
+	dim, ok := timeIntegrals[u.Dimension]
+	if !ok {
+		return ""
+	}
+	return withScale(dim, u.scale*per.Seconds())
+}
+
+// Derivative returns the unit of the change per per of values in unit: kWh per hour
+// is kW. It is empty when the registry has no such unit.
+func Derivative(unit string, per time.Duration) string {
+	u, err := Lookup(unit)
+	if err != nil {
+		return ""
+	}
+	for rate, integral := range timeIntegrals {
+		if integral == u.Dimension {
+			return withScale(rate, u.scale/per.Seconds())
+		}
+	}
+	return ""
+}
+
+// withScale finds the unit of dim with the scale, empty if there is none
+func withScale(dim Dimension, scale float64) string {
+	for _, u := range registry {
+		if u.Dimension == dim && u.offset == 0 && math.Abs(u.scale-scale) <= 1e-9*scale {
+			return u.Symbol
+		}
+	}
+	return ""
+}
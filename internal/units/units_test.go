@@ -0,0 +1,56 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		v        float64
+		from, to string
+		want     float64
+	}{
+		{100, "degC", "degF", 212},
+		{32, "degF", "K", 273.15},
+		{1, "kWh", "MJ", 3.6},
+		{1, "bar", "kPa", 100},
+		{36, "km/h", "m/s", 10},
+	} {
+		got, err := Convert(tc.v, tc.from, tc.to)
+		if err != nil {
+			t.Fatalf("Convert(%v, %s, %s): %v", tc.v, tc.from, tc.to, err)
+		}
+		if math.Abs(got-tc.want) > 1e-9*math.Abs(tc.want) {
+			t.Errorf("Convert(%v, %s, %s) = %v, want %v", tc.v, tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestConverterKeepsPrecisionOfLargeCounters(t *testing.T) {
+	convert, err := Converter("Wh", "kWh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neighbouring readings of a Wh register above 1e12 still differ by 1 Wh
+	const reading = 1234567890123.0
+	delta := convert(reading+1) - convert(reading)
+	if math.Abs(delta-0.001) > 1e-6 {
+		t.Errorf("1 Wh step converts to %v kWh, want 0.001", delta)
+	}
+
+	back, err := Convert(convert(reading), "kWh", "Wh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(back-reading) > 1e-3 {
+		t.Errorf("round trip of %v Wh = %v", reading, back)
+	}
+}
+
+func TestConverterRejectsIncompatibleUnits(t *testing.T) {
+	if _, err := Converter("kW", "kWh"); err == nil {
+		t.Error("Converter(kW, kWh) succeeded, want an incompatible units error")
+	}
+}
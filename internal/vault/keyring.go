@@ -0,0 +1,125 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables holding the master keys. Keys are 32 random bytes,
+// base64 encoded. The key file holds one key per line; the first line is the
+// primary key and later lines are earlier keys kept for decryption.
+const (
+	EnvMasterKey     = "VAULT_MASTER_KEY"
+	EnvPreviousKeys  = "VAULT_PREVIOUS_KEYS"
+	EnvMasterKeyFile = "VAULT_MASTER_KEY_FILE"
+
+	keySize = 32
+)
+
+// ErrNoMasterKey is returned when secrets are used without a configured master key
+var ErrNoMasterKey = errors.New("no vault master key configured")
+
+// Keyring holds the master keys used to wrap data keys. New data keys are always
+// wrapped with the primary key; older keys remain available so secrets wrapped
+// before a rotation can still be opened until they are rewrapped.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring builds a keyring from raw 32-byte keys, the first being the primary
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string][]byte)}
+	for i, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("master key %d is %d bytes, want %d", i+1, len(key), keySize)
+		}
+		id := KeyId(key)
+		if i == 0 {
+			kr.primary = id
+		}
+		kr.keys[id] = key
+	}
+	return kr, nil
+}
+
+// LoadKeyring reads the master keys from VAULT_MASTER_KEY (plus the comma-separated
+// VAULT_PREVIOUS_KEYS) or from the file named by VAULT_MASTER_KEY_FILE. It returns
+// an empty keyring when neither is set.
+func LoadKeyring() (*Keyring, error) {
+	var encoded []string
+
+	if key := strings.TrimSpace(os.Getenv(EnvMasterKey)); key != "" {
+		encoded = append(encoded, key)
+		for _, prev := range strings.Split(os.Getenv(EnvPreviousKeys), ",") {
+			if prev = strings.TrimSpace(prev); prev != "" {
+				encoded = append(encoded, prev)
+			}
+		}
+	} else if path := os.Getenv(EnvMasterKeyFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				encoded = append(encoded, line)
+			}
+		}
+	}
+
+	keys := make([][]byte, 0, len(encoded))
+	for i, e := range encoded {
+		key, err := base64.StdEncoding.DecodeString(e)
+		if err != nil {
+			return nil, fmt.Errorf("master key %d is not valid base64: %w", i+1, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyring(keys...)
+}
+
+// GenerateKey returns a new random master key, base64 encoded
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// KeyId identifies a master key by a fingerprint, so keys need no separate names
+func KeyId(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Configured reports whether a primary key is available
+func (kr *Keyring) Configured() bool {
+	return kr != nil && kr.primary != ""
+}
+
+// PrimaryId returns the ID of the key new secrets are wrapped with
+func (kr *Keyring) PrimaryId() string {
+	if kr == nil {
+		return ""
+	}
+	return kr.primary
+}
+
+func (kr *Keyring) key(id string) ([]byte, error) {
+	if !kr.Configured() {
+		return nil, ErrNoMasterKey
+	}
+	key, ok := kr.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("master key %s is not in the keyring", id)
+	}
+	return key, nil
+}
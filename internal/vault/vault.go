@@ -0,0 +1,237 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nathanaday/iot-data-sandbox/internal/models"
+	"github.com/nathanaday/iot-data-sandbox/internal/persistence"
+)
+
+// ErrSecretNotFound is returned when deleting a secret that does not exist
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretInfo describes a stored secret without its value
+type SecretInfo struct {
+	Name        string
+	KeyId       string
+	WhenCreated time.Time
+	WhenUpdated time.Time
+}
+
+// Vault stores outbound tool credentials with envelope encryption: each value is
+// sealed with its own random AES-256-GCM data key, and the data key is stored
+// wrapped by a master key from the keyring. Rotating the master key only rewraps
+// the data keys.
+//
+// Values can be written and listed by anyone holding the Vault, but Reveal is
+// meant to be called only by the tool executor when it runs a tool.
+type Vault struct {
+	store   *persistence.Store
+	keyring *Keyring
+}
+
+func NewVault(store *persistence.Store, keyring *Keyring) *Vault {
+	return &Vault{
+		store:   store,
+		keyring: keyring,
+	}
+}
+
+// Configured reports whether a master key is available
+func (v *Vault) Configured() bool {
+	return v.keyring.Configured()
+}
+
+// KeyId returns the ID of the primary master key
+func (v *Vault) KeyId() string {
+	return v.keyring.PrimaryId()
+}
+
+// Put encrypts value and stores it as secret name of a tool, replacing any
+// previous value
+func (v *Vault) Put(toolId int64, name, value string) error {
+	kek, err := v.keyring.key(v.keyring.PrimaryId())
+	if err != nil {
+		return err
+	}
+
+	dek := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return err
+	}
+
+	ciphertext, err := seal(dek, []byte(value), secretAAD(toolId, name))
+	if err != nil {
+		return err
+	}
+	wrapped, err := seal(kek, dek, keyAAD(toolId, name))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	secret := &models.ToolSecret{
+		ToolId:      toolId,
+		Name:        name,
+		Ciphertext:  ciphertext,
+		WrappedKey:  wrapped,
+		KeyId:       v.keyring.PrimaryId(),
+		WhenCreated: now,
+		WhenUpdated: now,
+	}
+	return v.store.SaveToolSecret(secret.ToSchema())
+}
+
+// List describes the secrets of a tool without decrypting them
+func (v *Vault) List(toolId int64) ([]SecretInfo, error) {
+	schemas, err := v.store.LoadToolSecrets(toolId)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SecretInfo, 0, len(schemas))
+	for _, schema := range schemas {
+		infos = append(infos, SecretInfo{
+			Name:        schema.Name,
+			KeyId:       schema.KeyId,
+			WhenCreated: schema.WhenCreated,
+			WhenUpdated: schema.WhenUpdated,
+		})
+	}
+	return infos, nil
+}
+
+// Delete removes a secret
+func (v *Vault) Delete(toolId int64, name string) error {
+	err := v.store.DeleteToolSecret(toolId, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return err
+}
+
+// Reveal decrypts all secrets of a tool. Only the tool executor should call it.
+func (v *Vault) Reveal(toolId int64) (map[string]string, error) {
+	schemas, err := v.store.LoadToolSecrets(toolId)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(schemas))
+	for _, schema := range schemas {
+		secret := &models.ToolSecret{}
+		secret.FromSchema(schema)
+
+		dek, err := v.unwrap(secret)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := open(dek, secret.Ciphertext, secretAAD(secret.ToolId, secret.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", secret.Name, err)
+		}
+		values[secret.Name] = string(plaintext)
+	}
+	return values, nil
+}
+
+// Rotate rewraps every data key not already wrapped by the primary master key and
+// returns how many were rewrapped. Once it succeeds, previous master keys can be
+// removed from the configuration.
+func (v *Vault) Rotate() (int, error) {
+	kek, err := v.keyring.key(v.keyring.PrimaryId())
+	if err != nil {
+		return 0, err
+	}
+
+	schemas, err := v.store.LoadAllToolSecrets()
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, schema := range schemas {
+		if schema.KeyId == v.keyring.PrimaryId() {
+			continue
+		}
+
+		secret := &models.ToolSecret{}
+		secret.FromSchema(schema)
+
+		dek, err := v.unwrap(secret)
+		if err != nil {
+			return rotated, err
+		}
+		wrapped, err := seal(kek, dek, keyAAD(secret.ToolId, secret.Name))
+		if err != nil {
+			return rotated, err
+		}
+		if err := v.store.UpdateToolSecretKey(secret.ToolId, secret.Name, wrapped, v.keyring.PrimaryId()); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+func (v *Vault) unwrap(secret *models.ToolSecret) ([]byte, error) {
+	kek, err := v.keyring.key(secret.KeyId)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", secret.Name, err)
+	}
+	dek, err := open(kek, secret.WrappedKey, keyAAD(secret.ToolId, secret.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key of secret %s: %w", secret.Name, err)
+	}
+	return dek, nil
+}
+
+// The associated data binds each ciphertext to its row, so a value copied to
+// another tool or name fails to decrypt
+func secretAAD(toolId int64, name string) []byte {
+	return []byte(fmt.Sprintf("tool_secret:%d:%s", toolId, name))
+}
+
+func keyAAD(toolId int64, name string) []byte {
+	return []byte(fmt.Sprintf("tool_secret_key:%d:%s", toolId, name))
+}
+
+// seal encrypts plaintext with AES-256-GCM, prefixing the random nonce
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}